@@ -4,10 +4,19 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/dinowernli/almanac/pkg/auth"
 	"github.com/dinowernli/almanac/pkg/cluster"
+	"github.com/dinowernli/almanac/pkg/config"
+	"github.com/dinowernli/almanac/pkg/loki"
+	"github.com/dinowernli/almanac/pkg/otlp"
+	in "github.com/dinowernli/almanac/pkg/service/ingester"
+	"github.com/dinowernli/almanac/pkg/service/janitor"
 	"github.com/dinowernli/almanac/pkg/storage"
-	pb_almanac "github.com/dinowernli/almanac/proto"
 
 	"github.com/alecthomas/kingpin"
 	"github.com/prometheus/client_golang/prometheus"
@@ -17,77 +26,437 @@ import (
 )
 
 const (
-	metricsHttpPath = "/metrics"
+	metricsHttpPath  = "/metrics"
+	ingesterHttpPath = "/ingester"
+	mixerHttpPath    = "/mixer"
 )
 
 var (
-	flagStorageType = kingpin.Flag("storage", "Which kind of storage to use").Default(storage.StorageTypeMemory).Enum(storage.StorageTypeMemory, storage.StorageTypeDisk, storage.StorageTypeGcs)
-	flagGcsBucket   = kingpin.Flag("storage.gcs.bucket", "Which gcs bucket to use for storage").Default("almanac-dev").String()
-	flagDiskPath    = kingpin.Flag("storage.disk.path", "An existing empty directory to use as root for storage").Default("/tmp/almanac-dev").String()
+	// defaults holds the values of all flags not set explicitly.
+	defaults = config.Default()
 
-	flagAppenderPorts = kingpin.Flag("appender_ports", "Which ports to run appenders on").Default("5001", "5002", "5003", "5004", "5005").Ints()
-	flagHttpPort      = kingpin.Flag("http_port", "which port to run the http server on").Default("12345").Int()
+	serveCommand = kingpin.Command("serve", "Runs the services of the role selected with --role").Default()
+	fsckCommand  = kingpin.Command("fsck", "Checks the consistency of the chunks in storage")
 
-	flagIngestFanout         = kingpin.Flag("ingest_fanout", "How many appenders to send each ingested entry to").Default("2").Int()
-	flagSmallChunkMaxEntries = kingpin.Flag("small_chunk_max_entries", "The maximum number of entries in a small chunk").Default("10").Int()
-	flagSmallChunkMaxSpread  = kingpin.Flag("small_chunk_max_spread", "The maximum spread of a small chunk").Default("5s").Duration()
-	flagSmallChunkMaxAge     = kingpin.Flag("small_chunk_max_age", "The maximum time a small chunk can stay open").Default("3s").Duration()
-	flagBigChunkMaxSpread    = kingpin.Flag("big_chunk_max_spread", "The maximum spread of a big chunk").Default("12h").Duration()
+	flagRole = serveCommand.Flag("role", "Which services to run in this process").Default(cluster.RoleAll).Enum(cluster.Roles()...)
 
-	flagJanitorCompactionInterval = kingpin.Flag("janitor_compaction_interval", "How frequently the janitor runs compactions").Default("10s").Duration()
+	flagConfig               = kingpin.Flag("config", "A yaml or json configuration file, overridden by any flags set explicitly").String()
+	flagConfigReloadInterval = serveCommand.Flag("config.reload_interval", "How frequently to check the configuration file for changes, zero to disable").Default("10s").Duration()
+	flagShutdownTimeout      = serveCommand.Flag("shutdown_timeout", "How long to wait for open chunks to be flushed and work in progress to finish on shutdown").Default("25s").Duration()
+	flagLogLevel             = kingpin.Flag("log_level", "The minimum level of the messages logged").Default(defaults.LogLevel).String()
+
+	flagStorageType = kingpin.Flag("storage", "Which kind of storage to use").Default(defaults.Storage.Type).Enum(storage.StorageTypeMemory, storage.StorageTypeDisk, storage.StorageTypeGcs)
+	flagGcsBucket   = kingpin.Flag("storage.gcs.bucket", "Which gcs bucket to use for storage").Default(defaults.Storage.GcsBucket).String()
+	flagCodec       = kingpin.Flag("storage.codec", "Which codec to use to compress stored chunks").Default(defaults.Storage.Codec).Enum(storage.Codecs()...)
+	flagDiskPath    = kingpin.Flag("storage.disk.path", "An existing empty directory to use as root for storage").Default(defaults.Storage.DiskPath).String()
+
+	flagAppenderPorts = kingpin.Flag("appender_ports", "Which ports to run appenders on").Default(intStrings(defaults.Ports.Appenders)...).Ints()
+	flagHttpPort      = kingpin.Flag("http_port", "which port to run the http server on").Default(fmt.Sprint(defaults.Ports.Http)).Int()
+	flagAdminPort     = kingpin.Flag("admin_port", "Which port to run the admin grpc service on").Default(fmt.Sprint(defaults.Ports.Admin)).Int()
+	flagApiPort       = kingpin.Flag("api_port", "Which port to run the ingester and mixer grpc services on").Default(fmt.Sprint(defaults.Ports.Api)).Int()
+
+	flagAppenderAddresses        = kingpin.Flag("discovery.appenders", "The addresses of the appenders, unless running all roles").Strings()
+	flagAppenderDns              = kingpin.Flag("discovery.appender_dns", "A host:port resolving to all appenders, takes precedence over --discovery.appenders").String()
+	flagDiscoveryRefreshInterval = kingpin.Flag("discovery.refresh_interval", "How frequently to resolve the appender dns name").Default(defaults.Discovery.RefreshInterval.String()).Duration()
+
+	flagIngestFanout         = kingpin.Flag("ingest_fanout", "How many appenders to send each ingested entry to").Default(fmt.Sprint(defaults.Ingester.Fanout)).Int()
+	flagStreamLabels         = kingpin.Flag("appender_stream_labels", "The entry fields whose values separate entries into distinct chunks, e.g., service").Default(defaults.Appender.StreamLabels...).Strings()
+	flagSmallChunkMaxEntries = kingpin.Flag("small_chunk_max_entries", "The maximum number of entries in a small chunk").Default(fmt.Sprint(defaults.Appender.MaxChunkEntries)).Int()
+	flagSmallChunkMaxSpread  = kingpin.Flag("small_chunk_max_spread", "The maximum spread of a small chunk").Default(defaults.Appender.MaxChunkSpread.String()).Duration()
+	flagSmallChunkMaxAge     = kingpin.Flag("small_chunk_max_age", "The maximum time a small chunk can stay open").Default(defaults.Appender.MaxChunkAge.String()).Duration()
+	flagAppenderMaxEntries   = kingpin.Flag("appender_max_memory_entries", "How many entries an appender holds in memory before rejecting new ones").Default(fmt.Sprint(defaults.Appender.MaxMemoryEntries)).Int()
+	flagAppenderMaxBytes     = kingpin.Flag("appender_max_memory_bytes", "How many bytes of entries an appender holds in memory before rejecting new ones").Default(fmt.Sprint(defaults.Appender.MaxMemoryBytes)).Int64()
+	flagAppenderSpillDir     = kingpin.Flag("appender_spill_dir", "A local directory for chunks which cannot be written to storage, empty to keep retrying them in memory").Default(defaults.Appender.SpillDir).String()
+	flagSpillDrainInterval   = kingpin.Flag("appender_spill_drain_interval", "How frequently to upload spilled chunks to storage").Default(defaults.Appender.SpillDrainInterval.String()).Duration()
+	flagBigChunkSpreads      = kingpin.Flag("big_chunk_spreads", "The maximum spread of a big chunk on each compaction level").Default(levelSpreads(defaults.Janitor.Levels)...).DurationList()
+	flagBigChunkMinBytes     = kingpin.Flag("big_chunk_min_bytes", "The size below which a big chunk on each compaction level gets merged with its neighbours").Default(levelMinBytes(defaults.Janitor.Levels)...).Int64List()
+	flagBigChunkSettleDelay  = kingpin.Flag("big_chunk_settle_delay", "How long after their end chunks become eligible for compaction").Default(defaults.Janitor.SettleDelay.String()).Duration()
+
+	flagJanitorCompactionInterval = kingpin.Flag("janitor_compaction_interval", "How frequently the janitor runs compactions").Default(defaults.Janitor.CompactionInterval.String()).Duration()
+	flagJanitorNumWorkers         = kingpin.Flag("janitor_num_workers", "How many chunks the janitor loads or deletes concurrently").Default(fmt.Sprint(defaults.Janitor.NumWorkers)).Int()
+	flagJanitorMaxCompactionBytes = kingpin.Flag("janitor_max_compaction_bytes", "The maximum total decoded size of the entries merged into a single big chunk").Default(fmt.Sprint(defaults.Janitor.MaxCompactionBytes)).Int64()
+	flagJanitorScrubInterval      = kingpin.Flag("janitor_scrub_interval", "How frequently the janitor checks the consistency of storage, zero to disable").Default(defaults.Janitor.ScrubInterval.String()).Duration()
+	flagJanitorScrubRepair        = kingpin.Flag("janitor_scrub_repair", "Whether the janitor repairs problems found while scrubbing").Default(fmt.Sprint(defaults.Janitor.ScrubRepair)).Bool()
+
+	flagAdminEnablePurge = kingpin.Flag("admin.enable_purge", "Whether the admin service accepts purges, which requires auth tokens").Default(fmt.Sprint(defaults.Admin.EnablePurge)).Bool()
+
+	flagTlsCertFile = kingpin.Flag("tls.cert_file", "A pem certificate to serve on the api, admin and http ports, plaintext if empty").Default(defaults.Tls.CertFile).String()
+	flagTlsKeyFile  = kingpin.Flag("tls.key_file", "The pem private key of --tls.cert_file").Default(defaults.Tls.KeyFile).String()
+
+	flagInternalTlsCertFile = kingpin.Flag("internal_tls.cert_file", "A pem certificate for mutual tls with the appenders, plaintext if empty").Default(defaults.InternalTls.CertFile).String()
+	flagInternalTlsKeyFile  = kingpin.Flag("internal_tls.key_file", "The pem private key of --internal_tls.cert_file").Default(defaults.InternalTls.KeyFile).String()
+	flagInternalTlsCaFile   = kingpin.Flag("internal_tls.ca_file", "The pem certificates of the authorities issuing internal certificates").Default(defaults.InternalTls.CaFile).String()
+
+	flagFsckRepair = fsckCommand.Flag("repair", "Whether to repair the problems found").Default("false").Bool()
 )
 
+// flagOverrides holds, for each flag corresponding to a configuration value, a function which
+// writes the value of the flag into a configuration.
+var flagOverrides = map[string]func(*config.File) error{
+	"log_level": func(f *config.File) error { f.LogLevel = *flagLogLevel; return nil },
+
+	"storage":            func(f *config.File) error { f.Storage.Type = *flagStorageType; return nil },
+	"storage.gcs.bucket": func(f *config.File) error { f.Storage.GcsBucket = *flagGcsBucket; return nil },
+	"storage.codec":      func(f *config.File) error { f.Storage.Codec = *flagCodec; return nil },
+	"storage.disk.path":  func(f *config.File) error { f.Storage.DiskPath = *flagDiskPath; return nil },
+
+	"appender_ports": func(f *config.File) error { f.Ports.Appenders = *flagAppenderPorts; return nil },
+	"http_port":      func(f *config.File) error { f.Ports.Http = *flagHttpPort; return nil },
+	"admin_port":     func(f *config.File) error { f.Ports.Admin = *flagAdminPort; return nil },
+	"api_port":       func(f *config.File) error { f.Ports.Api = *flagApiPort; return nil },
+
+	"discovery.appenders":    func(f *config.File) error { f.Discovery.Appenders = *flagAppenderAddresses; return nil },
+	"discovery.appender_dns": func(f *config.File) error { f.Discovery.AppenderDns = *flagAppenderDns; return nil },
+	"discovery.refresh_interval": func(f *config.File) error {
+		f.Discovery.RefreshInterval = config.Duration{Duration: *flagDiscoveryRefreshInterval}
+		return nil
+	},
+
+	"ingest_fanout":           func(f *config.File) error { f.Ingester.Fanout = *flagIngestFanout; return nil },
+	"appender_stream_labels":  func(f *config.File) error { f.Appender.StreamLabels = *flagStreamLabels; return nil },
+	"small_chunk_max_entries": func(f *config.File) error { f.Appender.MaxChunkEntries = *flagSmallChunkMaxEntries; return nil },
+	"small_chunk_max_spread": func(f *config.File) error {
+		f.Appender.MaxChunkSpread = config.Duration{Duration: *flagSmallChunkMaxSpread}
+		return nil
+	},
+	"small_chunk_max_age": func(f *config.File) error {
+		f.Appender.MaxChunkAge = config.Duration{Duration: *flagSmallChunkMaxAge}
+		return nil
+	},
+	"appender_max_memory_entries": func(f *config.File) error { f.Appender.MaxMemoryEntries = *flagAppenderMaxEntries; return nil },
+	"appender_max_memory_bytes":   func(f *config.File) error { f.Appender.MaxMemoryBytes = *flagAppenderMaxBytes; return nil },
+	"appender_spill_dir":          func(f *config.File) error { f.Appender.SpillDir = *flagAppenderSpillDir; return nil },
+	"appender_spill_drain_interval": func(f *config.File) error {
+		f.Appender.SpillDrainInterval = config.Duration{Duration: *flagSpillDrainInterval}
+		return nil
+	},
+	"big_chunk_settle_delay": func(f *config.File) error {
+		f.Janitor.SettleDelay = config.Duration{Duration: *flagBigChunkSettleDelay}
+		return nil
+	},
+
+	"janitor_compaction_interval": func(f *config.File) error {
+		f.Janitor.CompactionInterval = config.Duration{Duration: *flagJanitorCompactionInterval}
+		return nil
+	},
+	"janitor_num_workers":          func(f *config.File) error { f.Janitor.NumWorkers = *flagJanitorNumWorkers; return nil },
+	"janitor_max_compaction_bytes": func(f *config.File) error { f.Janitor.MaxCompactionBytes = *flagJanitorMaxCompactionBytes; return nil },
+	"janitor_scrub_interval": func(f *config.File) error {
+		f.Janitor.ScrubInterval = config.Duration{Duration: *flagJanitorScrubInterval}
+		return nil
+	},
+	"janitor_scrub_repair": func(f *config.File) error { f.Janitor.ScrubRepair = *flagJanitorScrubRepair; return nil },
+
+	"admin.enable_purge": func(f *config.File) error { f.Admin.EnablePurge = *flagAdminEnablePurge; return nil },
+
+	"tls.cert_file": func(f *config.File) error { f.Tls.CertFile = *flagTlsCertFile; return nil },
+	"tls.key_file":  func(f *config.File) error { f.Tls.KeyFile = *flagTlsKeyFile; return nil },
+
+	"internal_tls.cert_file": func(f *config.File) error { f.InternalTls.CertFile = *flagInternalTlsCertFile; return nil },
+	"internal_tls.key_file":  func(f *config.File) error { f.InternalTls.KeyFile = *flagInternalTlsKeyFile; return nil },
+	"internal_tls.ca_file":   func(f *config.File) error { f.InternalTls.CaFile = *flagInternalTlsCaFile; return nil },
+}
+
 func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	kingpin.Parse()
+	command := kingpin.Parse()
 	logger := logrus.New()
 	logger.Out = os.Stderr
 
-	conf := &cluster.Config{
-		SmallChunkMaxEntries: *flagSmallChunkMaxEntries,
-		SmallChunkSpread:     *flagSmallChunkMaxSpread,
-		SmallChunkMaxAge:     *flagSmallChunkMaxAge,
-		BigChunkMaxSpread:    *flagBigChunkMaxSpread,
-
-		JanitorCompactionInterval: *flagJanitorCompactionInterval,
+	file, err := loadConfig(os.Args[1:])
+	if err != nil {
+		kingpin.Fatalf("%v", err)
+	}
+	level, err := logrus.ParseLevel(file.LogLevel)
+	if err != nil {
+		panic(err)
+	}
+	logger.SetLevel(level)
 
-		StorageType: *flagStorageType,
-		GcsBucket:   *flagGcsBucket,
-		DiskPath:    *flagDiskPath,
+	if command == fsckCommand.FullCommand() {
+		os.Exit(runFsck(ctx, logger, file))
 	}
 
-	cluster, err := cluster.CreateCluster(ctx, logger, conf, *flagAppenderPorts, *flagIngestFanout)
+	conf := file.ClusterConfig()
+	conf.DiskReuse = *flagRole != cluster.RoleAll
+
+	mux := http.NewServeMux()
+	mux.Handle(metricsHttpPath, prometheus.InstrumentHandler(metricsHttpPath, promhttp.Handler()))
+	logger.Infof("Metrics at %s", formatLink(file.Ports.Http, metricsHttpPath))
+
+	services, err := startRole(ctx, logger, file, conf, mux)
 	if err != nil {
 		panic(err)
 	}
 
-	ingestRequest1 := &pb_almanac.IngestRequest{EntryJson: `{ "message": "foo", "timestamp_ms": 5000 }`}
-	_, err = cluster.Ingester.Ingest(context.Background(), ingestRequest1)
+	if *flagConfig != "" && *flagConfigReloadInterval > 0 {
+		current := file
+		err := config.Watch(ctx, logger, *flagConfig, *flagConfigReloadInterval, func() {
+			updated, err := reloadConfig(logger, current, services)
+			if err != nil {
+				logger.WithError(err).Warnf("Unable to reload config file %s", *flagConfig)
+				return
+			}
+			current = updated
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	server := &http.Server{Addr: fmt.Sprintf(":%d", file.Ports.Http), Handler: services.authenticator.HttpHandler(mux, httpRole)}
+	go func() {
+		var err error
+		if file.Tls.CertFile != "" {
+			err = server.ListenAndServeTLS(file.Tls.CertFile, file.Tls.KeyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logger.WithError(err).Errorf("Http server failed")
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	received := <-signals
+	logger.Infof("Received %v, shutting down", received)
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(ctx, *flagShutdownTimeout)
+	defer shutdownCancel()
+	err = services.shutdown(shutdownCtx)
+	if err != nil {
+		logger.WithError(err).Errorf("Shutdown incomplete")
+	}
+	server.Shutdown(shutdownCtx)
+	logger.Infof("Shut down")
+}
+
+// loadConfig returns the configuration made up of the configuration file, if any, and the flags
+// set explicitly on the supplied command line, which must already have been parsed.
+func loadConfig(args []string) (*config.File, error) {
+	file := config.Default()
+	if *flagConfig != "" {
+		var err error
+		file, err = config.Load(*flagConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Parse the command line again to find out which flags were actually set, as opposed to
+	// holding their default values.
+	parsed, err := kingpin.CommandLine.ParseContext(args)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse command line: %v", err)
+	}
+	set := map[string]bool{}
+	for _, element := range parsed.Elements {
+		flag, ok := element.Clause.(*kingpin.FlagClause)
+		if !ok {
+			continue
+		}
+		set[flag.Model().Name] = true
+		override, ok := flagOverrides[flag.Model().Name]
+		if !ok {
+			continue
+		}
+		err := override(file)
+		if err != nil {
+			return nil, fmt.Errorf("unable to apply flag --%s: %v", flag.Model().Name, err)
+		}
+	}
+
+	// The compaction levels are made up of the values of two flags, so they are overridden once
+	// it is known which of these are set.
+	var spreads []time.Duration
+	var minBytes []int64
+	if set["big_chunk_spreads"] {
+		spreads = *flagBigChunkSpreads
+	}
+	if set["big_chunk_min_bytes"] {
+		minBytes = *flagBigChunkMinBytes
+	}
+	err = overrideLevels(file, spreads, minBytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to apply flags --big_chunk_spreads and --big_chunk_min_bytes: %v", err)
+	}
+
+	err = file.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// reloadConfig loads the configuration again and applies all changed settings which can change
+// while running to the supplied services. Returns the newly loaded configuration.
+func reloadConfig(logger *logrus.Logger, current *config.File, s *services) (*config.File, error) {
+	updated, err := loadConfig(os.Args[1:])
+	if err != nil {
+		return nil, err
+	}
+	if config.RequiresRestart(current, updated) {
+		logger.Warnf("Config file %s changed settings which only take effect after a restart", *flagConfig)
+	}
+
+	level, err := logrus.ParseLevel(updated.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	logger.SetLevel(level)
+
+	err = s.authenticator.SetCredentials(updated.ClusterConfig().Credentials)
+	if err != nil {
+		return nil, fmt.Errorf("unable to update credentials: %v", err)
+	}
+
+	for _, a := range s.appenders {
+		err := a.SetChunkLimits(updated.Appender.MaxChunkEntries, updated.Appender.MaxChunkSpread.Duration, updated.Appender.MaxChunkAge.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("unable to update appender: %v", err)
+		}
+		err = a.SetMemoryLimits(updated.Appender.MaxMemoryEntries, updated.Appender.MaxMemoryBytes)
+		if err != nil {
+			return nil, fmt.Errorf("unable to update appender: %v", err)
+		}
+	}
+	if s.ingester != nil {
+		err := s.ingester.SetFanout(updated.Ingester.Fanout)
+		if err != nil {
+			return nil, fmt.Errorf("unable to update ingester: %v", err)
+		}
+		conf := updated.ClusterConfig()
+		err = s.ingester.SetLimits(conf.IngestLimits, conf.TenantIngestLimits)
+		if err != nil {
+			return nil, fmt.Errorf("unable to update ingester: %v", err)
+		}
+	}
+	if s.janitor != nil {
+		conf := updated.ClusterConfig()
+		policy, err := janitor.NewTieredPolicy(conf.BigChunkLevels, conf.BigChunkSettleDelay)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create compaction policy: %v", err)
+		}
+		err = s.janitor.Reconfigure(policy, conf.JanitorMaxCompactionBytes, conf.JanitorScrubRepair)
+		if err != nil {
+			return nil, fmt.Errorf("unable to update janitor: %v", err)
+		}
+		err = s.janitor.SetQuotas(conf.Quotas)
+		if err != nil {
+			return nil, fmt.Errorf("unable to update janitor: %v", err)
+		}
+	}
+
+	logger.Infof("Reloaded config file %s", *flagConfig)
+	return updated, nil
+}
+
+// runFsck checks the contents of the configured storage and prints all problems found. Returns
+// the exit code of the process, which is non-zero if any problems remain.
+func runFsck(ctx context.Context, logger *logrus.Logger, file *config.File) int {
+	s, err := storage.Open(file.Storage.Type, file.Storage.GcsBucket, file.Storage.DiskPath)
 	if err != nil {
 		panic(err)
 	}
 
-	ingestRequest2 := &pb_almanac.IngestRequest{EntryJson: `{ "message": "foo", "timestamp_ms": 5007 }`}
-	_, err = cluster.Ingester.Ingest(context.Background(), ingestRequest2)
+	checker, err := janitor.NewChecker(logger, s, file.Janitor.NumWorkers)
+	if err != nil {
+		panic(err)
+	}
+	report, err := checker.Check(ctx, *flagFsckRepair)
 	if err != nil {
 		panic(err)
 	}
 
-	mux := http.NewServeMux()
-	mux.Handle(metricsHttpPath, prometheus.InstrumentHandler(metricsHttpPath, promhttp.Handler()))
-	logger.Infof("Metrics at %s", formatLink(metricsHttpPath))
+	for _, p := range report.Problems {
+		fmt.Println(p)
+	}
+	fmt.Printf("Checked %d chunk(s): %d problem(s), %d unrepaired, %d chunk(s) in quarantine\n", report.NumChunks, len(report.Problems), report.NumUnrepaired(), report.NumQuarantined)
+	if report.NumUnrepaired() > 0 {
+		return 1
+	}
+	return 0
+}
 
-	cluster.Mixer.RegisterHttp(mux)
-	logger.Infof("Mixer at %s", formatLink("/mixer"))
+// httpRole returns the role required to access the http page at the supplied path. Metrics are
+// accessible to everyone, such that scrapers need no token.
+func httpRole(path string) string {
+	switch path {
+	case metricsHttpPath:
+		return ""
+	case ingesterHttpPath:
+		return auth.RoleIngest
+	case mixerHttpPath:
+		return auth.RoleRead
+	case loki.PushPath, otlp.HttpPath:
+		return auth.RoleIngest
+	}
+	if strings.HasPrefix(path, in.ElasticPathPrefix) {
+		return auth.RoleIngest
+	}
+	if strings.HasPrefix(path, loki.PathPrefix) {
+		return auth.RoleRead
+	}
+	return auth.RoleAdmin
+}
 
-	cluster.Ingester.RegisterHttp(mux)
-	logger.Infof("Ingester at %s", formatLink("/ingester"))
+func formatLink(port int, path string) string {
+	return fmt.Sprintf("http://localhost:%d%s", port, path)
+}
 
-	http.ListenAndServe(fmt.Sprintf(":%d", *flagHttpPort), mux)
+func intStrings(values []int) []string {
+	result := []string{}
+	for _, v := range values {
+		result = append(result, fmt.Sprint(v))
+	}
+	return result
 }
 
-func formatLink(path string) string {
-	return fmt.Sprintf("http://localhost:%d%s", *flagHttpPort, path)
+// overrideLevels replaces the compaction levels of the supplied configuration with new ones made
+// up of the supplied spreads and min bytes, unless both are nil. If only one of them is supplied,
+// the other values are taken from the current levels, in which case the number of values must
+// match the number of current levels.
+func overrideLevels(f *config.File, spreads []time.Duration, minBytes []int64) error {
+	if spreads == nil && minBytes == nil {
+		return nil
+	}
+	if spreads == nil {
+		for _, l := range f.Janitor.Levels {
+			spreads = append(spreads, l.Spread.Duration)
+		}
+	}
+	if minBytes == nil {
+		for _, l := range f.Janitor.Levels {
+			minBytes = append(minBytes, l.MinBytes)
+		}
+	}
+	if len(spreads) != len(minBytes) {
+		return fmt.Errorf("got %d big chunk spreads but %d big chunk min bytes", len(spreads), len(minBytes))
+	}
+
+	levels := []config.Level{}
+	for i, spread := range spreads {
+		levels = append(levels, config.Level{Spread: config.Duration{Duration: spread}, MinBytes: minBytes[i]})
+	}
+	f.Janitor.Levels = levels
+	return nil
+}
+
+func levelSpreads(levels []config.Level) []string {
+	result := []string{}
+	for _, l := range levels {
+		result = append(result, l.Spread.String())
+	}
+	return result
+}
+
+func levelMinBytes(levels []config.Level) []string {
+	result := []string{}
+	for _, l := range levels {
+		result = append(result, fmt.Sprint(l.MinBytes))
+	}
+	return result
 }
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dinowernli/almanac/pkg/config"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigLevelsFromFlags(t *testing.T) {
+	args := []string{
+		"serve",
+		"--big_chunk_spreads=1h", "--big_chunk_spreads=6h", "--big_chunk_spreads=24h",
+		"--big_chunk_min_bytes=1024", "--big_chunk_min_bytes=4096", "--big_chunk_min_bytes=16384",
+	}
+	resetListFlags()
+	defer resetListFlags()
+	_, err := kingpin.CommandLine.Parse(args)
+	require.NoError(t, err)
+
+	file, err := loadConfig(args)
+	require.NoError(t, err)
+	assert.Equal(t, []config.Level{
+		{Spread: config.Duration{Duration: time.Hour}, MinBytes: 1024},
+		{Spread: config.Duration{Duration: 6 * time.Hour}, MinBytes: 4096},
+		{Spread: config.Duration{Duration: 24 * time.Hour}, MinBytes: 16384},
+	}, file.Janitor.Levels)
+}
+
+func TestOverrideLevels(t *testing.T) {
+	// Nothing changes unless one of the flags is set.
+	file := config.Default()
+	assert.NoError(t, overrideLevels(file, nil, nil))
+	assert.Equal(t, config.Default().Janitor.Levels, file.Janitor.Levels)
+
+	// Values of a flag which is not set are taken from the current levels.
+	assert.NoError(t, overrideLevels(file, nil, []int64{1, 2}))
+	assert.Equal(t, []config.Level{
+		{Spread: config.Duration{Duration: time.Hour}, MinBytes: 1},
+		{Spread: config.Duration{Duration: 24 * time.Hour}, MinBytes: 2},
+	}, file.Janitor.Levels)
+
+	assert.NoError(t, overrideLevels(file, []time.Duration{time.Minute}, []int64{5}))
+	assert.Equal(t, []config.Level{{Spread: config.Duration{Duration: time.Minute}, MinBytes: 5}}, file.Janitor.Levels)
+
+	assert.Error(t, overrideLevels(file, []time.Duration{time.Minute, time.Hour}, nil))
+	assert.Error(t, overrideLevels(file, []time.Duration{time.Minute}, []int64{1, 2}))
+}
+
+// resetListFlags clears the values of the list flags, which would otherwise accumulate the values
+// of every parse of the global command line.
+func resetListFlags() {
+	*flagBigChunkSpreads = nil
+	*flagBigChunkMinBytes = nil
+}
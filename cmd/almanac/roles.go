@@ -0,0 +1,456 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/dinowernli/almanac/pkg/auth"
+	"github.com/dinowernli/almanac/pkg/cluster"
+	"github.com/dinowernli/almanac/pkg/config"
+	"github.com/dinowernli/almanac/pkg/fluent"
+	"github.com/dinowernli/almanac/pkg/otlp"
+	"github.com/dinowernli/almanac/pkg/service/admin"
+	"github.com/dinowernli/almanac/pkg/service/appender"
+	in "github.com/dinowernli/almanac/pkg/service/ingester"
+	"github.com/dinowernli/almanac/pkg/service/janitor"
+	mx "github.com/dinowernli/almanac/pkg/service/mixer"
+	"github.com/dinowernli/almanac/pkg/syslog"
+	"github.com/dinowernli/almanac/pkg/util"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// services holds the services started in this process which need to be reconfigured or shut down
+// while running. Services not running in this process are nil.
+type services struct {
+	appenders     []*appender.Appender
+	ingester      *in.Ingester
+	janitor       *janitor.Janitor
+	authenticator *auth.Authenticator
+	syslog        *syslog.Receiver
+	fluent        *fluent.Receiver
+
+	// servers holds the grpc servers started by this process. If all roles run in this process,
+	// cluster holds the local cluster, which takes care of shutting down appenders and janitor.
+	servers []*grpc.Server
+	cluster *cluster.LocalCluster
+}
+
+// startRole starts the services making up the selected role, registering their pages on the
+// supplied mux.
+func startRole(ctx context.Context, logger *logrus.Logger, file *config.File, conf *cluster.Config, mux *http.ServeMux) (*services, error) {
+	logger.Infof("Starting role %s", *flagRole)
+	switch *flagRole {
+	case cluster.RoleAll:
+		return startAll(ctx, logger, file, conf, mux)
+	case cluster.RoleAppender:
+		return startAppenders(ctx, logger, file, conf)
+	case cluster.RoleIngester:
+		return startIngester(ctx, logger, file, conf, mux)
+	case cluster.RoleMixer:
+		return startMixer(ctx, logger, file, conf, mux)
+	case cluster.RoleJanitor:
+		return startJanitor(ctx, logger, file, conf)
+	}
+	return nil, fmt.Errorf("unknown role: %s", *flagRole)
+}
+
+// startAll runs a local cluster with all services in this process, and ingests a few entries.
+func startAll(ctx context.Context, logger *logrus.Logger, file *config.File, conf *cluster.Config, mux *http.ServeMux) (*services, error) {
+	c, err := cluster.CreateCluster(ctx, logger, conf, file.Ports.Appenders, file.Ingester.Fanout)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create cluster: %v", err)
+	}
+
+	ingestRequest1 := &pb_almanac.IngestRequest{EntryJson: `{ "message": "foo", "timestamp_ms": 5000 }`}
+	_, err = c.Ingester.Ingest(ctx, ingestRequest1)
+	if err != nil {
+		return nil, fmt.Errorf("unable to ingest example entry: %v", err)
+	}
+
+	ingestRequest2 := &pb_almanac.IngestRequest{EntryJson: `{ "message": "foo", "timestamp_ms": 5007 }`}
+	_, err = c.Ingester.Ingest(ctx, ingestRequest2)
+	if err != nil {
+		return nil, fmt.Errorf("unable to ingest example entry: %v", err)
+	}
+
+	options, err := cluster.CreateServerOptions(conf, c.Authenticator)
+	if err != nil {
+		return nil, err
+	}
+	adminServer, err := serveGrpc(logger, "Admin", file.Ports.Admin, options, func(server *grpc.Server) {
+		pb_almanac.RegisterAdminServer(server, c.Admin)
+	})
+	if err != nil {
+		return nil, err
+	}
+	receiver := otlp.New(logger, c.Ingester)
+	apiServer, err := serveGrpc(logger, "Ingester and mixer", file.Ports.Api, options, func(server *grpc.Server) {
+		pb_almanac.RegisterIngesterServer(server, c.Ingester)
+		pb_almanac.RegisterMixerServer(server, c.Mixer)
+		receiver.RegisterGrpc(server)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.Mixer.RegisterHttp(mux)
+	logger.Infof("Mixer at %s", formatLink(file.Ports.Http, mixerHttpPath))
+
+	c.Ingester.RegisterHttp(mux)
+	receiver.RegisterHttp(mux)
+	logger.Infof("Ingester at %s", formatLink(file.Ports.Http, ingesterHttpPath))
+
+	syslogReceiver, err := startSyslog(logger, file, c.Ingester)
+	if err != nil {
+		return nil, err
+	}
+	fluentReceiver, err := startFluent(logger, file, c.Ingester)
+	if err != nil {
+		return nil, err
+	}
+	return &services{
+		appenders:     c.Appenders,
+		ingester:      c.Ingester,
+		janitor:       c.Janitor,
+		authenticator: c.Authenticator,
+		syslog:        syslogReceiver,
+		fluent:        fluentReceiver,
+		servers:       []*grpc.Server{apiServer, adminServer},
+		cluster:       c,
+	}, nil
+}
+
+// startAppenders runs an appender on each of the configured appender ports.
+func startAppenders(ctx context.Context, logger *logrus.Logger, file *config.File, conf *cluster.Config) (*services, error) {
+	storage, err := cluster.CreateStorage(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	spill, err := cluster.CreateSpill(ctx, logger, conf, storage)
+	if err != nil {
+		return nil, err
+	}
+
+	authenticator, err := cluster.CreateAuthenticator(logger, conf)
+	if err != nil {
+		return nil, err
+	}
+	reloader, err := cluster.CreateReloader(ctx, logger, conf)
+	if err != nil {
+		return nil, err
+	}
+	options := cluster.CreateAppenderServerOptions(authenticator, reloader)
+
+	appenders := []*appender.Appender{}
+	servers := []*grpc.Server{}
+	for _, port := range file.Ports.Appenders {
+		a, err := appender.New(logger, storage, conf.StreamLabels, conf.SmallChunkMaxEntries, conf.SmallChunkSpread, conf.SmallChunkMaxAge, conf.AppenderMaxEntries, conf.AppenderMaxBytes, spill)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create appender %d: %v", port, err)
+		}
+		server, err := serveGrpc(logger, "Appender", port, options, func(server *grpc.Server) {
+			pb_almanac.RegisterAppenderServer(server, a)
+		})
+		if err != nil {
+			return nil, err
+		}
+		appenders = append(appenders, a)
+		servers = append(servers, server)
+	}
+	return &services{appenders: appenders, authenticator: authenticator, servers: servers}, nil
+}
+
+// startIngester runs an ingester which talks to the appenders found through discovery.
+func startIngester(ctx context.Context, logger *logrus.Logger, file *config.File, conf *cluster.Config, mux *http.ServeMux) (*services, error) {
+	discovery, err := cluster.CreateDiscovery(ctx, logger, conf)
+	if err != nil {
+		return nil, err
+	}
+	limiter, err := cluster.CreateLimiter(conf)
+	if err != nil {
+		return nil, err
+	}
+	ingester, err := in.New(logger, discovery, file.Ingester.Fanout, limiter)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create ingester: %v", err)
+	}
+
+	authenticator, options, err := createAuth(logger, conf)
+	if err != nil {
+		return nil, err
+	}
+	receiver := otlp.New(logger, ingester)
+	server, err := serveGrpc(logger, "Ingester", file.Ports.Api, options, func(server *grpc.Server) {
+		pb_almanac.RegisterIngesterServer(server, ingester)
+		receiver.RegisterGrpc(server)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ingester.RegisterHttp(mux)
+	receiver.RegisterHttp(mux)
+	logger.Infof("Ingester at %s", formatLink(file.Ports.Http, ingesterHttpPath))
+
+	syslogReceiver, err := startSyslog(logger, file, ingester)
+	if err != nil {
+		return nil, err
+	}
+	fluentReceiver, err := startFluent(logger, file, ingester)
+	if err != nil {
+		return nil, err
+	}
+	return &services{
+		ingester:      ingester,
+		authenticator: authenticator,
+		syslog:        syslogReceiver,
+		fluent:        fluentReceiver,
+		servers:       []*grpc.Server{server},
+	}, nil
+}
+
+// startMixer runs a mixer which searches storage and the appenders found through discovery.
+func startMixer(ctx context.Context, logger *logrus.Logger, file *config.File, conf *cluster.Config, mux *http.ServeMux) (*services, error) {
+	storage, err := cluster.CreateStorage(conf)
+	if err != nil {
+		return nil, err
+	}
+	discovery, err := cluster.CreateDiscovery(ctx, logger, conf)
+	if err != nil {
+		return nil, err
+	}
+	mixer := mx.New(logger, storage, discovery)
+
+	authenticator, options, err := createAuth(logger, conf)
+	if err != nil {
+		return nil, err
+	}
+	server, err := serveGrpc(logger, "Mixer", file.Ports.Api, options, func(server *grpc.Server) {
+		pb_almanac.RegisterMixerServer(server, mixer)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mixer.RegisterHttp(mux)
+	logger.Infof("Mixer at %s", formatLink(file.Ports.Http, mixerHttpPath))
+	return &services{authenticator: authenticator, servers: []*grpc.Server{server}}, nil
+}
+
+// startJanitor runs the janitor along with the admin service, whose purges the janitor carries
+// out. There must only ever be a single process running this role.
+func startJanitor(ctx context.Context, logger *logrus.Logger, file *config.File, conf *cluster.Config) (*services, error) {
+	storage, err := cluster.CreateStorage(conf)
+	if err != nil {
+		return nil, err
+	}
+	j, err := cluster.CreateJanitor(ctx, logger, conf, storage)
+	if err != nil {
+		return nil, err
+	}
+
+	authenticator, options, err := createAuth(logger, conf)
+	if err != nil {
+		return nil, err
+	}
+	server, err := serveGrpc(logger, "Admin", file.Ports.Admin, options, func(server *grpc.Server) {
+		pb_almanac.RegisterAdminServer(server, admin.New(logger, storage, conf.EnablePurge))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &services{janitor: j, authenticator: authenticator, servers: []*grpc.Server{server}}, nil
+}
+
+// shutdown stops the services in an orderly fashion. The syslog and fluent receivers and grpc
+// servers stop accepting requests first, then the appenders flush their open chunks to storage
+// while the janitor finishes its work in progress. Returns an error if the supplied context is done
+// before all of this has happened.
+func (s *services) shutdown(ctx context.Context) error {
+	if s.syslog != nil {
+		s.syslog.Close()
+	}
+	if s.fluent != nil {
+		s.fluent.Close()
+	}
+	for _, server := range s.servers {
+		util.GracefulStop(ctx, server)
+	}
+	if s.cluster != nil {
+		return s.cluster.Shutdown(ctx)
+	}
+
+	janitorErr := make(chan error, 1)
+	go func() {
+		if s.janitor == nil {
+			janitorErr <- nil
+			return
+		}
+		janitorErr <- s.janitor.Stop(ctx)
+	}()
+
+	problems := []string{}
+	for i, a := range s.appenders {
+		err := a.Close(ctx)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("appender %d: %v", i, err))
+		}
+	}
+	err := <-janitorErr
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("janitor: %v", err))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("unable to shut down cleanly: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// createAuth returns the authenticator of this process along with the options for the grpc
+// servers it exposes to external clients.
+func createAuth(logger *logrus.Logger, conf *cluster.Config) (*auth.Authenticator, []grpc.ServerOption, error) {
+	authenticator, err := cluster.CreateAuthenticator(logger, conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	options, err := cluster.CreateServerOptions(conf, authenticator)
+	if err != nil {
+		return nil, nil, err
+	}
+	return authenticator, options, nil
+}
+
+// startSyslog starts receiving syslog messages on the configured ports, passing them on to the
+// supplied ingester. Returns nil if no syslog port is configured.
+func startSyslog(logger *logrus.Logger, file *config.File, ingester pb_almanac.IngesterServer) (*syslog.Receiver, error) {
+	if file.Syslog.UdpPort == 0 && file.Syslog.TcpPort == 0 && file.Syslog.TlsPort == 0 {
+		return nil, nil
+	}
+	receiver, err := syslog.New(logger, ingester, file.Syslog.Tenant)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create syslog receiver: %v", err)
+	}
+
+	err = func() error {
+		if port := file.Syslog.UdpPort; port != 0 {
+			conn, err := net.ListenPacket("udp", fmt.Sprintf(":%d", port))
+			if err != nil {
+				return fmt.Errorf("unable to listen on udp port %d: %v", port, err)
+			}
+			err = receiver.ServeUdp(conn)
+			if err != nil {
+				return err
+			}
+			logger.Infof("Syslog over udp at localhost:%d", port)
+		}
+		if port := file.Syslog.TcpPort; port != 0 {
+			listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+			if err != nil {
+				return fmt.Errorf("unable to listen on port %d: %v", port, err)
+			}
+			err = receiver.ServeTcp(listener)
+			if err != nil {
+				return err
+			}
+			logger.Infof("Syslog over tcp at localhost:%d", port)
+		}
+		if port := file.Syslog.TlsPort; port != 0 {
+			listener, err := listenTls(file, port)
+			if err != nil {
+				return err
+			}
+			err = receiver.ServeTcp(listener)
+			if err != nil {
+				return err
+			}
+			logger.Infof("Syslog over tls at localhost:%d", port)
+		}
+		return nil
+	}()
+	if err != nil {
+		receiver.Close()
+		return nil, err
+	}
+	return receiver, nil
+}
+
+// startFluent starts receiving events over the forward protocol on the configured ports, passing
+// them on to the supplied ingester. Returns nil if no fluent port is configured.
+func startFluent(logger *logrus.Logger, file *config.File, ingester pb_almanac.IngesterServer) (*fluent.Receiver, error) {
+	if file.Fluent.Port == 0 && file.Fluent.TlsPort == 0 {
+		return nil, nil
+	}
+	receiver, err := fluent.New(logger, ingester, file.Fluent.Tenant)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create fluent receiver: %v", err)
+	}
+
+	err = func() error {
+		if port := file.Fluent.Port; port != 0 {
+			listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+			if err != nil {
+				return fmt.Errorf("unable to listen on port %d: %v", port, err)
+			}
+			err = receiver.ServeTcp(listener)
+			if err != nil {
+				return err
+			}
+			logger.Infof("Fluent forward at localhost:%d", port)
+		}
+		if port := file.Fluent.TlsPort; port != 0 {
+			listener, err := listenTls(file, port)
+			if err != nil {
+				return err
+			}
+			err = receiver.ServeTcp(listener)
+			if err != nil {
+				return err
+			}
+			logger.Infof("Fluent forward over tls at localhost:%d", port)
+		}
+		return nil
+	}()
+	if err != nil {
+		receiver.Close()
+		return nil, err
+	}
+	return receiver, nil
+}
+
+// listenTls listens on all interfaces on the supplied port, serving the certificate configured
+// under tls to the connections it accepts.
+func listenTls(file *config.File, port int) (net.Listener, error) {
+	certificate, err := tls.LoadX509KeyPair(file.Tls.CertFile, file.Tls.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load tls key pair: %v", err)
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on port %d: %v", port, err)
+	}
+	return tls.NewListener(listener, &tls.Config{Certificates: []tls.Certificate{certificate}}), nil
+}
+
+// serveGrpc starts a grpc server with the supplied options listening on all interfaces on the
+// supplied port, with the services added by the supplied function.
+func serveGrpc(logger *logrus.Logger, name string, port int, options []grpc.ServerOption, register func(*grpc.Server)) (*grpc.Server, error) {
+	listen, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on port %d: %v", port, err)
+	}
+
+	server := grpc.NewServer(options...)
+	register(server)
+	go server.Serve(listen)
+	logger.Infof("%s service at localhost:%d", name, port)
+	return server, nil
+}
@@ -0,0 +1,177 @@
+// Command almanacctl is a command line client for a running almanac system. It searches for and
+// ingests log entries through the mixer and ingester services, and inspects chunks in storage.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/dinowernli/almanac/pkg/auth"
+	"github.com/dinowernli/almanac/pkg/storage"
+	"github.com/dinowernli/almanac/pkg/tenant"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/alecthomas/kingpin"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	outputJson = "json"
+	outputText = "text"
+
+	formatAuto   = "auto"
+	formatJson   = "json"
+	formatLogfmt = "logfmt"
+	formatText   = "text"
+)
+
+var (
+	flagMixer    = kingpin.Flag("mixer", "The address of the mixer grpc service").Default("localhost:5000").String()
+	flagIngester = kingpin.Flag("ingester", "The address of the ingester grpc service").Default("localhost:5000").String()
+	flagTimeout  = kingpin.Flag("timeout", "How long to wait for each request").Default("30s").Duration()
+	flagTenant   = kingpin.Flag("tenant", "The tenant whose entries and chunks to act on, the default tenant if empty").String()
+	flagToken    = kingpin.Flag("token", "The token to authenticate with, if the services require one").Envar("ALMANAC_TOKEN").String()
+	flagTls      = kingpin.Flag("tls", "Whether to connect to the services using tls").Bool()
+	flagTlsCa    = kingpin.Flag("tls.ca_file", "A pem file holding the certificate authorities to trust instead of the system ones, implies --tls").ExistingFile()
+	flagOutput   = kingpin.Flag("output", "How to print log entries, json prints one raw entry per line").Short('o').Default(outputJson).Enum(outputJson, outputText)
+
+	flagStorageType = kingpin.Flag("storage", "Which kind of storage to inspect").Default(storage.StorageTypeDisk).Enum(storage.StorageTypeDisk, storage.StorageTypeGcs)
+	flagGcsBucket   = kingpin.Flag("storage.gcs.bucket", "Which gcs bucket to inspect").Default("almanac-dev").String()
+	flagDiskPath    = kingpin.Flag("storage.disk.path", "The root directory of the storage to inspect").Default("/tmp/almanac-dev").String()
+
+	searchCommand    = kingpin.Command("search", "Searches for log entries, oldest first")
+	flagSearchQuery  = searchCommand.Arg("query", "The query to execute").Required().String()
+	flagSearchStart  = searchCommand.Flag("start", "Only return entries at or after this time, in epoch milliseconds").Int64()
+	flagSearchEnd    = searchCommand.Flag("end", "Only return entries at or before this time, in epoch milliseconds").Int64()
+	flagSearchSince  = searchCommand.Flag("since", "Only return entries newer than this, takes precedence over --start").Duration()
+	flagSearchNum    = searchCommand.Flag("num", "The maximum number of entries to return").Short('n').Default("100").Int32()
+	flagSearchLabels = searchCommand.Flag("labels", `Only return entries matching this label selector, e.g., 'service="web", env!="dev"'`).Short('l').String()
+
+	tailCommand      = kingpin.Command("tail", "Continuously prints new log entries matching a query")
+	flagTailQuery    = tailCommand.Arg("query", "The query to execute").Required().String()
+	flagTailSince    = tailCommand.Flag("since", "How far back to start").Default("1m").Duration()
+	flagTailInterval = tailCommand.Flag("interval", "How frequently to poll for new entries").Default("1s").Duration()
+	flagTailNum      = tailCommand.Flag("num", "The maximum number of entries to fetch per poll").Short('n').Default("100").Int32()
+	flagTailLabels   = tailCommand.Flag("labels", "Only return entries matching this label selector").Short('l').String()
+
+	ingestCommand       = kingpin.Command("ingest", "Ingests entries, one per line")
+	flagIngestFiles     = ingestCommand.Arg("files", "The files to read entries from, stdin if none are supplied").ExistingFiles()
+	flagIngestBatchSize = ingestCommand.Flag("batch_size", "How many entries to ingest concurrently").Default("100").Int()
+	flagIngestFormat    = ingestCommand.Flag("format", "The format of the entries, detected for each line if auto").Default(formatAuto).Enum(formatAuto, formatJson, formatLogfmt, formatText)
+
+	chunksCommand          = kingpin.Command("chunks", "Inspects the chunks in storage")
+	chunksLsCommand        = chunksCommand.Command("ls", "Lists the chunks in storage")
+	flagChunksLsType       = chunksLsCommand.Flag("type", "Which type of chunks to list").Default(chunkTypeAll).Enum(chunkTypeAll, chunkTypeSmall, chunkTypeBig)
+	flagChunksLsStart      = chunksLsCommand.Flag("start", "Only list chunks ending at or after this time, in epoch milliseconds").Int64()
+	flagChunksLsEnd        = chunksLsCommand.Flag("end", "Only list chunks starting at or before this time, in epoch milliseconds").Int64()
+	chunksInspectCommand   = chunksCommand.Command("inspect", "Prints the contents of a chunk in storage")
+	flagChunksInspectChunk = chunksInspectCommand.Arg("id", "The id of the chunk, as printed by 'chunks ls'").Required().String()
+)
+
+func main() {
+	command := kingpin.Parse()
+	err := tenant.Validate(*flagTenant)
+	kingpin.FatalIfError(err, "")
+
+	// Services read the tenant from the metadata of every call made with this context.
+	ctx := tenant.NewOutgoingContext(context.Background(), *flagTenant)
+
+	switch command {
+	case searchCommand.FullCommand():
+		err = runSearch(ctx)
+	case tailCommand.FullCommand():
+		err = runTail(ctx)
+	case ingestCommand.FullCommand():
+		err = runIngest(ctx)
+	case chunksLsCommand.FullCommand():
+		err = runChunksLs(ctx)
+	case chunksInspectCommand.FullCommand():
+		err = runChunksInspect(ctx)
+	}
+	kingpin.FatalIfError(err, "")
+}
+
+func dialMixer() (pb_almanac.MixerClient, error) {
+	options, err := dialOptions()
+	if err != nil {
+		return nil, err
+	}
+	connection, err := grpc.Dial(*flagMixer, options...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to dial mixer %s: %v", *flagMixer, err)
+	}
+	return pb_almanac.NewMixerClient(connection), nil
+}
+
+func dialIngester() (pb_almanac.IngesterClient, error) {
+	options, err := dialOptions()
+	if err != nil {
+		return nil, err
+	}
+	connection, err := grpc.Dial(*flagIngester, options...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to dial ingester %s: %v", *flagIngester, err)
+	}
+	return pb_almanac.NewIngesterClient(connection), nil
+}
+
+// dialOptions returns the options for dialing the services, as configured by the flags.
+func dialOptions() ([]grpc.DialOption, error) {
+	result := []grpc.DialOption{grpc.WithInsecure()}
+	if *flagTlsCa != "" {
+		creds, err := credentials.NewClientTLSFromFile(*flagTlsCa, "")
+		if err != nil {
+			return nil, fmt.Errorf("unable to load certificate authorities: %v", err)
+		}
+		result = []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	} else if *flagTls {
+		result = []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(nil, ""))}
+	}
+	if *flagToken != "" {
+		result = append(result, grpc.WithPerRPCCredentials(auth.NewTokenCredentials(*flagToken)))
+	}
+	return result, nil
+}
+
+func openStorage() (*storage.Storage, error) {
+	return storage.Open(*flagStorageType, *flagGcsBucket, *flagDiskPath)
+}
+
+// printEntry writes the supplied entry in the requested output format.
+func printEntry(writer io.Writer, entry *pb_almanac.LogEntry) error {
+	// Entries are printed on a single line, so that each line holds exactly one entry. Entries
+	// which are not valid json are printed as they are.
+	entryJson := []byte(entry.EntryJson)
+	compacted := &bytes.Buffer{}
+	if err := json.Compact(compacted, entryJson); err == nil {
+		entryJson = compacted.Bytes()
+	}
+
+	var err error
+	if *flagOutput == outputText {
+		_, err = fmt.Fprintf(writer, "%s %s\n", formatMs(entry.TimestampMs), entryJson)
+	} else {
+		_, err = fmt.Fprintf(writer, "%s\n", entryJson)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to print entry: %v", err)
+	}
+	return nil
+}
+
+// warnIfIncomplete tells the user on stderr if the supplied response is missing entries.
+func warnIfIncomplete(response *pb_almanac.SearchResponse) {
+	if len(response.CorruptChunks) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: results are incomplete, skipped %d corrupt chunk(s)\n", len(response.CorruptChunks))
+	}
+}
+
+func nowMs() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
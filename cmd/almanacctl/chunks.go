@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	st "github.com/dinowernli/almanac/pkg/storage"
+	"github.com/dinowernli/almanac/pkg/util"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	chunkTypeAll   = "all"
+	chunkTypeSmall = "small"
+	chunkTypeBig   = "big"
+)
+
+// chunkListing is the json representation of a chunk printed by "chunks ls".
+type chunkListing struct {
+	Id        string `json:"id"`
+	Type      string `json:"type"`
+	StartMs   int64  `json:"start_ms"`
+	EndMs     int64  `json:"end_ms"`
+	SizeBytes int64  `json:"size_bytes"`
+
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+func runChunksLs(ctx context.Context) error {
+	storage, err := openStorage()
+	if err != nil {
+		return err
+	}
+
+	types := []pb_almanac.ChunkId_Type{}
+	if *flagChunksLsType != chunkTypeBig {
+		types = append(types, pb_almanac.ChunkId_SMALL)
+	}
+	if *flagChunksLsType != chunkTypeSmall {
+		types = append(types, pb_almanac.ChunkId_BIG)
+	}
+
+	listings := []*chunkListing{}
+	for _, chunkType := range types {
+		infos, err := storage.ListChunkInfos(ctx, *flagTenant, *flagChunksLsStart, *flagChunksLsEnd, chunkType)
+		if err != nil {
+			return fmt.Errorf("unable to list chunks: %v", err)
+		}
+		for _, info := range infos {
+			// Listing does not filter by time yet, so do it here.
+			if *flagChunksLsStart != 0 && info.Id.EndMs < *flagChunksLsStart {
+				continue
+			}
+			if *flagChunksLsEnd != 0 && info.Id.StartMs > *flagChunksLsEnd {
+				continue
+			}
+			id, err := st.ChunkId(info.Id)
+			if err != nil {
+				return fmt.Errorf("unable to compute chunk id: %v", err)
+			}
+			listings = append(listings, &chunkListing{
+				Id:        id,
+				Type:      info.Id.Type.String(),
+				StartMs:   info.Id.StartMs,
+				EndMs:     info.Id.EndMs,
+				SizeBytes: info.SizeBytes,
+				Labels:    info.Id.Labels,
+			})
+		}
+	}
+	sort.Slice(listings, func(i, j int) bool {
+		if listings[i].StartMs != listings[j].StartMs {
+			return listings[i].StartMs < listings[j].StartMs
+		}
+		return listings[i].Id < listings[j].Id
+	})
+
+	if *flagOutput == outputText {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(writer, "ID\tTYPE\tSTART\tEND\tSIZE")
+		for _, l := range listings {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%d\n", l.Id, l.Type, formatMs(l.StartMs), formatMs(l.EndMs), l.SizeBytes)
+		}
+		return writer.Flush()
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for _, l := range listings {
+		err := encoder.Encode(l)
+		if err != nil {
+			return fmt.Errorf("unable to print chunk: %v", err)
+		}
+	}
+	return nil
+}
+
+// runChunksInspect prints the entries of a single chunk. In text output, the entries are
+// preceded by a summary of the chunk.
+func runChunksInspect(ctx context.Context) error {
+	storage, err := openStorage()
+	if err != nil {
+		return err
+	}
+
+	chunkId, err := st.ChunkIdProto(*flagChunksInspectChunk)
+	if err != nil {
+		return fmt.Errorf("unable to parse chunk id: %v", err)
+	}
+	chunk, err := storage.LoadChunk(ctx, chunkId)
+	if err != nil {
+		return fmt.Errorf("unable to load chunk: %v", err)
+	}
+	defer chunk.Close()
+
+	out := bufio.NewWriter(os.Stdout)
+	if *flagOutput == outputText {
+		fmt.Fprintf(out, "Id:      %s\n", *flagChunksInspectChunk)
+		fmt.Fprintf(out, "Type:    %v\n", chunk.Id().Type)
+		fmt.Fprintf(out, "Start:   %s\n", formatMs(chunk.Id().StartMs))
+		fmt.Fprintf(out, "End:     %s\n", formatMs(chunk.Id().EndMs))
+		if len(chunk.Id().Labels) > 0 {
+			fmt.Fprintf(out, "Labels:  %s\n", st.EncodeLabels(chunk.Id().Labels))
+		}
+		fmt.Fprintf(out, "Entries: %d\n\n", len(chunk.Entries()))
+	}
+	for _, entry := range chunk.Entries() {
+		err := printEntry(out, entry)
+		if err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+func formatMs(ms int64) string {
+	return util.TimeMs(ms).UTC().Format(time.RFC3339Nano)
+}
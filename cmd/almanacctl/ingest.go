@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	in "github.com/dinowernli/almanac/pkg/service/ingester"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"golang.org/x/net/context"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// maxEntryBytes is the size of the longest line accepted as a single entry.
+	maxEntryBytes = 1024 * 1024
+)
+
+func runIngest(ctx context.Context) error {
+	if *flagIngestBatchSize <= 0 {
+		return fmt.Errorf("batch size must be positive, but got: %d", *flagIngestBatchSize)
+	}
+	client, err := dialIngester()
+	if err != nil {
+		return err
+	}
+
+	format := pb_almanac.IngestRequest_Format(pb_almanac.IngestRequest_Format_value[strings.ToUpper(*flagIngestFormat)])
+	b := &batcher{client: client, format: format, batch: []string{}}
+	if len(*flagIngestFiles) == 0 {
+		err := b.readFrom(ctx, "stdin", os.Stdin)
+		if err != nil {
+			return err
+		}
+	}
+	for _, filename := range *flagIngestFiles {
+		file, err := os.Open(filename)
+		if err != nil {
+			return fmt.Errorf("unable to open file %s: %v", filename, err)
+		}
+		err = b.readFrom(ctx, filename, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	err = b.flush(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Ingested %d entries\n", b.numIngested)
+	return nil
+}
+
+// batcher collects entries and ingests them in batches of the configured size.
+type batcher struct {
+	client      pb_almanac.IngesterClient
+	format      pb_almanac.IngestRequest_Format
+	batch       []string
+	numIngested int
+}
+
+// readFrom adds every non-empty line of the supplied reader as an entry.
+func (b *batcher) readFrom(ctx context.Context, name string, reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), maxEntryBytes)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		b.batch = append(b.batch, line)
+		if len(b.batch) >= *flagIngestBatchSize {
+			err := b.flush(ctx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("unable to read entries from %s: %v", name, err)
+	}
+	return nil
+}
+
+// flush sends all the pending entries to the ingester concurrently. Returns once all of them
+// have been ingested, or on the first failure.
+func (b *batcher) flush(ctx context.Context) error {
+	if len(b.batch) == 0 {
+		return nil
+	}
+
+	batchCtx, cancel := context.WithTimeout(ctx, *flagTimeout)
+	defer cancel()
+
+	g, groupCtx := errgroup.WithContext(batchCtx)
+	for _, e := range b.batch {
+		line := e
+		g.Go(func() error {
+			return ingestWithRetries(groupCtx, b.client, &pb_almanac.IngestRequest{EntryJson: line, Format: b.format})
+		})
+	}
+	err := g.Wait()
+	if err != nil {
+		return fmt.Errorf("unable to ingest batch after %d entries: %v", b.numIngested, err)
+	}
+	b.numIngested += len(b.batch)
+	b.batch = []string{}
+	return nil
+}
+
+// ingestWithRetries ingests the supplied entry. If the entry's tenant is over its rate limits, it
+// waits for as long as the ingester asks it to and tries again, until the supplied context is done.
+func ingestWithRetries(ctx context.Context, client pb_almanac.IngesterClient, request *pb_almanac.IngestRequest) error {
+	for {
+		var trailer metadata.MD
+		_, err := client.Ingest(ctx, request, grpc.Trailer(&trailer))
+		if err == nil {
+			return nil
+		}
+		wait := in.RetryAfter(trailer)
+		if grpc.Code(err) != codes.ResourceExhausted || wait == 0 {
+			return fmt.Errorf("unable to ingest entry %s: %v", request.EntryJson, err)
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return fmt.Errorf("unable to ingest entry %s: %v", request.EntryJson, err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"time"
+
+	st "github.com/dinowernli/almanac/pkg/storage"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"golang.org/x/net/context"
+)
+
+func runSearch(ctx context.Context) error {
+	client, err := dialMixer()
+	if err != nil {
+		return err
+	}
+
+	selector, err := st.ParseLabelSelector(*flagSearchLabels)
+	if err != nil {
+		return err
+	}
+
+	request := &pb_almanac.SearchRequest{
+		Query:         *flagSearchQuery,
+		Num:           *flagSearchNum,
+		StartMs:       *flagSearchStart,
+		EndMs:         *flagSearchEnd,
+		LabelSelector: selector,
+	}
+	if *flagSearchSince > 0 {
+		request.StartMs = nowMs() - int64(*flagSearchSince/time.Millisecond)
+	}
+
+	requestCtx, cancel := context.WithTimeout(ctx, *flagTimeout)
+	defer cancel()
+	response, err := client.Search(requestCtx, request)
+	if err != nil {
+		return fmt.Errorf("unable to search: %v", err)
+	}
+	warnIfIncomplete(response)
+
+	out := bufio.NewWriter(os.Stdout)
+	for _, entry := range response.Entries {
+		err := printEntry(out, entry)
+		if err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// runTail repeatedly searches for entries newer than the last one printed. Entries which show up
+// with a timestamp older than the last one printed are not picked up.
+func runTail(ctx context.Context) error {
+	client, err := dialMixer()
+	if err != nil {
+		return err
+	}
+	selector, err := st.ParseLabelSelector(*flagTailLabels)
+	if err != nil {
+		return err
+	}
+
+	cursor := newTailCursor(nowMs() - int64(*flagTailSince/time.Millisecond))
+	out := bufio.NewWriter(os.Stdout)
+	for {
+		requestCtx, cancel := context.WithTimeout(ctx, *flagTimeout)
+		response, err := client.Search(requestCtx, &pb_almanac.SearchRequest{
+			Query:         *flagTailQuery,
+			Num:           *flagTailNum,
+			StartMs:       cursor.ms,
+			LabelSelector: selector,
+		})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("unable to search: %v", err)
+		}
+		warnIfIncomplete(response)
+
+		skippedMs := cursor.ms
+		entries, skipped := cursor.next(response.Entries, *flagTailNum)
+		for _, entry := range entries {
+			err := printEntry(out, entry)
+			if err != nil {
+				return err
+			}
+		}
+		err = out.Flush()
+		if err != nil {
+			return fmt.Errorf("unable to flush output: %v", err)
+		}
+		if skipped {
+			fmt.Fprintf(os.Stderr, "Warning: more than %d entries have timestamp %d, skipped the rest of them\n", *flagTailNum, skippedMs)
+		}
+
+		// A full page of results means there are likely more entries waiting, so only back off
+		// once we have caught up.
+		if (len(entries) == 0 && !skipped) || int32(len(response.Entries)) < *flagTailNum {
+			time.Sleep(*flagTailInterval)
+		}
+	}
+}
+
+// tailCursor tracks how far tail has gotten. Searches start at the timestamp of the cursor, which
+// is inclusive, so the entries already printed with that timestamp are remembered to skip them.
+type tailCursor struct {
+	ms      int64
+	printed map[string]struct{}
+}
+
+func newTailCursor(ms int64) *tailCursor {
+	return &tailCursor{ms: ms, printed: map[string]struct{}{}}
+}
+
+// next returns the entries of the supplied page of results, fetched with the supplied maximum
+// number of entries, which have not been returned before, and moves the cursor past them. Searches
+// cannot page through more entries with the same timestamp than fit on a page, so a full page of
+// such entries which are all known moves the cursor past their timestamp. Returns whether this
+// happened, in which case any further entries with that timestamp are skipped.
+func (c *tailCursor) next(page []*pb_almanac.LogEntry, num int32) ([]*pb_almanac.LogEntry, bool) {
+	result := []*pb_almanac.LogEntry{}
+	for _, entry := range page {
+		if _, ok := c.printed[entry.Id]; ok {
+			continue
+		}
+		if entry.TimestampMs > c.ms {
+			c.ms = entry.TimestampMs
+			c.printed = map[string]struct{}{}
+		}
+		c.printed[entry.Id] = struct{}{}
+		result = append(result, entry)
+	}
+
+	full := len(page) > 0 && int32(len(page)) >= num
+	if len(result) == 0 && full && page[0].TimestampMs == c.ms && page[len(page)-1].TimestampMs == c.ms {
+		c.ms++
+		c.printed = map[string]struct{}{}
+		return result, true
+	}
+	return result, false
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTailCursor(t *testing.T) {
+	cursor := newTailCursor(100)
+	first := []*pb_almanac.LogEntry{{Id: "a", TimestampMs: 100}, {Id: "b", TimestampMs: 101}}
+	entries, skipped := cursor.next(first, 2)
+	assert.Equal(t, first, entries)
+	assert.False(t, skipped)
+	assert.Equal(t, int64(101), cursor.ms)
+
+	// The next search starts at the last timestamp, so entries printed with it come back.
+	entries, skipped = cursor.next([]*pb_almanac.LogEntry{{Id: "b", TimestampMs: 101}, {Id: "c", TimestampMs: 101}}, 2)
+	assert.Equal(t, []*pb_almanac.LogEntry{{Id: "c", TimestampMs: 101}}, entries)
+	assert.False(t, skipped)
+	assert.Equal(t, int64(101), cursor.ms)
+}
+
+func TestTailCursorAdvancesPastFullTimestamp(t *testing.T) {
+	// More entries share a timestamp than fit on a page, so every search from that timestamp
+	// returns the same page.
+	page := []*pb_almanac.LogEntry{{Id: "a", TimestampMs: 100}, {Id: "b", TimestampMs: 100}}
+	cursor := newTailCursor(100)
+	entries, skipped := cursor.next(page, 2)
+	assert.Equal(t, page, entries)
+	assert.False(t, skipped)
+
+	entries, skipped = cursor.next(page, 2)
+	assert.Empty(t, entries)
+	assert.True(t, skipped)
+	assert.Equal(t, int64(101), cursor.ms)
+
+	// Pages which are not full mean the timestamp has been exhausted.
+	cursor = newTailCursor(100)
+	cursor.next(page, 3)
+	entries, skipped = cursor.next(page, 3)
+	assert.Empty(t, entries)
+	assert.False(t, skipped)
+	assert.Equal(t, int64(100), cursor.ms)
+}
@@ -0,0 +1,288 @@
+// Package auth authenticates the callers of the grpc services and http pages using bearer
+// tokens, and authorizes them based on the role of their token.
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/dinowernli/almanac/pkg/tenant"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// The roles a token can have. Admin tokens may do everything, including what the other roles
+// may do.
+const (
+	RoleIngest = "ingest"
+	RoleRead   = "read"
+	RoleAdmin  = "admin"
+)
+
+const (
+	// AuthorizationKey is the grpc metadata key and http header carrying "Bearer <token>".
+	AuthorizationKey = "authorization"
+
+	// ApiKeyKey is the grpc metadata key and http header carrying a bare token, for clients
+	// which cannot set bearer tokens.
+	ApiKeyKey = "x-api-key"
+
+	bearerPrefix = "Bearer "
+)
+
+var (
+	// methodRoles holds the role required by each grpc method. Methods not listed here, such as
+	// those of the admin service, require the admin role.
+	methodRoles = map[string]string{
+		"/almanac.Ingester/Ingest": RoleIngest,
+		"/almanac.Appender/Append": RoleIngest,
+		"/almanac.Mixer/Search":    RoleRead,
+		"/almanac.Appender/Search": RoleRead,
+
+		"/opentelemetry.proto.collector.logs.v1.LogsService/Export": RoleIngest,
+	}
+)
+
+// Roles returns the names of all roles a token can have.
+func Roles() []string {
+	return []string{RoleIngest, RoleRead, RoleAdmin}
+}
+
+// Credential is a token along with what its holder may do. If Tenant is set, the holder may only
+// act on that tenant, and requests which do not name a tenant are taken to be for it.
+type Credential struct {
+	Name   string
+	Token  string
+	Role   string
+	Tenant string
+}
+
+// Validate returns an error if the credential is incomplete or names an unknown role or an
+// invalid tenant.
+func (c Credential) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("credential must have a name")
+	}
+	if c.Token == "" {
+		return fmt.Errorf("credential [%s] must have a token", c.Name)
+	}
+	if !knownRole(c.Role) {
+		return fmt.Errorf("credential [%s] has unknown role [%s], must be one of %v", c.Name, c.Role, Roles())
+	}
+	err := tenant.Validate(c.Tenant)
+	if err != nil {
+		return fmt.Errorf("credential [%s] has invalid tenant: %v", c.Name, err)
+	}
+	return nil
+}
+
+// allows returns whether the holder of this credential may do what requires the supplied role.
+func (c Credential) allows(role string) bool {
+	return c.Role == RoleAdmin || c.Role == role
+}
+
+// Authenticator checks the tokens presented by callers against a set of credentials. An
+// authenticator without credentials lets everyone do everything, which is how clusters which do
+// not configure any tokens behave.
+type Authenticator struct {
+	logger *logrus.Logger
+
+	mutex       sync.RWMutex
+	credentials []Credential
+}
+
+// NewAuthenticator returns an authenticator accepting the supplied credentials.
+func NewAuthenticator(logger *logrus.Logger, credentials []Credential) (*Authenticator, error) {
+	result := &Authenticator{logger: logger}
+	err := result.SetCredentials(credentials)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetCredentials replaces the accepted credentials, taking effect for all subsequent requests.
+func (a *Authenticator) SetCredentials(credentials []Credential) error {
+	err := ValidateCredentials(credentials)
+	if err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.credentials = append([]Credential{}, credentials...)
+	return nil
+}
+
+// ValidateCredentials returns an error if any of the supplied credentials is invalid, or if two
+// of them share a name or a token.
+func ValidateCredentials(credentials []Credential) error {
+	names := map[string]bool{}
+	tokens := map[string]bool{}
+	for _, c := range credentials {
+		err := c.Validate()
+		if err != nil {
+			return err
+		}
+		if names[c.Name] {
+			return fmt.Errorf("duplicate credential name [%s]", c.Name)
+		}
+		if tokens[c.Token] {
+			return fmt.Errorf("credential [%s] reuses the token of another credential", c.Name)
+		}
+		names[c.Name] = true
+		tokens[c.Token] = true
+	}
+	return nil
+}
+
+// UnaryInterceptor returns a grpc interceptor which rejects calls without a valid token with
+// Unauthenticated, and calls whose token lacks the role required by the method with
+// PermissionDenied. Calls made with a token bound to a tenant only ever see that tenant.
+func (a *Authenticator) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		token := ""
+		if values := md[AuthorizationKey]; len(values) > 0 {
+			token = strings.TrimPrefix(values[0], bearerPrefix)
+		} else if values := md[ApiKeyKey]; len(values) > 0 {
+			token = values[0]
+		}
+
+		role := methodRole(info.FullMethod)
+		credential, err := a.authorize(token, role)
+		if err != nil {
+			a.logger.WithFields(logrus.Fields{"method": info.FullMethod}).WithError(err).Warnf("Denied")
+			return nil, err
+		}
+		if credential == nil || credential.Tenant == tenant.Default {
+			return handler(ctx, request)
+		}
+
+		requested := md[tenant.MetadataKey]
+		if len(requested) > 0 && (len(requested) > 1 || requested[0] != credential.Tenant) {
+			err := grpc.Errorf(codes.PermissionDenied, "credential [%s] may only access tenant [%s]", credential.Name, credential.Tenant)
+			a.logger.WithFields(logrus.Fields{"method": info.FullMethod}).WithError(err).Warnf("Denied")
+			return nil, err
+		}
+		bound := md.Copy()
+		bound[tenant.MetadataKey] = []string{credential.Tenant}
+		return handler(metadata.NewIncomingContext(ctx, bound), request)
+	}
+}
+
+// HttpHandler returns a handler which only passes requests on to the supplied handler if they
+// carry a token with the role the supplied function requires for their path. An empty role
+// makes the path accessible to everyone. Tokens are read from the authorization header, either as
+// a bearer token or as the password of basic auth, or from the x-api-key header.
+func (a *Authenticator) HttpHandler(handler http.Handler, pathRole func(path string) string) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		role := pathRole(request.URL.Path)
+		if role == "" {
+			handler.ServeHTTP(writer, request)
+			return
+		}
+
+		token := request.Header.Get(ApiKeyKey)
+		if header := request.Header.Get(AuthorizationKey); strings.HasPrefix(header, bearerPrefix) {
+			token = strings.TrimPrefix(header, bearerPrefix)
+		} else if _, password, ok := request.BasicAuth(); ok {
+			token = password
+		}
+
+		credential, err := a.authorize(token, role)
+		if err != nil {
+			a.logger.WithFields(logrus.Fields{"path": request.URL.Path}).WithError(err).Warnf("Denied")
+			if grpc.Code(err) == codes.Unauthenticated {
+				writer.Header().Set("WWW-Authenticate", `Basic realm="almanac"`)
+				http.Error(writer, err.Error(), http.StatusUnauthorized)
+			} else {
+				http.Error(writer, err.Error(), http.StatusForbidden)
+			}
+			return
+		}
+		if credential == nil || credential.Tenant == tenant.Default {
+			handler.ServeHTTP(writer, request)
+			return
+		}
+
+		if requested := request.Header.Get(tenant.HttpHeader); requested != "" && requested != credential.Tenant {
+			err := fmt.Errorf("credential [%s] may only access tenant [%s]", credential.Name, credential.Tenant)
+			a.logger.WithFields(logrus.Fields{"path": request.URL.Path}).WithError(err).Warnf("Denied")
+			http.Error(writer, err.Error(), http.StatusForbidden)
+			return
+		}
+		request.Header.Set(tenant.HttpHeader, credential.Tenant)
+		handler.ServeHTTP(writer, request)
+	})
+}
+
+// authorize returns the credential holding the supplied token if it allows the supplied role,
+// and a grpc error otherwise. Returns nil without error if there are no credentials at all.
+func (a *Authenticator) authorize(token string, role string) (*Credential, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	if len(a.credentials) == 0 {
+		return nil, nil
+	}
+	if token == "" {
+		return nil, grpc.Errorf(codes.Unauthenticated, "missing token")
+	}
+
+	// Compare against every token in constant time, so that the time taken does not reveal how
+	// much of a token was right.
+	var match *Credential
+	for i := range a.credentials {
+		if subtle.ConstantTimeCompare([]byte(a.credentials[i].Token), []byte(token)) == 1 {
+			match = &a.credentials[i]
+		}
+	}
+	if match == nil {
+		return nil, grpc.Errorf(codes.Unauthenticated, "unknown token")
+	}
+	if !match.allows(role) {
+		return nil, grpc.Errorf(codes.PermissionDenied, "credential [%s] has role [%s], but [%s] is required", match.Name, match.Role, role)
+	}
+	result := *match
+	return &result, nil
+}
+
+// NewTokenCredentials returns per-call credentials which present the supplied token as a bearer
+// token. The token is sent even over connections without transport security.
+func NewTokenCredentials(token string) credentials.PerRPCCredentials {
+	return tokenCredentials(token)
+}
+
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{AuthorizationKey: bearerPrefix + string(t)}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+func methodRole(fullMethod string) string {
+	if role, ok := methodRoles[fullMethod]; ok {
+		return role
+	}
+	return RoleAdmin
+}
+
+func knownRole(role string) bool {
+	for _, r := range Roles() {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dinowernli/almanac/pkg/tenant"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	ingestMethod = "/almanac.Ingester/Ingest"
+	searchMethod = "/almanac.Mixer/Search"
+	purgeMethod  = "/almanac.Admin/Purge"
+)
+
+var (
+	testCredentials = []Credential{
+		{Name: "writer", Token: "writer-token", Role: RoleIngest},
+		{Name: "reader", Token: "reader-token", Role: RoleRead},
+		{Name: "root", Token: "root-token", Role: RoleAdmin},
+		{Name: "acme", Token: "acme-token", Role: RoleRead, Tenant: "acme"},
+	}
+)
+
+func TestValidateCredentials(t *testing.T) {
+	assert.NoError(t, ValidateCredentials(testCredentials))
+	assert.NoError(t, ValidateCredentials(nil))
+
+	assert.Error(t, ValidateCredentials([]Credential{{Name: "a", Token: "t", Role: "owner"}}))
+	assert.Error(t, ValidateCredentials([]Credential{{Name: "a", Role: RoleRead}}))
+	assert.Error(t, ValidateCredentials([]Credential{{Name: "a", Token: "t", Role: RoleRead, Tenant: "Acme"}}))
+	assert.Error(t, ValidateCredentials([]Credential{{Name: "a", Token: "t", Role: RoleRead}, {Name: "a", Token: "u", Role: RoleRead}}))
+	assert.Error(t, ValidateCredentials([]Credential{{Name: "a", Token: "t", Role: RoleRead}, {Name: "b", Token: "t", Role: RoleRead}}))
+}
+
+func TestInterceptorWithoutCredentials(t *testing.T) {
+	a := newTestAuthenticator(t, nil)
+	_, err := call(a, context.Background(), purgeMethod)
+	assert.NoError(t, err)
+}
+
+func TestInterceptorChecksRoles(t *testing.T) {
+	a := newTestAuthenticator(t, testCredentials)
+
+	_, err := call(a, context.Background(), ingestMethod)
+	assert.Equal(t, codes.Unauthenticated, grpc.Code(err))
+	_, err = call(a, withToken("wrong-token"), ingestMethod)
+	assert.Equal(t, codes.Unauthenticated, grpc.Code(err))
+
+	_, err = call(a, withToken("writer-token"), ingestMethod)
+	assert.NoError(t, err)
+	_, err = call(a, withToken("writer-token"), searchMethod)
+	assert.Equal(t, codes.PermissionDenied, grpc.Code(err))
+
+	_, err = call(a, withToken("reader-token"), searchMethod)
+	assert.NoError(t, err)
+	_, err = call(a, withToken("reader-token"), purgeMethod)
+	assert.Equal(t, codes.PermissionDenied, grpc.Code(err))
+
+	for _, method := range []string{ingestMethod, searchMethod, purgeMethod} {
+		_, err = call(a, withToken("root-token"), method)
+		assert.NoError(t, err, method)
+	}
+
+	// Api keys work just like bearer tokens.
+	apiKey := metadata.NewIncomingContext(context.Background(), metadata.Pairs(ApiKeyKey, "reader-token"))
+	_, err = call(a, apiKey, searchMethod)
+	assert.NoError(t, err)
+}
+
+func TestInterceptorBindsTenant(t *testing.T) {
+	a := newTestAuthenticator(t, testCredentials)
+
+	tenantId, err := call(a, withToken("acme-token"), searchMethod)
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", tenantId)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(AuthorizationKey, "Bearer acme-token", tenant.MetadataKey, "acme"))
+	tenantId, err = call(a, ctx, searchMethod)
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", tenantId)
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(AuthorizationKey, "Bearer acme-token", tenant.MetadataKey, "other"))
+	_, err = call(a, ctx, searchMethod)
+	assert.Equal(t, codes.PermissionDenied, grpc.Code(err))
+
+	// Tokens without a tenant may act on any tenant.
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(AuthorizationKey, "Bearer root-token", tenant.MetadataKey, "other"))
+	tenantId, err = call(a, ctx, searchMethod)
+	assert.NoError(t, err)
+	assert.Equal(t, "other", tenantId)
+}
+
+func TestSetCredentials(t *testing.T) {
+	a := newTestAuthenticator(t, testCredentials)
+	assert.Error(t, a.SetCredentials([]Credential{{Name: "a", Role: RoleRead}}))
+
+	_, err := call(a, withToken("reader-token"), searchMethod)
+	assert.NoError(t, err)
+
+	assert.NoError(t, a.SetCredentials([]Credential{{Name: "reader", Token: "new-token", Role: RoleRead}}))
+	_, err = call(a, withToken("reader-token"), searchMethod)
+	assert.Equal(t, codes.Unauthenticated, grpc.Code(err))
+	_, err = call(a, withToken("new-token"), searchMethod)
+	assert.NoError(t, err)
+}
+
+func TestHttpHandler(t *testing.T) {
+	a := newTestAuthenticator(t, testCredentials)
+	pathRole := func(path string) string {
+		if path == "/public" {
+			return ""
+		}
+		return RoleRead
+	}
+	handler := a.HttpHandler(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Write([]byte(request.Header.Get(tenant.HttpHeader)))
+	}), pathRole)
+
+	serve := func(path string, setup func(*http.Request)) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("GET", path, nil)
+		if setup != nil {
+			setup(request)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	assert.Equal(t, http.StatusOK, serve("/public", nil).Code)
+
+	response := serve("/page", nil)
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	assert.NotEmpty(t, response.Header().Get("WWW-Authenticate"))
+
+	assert.Equal(t, http.StatusOK, serve("/page", func(r *http.Request) { r.Header.Set("Authorization", "Bearer reader-token") }).Code)
+	assert.Equal(t, http.StatusOK, serve("/page", func(r *http.Request) { r.SetBasicAuth("anyone", "reader-token") }).Code)
+	assert.Equal(t, http.StatusOK, serve("/page", func(r *http.Request) { r.Header.Set("X-Api-Key", "reader-token") }).Code)
+	assert.Equal(t, http.StatusForbidden, serve("/page", func(r *http.Request) { r.Header.Set("X-Api-Key", "writer-token") }).Code)
+
+	response = serve("/page", func(r *http.Request) { r.Header.Set("X-Api-Key", "acme-token") })
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "acme", response.Body.String())
+
+	response = serve("/page", func(r *http.Request) {
+		r.Header.Set("X-Api-Key", "acme-token")
+		r.Header.Set(tenant.HttpHeader, "other")
+	})
+	assert.Equal(t, http.StatusForbidden, response.Code)
+}
+
+func TestTokenCredentials(t *testing.T) {
+	md, err := NewTokenCredentials("some-token").GetRequestMetadata(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{AuthorizationKey: "Bearer some-token"}, md)
+}
+
+func newTestAuthenticator(t *testing.T, credentials []Credential) *Authenticator {
+	result, err := NewAuthenticator(logrus.New(), credentials)
+	assert.NoError(t, err)
+	return result
+}
+
+func withToken(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(AuthorizationKey, "Bearer "+token))
+}
+
+// call runs the interceptor of the supplied authenticator for the supplied method, returning the
+// tenant the handler would have served.
+func call(a *Authenticator, ctx context.Context, method string) (string, error) {
+	result, err := a.UnaryInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, request interface{}) (interface{}, error) {
+		return tenant.Resolve(ctx, "")
+	})
+	if err != nil {
+		return "", err
+	}
+	return result.(string), nil
+}
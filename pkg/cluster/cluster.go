@@ -3,51 +3,215 @@ package cluster
 import (
 	"fmt"
 	"net"
+	"strings"
 	"time"
 
+	"github.com/dinowernli/almanac/pkg/auth"
+	"github.com/dinowernli/almanac/pkg/mtls"
+	"github.com/dinowernli/almanac/pkg/service/admin"
 	"github.com/dinowernli/almanac/pkg/service/appender"
 	dc "github.com/dinowernli/almanac/pkg/service/discovery"
 	in "github.com/dinowernli/almanac/pkg/service/ingester"
 	"github.com/dinowernli/almanac/pkg/service/janitor"
 	mx "github.com/dinowernli/almanac/pkg/service/mixer"
 	st "github.com/dinowernli/almanac/pkg/storage"
+	"github.com/dinowernli/almanac/pkg/util"
 	pb_almanac "github.com/dinowernli/almanac/proto"
 
 	"github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
+// The roles a single process can run as.
+const (
+	RoleAll      = "all"
+	RoleAppender = "appender"
+	RoleIngester = "ingester"
+	RoleMixer    = "mixer"
+	RoleJanitor  = "janitor"
+)
+
+// Roles returns the names of all roles a process can run as.
+func Roles() []string {
+	return []string{RoleAll, RoleAppender, RoleIngester, RoleMixer, RoleJanitor}
+}
+
 // Config holds a few configurable values defining the behavior of the system.
 type Config struct {
 	SmallChunkMaxEntries int
 	SmallChunkSpread     time.Duration
 	SmallChunkMaxAge     time.Duration
 
-	BigChunkMaxSpread time.Duration
+	// StreamLabels names the entry fields by which appenders separate entries into chunks.
+	StreamLabels []string
+
+	// AppenderMaxEntries and AppenderMaxBytes bound the entries each appender holds in memory.
+	AppenderMaxEntries int
+	AppenderMaxBytes   int64
+
+	// AppenderSpillDir is a local directory holding chunks which the appenders could not write to
+	// storage, drained every SpillDrainInterval. Empty disables spilling, in which case appenders
+	// keep retrying such chunks.
+	AppenderSpillDir   string
+	SpillDrainInterval time.Duration
+
+	// IngestLimits bound what each tenant may ingest, unless the tenant has limits of its own in
+	// TenantIngestLimits. If IngestLimitLabels is set, the limits apply to each stream of a tenant.
+	IngestLimits       in.Limits
+	TenantIngestLimits map[string]in.Limits
+	IngestLimitLabels  []string
+
+	// Quotas bound how many bytes each tenant may store per day, checked by the janitor.
+	Quotas janitor.Quotas
+
+	// BigChunkLevels defines the tiers into which the janitor compacts chunks, ordered by
+	// increasing spread.
+	BigChunkLevels []janitor.Level
+
+	// BigChunkSettleDelay is how long after their end chunks become eligible for compaction.
+	BigChunkSettleDelay time.Duration
 
 	JanitorCompactionInterval time.Duration
+	JanitorNumWorkers         int
+	JanitorMaxCompactionBytes int64
+
+	// JanitorScrubInterval is how frequently the janitor checks the consistency of storage. Zero
+	// disables scrubbing. Problems are only repaired if JanitorScrubRepair is set.
+	JanitorScrubInterval time.Duration
+	JanitorScrubRepair   bool
+
+	StorageType  string
+	StorageCodec string
+	GcsBucket    string
+	DiskPath     string
+
+	// DiskReuse allows disk storage to start out holding chunks, e.g., because several
+	// processes share the same directory. Otherwise, the directory must be empty.
+	DiskReuse bool
+
+	// AppenderAddresses lists the appenders talked to by processes which do not run the
+	// appenders themselves. Ignored if AppenderDns is set.
+	AppenderAddresses []string
+
+	// AppenderDns is a host and port which resolves to all appenders, e.g., a headless
+	// kubernetes service. It is resolved again every DiscoveryRefreshInterval.
+	AppenderDns              string
+	DiscoveryRefreshInterval time.Duration
+
+	// Credentials lists the tokens accepted by all services. If empty, everyone may do everything.
+	// ClientToken is the token presented by this process when calling the appenders.
+	Credentials []auth.Credential
+	ClientToken string
+
+	// TlsCertFile and TlsKeyFile hold the certificate served to external clients, i.e., on the
+	// api, admin and http ports. If unset, these ports serve plaintext.
+	TlsCertFile string
+	TlsKeyFile  string
+
+	// EnablePurge is whether the admin service accepts purges.
+	EnablePurge bool
 
-	StorageType string
-	GcsBucket   string
-	DiskPath    string
+	// InternalTls holds the files used for mutual tls between the appenders and the processes
+	// calling them, checked for changes every InternalTlsReloadInterval. If its files are unset,
+	// these connections are plaintext.
+	InternalTls               mtls.Files
+	InternalTlsReloadInterval time.Duration
 }
 
 // LocalCluster holds a test setup ready to use for testing.
 type LocalCluster struct {
 	Mixer    *mx.Mixer
 	Ingester *in.Ingester
+	Admin    *admin.Admin
 
-	Janitor   *janitor.Janitor
-	Appenders []*appender.Appender
-	Storage   *st.Storage
-	Discovery *dc.Discovery
+	Janitor       *janitor.Janitor
+	Appenders     []*appender.Appender
+	Storage       *st.Storage
+	Discovery     *dc.Discovery
+	Authenticator *auth.Authenticator
 
 	servers []*grpc.Server
 }
 
 // CreateCluster sets up a test cluster, including all services required to run the system.
 func CreateCluster(ctx context.Context, logger *logrus.Logger, config *Config, appenderPorts []int, ingestFanout int) (*LocalCluster, error) {
+	storage, err := CreateStorage(config)
+	if err != nil {
+		return nil, err
+	}
+
+	spill, err := CreateSpill(ctx, logger, config, storage)
+	if err != nil {
+		return nil, err
+	}
+
+	authenticator, err := CreateAuthenticator(logger, config)
+	if err != nil {
+		return nil, err
+	}
+
+	reloader, err := CreateReloader(ctx, logger, config)
+	if err != nil {
+		return nil, err
+	}
+
+	appenders := []*appender.Appender{}
+	servers := []*grpc.Server{}
+	appenderAddresses := []string{}
+	for _, port := range appenderPorts {
+		appender, err := appender.New(logger, storage, config.StreamLabels, config.SmallChunkMaxEntries, config.SmallChunkSpread, config.SmallChunkMaxAge, config.AppenderMaxEntries, config.AppenderMaxBytes, spill)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create appender %d: %v", port, err)
+		}
+
+		server, address, err := startAppenderServer(appender, port, CreateAppenderServerOptions(authenticator, reloader))
+		if err != nil {
+			return nil, fmt.Errorf("unable to start appender %d: %v", port, err)
+		}
+		servers = append(servers, server)
+		appenders = append(appenders, appender)
+		appenderAddresses = append(appenderAddresses, address)
+
+		logger.Infof("Started appender at address: %s", address)
+	}
+
+	discovery, err := dc.New(appenderAddresses, CreateDialOptions(config, reloader))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create discovery: %v", err)
+	}
+
+	limiter, err := CreateLimiter(config)
+	if err != nil {
+		return nil, err
+	}
+	ingester, err := in.New(logger, discovery, ingestFanout, limiter)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create ingester: %v", err)
+	}
+
+	janitor, err := CreateJanitor(ctx, logger, config, storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalCluster{
+		Appenders:     appenders,
+		Ingester:      ingester,
+		Janitor:       janitor,
+		Storage:       storage,
+		Discovery:     discovery,
+		Authenticator: authenticator,
+		Mixer:         mx.New(logger, storage, discovery),
+		Admin:         admin.New(logger, storage, config.EnablePurge),
+
+		servers: servers,
+	}, nil
+}
+
+// CreateStorage returns the storage described by the supplied config.
+func CreateStorage(config *Config) (*st.Storage, error) {
 	var err error
 	var storage *st.Storage
 	if config.StorageType == st.StorageTypeMemory {
@@ -60,6 +224,11 @@ func CreateCluster(ctx context.Context, logger *logrus.Logger, config *Config, a
 		if err != nil {
 			return nil, fmt.Errorf("unable to create gcs storage: %v", err)
 		}
+	} else if config.StorageType == st.StorageTypeDisk && config.DiskReuse {
+		storage, err = st.OpenDiskStorage(config.DiskPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open disk storage: %v", err)
+		}
 	} else if config.StorageType == st.StorageTypeDisk {
 		storage, err = st.NewDiskStorage(config.DiskPath)
 		if err != nil {
@@ -69,51 +238,144 @@ func CreateCluster(ctx context.Context, logger *logrus.Logger, config *Config, a
 		return nil, fmt.Errorf("unrecognized storage type: %s", config.StorageType)
 	}
 
-	appenders := []*appender.Appender{}
-	servers := []*grpc.Server{}
-	appenderAddresses := []string{}
-	for _, port := range appenderPorts {
-		appender, err := appender.New(logger, storage, config.SmallChunkMaxEntries, config.SmallChunkSpread, config.SmallChunkMaxAge)
+	if config.StorageCodec != "" {
+		err = storage.SetCodec(config.StorageCodec)
 		if err != nil {
-			return nil, fmt.Errorf("unable to create appender %d: %v", port, err)
+			return nil, fmt.Errorf("unable to set storage codec: %v", err)
 		}
+	}
+
+	return storage, nil
+}
+
+// CreateSpill returns the spill shared by the appenders of a process, or nil if spilling is
+// disabled in the supplied config.
+func CreateSpill(ctx context.Context, logger *logrus.Logger, config *Config, storage *st.Storage) (*appender.Spill, error) {
+	if config.AppenderSpillDir == "" {
+		return nil, nil
+	}
+	spill, err := appender.NewSpill(ctx, logger, storage, config.AppenderSpillDir, config.SpillDrainInterval)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create spill: %v", err)
+	}
+	return spill, nil
+}
 
-		server, address, err := startAppenderServer(appender, port)
+// CreateDiscovery returns a discovery which finds the appenders described by the supplied config.
+func CreateDiscovery(ctx context.Context, logger *logrus.Logger, config *Config) (*dc.Discovery, error) {
+	reloader, err := CreateReloader(ctx, logger, config)
+	if err != nil {
+		return nil, err
+	}
+	dialOptions := CreateDialOptions(config, reloader)
+
+	if config.AppenderDns != "" {
+		discovery, err := dc.NewFromDns(ctx, logger, config.AppenderDns, config.DiscoveryRefreshInterval, dialOptions)
 		if err != nil {
-			return nil, fmt.Errorf("unable to start appender %d: %v", port, err)
+			return nil, fmt.Errorf("unable to create dns discovery: %v", err)
 		}
-		servers = append(servers, server)
-		appenders = append(appenders, appender)
-		appenderAddresses = append(appenderAddresses, address)
+		return discovery, nil
+	}
+	if len(config.AppenderAddresses) == 0 {
+		return nil, fmt.Errorf("must supply appender addresses or an appender dns name")
+	}
+	discovery, err := dc.New(config.AppenderAddresses, dialOptions)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create discovery: %v", err)
+	}
+	return discovery, nil
+}
 
-		logger.Infof("Started appender at address: %s", address)
+// CreateReloader returns the reloader holding the internal tls files of the supplied config, or
+// nil if internal connections are plaintext.
+func CreateReloader(ctx context.Context, logger *logrus.Logger, config *Config) (*mtls.Reloader, error) {
+	if config.InternalTls.CertFile == "" {
+		return nil, nil
 	}
+	result, err := mtls.NewReloader(ctx, logger, config.InternalTls, config.InternalTlsReloadInterval)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load internal tls files: %v", err)
+	}
+	return result, nil
+}
+
+// CreateAppenderServerOptions returns the options for the grpc servers of appenders, which check
+// tokens using the supplied authenticator and, if the supplied reloader is not nil, require
+// mutual tls.
+func CreateAppenderServerOptions(authenticator *auth.Authenticator, reloader *mtls.Reloader) []grpc.ServerOption {
+	result := []grpc.ServerOption{grpc.UnaryInterceptor(authenticator.UnaryInterceptor())}
+	if reloader != nil {
+		result = append(result, grpc.Creds(reloader.ServerCredentials()))
+	}
+	return result
+}
 
-	discovery, err := dc.New(appenderAddresses)
+// CreateAuthenticator returns an authenticator accepting the credentials of the supplied config.
+func CreateAuthenticator(logger *logrus.Logger, config *Config) (*auth.Authenticator, error) {
+	result, err := auth.NewAuthenticator(logger, config.Credentials)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create discovery: %v", err)
+		return nil, fmt.Errorf("unable to create authenticator: %v", err)
+	}
+	return result, nil
+}
+
+// CreateServerOptions returns the options for grpc servers facing external clients, which check
+// tokens using the supplied authenticator and serve the configured certificate, if any.
+func CreateServerOptions(config *Config, authenticator *auth.Authenticator) ([]grpc.ServerOption, error) {
+	result := []grpc.ServerOption{
+		grpc.UnaryInterceptor(authenticator.UnaryInterceptor()),
+
+		// Otlp exporters such as the opentelemetry collector gzip their requests by default.
+		grpc.RPCDecompressor(grpc.NewGZIPDecompressor()),
+	}
+	if config.TlsCertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(config.TlsCertFile, config.TlsKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load tls certificate: %v", err)
+		}
+		result = append(result, grpc.Creds(creds))
+	}
+	return result, nil
+}
+
+// CreateDialOptions returns the options with which processes dial the appenders. If the supplied
+// reloader is not nil, connections use mutual tls and only talk to appenders.
+func CreateDialOptions(config *Config, reloader *mtls.Reloader) []grpc.DialOption {
+	result := []grpc.DialOption{grpc.WithInsecure()}
+	if reloader != nil {
+		result = []grpc.DialOption{grpc.WithTransportCredentials(reloader.ClientCredentials(mtls.IdentityAppender))}
+	}
+	if config.ClientToken != "" {
+		result = append(result, grpc.WithPerRPCCredentials(auth.NewTokenCredentials(config.ClientToken)))
 	}
+	return result
+}
 
-	ingester, err := in.New(logger, discovery, ingestFanout)
+// CreateJanitor returns a janitor for the supplied storage, configured by the supplied config.
+func CreateJanitor(ctx context.Context, logger *logrus.Logger, config *Config, storage *st.Storage) (*janitor.Janitor, error) {
+	policy, err := janitor.NewTieredPolicy(config.BigChunkLevels, config.BigChunkSettleDelay)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create ingester: %v", err)
+		return nil, fmt.Errorf("unable to create compaction policy: %v", err)
 	}
 
-	janitor, err := janitor.New(ctx, logger, storage, config.JanitorCompactionInterval, config.BigChunkMaxSpread)
+	result, err := janitor.New(ctx, logger, storage, config.JanitorCompactionInterval, policy, config.JanitorNumWorkers, config.JanitorMaxCompactionBytes, config.JanitorScrubInterval, config.JanitorScrubRepair)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create janitor: %v", err)
 	}
+	err = result.SetQuotas(config.Quotas)
+	if err != nil {
+		return nil, fmt.Errorf("unable to set janitor quotas: %v", err)
+	}
+	return result, nil
+}
 
-	return &LocalCluster{
-		Appenders: appenders,
-		Ingester:  ingester,
-		Janitor:   janitor,
-		Storage:   storage,
-		Discovery: discovery,
-		Mixer:     mx.New(logger, storage, discovery),
-
-		servers: servers,
-	}, nil
+// CreateLimiter returns the limiter applying the ingestion limits of the supplied config.
+func CreateLimiter(config *Config) (*in.Limiter, error) {
+	result, err := in.NewLimiter(config.IngestLimits, config.TenantIngestLimits, config.IngestLimitLabels)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create limiter: %v", err)
+	}
+	return result, nil
 }
 
 // Stop stops all the servers running as part of this local cluster.
@@ -123,13 +385,46 @@ func (c *LocalCluster) Stop() {
 	}
 }
 
-func startAppenderServer(appender *appender.Appender, port int) (*grpc.Server, string, error) {
+// Shutdown stops the cluster in an orderly fashion: the appenders stop accepting entries and
+// flush their open chunks to storage while the janitor stops once it is done with the work in
+// progress. Returns an error if the supplied context is done before all of this has happened.
+func (c *LocalCluster) Shutdown(ctx context.Context) error {
+	for _, s := range c.servers {
+		util.GracefulStop(ctx, s)
+	}
+
+	// The janitor stops concurrently, such that a long compaction does not eat into the time the
+	// appenders have to flush their chunks.
+	janitorErr := make(chan error, 1)
+	go func() {
+		janitorErr <- c.Janitor.Stop(ctx)
+	}()
+
+	problems := []string{}
+	for i, a := range c.Appenders {
+		err := a.Close(ctx)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("appender %d: %v", i, err))
+		}
+	}
+	err := <-janitorErr
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("janitor: %v", err))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("unable to shut down cleanly: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func startAppenderServer(appender *appender.Appender, port int, options []grpc.ServerOption) (*grpc.Server, string, error) {
 	listen, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to listen for port %d: %v", port, err)
 	}
 
-	server := grpc.NewServer()
+	server := grpc.NewServer(options...)
 	pb_almanac.RegisterAppenderServer(server, appender)
 	go func() {
 		server.Serve(listen)
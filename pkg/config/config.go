@@ -0,0 +1,498 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/dinowernli/almanac/pkg/auth"
+	"github.com/dinowernli/almanac/pkg/cluster"
+	"github.com/dinowernli/almanac/pkg/mtls"
+	in "github.com/dinowernli/almanac/pkg/service/ingester"
+	"github.com/dinowernli/almanac/pkg/service/janitor"
+	st "github.com/dinowernli/almanac/pkg/storage"
+	"github.com/dinowernli/almanac/pkg/tenant"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// File is the structure of a configuration file. Files can be written in yaml or json, and any
+// value not present in a file takes its default value.
+type File struct {
+	// LogLevel is the minimum level of the messages logged, e.g., "info" or "debug".
+	LogLevel string `yaml:"log_level"`
+
+	Storage   Storage   `yaml:"storage"`
+	Discovery Discovery `yaml:"discovery"`
+	Ports     Ports     `yaml:"ports"`
+	Ingester  Ingester  `yaml:"ingester"`
+	Appender  Appender  `yaml:"appender"`
+	Janitor   Janitor   `yaml:"janitor"`
+	Limits    Limits    `yaml:"limits"`
+	Auth      Auth      `yaml:"auth"`
+	Tls       Tls       `yaml:"tls"`
+	Syslog    Syslog    `yaml:"syslog"`
+	Fluent    Fluent    `yaml:"fluent"`
+	Admin     Admin     `yaml:"admin"`
+
+	InternalTls InternalTls `yaml:"internal_tls"`
+}
+
+// Storage configures where chunks are stored.
+type Storage struct {
+	Type      string `yaml:"type"`
+	Codec     string `yaml:"codec"`
+	GcsBucket string `yaml:"gcs_bucket"`
+	DiskPath  string `yaml:"disk_path"`
+}
+
+// Discovery configures how processes find the appenders.
+type Discovery struct {
+	Appenders       []string `yaml:"appenders"`
+	AppenderDns     string   `yaml:"appender_dns"`
+	RefreshInterval Duration `yaml:"refresh_interval"`
+}
+
+// Ports configures which ports each role serves on.
+type Ports struct {
+	Http      int   `yaml:"http"`
+	Api       int   `yaml:"api"`
+	Admin     int   `yaml:"admin"`
+	Appenders []int `yaml:"appenders"`
+}
+
+// Ingester configures the ingesters.
+type Ingester struct {
+	Fanout int `yaml:"fanout"`
+}
+
+// Appender configures the open chunks held by appenders, and how much they hold in memory before
+// rejecting new entries. Entries are kept in separate chunks per distinct value of the fields named
+// in StreamLabels. Chunks which cannot be written to storage are kept in SpillDir, if set.
+type Appender struct {
+	StreamLabels       []string `yaml:"stream_labels"`
+	MaxChunkEntries    int      `yaml:"max_chunk_entries"`
+	MaxChunkSpread     Duration `yaml:"max_chunk_spread"`
+	MaxChunkAge        Duration `yaml:"max_chunk_age"`
+	MaxMemoryEntries   int      `yaml:"max_memory_entries"`
+	MaxMemoryBytes     int64    `yaml:"max_memory_bytes"`
+	SpillDir           string   `yaml:"spill_dir"`
+	SpillDrainInterval Duration `yaml:"spill_drain_interval"`
+}
+
+// Janitor configures compactions and scrubs.
+type Janitor struct {
+	CompactionInterval Duration `yaml:"compaction_interval"`
+	NumWorkers         int      `yaml:"num_workers"`
+	MaxCompactionBytes int64    `yaml:"max_compaction_bytes"`
+	Levels             []Level  `yaml:"levels"`
+	SettleDelay        Duration `yaml:"settle_delay"`
+	ScrubInterval      Duration `yaml:"scrub_interval"`
+	ScrubRepair        bool     `yaml:"scrub_repair"`
+}
+
+// Limits configures how much each tenant may ingest and store. Tenants listed under Tenants get
+// exactly the limits given there, all others get the Default limits. Zero values mean unlimited.
+// If StreamLabels is set, the ingestion rates apply to each distinct combination of values of the
+// named fields within a tenant.
+type Limits struct {
+	StreamLabels []string                `yaml:"stream_labels"`
+	Default      TenantLimits            `yaml:"default"`
+	Tenants      map[string]TenantLimits `yaml:"tenants"`
+}
+
+// TenantLimits configures the limits of a single tenant. The ingesters reject entries beyond the
+// rates and sizes, while the janitor reports tenants which store more than DailyBytes per day.
+type TenantLimits struct {
+	EntriesPerSecond float64 `yaml:"entries_per_second"`
+	BytesPerSecond   float64 `yaml:"bytes_per_second"`
+	MaxEntryBytes    int     `yaml:"max_entry_bytes"`
+	DailyBytes       int64   `yaml:"daily_bytes"`
+}
+
+// Auth configures which tokens callers must present. If Tokens is empty, everyone may do
+// everything. Otherwise, ClientToken is presented by each process when calling the appenders and
+// must belong to an admin token.
+type Auth struct {
+	Tokens      []Token `yaml:"tokens"`
+	ClientToken string  `yaml:"client_token"`
+}
+
+// Token configures a single token, see auth.Credential.
+type Token struct {
+	Name   string `yaml:"name"`
+	Token  string `yaml:"token"`
+	Role   string `yaml:"role"`
+	Tenant string `yaml:"tenant"`
+}
+
+// Tls configures the certificate served on the api, admin and http ports.
+type Tls struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// InternalTls configures mutual tls between the appenders and the processes calling them. All
+// certificates must be issued by an authority in CaFile, and appenders must have "appender" among
+// the organizational units of their certificate. The files are checked for changes every
+// ReloadInterval.
+type InternalTls struct {
+	CertFile       string   `yaml:"cert_file"`
+	KeyFile        string   `yaml:"key_file"`
+	CaFile         string   `yaml:"ca_file"`
+	ReloadInterval Duration `yaml:"reload_interval"`
+}
+
+// Syslog configures the syslog receiver of the ingesters. Each port left at zero is not served,
+// and TlsPort serves the certificate configured under tls. Senders are not authenticated, and
+// all messages are ingested into Tenant.
+type Syslog struct {
+	UdpPort int    `yaml:"udp_port"`
+	TcpPort int    `yaml:"tcp_port"`
+	TlsPort int    `yaml:"tls_port"`
+	Tenant  string `yaml:"tenant"`
+}
+
+// Fluent configures the receiver of the ingesters for the forward protocol of fluentd and fluent
+// bit, usually served on port 24224. Each port left at zero is not served, and TlsPort serves the
+// certificate configured under tls. Senders are not authenticated, and all events are ingested
+// into Tenant.
+type Fluent struct {
+	Port    int    `yaml:"port"`
+	TlsPort int    `yaml:"tls_port"`
+	Tenant  string `yaml:"tenant"`
+}
+
+// Admin configures the admin service. Purges cannot be undone, so they are refused unless
+// EnablePurge is set, which requires auth tokens such that only admins can purge.
+type Admin struct {
+	EnablePurge bool `yaml:"enable_purge"`
+}
+
+// Level configures a single compaction level, see janitor.Level.
+type Level struct {
+	Spread   Duration `yaml:"spread"`
+	MinBytes int64    `yaml:"min_bytes"`
+}
+
+// Duration is a time.Duration written as a string such as "1m30s" in configuration files.
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	err := unmarshal(&value)
+	if err != nil {
+		return fmt.Errorf("expected a duration such as \"10s\": %v", err)
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %v", value, err)
+	}
+	d.Duration = parsed
+	return nil
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+// Default returns the configuration used for anything not set explicitly.
+func Default() *File {
+	return &File{
+		LogLevel: logrus.InfoLevel.String(),
+		Storage: Storage{
+			Type:      st.StorageTypeMemory,
+			Codec:     st.CodecZstd,
+			GcsBucket: "almanac-dev",
+			DiskPath:  "/tmp/almanac-dev",
+		},
+		Discovery: Discovery{
+			Appenders:       []string{},
+			RefreshInterval: Duration{10 * time.Second},
+		},
+		Ports: Ports{
+			Http:      12345,
+			Api:       5000,
+			Admin:     5100,
+			Appenders: []int{5001, 5002, 5003, 5004, 5005},
+		},
+		Ingester: Ingester{
+			Fanout: 2,
+		},
+		Appender: Appender{
+			StreamLabels:       []string{},
+			MaxChunkEntries:    10,
+			MaxChunkSpread:     Duration{5 * time.Second},
+			MaxChunkAge:        Duration{3 * time.Second},
+			MaxMemoryEntries:   1000000,
+			MaxMemoryBytes:     512 * 1024 * 1024,
+			SpillDrainInterval: Duration{30 * time.Second},
+		},
+		Janitor: Janitor{
+			CompactionInterval: Duration{10 * time.Second},
+			NumWorkers:         8,
+			MaxCompactionBytes: 256 * 1024 * 1024,
+			Levels: []Level{
+				{Spread: Duration{time.Hour}, MinBytes: 1024 * 1024},
+				{Spread: Duration{24 * time.Hour}, MinBytes: 16 * 1024 * 1024},
+			},
+			SettleDelay:   Duration{5 * time.Minute},
+			ScrubInterval: Duration{time.Hour},
+		},
+		Limits: Limits{
+			StreamLabels: []string{},
+			Tenants:      map[string]TenantLimits{},
+		},
+		Auth: Auth{
+			Tokens: []Token{},
+		},
+		InternalTls: InternalTls{
+			ReloadInterval: Duration{time.Minute},
+		},
+	}
+}
+
+// Load reads the configuration file at the supplied path. Values not present in the file take
+// their default values. The returned configuration has not been validated.
+func Load(path string) (*File, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file %s: %v", path, err)
+	}
+	result, err := Parse(contents)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse config file %s: %v", path, err)
+	}
+	return result, nil
+}
+
+// Parse turns the supplied yaml or json contents into a configuration. Values not present in the
+// contents take their default values. Unknown fields are rejected.
+func Parse(contents []byte) (*File, error) {
+	result := Default()
+	err := yaml.UnmarshalStrict(contents, result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Validate checks that all values make sense, returning an error describing every problem.
+func (f *File) Validate() error {
+	problems := []string{}
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	_, err := logrus.ParseLevel(f.LogLevel)
+	check(err == nil, "log_level: unknown level %q", f.LogLevel)
+
+	check(contains([]string{st.StorageTypeMemory, st.StorageTypeDisk, st.StorageTypeGcs}, f.Storage.Type), "storage.type: unknown type %q", f.Storage.Type)
+	check(contains(st.Codecs(), f.Storage.Codec), "storage.codec: unknown codec %q, must be one of %v", f.Storage.Codec, st.Codecs())
+	check(f.Storage.Type != st.StorageTypeGcs || f.Storage.GcsBucket != "", "storage.gcs_bucket: must be set for gcs storage")
+	check(f.Storage.Type != st.StorageTypeDisk || f.Storage.DiskPath != "", "storage.disk_path: must be set for disk storage")
+
+	check(f.Discovery.RefreshInterval.Duration > 0, "discovery.refresh_interval: must be positive, but got %v", f.Discovery.RefreshInterval)
+
+	for name, port := range map[string]int{"http": f.Ports.Http, "api": f.Ports.Api, "admin": f.Ports.Admin} {
+		check(validPort(port), "ports.%s: invalid port %d", name, port)
+	}
+	for _, port := range f.Ports.Appenders {
+		check(validPort(port), "ports.appenders: invalid port %d", port)
+	}
+
+	check(f.Ingester.Fanout >= 1, "ingester.fanout: must be at least 1, but got %d", f.Ingester.Fanout)
+
+	seenLabels := map[string]bool{}
+	for _, label := range f.Appender.StreamLabels {
+		check(label != "", "appender.stream_labels: labels must not be empty")
+		check(!seenLabels[label], "appender.stream_labels: duplicate label %s", label)
+		seenLabels[label] = true
+	}
+	check(f.Appender.MaxChunkEntries >= 1, "appender.max_chunk_entries: must be at least 1, but got %d", f.Appender.MaxChunkEntries)
+	check(f.Appender.MaxChunkSpread.Duration > 0, "appender.max_chunk_spread: must be positive, but got %v", f.Appender.MaxChunkSpread)
+	check(f.Appender.MaxChunkAge.Duration > 0, "appender.max_chunk_age: must be positive, but got %v", f.Appender.MaxChunkAge)
+	check(f.Appender.MaxMemoryEntries >= 1, "appender.max_memory_entries: must be at least 1, but got %d", f.Appender.MaxMemoryEntries)
+	check(f.Appender.MaxMemoryBytes >= 1, "appender.max_memory_bytes: must be at least 1, but got %d", f.Appender.MaxMemoryBytes)
+	check(f.Appender.SpillDrainInterval.Duration > 0, "appender.spill_drain_interval: must be positive, but got %v", f.Appender.SpillDrainInterval)
+
+	check(f.Janitor.CompactionInterval.Duration > 0, "janitor.compaction_interval: must be positive, but got %v", f.Janitor.CompactionInterval)
+	check(f.Janitor.NumWorkers > 0, "janitor.num_workers: must be positive, but got %d", f.Janitor.NumWorkers)
+	check(f.Janitor.MaxCompactionBytes > 0, "janitor.max_compaction_bytes: must be positive, but got %d", f.Janitor.MaxCompactionBytes)
+	check(f.Janitor.SettleDelay.Duration >= 0, "janitor.settle_delay: must not be negative, but got %v", f.Janitor.SettleDelay)
+	check(f.Janitor.ScrubInterval.Duration >= 0, "janitor.scrub_interval: must not be negative, but got %v", f.Janitor.ScrubInterval)
+	_, err = janitor.NewTieredPolicy(f.bigChunkLevels(), f.Janitor.SettleDelay.Duration)
+	check(err == nil, "janitor.levels: %v", err)
+
+	seenLabels = map[string]bool{}
+	for _, label := range f.Limits.StreamLabels {
+		check(label != "", "limits.stream_labels: labels must not be empty")
+		check(!seenLabels[label], "limits.stream_labels: duplicate label %s", label)
+		seenLabels[label] = true
+	}
+	checkLimits := func(name string, l TenantLimits) {
+		check(l.EntriesPerSecond >= 0, "%s.entries_per_second: must not be negative, but got %v", name, l.EntriesPerSecond)
+		check(l.BytesPerSecond >= 0, "%s.bytes_per_second: must not be negative, but got %v", name, l.BytesPerSecond)
+		check(l.MaxEntryBytes >= 0, "%s.max_entry_bytes: must not be negative, but got %d", name, l.MaxEntryBytes)
+		check(l.DailyBytes >= 0, "%s.daily_bytes: must not be negative, but got %d", name, l.DailyBytes)
+	}
+	checkLimits("limits.default", f.Limits.Default)
+	for name, l := range f.Limits.Tenants {
+		err := tenant.Validate(name)
+		check(err == nil, "limits.tenants: %v", err)
+		checkLimits("limits.tenants."+name, l)
+	}
+
+	err = auth.ValidateCredentials(f.credentials())
+	check(err == nil, "auth.tokens: %v", err)
+	if len(f.Auth.Tokens) > 0 {
+		isAdmin := false
+		for _, t := range f.Auth.Tokens {
+			isAdmin = isAdmin || (t.Token == f.Auth.ClientToken && t.Role == auth.RoleAdmin && t.Tenant == tenant.Default)
+		}
+		check(isAdmin, "auth.client_token: must be the token of an admin without tenant when tokens are set")
+	}
+	check(!f.Admin.EnablePurge || len(f.Auth.Tokens) > 0, "admin.enable_purge: requires auth.tokens, so that only admins can purge")
+	check((f.Tls.CertFile == "") == (f.Tls.KeyFile == ""), "tls: cert_file and key_file must be set together")
+	numInternalFiles := 0
+	for _, path := range []string{f.InternalTls.CertFile, f.InternalTls.KeyFile, f.InternalTls.CaFile} {
+		if path != "" {
+			numInternalFiles++
+		}
+	}
+	check(numInternalFiles == 0 || numInternalFiles == 3, "internal_tls: cert_file, key_file and ca_file must be set together")
+	check(f.InternalTls.ReloadInterval.Duration > 0, "internal_tls.reload_interval: must be positive, but got %v", f.InternalTls.ReloadInterval)
+
+	for name, port := range map[string]int{"udp_port": f.Syslog.UdpPort, "tcp_port": f.Syslog.TcpPort, "tls_port": f.Syslog.TlsPort} {
+		check(port == 0 || validPort(port), "syslog.%s: invalid port %d", name, port)
+	}
+	check(f.Syslog.TlsPort == 0 || f.Tls.CertFile != "", "syslog.tls_port: requires tls.cert_file and tls.key_file")
+	err = tenant.Validate(f.Syslog.Tenant)
+	check(err == nil, "syslog.tenant: %v", err)
+
+	for name, port := range map[string]int{"port": f.Fluent.Port, "tls_port": f.Fluent.TlsPort} {
+		check(port == 0 || validPort(port), "fluent.%s: invalid port %d", name, port)
+	}
+	check(f.Fluent.TlsPort == 0 || f.Tls.CertFile != "", "fluent.tls_port: requires tls.cert_file and tls.key_file")
+	err = tenant.Validate(f.Fluent.Tenant)
+	check(err == nil, "fluent.tenant: %v", err)
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ClusterConfig returns the cluster config described by this configuration.
+func (f *File) ClusterConfig() *cluster.Config {
+	result := &cluster.Config{
+		SmallChunkMaxEntries: f.Appender.MaxChunkEntries,
+		SmallChunkSpread:     f.Appender.MaxChunkSpread.Duration,
+		SmallChunkMaxAge:     f.Appender.MaxChunkAge.Duration,
+		StreamLabels:         f.Appender.StreamLabels,
+		AppenderMaxEntries:   f.Appender.MaxMemoryEntries,
+		AppenderMaxBytes:     f.Appender.MaxMemoryBytes,
+		AppenderSpillDir:     f.Appender.SpillDir,
+		SpillDrainInterval:   f.Appender.SpillDrainInterval.Duration,
+		BigChunkLevels:       f.bigChunkLevels(),
+		BigChunkSettleDelay:  f.Janitor.SettleDelay.Duration,
+
+		IngestLimits:       ingestLimits(f.Limits.Default),
+		TenantIngestLimits: map[string]in.Limits{},
+		IngestLimitLabels:  f.Limits.StreamLabels,
+		Quotas:             janitor.Quotas{DefaultDailyBytes: f.Limits.Default.DailyBytes, TenantDailyBytes: map[string]int64{}},
+
+		JanitorCompactionInterval: f.Janitor.CompactionInterval.Duration,
+		JanitorNumWorkers:         f.Janitor.NumWorkers,
+		JanitorMaxCompactionBytes: f.Janitor.MaxCompactionBytes,
+		JanitorScrubInterval:      f.Janitor.ScrubInterval.Duration,
+		JanitorScrubRepair:        f.Janitor.ScrubRepair,
+
+		StorageType:  f.Storage.Type,
+		StorageCodec: f.Storage.Codec,
+		GcsBucket:    f.Storage.GcsBucket,
+		DiskPath:     f.Storage.DiskPath,
+
+		AppenderAddresses:        f.Discovery.Appenders,
+		AppenderDns:              f.Discovery.AppenderDns,
+		DiscoveryRefreshInterval: f.Discovery.RefreshInterval.Duration,
+
+		Credentials: f.credentials(),
+		ClientToken: f.Auth.ClientToken,
+		TlsCertFile: f.Tls.CertFile,
+		TlsKeyFile:  f.Tls.KeyFile,
+		EnablePurge: f.Admin.EnablePurge,
+
+		InternalTls:               mtls.Files{CertFile: f.InternalTls.CertFile, KeyFile: f.InternalTls.KeyFile, CaFile: f.InternalTls.CaFile},
+		InternalTlsReloadInterval: f.InternalTls.ReloadInterval.Duration,
+	}
+	for name, l := range f.Limits.Tenants {
+		result.TenantIngestLimits[name] = ingestLimits(l)
+		result.Quotas.TenantDailyBytes[name] = l.DailyBytes
+	}
+	return result
+}
+
+// RequiresRestart returns whether the updated configuration differs from the current one in any
+// setting which cannot change while running. The settings which can change are the log level,
+// the appender chunk and memory limits, the ingester fanout, the janitor's compaction levels, settle
+// delay, memory budget and whether scrubs repair problems, the limits of all tenants, and the
+// accepted tokens.
+func RequiresRestart(current *File, updated *File) bool {
+	withoutReloadable := *updated
+	withoutReloadable.LogLevel = current.LogLevel
+	withoutReloadable.Appender.MaxChunkEntries = current.Appender.MaxChunkEntries
+	withoutReloadable.Appender.MaxChunkSpread = current.Appender.MaxChunkSpread
+	withoutReloadable.Appender.MaxChunkAge = current.Appender.MaxChunkAge
+	withoutReloadable.Appender.MaxMemoryEntries = current.Appender.MaxMemoryEntries
+	withoutReloadable.Appender.MaxMemoryBytes = current.Appender.MaxMemoryBytes
+	withoutReloadable.Ingester.Fanout = current.Ingester.Fanout
+	withoutReloadable.Janitor.Levels = current.Janitor.Levels
+	withoutReloadable.Janitor.SettleDelay = current.Janitor.SettleDelay
+	withoutReloadable.Janitor.MaxCompactionBytes = current.Janitor.MaxCompactionBytes
+	withoutReloadable.Janitor.ScrubRepair = current.Janitor.ScrubRepair
+	withoutReloadable.Limits.Default = current.Limits.Default
+	withoutReloadable.Limits.Tenants = current.Limits.Tenants
+	withoutReloadable.Auth.Tokens = current.Auth.Tokens
+	return !reflect.DeepEqual(&withoutReloadable, current)
+}
+
+func (f *File) credentials() []auth.Credential {
+	result := []auth.Credential{}
+	for _, t := range f.Auth.Tokens {
+		result = append(result, auth.Credential{Name: t.Name, Token: t.Token, Role: t.Role, Tenant: t.Tenant})
+	}
+	return result
+}
+
+func ingestLimits(l TenantLimits) in.Limits {
+	return in.Limits{EntriesPerSecond: l.EntriesPerSecond, BytesPerSecond: l.BytesPerSecond, MaxEntryBytes: l.MaxEntryBytes}
+}
+
+func (f *File) bigChunkLevels() []janitor.Level {
+	result := []janitor.Level{}
+	for _, l := range f.Janitor.Levels {
+		result = append(result, janitor.Level{Spread: l.Spread.Duration, MinBytes: l.MinBytes})
+	}
+	return result
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dinowernli/almanac/pkg/auth"
+	st "github.com/dinowernli/almanac/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	yamlConfig = `
+log_level: debug
+storage:
+  type: disk
+  disk_path: /var/almanac
+appender:
+  stream_labels: [service, env]
+  max_chunk_entries: 100
+  max_chunk_age: 1m
+janitor:
+  levels:
+  - spread: 1h
+    min_bytes: 1024
+limits:
+  default:
+    entries_per_second: 100
+    daily_bytes: 1048576
+  tenants:
+    acme:
+      bytes_per_second: 2048
+      max_entry_bytes: 512
+`
+
+	jsonConfig = `{
+  "storage": {"type": "gcs", "gcs_bucket": "some-bucket"},
+  "ingester": {"fanout": 3}
+}`
+)
+
+func TestDefaultIsValid(t *testing.T) {
+	assert.NoError(t, Default().Validate())
+}
+
+func TestParseYaml(t *testing.T) {
+	file, err := Parse([]byte(yamlConfig))
+	assert.NoError(t, err)
+	assert.NoError(t, file.Validate())
+
+	assert.Equal(t, "debug", file.LogLevel)
+	assert.Equal(t, st.StorageTypeDisk, file.Storage.Type)
+	assert.Equal(t, "/var/almanac", file.Storage.DiskPath)
+	assert.Equal(t, []string{"service", "env"}, file.Appender.StreamLabels)
+	assert.Equal(t, 100, file.Appender.MaxChunkEntries)
+	assert.Equal(t, time.Minute, file.Appender.MaxChunkAge.Duration)
+	assert.Equal(t, []Level{{Spread: Duration{time.Hour}, MinBytes: 1024}}, file.Janitor.Levels)
+	assert.Equal(t, TenantLimits{EntriesPerSecond: 100, DailyBytes: 1048576}, file.Limits.Default)
+	assert.Equal(t, map[string]TenantLimits{"acme": {BytesPerSecond: 2048, MaxEntryBytes: 512}}, file.Limits.Tenants)
+
+	// Values not present in the file keep their defaults.
+	assert.Equal(t, Default().Appender.MaxChunkSpread, file.Appender.MaxChunkSpread)
+	assert.Equal(t, Default().Ports, file.Ports)
+}
+
+func TestParseJson(t *testing.T) {
+	file, err := Parse([]byte(jsonConfig))
+	assert.NoError(t, err)
+	assert.NoError(t, file.Validate())
+
+	assert.Equal(t, st.StorageTypeGcs, file.Storage.Type)
+	assert.Equal(t, "some-bucket", file.Storage.GcsBucket)
+	assert.Equal(t, 3, file.Ingester.Fanout)
+	assert.Equal(t, "some-bucket", file.ClusterConfig().GcsBucket)
+}
+
+func TestParseRejectsBadContents(t *testing.T) {
+	_, err := Parse([]byte("storage:\n  typo: disk\n"))
+	assert.Error(t, err)
+
+	_, err = Parse([]byte("appender:\n  max_chunk_age: forever\n"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "forever")
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	file := Default()
+	file.LogLevel = "chatty"
+	file.Ingester.Fanout = 0
+	file.Appender.StreamLabels = []string{"service", "service"}
+	file.Janitor.NumWorkers = -1
+	file.Janitor.Levels = []Level{}
+	file.Limits.Default.EntriesPerSecond = -1
+	file.Limits.Tenants = map[string]TenantLimits{"not a tenant": {}}
+
+	err := file.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "log_level")
+	assert.Contains(t, err.Error(), "ingester.fanout")
+	assert.Contains(t, err.Error(), "appender.stream_labels")
+	assert.Contains(t, err.Error(), "janitor.num_workers")
+	assert.Contains(t, err.Error(), "janitor.levels")
+	assert.Contains(t, err.Error(), "limits.default")
+	assert.Contains(t, err.Error(), "limits.tenants")
+}
+
+func TestValidateAuth(t *testing.T) {
+	file := Default()
+	file.Auth.Tokens = []Token{
+		{Name: "internal", Token: "secret", Role: auth.RoleAdmin},
+		{Name: "acme", Token: "acme-secret", Role: auth.RoleIngest, Tenant: "acme"},
+	}
+	assert.Error(t, file.Validate())
+
+	file.Auth.ClientToken = "acme-secret"
+	assert.Error(t, file.Validate())
+
+	file.Auth.ClientToken = "secret"
+	assert.NoError(t, file.Validate())
+	assert.Equal(t, 2, len(file.ClusterConfig().Credentials))
+
+	file.Auth.Tokens[1].Role = "owner"
+	assert.Error(t, file.Validate())
+
+	// Purges can only be enabled along with tokens.
+	file = Default()
+	file.Admin.EnablePurge = true
+	assert.Error(t, file.Validate())
+	file.Auth.Tokens = []Token{{Name: "internal", Token: "secret", Role: auth.RoleAdmin}}
+	file.Auth.ClientToken = "secret"
+	assert.NoError(t, file.Validate())
+	assert.True(t, file.ClusterConfig().EnablePurge)
+
+	file = Default()
+	file.Tls.CertFile = "/etc/almanac/cert.pem"
+	assert.Error(t, file.Validate())
+	file.Tls.KeyFile = "/etc/almanac/key.pem"
+	assert.NoError(t, file.Validate())
+}
+
+func TestValidateInternalTls(t *testing.T) {
+	file := Default()
+	file.InternalTls.CertFile = "/etc/almanac/internal.pem"
+	file.InternalTls.KeyFile = "/etc/almanac/internal.key"
+	assert.Error(t, file.Validate())
+
+	file.InternalTls.CaFile = "/etc/almanac/ca.pem"
+	assert.NoError(t, file.Validate())
+	assert.Equal(t, "/etc/almanac/ca.pem", file.ClusterConfig().InternalTls.CaFile)
+
+	file.InternalTls.ReloadInterval = Duration{0}
+	assert.Error(t, file.Validate())
+}
+
+func TestValidateSyslog(t *testing.T) {
+	file := Default()
+	file.Syslog.UdpPort = 514
+	file.Syslog.TcpPort = 514
+	file.Syslog.Tenant = "network"
+	assert.NoError(t, file.Validate())
+
+	file.Syslog.TlsPort = 6514
+	assert.Error(t, file.Validate())
+	file.Tls = Tls{CertFile: "/etc/almanac/cert.pem", KeyFile: "/etc/almanac/key.pem"}
+	assert.NoError(t, file.Validate())
+
+	file.Syslog.UdpPort = 70000
+	assert.Error(t, file.Validate())
+}
+
+func TestValidateFluent(t *testing.T) {
+	file := Default()
+	file.Fluent.Port = 24224
+	file.Fluent.Tenant = "kubernetes"
+	assert.NoError(t, file.Validate())
+
+	file.Fluent.TlsPort = 24225
+	assert.Error(t, file.Validate())
+	file.Tls = Tls{CertFile: "/etc/almanac/cert.pem", KeyFile: "/etc/almanac/key.pem"}
+	assert.NoError(t, file.Validate())
+
+	file.Fluent.Tenant = "not a tenant!"
+	assert.Error(t, file.Validate())
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "almanac-config-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "almanac.yaml")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(yamlConfig), 0644))
+
+	file, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "debug", file.LogLevel)
+
+	_, err = Load(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestRequiresRestart(t *testing.T) {
+	current := Default()
+
+	reloadable := Default()
+	reloadable.LogLevel = "debug"
+	reloadable.Appender.MaxChunkEntries = 1000
+	reloadable.Ingester.Fanout = 1
+	reloadable.Janitor.Levels = reloadable.Janitor.Levels[:1]
+	reloadable.Janitor.ScrubRepair = true
+	reloadable.Limits.Default.BytesPerSecond = 1024
+	reloadable.Limits.Tenants = map[string]TenantLimits{"acme": {DailyBytes: 1024}}
+	reloadable.Auth.Tokens = []Token{{Name: "reader", Token: "secret", Role: auth.RoleRead}}
+	assert.False(t, RequiresRestart(current, reloadable))
+
+	restart := Default()
+	restart.Ports.Api = 6000
+	assert.True(t, RequiresRestart(current, restart))
+
+	labels := Default()
+	labels.Limits.StreamLabels = []string{"service"}
+	assert.True(t, RequiresRestart(current, labels))
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// Watch reads the file at the supplied path every interval until the supplied context is done,
+// and calls onChange whenever its contents differ from the previous read. The contents are
+// compared rather than modification times, such that replacing the file through a symlink (as
+// done for kubernetes config maps) is picked up as well.
+func Watch(ctx context.Context, logger *logrus.Logger, path string, interval time.Duration, onChange func()) error {
+	if interval <= 0 {
+		return fmt.Errorf("watch interval must be positive, but got %v", interval)
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read config file %s: %v", path, err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		for {
+			select {
+			case <-ticker.C:
+				updated, err := ioutil.ReadFile(path)
+				if err != nil {
+					logger.WithError(err).Warnf("Unable to read config file %s", path)
+					continue
+				}
+				if bytes.Equal(updated, contents) {
+					continue
+				}
+				contents = updated
+				onChange()
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return nil
+}
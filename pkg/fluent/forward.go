@@ -0,0 +1,226 @@
+package fluent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	// maxChunkBytes is the size of the largest string, binary or decompressed chunk accepted.
+	maxChunkBytes = 64 * 1024 * 1024
+
+	optionChunk      = "chunk"
+	optionCompressed = "compressed"
+	compressedGzip   = "gzip"
+	compressedText   = "text"
+
+	// eventTimeType is the extension type of event times, which hold seconds and nanoseconds.
+	eventTimeType = 0
+
+	tagField       = "tag"
+	timestampField = "timestamp_ms"
+	nanosPerMilli  = 1000000
+	nanosPerSecond = 1000000000
+)
+
+// message is a single message of the forward protocol, which carries any number of events
+// sharing the same tag.
+type message struct {
+	tag string
+
+	// entries holds the undecoded events, each of them an array of time and record.
+	entries []interface{}
+
+	// chunk is the id the sender expects to be acknowledged once the events are ingested, if any.
+	chunk string
+}
+
+// parseMessage returns the message made up of the supplied value, which is in one of the modes
+// of the forward protocol:
+//
+//	Message:                 [tag, time, record, option?]
+//	Forward:                 [tag, [[time, record], ...], option?]
+//	PackedForward:           [tag, <time and record pairs in messagepack>, option?]
+//	CompressedPackedForward: as PackedForward, with the pairs gzipped and option.compressed set
+func parseMessage(value interface{}) (*message, error) {
+	array, ok := value.([]interface{})
+	if !ok || len(array) < 2 {
+		return nil, fmt.Errorf("expected an array of at least two elements")
+	}
+	tag, ok := stringOf(array[0])
+	if !ok {
+		return nil, fmt.Errorf("tag must be a string")
+	}
+
+	result := &message{tag: tag}
+	var packed []byte
+	optionIndex := 2
+	switch second := array[1].(type) {
+	case []interface{}:
+		result.entries = second
+	case string:
+		packed = []byte(second)
+	case []byte:
+		packed = second
+	default:
+		if len(array) < 3 {
+			return nil, fmt.Errorf("expected a record after the time")
+		}
+		result.entries = []interface{}{[]interface{}{array[1], array[2]}}
+		optionIndex = 3
+	}
+
+	options := map[string]interface{}{}
+	if len(array) > optionIndex+1 {
+		return nil, fmt.Errorf("expected at most %d elements, but got %d", optionIndex+1, len(array))
+	}
+	if len(array) == optionIndex+1 && array[optionIndex] != nil {
+		options, ok = array[optionIndex].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("option must be a map")
+		}
+	}
+	if chunk, ok := options[optionChunk]; ok {
+		result.chunk, ok = stringOf(chunk)
+		if !ok {
+			return nil, fmt.Errorf("chunk must be a string")
+		}
+	}
+
+	if packed != nil {
+		compressed, _ := stringOf(options[optionCompressed])
+		entries, err := unpack(packed, compressed)
+		if err != nil {
+			return nil, err
+		}
+		result.entries = entries
+	}
+	return result, nil
+}
+
+// unpack returns the events packed into the supplied bytes, compressed as indicated.
+func unpack(packed []byte, compressed string) ([]interface{}, error) {
+	var reader io.Reader = bytes.NewReader(packed)
+	switch compressed {
+	case "", compressedText:
+	case compressedGzip:
+		// Senders may append gzip members to a chunk as it grows, which the reader reads in turn.
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decompress entries: %v", err)
+		}
+		defer gzipReader.Close()
+		decompressed, err := ioutil.ReadAll(io.LimitReader(gzipReader, maxChunkBytes+1))
+		if err != nil {
+			return nil, fmt.Errorf("unable to decompress entries: %v", err)
+		}
+		if len(decompressed) > maxChunkBytes {
+			return nil, fmt.Errorf("decompressed entries exceed %d bytes", maxChunkBytes)
+		}
+		reader = bytes.NewReader(decompressed)
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compressed)
+	}
+
+	result := []interface{}{}
+	decoder := newDecoder(reader)
+	for {
+		entry, err := decoder.decode()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode packed entries: %v", err)
+		}
+		result = append(result, entry)
+	}
+}
+
+// entry returns the json of the entry storing the supplied event, an array of time and record.
+// The fields of the record become the fields of the entry, along with the tag and the time of
+// the event unless the record already has fields of the same names.
+func entry(tag string, event interface{}) (string, error) {
+	pair, ok := event.([]interface{})
+	if !ok || len(pair) != 2 {
+		return "", fmt.Errorf("event must be an array of time and record")
+	}
+	timeNs, err := eventTime(pair[0])
+	if err != nil {
+		return "", err
+	}
+	record, ok := pair[1].(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("record must be a map")
+	}
+
+	fields := jsonValue(record).(map[string]interface{})
+	if _, ok := fields[tagField]; !ok {
+		fields[tagField] = tag
+	}
+	if _, ok := fields[timestampField]; !ok && timeNs > 0 {
+		fields[timestampField] = timeNs / nanosPerMilli
+	}
+
+	result, err := json.Marshal(fields)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal entry: %v", err)
+	}
+	return string(result), nil
+}
+
+// eventTime returns the supplied time in nanoseconds since the epoch. Times are either whole
+// seconds or event times with nanosecond precision. Some senders attach metadata, in which case
+// the time is the first element of an array.
+func eventTime(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int64:
+		return v * nanosPerSecond, nil
+	case float64:
+		return int64(v * nanosPerSecond), nil
+	case extension:
+		if v.Type != eventTimeType || len(v.Data) != 8 {
+			return 0, fmt.Errorf("invalid event time extension of type %d", v.Type)
+		}
+		seconds := int64(binary.BigEndian.Uint32(v.Data[:4]))
+		nanos := int64(binary.BigEndian.Uint32(v.Data[4:]))
+		return seconds*nanosPerSecond + nanos, nil
+	case []interface{}:
+		if len(v) > 0 {
+			return eventTime(v[0])
+		}
+	}
+	return 0, fmt.Errorf("invalid time %v", value)
+}
+
+// jsonValue returns the supplied decoded value as something which marshals to the equivalent
+// json. Binaries become strings, event times milliseconds since the epoch, and any other
+// extensions their raw bytes.
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case extension:
+		if timeNs, err := eventTime(v); err == nil {
+			return timeNs / nanosPerMilli
+		}
+		return v.Data
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, element := range v {
+			result[i] = jsonValue(element)
+		}
+		return result
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for key, element := range v {
+			result[key] = jsonValue(element)
+		}
+		return result
+	}
+	return value
+}
@@ -0,0 +1,109 @@
+package fluent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMessage(t *testing.T) {
+	first := []interface{}{int64(1500000000), map[string]interface{}{"message": "first"}}
+	second := []interface{}{eventTimeOf(1500000001, 0), map[string]interface{}{"message": "second"}}
+	options := map[string]interface{}{"chunk": "abc", "size": int64(2)}
+
+	// Message mode carries a single event.
+	m, err := parseMessage([]interface{}{"app", first[0], first[1], options})
+	assert.NoError(t, err)
+	assert.Equal(t, "app", m.tag)
+	assert.Equal(t, []interface{}{first}, m.entries)
+	assert.Equal(t, "abc", m.chunk)
+
+	// Forward mode carries an array of events.
+	m, err = parseMessage([]interface{}{"app", []interface{}{first, second}})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{first, second}, m.entries)
+	assert.Equal(t, "", m.chunk)
+
+	// PackedForward mode carries the events encoded one after the other.
+	m, err = parseMessage([]interface{}{[]byte("app"), pack(first, second), options})
+	assert.NoError(t, err)
+	assert.Equal(t, "app", m.tag)
+	assert.Equal(t, []interface{}{first, second}, m.entries)
+
+	// CompressedPackedForward mode gzips them, possibly in several members.
+	var compressed bytes.Buffer
+	for _, event := range []interface{}{first, second} {
+		writer := gzip.NewWriter(&compressed)
+		writer.Write(pack(event))
+		writer.Close()
+	}
+	m, err = parseMessage([]interface{}{"app", compressed.Bytes(), map[string]interface{}{"chunk": "abc", "compressed": "gzip"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{first, second}, m.entries)
+	assert.Equal(t, "abc", m.chunk)
+}
+
+func TestParseMessageRejectsInvalidMessages(t *testing.T) {
+	invalid := []interface{}{
+		"app",
+		[]interface{}{"app"},
+		[]interface{}{int64(1), []interface{}{}},
+		[]interface{}{"app", int64(1500000000)},
+		[]interface{}{"app", []interface{}{}, "not an option"},
+		[]interface{}{"app", []interface{}{}, map[string]interface{}{}, nil},
+		[]interface{}{"app", []interface{}{}, map[string]interface{}{"chunk": int64(1)}},
+		[]interface{}{"app", []byte{0x92, 0x01}},
+		[]interface{}{"app", pack("x"), map[string]interface{}{"compressed": "zstd"}},
+		[]interface{}{"app", pack("x"), map[string]interface{}{"compressed": "gzip"}},
+	}
+	for _, value := range invalid {
+		_, err := parseMessage(value)
+		assert.Error(t, err, "%v", value)
+	}
+}
+
+func TestEntry(t *testing.T) {
+	record := map[string]interface{}{
+		"log":    []byte("hello"),
+		"stream": "stdout",
+		"kubernetes": map[string]interface{}{
+			"pod_name": "web-1",
+			"labels":   []interface{}{[]byte("a"), int64(2)},
+		},
+	}
+	entryJson, err := entry("kube.web", []interface{}{eventTimeOf(1500000000, 123456789), record})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"log": "hello",
+		"stream": "stdout",
+		"kubernetes": {"pod_name": "web-1", "labels": ["a", 2]},
+		"tag": "kube.web",
+		"timestamp_ms": 1500000000123
+	}`, entryJson)
+
+	// Fields of the record take precedence, and times may carry metadata.
+	record = map[string]interface{}{"tag": "own", "timestamp_ms": int64(5000)}
+	entryJson, err = entry("app", []interface{}{[]interface{}{int64(1500000000), map[string]interface{}{}}, record})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"tag": "own", "timestamp_ms": 5000}`, entryJson)
+
+	entryJson, err = entry("app", []interface{}{float64(1.5), map[string]interface{}{}})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"tag": "app", "timestamp_ms": 1500}`, entryJson)
+}
+
+func TestEntryRejectsInvalidEvents(t *testing.T) {
+	invalid := []interface{}{
+		"event",
+		[]interface{}{int64(1)},
+		[]interface{}{"yesterday", map[string]interface{}{}},
+		[]interface{}{extension{Type: 1, Data: make([]byte, 8)}, map[string]interface{}{}},
+		[]interface{}{int64(1), "record"},
+	}
+	for _, event := range invalid {
+		_, err := entry("app", event)
+		assert.Error(t, err, "%v", event)
+	}
+}
@@ -0,0 +1,264 @@
+package fluent
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	// maxDepth is how deeply arrays and maps may be nested within a value.
+	maxDepth = 100
+)
+
+// extension is a messagepack value of an application defined type, such as the event times of
+// the forward protocol.
+type extension struct {
+	Type int8
+	Data []byte
+}
+
+// decoder reads messagepack values from a stream. Integers are decoded as int64, or as uint64 if
+// they do not fit, strings as string, binaries as []byte, arrays as []interface{} and maps as
+// map[string]interface{}, with keys other than strings formatted as strings.
+type decoder struct {
+	reader *bufio.Reader
+}
+
+func newDecoder(reader io.Reader) *decoder {
+	return &decoder{reader: bufio.NewReader(reader)}
+}
+
+// decode returns the next value of the stream, or io.EOF if the stream ends before it.
+func (d *decoder) decode() (interface{}, error) {
+	return d.decodeValue(0)
+}
+
+func (d *decoder) decodeValue(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("values nested more than %d levels deep", maxDepth)
+	}
+	b, err := d.reader.ReadByte()
+	if err == io.EOF && depth > 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xf0 == 0x80:
+		return d.decodeMap(int(b&0x0f), depth)
+	case b&0xf0 == 0x90:
+		return d.decodeArray(int(b&0x0f), depth)
+	case b&0xe0 == 0xa0:
+		return d.decodeString(int(b & 0x1f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.readLength(1 << (b - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return d.read(n)
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.readLength(1 << (b - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeExtension(n)
+	case 0xca:
+		data, err := d.read(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data))), nil
+	case 0xcb:
+		data, err := d.read(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		value, err := d.readUint(1 << (b - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if value > math.MaxInt64 {
+			return value, nil
+		}
+		return int64(value), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (b - 0xd0)
+		value, err := d.readUint(size)
+		if err != nil {
+			return nil, err
+		}
+		// Sign extend from the size read.
+		shift := uint(64 - 8*size)
+		return int64(value<<shift) >> shift, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.decodeExtension(1 << (b - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.readLength(1 << (b - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeString(n)
+	case 0xdc, 0xdd:
+		n, err := d.readLength(2 << (b - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.readLength(2 << (b - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(n, depth)
+	}
+	return nil, fmt.Errorf("invalid type byte 0x%x", b)
+}
+
+func (d *decoder) decodeString(n int) (interface{}, error) {
+	data, err := d.read(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (d *decoder) decodeExtension(n int) (interface{}, error) {
+	data, err := d.read(n + 1)
+	if err != nil {
+		return nil, err
+	}
+	return extension{Type: int8(data[0]), Data: data[1:]}, nil
+}
+
+func (d *decoder) decodeArray(n int, depth int) (interface{}, error) {
+	result := make([]interface{}, 0, minInt(n, 1024))
+	for i := 0; i < n; i++ {
+		value, err := d.decodeValue(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+	}
+	return result, nil
+}
+
+func (d *decoder) decodeMap(n int, depth int) (interface{}, error) {
+	result := map[string]interface{}{}
+	for i := 0; i < n; i++ {
+		key, err := d.decodeValue(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.decodeValue(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := stringOf(key); ok {
+			result[s] = value
+		} else {
+			result[fmt.Sprint(key)] = value
+		}
+	}
+	return result, nil
+}
+
+// readLength reads a length made up of the supplied number of bytes, which must not exceed the
+// size of the largest chunk accepted.
+func (d *decoder) readLength(size int) (int, error) {
+	value, err := d.readUint(size)
+	if err != nil {
+		return 0, err
+	}
+	if value > maxChunkBytes {
+		return 0, fmt.Errorf("length %d exceeds %d", value, maxChunkBytes)
+	}
+	return int(value), nil
+}
+
+// readUint reads a big endian unsigned integer made up of the supplied number of bytes.
+func (d *decoder) readUint(size int) (uint64, error) {
+	data, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+	result := uint64(0)
+	for _, b := range data {
+		result = result<<8 | uint64(b)
+	}
+	return result, nil
+}
+
+// read reads exactly n bytes, which must all be there since they are part of a value.
+func (d *decoder) read(n int) ([]byte, error) {
+	result := make([]byte, n)
+	_, err := io.ReadFull(d.reader, result)
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// stringOf returns the supplied value as a string if it is a string or a binary, which older
+// senders use for strings.
+func stringOf(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	}
+	return "", false
+}
+
+// encodeAck returns the response acknowledging the supplied chunk, a map holding the chunk id
+// under "ack".
+func encodeAck(chunk string) []byte {
+	result := []byte{0x81}
+	result = appendString(result, "ack")
+	return appendString(result, chunk)
+}
+
+func appendString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, 0xda, byte(n>>8), byte(n))
+	default:
+		b = append(b, 0xdb, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(b, s...)
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,153 @@
+package fluent
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecode(t *testing.T) {
+	cases := []struct {
+		data     []byte
+		expected interface{}
+	}{
+		{[]byte{0x07}, int64(7)},
+		{[]byte{0xff}, int64(-1)},
+		{[]byte{0xcc, 0xc8}, int64(200)},
+		{[]byte{0xcd, 0x01, 0x00}, int64(256)},
+		{[]byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, uint64(math.MaxUint64)},
+		{[]byte{0xd0, 0x80}, int64(-128)},
+		{[]byte{0xd2, 0xff, 0xff, 0xff, 0xfe}, int64(-2)},
+		{[]byte{0xca, 0x3f, 0xc0, 0x00, 0x00}, float64(1.5)},
+		{[]byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}, float64(1.5)},
+		{[]byte{0xc0}, nil},
+		{[]byte{0xc3}, true},
+		{[]byte{0xa2, 'h', 'i'}, "hi"},
+		{[]byte{0xd9, 0x02, 'h', 'i'}, "hi"},
+		{[]byte{0xc4, 0x02, 'h', 'i'}, []byte("hi")},
+		{[]byte{0x92, 0x01, 0xa1, 'a'}, []interface{}{int64(1), "a"}},
+		{[]byte{0xdc, 0x00, 0x01, 0xc2}, []interface{}{false}},
+		{[]byte{0x82, 0xa1, 'a', 0x01, 0x02, 0x03}, map[string]interface{}{"a": int64(1), "2": int64(3)}},
+		{[]byte{0xd7, 0x00, 0, 0, 0, 1, 0, 0, 0, 2}, extension{Type: 0, Data: []byte{0, 0, 0, 1, 0, 0, 0, 2}}},
+		{[]byte{0xc7, 0x01, 0x05, 0xaa}, extension{Type: 5, Data: []byte{0xaa}}},
+	}
+	for _, c := range cases {
+		value, err := newDecoder(bytes.NewReader(c.data)).decode()
+		assert.NoError(t, err, "%x", c.data)
+		assert.Equal(t, c.expected, value, "%x", c.data)
+	}
+}
+
+func TestDecodeStream(t *testing.T) {
+	d := newDecoder(bytes.NewReader(pack("a", int64(1))))
+	value, err := d.decode()
+	assert.NoError(t, err)
+	assert.Equal(t, "a", value)
+	value, err = d.decode()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), value)
+
+	// The stream may only end between values.
+	_, err = d.decode()
+	assert.Equal(t, io.EOF, err)
+	_, err = newDecoder(bytes.NewReader([]byte{0x92, 0x01})).decode()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	_, err = newDecoder(bytes.NewReader([]byte{0xa3, 'a'})).decode()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestDecodeRejectsInvalidInput(t *testing.T) {
+	_, err := newDecoder(bytes.NewReader([]byte{0xc1})).decode()
+	assert.Error(t, err)
+
+	// Lengths beyond the largest chunk fail before anything is allocated.
+	_, err = newDecoder(bytes.NewReader([]byte{0xc6, 0xff, 0xff, 0xff, 0xff})).decode()
+	assert.Error(t, err)
+
+	nested := bytes.Repeat([]byte{0x91}, maxDepth+2)
+	_, err = newDecoder(bytes.NewReader(append(nested, 0x01))).decode()
+	assert.Error(t, err)
+}
+
+func TestEncodeAck(t *testing.T) {
+	for _, chunk := range []string{"p8n9gmxTQVC8/nh2wlKKeQ==", string(bytes.Repeat([]byte{'c'}, 300))} {
+		value, err := newDecoder(bytes.NewReader(encodeAck(chunk))).decode()
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"ack": chunk}, value)
+	}
+}
+
+// eventTimeOf returns the event time extension holding the supplied time.
+func eventTimeOf(seconds uint32, nanos uint32) extension {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint32(data, seconds)
+	binary.BigEndian.PutUint32(data[4:], nanos)
+	return extension{Type: eventTimeType, Data: data}
+}
+
+// pack returns the messagepack encoding of the supplied values, one after the other. Only the
+// types needed by the tests are supported.
+func pack(values ...interface{}) []byte {
+	var buffer bytes.Buffer
+	for _, value := range values {
+		packValue(&buffer, value)
+	}
+	return buffer.Bytes()
+}
+
+func packValue(buffer *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		buffer.WriteByte(0xc0)
+	case bool:
+		if v {
+			buffer.WriteByte(0xc3)
+		} else {
+			buffer.WriteByte(0xc2)
+		}
+	case int:
+		packValue(buffer, int64(v))
+	case int64:
+		buffer.WriteByte(0xd3)
+		binary.Write(buffer, binary.BigEndian, v)
+	case float64:
+		buffer.WriteByte(0xcb)
+		binary.Write(buffer, binary.BigEndian, math.Float64bits(v))
+	case string:
+		buffer.Write(appendString(nil, v))
+	case []byte:
+		buffer.WriteByte(0xc6)
+		binary.Write(buffer, binary.BigEndian, uint32(len(v)))
+		buffer.Write(v)
+	case extension:
+		buffer.WriteByte(0xc7)
+		buffer.WriteByte(byte(len(v.Data)))
+		buffer.WriteByte(byte(v.Type))
+		buffer.Write(v.Data)
+	case []interface{}:
+		buffer.WriteByte(0xdd)
+		binary.Write(buffer, binary.BigEndian, uint32(len(v)))
+		for _, element := range v {
+			packValue(buffer, element)
+		}
+	case map[string]interface{}:
+		keys := []string{}
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		buffer.WriteByte(0xdf)
+		binary.Write(buffer, binary.BigEndian, uint32(len(v)))
+		for _, key := range keys {
+			packValue(buffer, key)
+			packValue(buffer, v[key])
+		}
+	default:
+		panic("unsupported type")
+	}
+}
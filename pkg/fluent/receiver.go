@@ -0,0 +1,236 @@
+// Package fluent receives events forwarded by fluentd and fluent bit over the forward protocol,
+// and ingests each of them as an entry made up of the fields of its record.
+package fluent
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/dinowernli/almanac/pkg/util"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	// ingestTimeout bounds how long the events of a single message take to be ingested, after
+	// which the message is not acknowledged and the sender retries it.
+	ingestTimeout = 30 * time.Second
+)
+
+var (
+	// All receivers in a process share the same metrics, such that their values add up.
+	metricsOnce      sync.Once
+	metricsInstance  *receiverMetrics
+	metricsCreateErr error
+)
+
+type receiverMetrics struct {
+	numReceived prometheus.Counter
+	numRejected prometheus.Counter
+}
+
+// sharedMetrics returns the metrics of all receivers in this process, registering them in the
+// default registry the first time around.
+func sharedMetrics() (*receiverMetrics, error) {
+	metricsOnce.Do(func() {
+		metricsInstance, metricsCreateErr = newReceiverMetrics()
+	})
+	return metricsInstance, metricsCreateErr
+}
+
+// newReceiverMetrics returns a struct with metrics registered in the default registry.
+func newReceiverMetrics() (*receiverMetrics, error) {
+	result := &receiverMetrics{}
+
+	result.numReceived = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "almanac_fluent_received_events",
+		Help: "The number of events received over the forward protocol",
+	})
+	if err := util.RegisterLenient(result.numReceived); err != nil {
+		return nil, err
+	}
+
+	result.numRejected = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "almanac_fluent_rejected_events",
+		Help: "The number of events received over the forward protocol which were dropped as invalid",
+	})
+	if err := util.RegisterLenient(result.numRejected); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Receiver accepts connections of fluentd and fluent bit and passes the events they forward on
+// to an ingester. Messages which ask for an acknowledgement get one once all their events have
+// been ingested, which means that the appenders have accepted them. Invalid events are dropped,
+// but any other failure closes the connection without an acknowledgement such that the sender
+// retries the whole message. Senders are not authenticated, so the sockets should only be
+// reachable by trusted senders.
+type Receiver struct {
+	logger   *logrus.Logger
+	ingester pb_almanac.IngesterServer
+	tenant   string
+	metrics  *receiverMetrics
+
+	mutex   sync.Mutex
+	closed  bool
+	sockets map[io.Closer]bool
+	wg      sync.WaitGroup
+}
+
+// New returns a receiver which ingests all events into the supplied tenant.
+func New(logger *logrus.Logger, ingester pb_almanac.IngesterServer, tenantId string) (*Receiver, error) {
+	metrics, err := sharedMetrics()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create metrics: %v", err)
+	}
+	return &Receiver{
+		logger:   logger,
+		ingester: ingester,
+		tenant:   tenantId,
+		metrics:  metrics,
+		sockets:  map[io.Closer]bool{},
+	}, nil
+}
+
+// ServeTcp accepts connections from the supplied listener in the background until the receiver
+// is closed. Listeners returned by tls.NewListener are supported as well.
+func (r *Receiver) ServeTcp(listener net.Listener) error {
+	if !r.track(listener) {
+		return fmt.Errorf("receiver is closed")
+	}
+	go func() {
+		defer r.untrack(listener)
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !r.isClosed() {
+					r.logger.WithError(err).Warnf("Unable to accept forward connection, stopping")
+				}
+				return
+			}
+			if !r.track(conn) {
+				conn.Close()
+				return
+			}
+			go func() {
+				defer r.untrack(conn)
+				err := r.serveConn(conn)
+				if err != nil && !r.isClosed() {
+					r.logger.WithError(err).Warnf("Closing forward connection from %v", conn.RemoteAddr())
+				}
+			}()
+		}
+	}()
+	return nil
+}
+
+// Close stops accepting events and waits for the ones already received to be ingested.
+func (r *Receiver) Close() error {
+	r.mutex.Lock()
+	r.closed = true
+	for socket := range r.sockets {
+		socket.Close()
+	}
+	r.mutex.Unlock()
+
+	r.wg.Wait()
+	return nil
+}
+
+// serveConn reads messages from the supplied connection until it is closed, acknowledging them
+// as requested once their events are ingested.
+func (r *Receiver) serveConn(conn net.Conn) error {
+	decoder := newDecoder(conn)
+	for {
+		value, err := decoder.decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to decode message: %v", err)
+		}
+
+		// Invalid messages are not acknowledged, as fluentd does, which eventually makes the
+		// sender give up on them.
+		m, err := parseMessage(value)
+		if err != nil {
+			r.metrics.numRejected.Inc()
+			r.logger.WithError(err).Warnf("Dropping invalid forward message")
+			continue
+		}
+
+		err = r.ingest(m)
+		if err != nil {
+			return err
+		}
+		if m.chunk != "" {
+			_, err := conn.Write(encodeAck(m.chunk))
+			if err != nil {
+				return fmt.Errorf("unable to acknowledge chunk: %v", err)
+			}
+		}
+	}
+}
+
+// ingest ingests the events of the supplied message. Events which cannot be converted to entries
+// or which the ingester rejects as invalid are dropped, since sending them again would not help.
+// Returns an error if any other event fails to be ingested.
+func (r *Receiver) ingest(m *message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ingestTimeout)
+	defer cancel()
+
+	logger := r.logger.WithFields(logrus.Fields{"tag": m.tag})
+	for _, event := range m.entries {
+		r.metrics.numReceived.Inc()
+		entryJson, err := entry(m.tag, event)
+		if err == nil {
+			_, err = r.ingester.Ingest(ctx, &pb_almanac.IngestRequest{EntryJson: entryJson, Tenant: r.tenant, Format: pb_almanac.IngestRequest_JSON})
+			if err != nil && grpc.Code(err) != codes.InvalidArgument {
+				return fmt.Errorf("unable to ingest event: %v", err)
+			}
+		}
+		if err != nil {
+			r.metrics.numRejected.Inc()
+			logger.WithError(err).Warnf("Dropping forwarded event")
+		}
+	}
+	return nil
+}
+
+// track adds the supplied socket to those closed along with the receiver. Returns false if the
+// receiver is already closed.
+func (r *Receiver) track(socket io.Closer) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed {
+		return false
+	}
+	r.sockets[socket] = true
+	r.wg.Add(1)
+	return true
+}
+
+func (r *Receiver) untrack(socket io.Closer) {
+	r.mutex.Lock()
+	delete(r.sockets, socket)
+	r.mutex.Unlock()
+
+	socket.Close()
+	r.wg.Done()
+}
+
+func (r *Receiver) isClosed() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.closed
+}
@@ -0,0 +1,156 @@
+package fluent
+
+import (
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	testTenant = "kubernetes"
+)
+
+func TestReceiveAcknowledgesIngestedChunks(t *testing.T) {
+	ingester := &fakeIngester{}
+	conn, stop := startReceiver(t, ingester)
+	defer stop()
+
+	events := []interface{}{
+		[]interface{}{eventTimeOf(1500000000, 0), map[string]interface{}{"log": "first"}},
+		[]interface{}{eventTimeOf(1500000001, 0), map[string]interface{}{"log": "second"}},
+	}
+	_, err := conn.Write(pack([]interface{}{"kube.web", pack(events...), map[string]interface{}{"chunk": "chunk-1", "size": int64(2)}}))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"ack": "chunk-1"}, readAck(t, conn))
+
+	// Messages which do not ask for an acknowledgement on the same connection get none.
+	_, err = conn.Write(pack([]interface{}{"kube.web", int64(1500000002), map[string]interface{}{"log": "third"}}))
+	assert.NoError(t, err)
+	_, err = conn.Write(pack([]interface{}{"kube.web", []interface{}{}, map[string]interface{}{"chunk": "chunk-2"}}))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"ack": "chunk-2"}, readAck(t, conn))
+
+	requests := ingester.all()
+	assert.Equal(t, 3, len(requests))
+	assert.Equal(t, testTenant, requests[0].Tenant)
+	assert.Equal(t, pb_almanac.IngestRequest_JSON, requests[0].Format)
+	assert.JSONEq(t, `{"log": "first", "tag": "kube.web", "timestamp_ms": 1500000000000}`, requests[0].EntryJson)
+	assert.JSONEq(t, `{"log": "third", "tag": "kube.web", "timestamp_ms": 1500000002000}`, requests[2].EntryJson)
+}
+
+func TestReceiveDropsInvalidEvents(t *testing.T) {
+	ingester := &fakeIngester{failures: map[string]error{"bad": grpc.Errorf(codes.InvalidArgument, "bad entry")}}
+	conn, stop := startReceiver(t, ingester)
+	defer stop()
+
+	events := []interface{}{
+		[]interface{}{int64(1500000000), map[string]interface{}{"log": "bad"}},
+		[]interface{}{int64(1500000000), "not a record"},
+		[]interface{}{int64(1500000000), map[string]interface{}{"log": "good"}},
+	}
+	_, err := conn.Write(pack([]interface{}{"app", events, map[string]interface{}{"chunk": "chunk-1"}}))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"ack": "chunk-1"}, readAck(t, conn))
+
+	requests := ingester.all()
+	assert.Equal(t, 1, len(requests))
+	assert.Contains(t, requests[0].EntryJson, "good")
+}
+
+func TestReceiveDoesNotAcknowledgeFailedChunks(t *testing.T) {
+	ingester := &fakeIngester{failures: map[string]error{"unlucky": grpc.Errorf(codes.Internal, "no appenders")}}
+	conn, stop := startReceiver(t, ingester)
+	defer stop()
+
+	events := []interface{}{
+		[]interface{}{int64(1500000000), map[string]interface{}{"log": "unlucky"}},
+	}
+	_, err := conn.Write(pack([]interface{}{"app", events, map[string]interface{}{"chunk": "chunk-1"}}))
+	assert.NoError(t, err)
+
+	// The connection is closed without an acknowledgement, so that the sender retries.
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 0, len(ingester.all()))
+}
+
+func TestCloseStopsReceiving(t *testing.T) {
+	r, err := New(logrus.New(), &fakeIngester{}, testTenant)
+	assert.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	assert.NoError(t, r.ServeTcp(listener))
+
+	assert.NoError(t, r.Close())
+	_, err = net.Dial("tcp", listener.Addr().String())
+	assert.Error(t, err)
+
+	other, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer other.Close()
+	assert.Error(t, r.ServeTcp(other))
+}
+
+// startReceiver serves a receiver on a local port and returns a connection to it, along with a
+// function which closes both.
+func startReceiver(t *testing.T, ingester *fakeIngester) (net.Conn, func()) {
+	r, err := New(logrus.New(), ingester, testTenant)
+	assert.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	assert.NoError(t, r.ServeTcp(listener))
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	return conn, func() {
+		conn.Close()
+		r.Close()
+	}
+}
+
+func readAck(t *testing.T, conn net.Conn) interface{} {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	defer conn.SetReadDeadline(time.Time{})
+	value, err := newDecoder(conn).decode()
+	assert.NoError(t, err)
+	return value
+}
+
+// fakeIngester records the requests it accepts, and fails those whose entry contains one of the
+// keys of failures with the corresponding error.
+type fakeIngester struct {
+	failures map[string]error
+
+	mutex    sync.Mutex
+	requests []*pb_almanac.IngestRequest
+}
+
+func (i *fakeIngester) Ingest(ctx context.Context, request *pb_almanac.IngestRequest) (*pb_almanac.IngestResponse, error) {
+	for marker, err := range i.failures {
+		if strings.Contains(request.EntryJson, marker) {
+			return nil, err
+		}
+	}
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.requests = append(i.requests, request)
+	return &pb_almanac.IngestResponse{}, nil
+}
+
+func (i *fakeIngester) all() []*pb_almanac.IngestRequest {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return append([]*pb_almanac.IngestRequest{}, i.requests...)
+}
@@ -18,6 +18,8 @@ var (
 // IngesterData holds the data required to render the ingester page.
 type IngesterData struct {
 	FormContent string
+	FormFormat  string
+	Formats     []string
 	Error       error
 	Result      string
 }
@@ -35,6 +37,7 @@ func (d *IngesterData) Render(writer io.Writer) error {
 // MixerData holds the data required to render the mixer page.
 type MixerData struct {
 	FormQuery   string
+	FormLabels  string
 	FormStartMs string
 	FormEndMs   string
 	Error       error
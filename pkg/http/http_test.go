@@ -29,6 +29,8 @@ func TestRenderMixer(t *testing.T) {
 func TestRenderIngester(t *testing.T) {
 	data := &IngesterData{
 		FormContent: "some json blob",
+		FormFormat:  "JSON",
+		Formats:     []string{"AUTO", "JSON"},
 		Error:       nil,
 		Result:      "some result",
 	}
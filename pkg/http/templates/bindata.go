@@ -69,7 +69,7 @@ func (fi bindataFileInfo) Sys() interface{} {
 	return nil
 }
 
-var _ingesterHtmlTmpl = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x52\x4d\x6e\xdb\x3c\x10\xdd\xf3\x14\xf3\x71\x93\xcd\x27\x2b\x4d\xd1\x8d\x42\xa9\x8b\xa2\x05\xba\xcd\x0d\x68\x69\x24\x11\x21\x39\x02\x39\x4e\xa3\x0a\xbe\x7b\x41\x49\xb4\xdd\x20\x45\xbb\xb3\xe7\xfd\xcc\x9b\x27\xaa\xff\x3a\x6a\x79\x9e\x10\x46\x76\xb6\x11\xca\x1a\xff\x0c\x63\xc0\xbe\x96\x23\xf3\x14\xab\xb2\xec\xc9\x73\x3c\x0c\x44\x83\x45\x3d\x99\x78\x68\xc9\x95\x6d\x8c\x9f\x7b\xed\x8c\x9d\xeb\x27\x3a\x12\x93\x84\x80\xb6\x96\x91\x67\x8b\x71\x44\x64\xd9\x08\xa1\xd8\xb0\xc5\xe6\xbb\x1f\x30\x32\x06\x55\x6e\xff\x85\x50\x2b\xaf\x11\xe2\x48\xdd\x0c\x8b\x00\x48\x5b\x8a\xcd\xb1\x82\xbb\xcd\xf3\xee\x7f\x88\xda\xc7\x22\x62\x30\xfd\xa3\x38\x0b\x71\x18\x51\x77\x18\x56\xc5\xa4\xbb\xce\xf8\xa1\x38\x12\x33\xb9\x0a\x3e\xdc\x4f\xaf\x8f\x37\x73\xa6\xe9\x3a\x5c\xed\xa3\xf9\x89\x15\x3c\x7c\x4a\xa3\x64\xd6\x92\x67\x6d\xfc\x1b\x3f\x8b\x3d\x5f\x85\x4e\x87\xc1\xf8\x0a\x1e\xee\xb3\x0a\x3d\x87\xb9\x30\x7e\x3a\xf1\xaa\x73\xc6\x17\x23\x9a\x61\xe4\xc4\xca\x32\xe3\x8b\x1f\xa6\xe3\xb1\x82\x8f\x79\xd6\x99\x38\x59\x3d\x57\x70\xb4\xd4\x3e\x3f\xbe\x3d\xda\x91\xa7\x38\xe9\x16\xd7\x35\xaa\xcc\x15\xa9\xd4\x51\x23\x00\x54\x67\x5e\xa0\xb5\x3a\xc6\x5a\x5e\xa2\xcb\x84\xfc\x8e\x6d\x1d\xc9\xbd\x76\x55\x76\xe6\xa5\x11\x17\xd6\xce\xef\x29\x38\xd0\x2d\x1b\xf2\xb5\x2c\xcd\xfe\x85\x24\x38\xe4\x91\xba\x5a\x4e\x14\x79\xf7\x06\xf8\x42\x9e\xd1\x73\x05\x8a\xf1\x95\x75\x40\x0d\x5e\x3b\xac\x65\x2b\xf3\xd2\x9b\x56\x64\xb3\x2c\x87\x6f\x14\xdc\x2e\x3b\x9f\x55\x99\x75\xd9\x51\x6d\xfd\xa5\xa7\x57\xcb\x78\x3a\x3a\xc3\x12\x5e\xb4\x3d\x61\x2d\xb7\xdc\xf9\xb0\x32\x25\xcd\xbf\xaf\xa7\x2c\x0b\x98\x1e\x0e\x4f\x18\x4f\x96\xe1\x7c\xce\xbe\x37\x3d\x84\x15\xbb\x1c\xf1\x7e\x49\x9b\xc1\xee\x7c\x21\x4e\x01\xd3\x11\x1b\x98\xf2\xa7\x41\x5e\x71\xe5\x2e\x0b\xa0\xef\xd2\xf6\xdb\x4c\x5f\x43\xa0\xf0\x7e\x24\x4c\xd0\x5f\x12\xad\xf2\x3f\x04\x5a\xb1\x7f\xcc\xb3\xcf\x55\xb9\xbd\x9f\x5f\x01\x00\x00\xff\xff\x68\x22\xb4\x8a\xeb\x03\x00\x00")
+var _ingesterHtmlTmpl = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x53\xcd\x6e\xd4\x30\x10\xbe\xfb\x29\x06\x0b\xa9\x17\x36\x29\x45\x5c\x52\x27\x1c\x10\x48\x5c\xfb\x06\xde\x78\x92\x58\x75\x3c\xc1\x9e\x2d\x0d\x51\xde\x1d\x39\x3f\xbb\xdb\xaa\x08\x0e\x2b\xed\xce\xf7\x33\xdf\x7c\xd6\xaa\x77\x86\x6a\x1e\x07\x84\x8e\x7b\x57\x09\xe5\xac\x7f\x84\x2e\x60\x53\xca\x8e\x79\x88\x45\x9e\x37\xe4\x39\x66\x2d\x51\xeb\x50\x0f\x36\x66\x35\xf5\x79\x1d\xe3\x97\x46\xf7\xd6\x8d\xe5\x03\x1d\x89\x49\x42\x40\x57\xca\xc8\xa3\xc3\xd8\x21\xb2\xac\x84\x50\x6c\xd9\x61\xf5\xc3\xb7\x18\x19\x83\xca\xd7\xdf\x42\xa8\x85\x57\x09\x71\x24\x33\xc2\x24\x00\xd2\x96\xc3\xea\x58\xc0\xcd\xea\x79\xf3\x01\xa2\xf6\xf1\x10\x31\xd8\xe6\x5e\xcc\x42\x64\x1d\x6a\x83\x61\x51\x0c\xda\x18\xeb\xdb\xc3\x91\x98\xa9\x2f\xe0\xe3\xed\xf0\x7c\x7f\x35\x67\x1a\x2e\xc3\xc5\x3e\xda\xdf\x58\xc0\xdd\xe7\x34\x4a\x66\x35\x79\xd6\xd6\xbf\xf2\x73\xd8\xf0\x45\xd8\xeb\xd0\x5a\x5f\xc0\xdd\xed\xae\x42\xcf\x61\x3c\x58\x3f\x9c\x78\xd1\xf5\xd6\x1f\x3a\xb4\x6d\xc7\x89\xb5\xcb\xac\x3f\xfc\xb2\x86\xbb\x02\x3e\xed\x33\x63\xe3\xe0\xf4\x58\xc0\xd1\x51\xfd\x78\xff\xfa\xe8\x9e\x3c\xc5\x41\xd7\xb8\x84\x53\xf9\x5e\x91\x4a\x1d\x55\x02\x40\x19\xfb\x04\xb5\xd3\x31\x96\xf2\x1c\x5d\x26\xe4\x25\xb6\x76\x24\xb7\xda\x55\x6e\xec\x53\x25\xce\xac\x8d\xdf\x50\xe8\x41\xd7\x6c\xc9\x97\x32\xb7\xdb\x0b\x49\xe8\x91\x3b\x32\xa5\x1c\x28\xf2\xe6\x0d\xf0\x95\x3c\xa3\xe7\x02\x14\xe3\x33\xeb\x80\x1a\xbc\xee\xb1\x94\xb5\xdc\x97\x5e\xb5\x22\xab\x69\xca\xbe\x53\xe8\x37\xd9\x3c\xab\x7c\xd7\xed\x8e\x09\xd6\xc9\x30\xa2\xc3\x9a\x37\xbb\xe6\xbc\x11\x60\x9a\x20\x68\xdf\x22\x2c\x56\x9a\x23\xcc\xf3\x19\x04\x50\x34\xa4\xec\xf0\xa4\xdd\x09\x4b\x39\x4d\xd9\x3c\xcb\x24\xb2\x0d\xe0\x4f\xc8\xe0\xfd\xa2\x4b\x1f\xcd\x30\xcf\xeb\x1e\x34\xd3\x04\xe8\x0d\xcc\x73\x0a\x99\x92\xad\x3e\x2f\xf6\xae\x84\x6d\xa2\xf2\x55\xba\x33\xd4\x72\x23\xa4\x3f\x4d\x29\xe3\xe9\xd8\x5b\x96\x7b\x8a\xb5\xf1\xed\x08\x95\xa7\x8e\xf7\xef\x97\x47\x58\x33\x66\x0f\x18\x4f\x8e\xaf\xf6\x5c\xbd\x60\x58\xb0\xab\x32\xde\x7a\xde\xd5\x60\x73\x3e\x13\x87\x80\xe9\xb2\x15\x4c\xf7\xa5\xc1\xbe\xe2\xc2\xbd\x5c\x79\x9d\xe9\x5b\x08\x14\xde\x8e\x84\x09\xfa\x47\xa2\x45\xfe\x97\x40\x0b\xf6\x9f\x79\xb6\xb9\xca\x8f\x64\xc6\x4a\xfc\x19\x00\xcb\x7f\x6b\x5f\xa5\x04\x00\x00")
 
 func ingesterHtmlTmplBytes() ([]byte, error) {
 	return bindataRead(
@@ -89,7 +89,7 @@ func ingesterHtmlTmpl() (*asset, error) {
 	return a, nil
 }
 
-var _mixerHtmlTmpl = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x54\xcd\x6e\xf3\x36\x10\xbc\xeb\x29\xb6\xec\xe1\xbb\xd4\x56\x12\xb4\x40\xa1\x50\xea\x29\x3d\x14\xc8\xa1\x49\x5e\x80\x92\x56\x36\x11\x91\x54\xb8\xeb\xd4\xae\xe0\x77\xff\x40\x51\x3f\xb6\xe3\x04\x39\x52\x3b\x33\x3b\x1c\xee\x4a\xfe\x52\xbb\x8a\x0f\x1d\xc2\x96\x4d\x5b\x24\xb2\xd5\xf6\x15\xb6\x1e\x9b\x5c\x6c\x99\x3b\xca\xd2\xb4\x71\x96\x69\xbd\x71\x6e\xd3\xa2\xea\x34\xad\x2b\x67\xd2\x8a\xe8\xaf\x46\x19\xdd\x1e\xf2\x27\x57\x3a\x76\x02\x3c\xb6\xb9\x20\x3e\xb4\x48\x5b\x44\x16\x45\x92\x48\xd6\xdc\x62\xf1\xa8\xf7\xe8\x65\x1a\x0f\x89\x1c\x30\x45\x92\x94\xae\x3e\x40\x9f\x00\x84\x0e\xab\xa8\x96\xc1\x8f\xa8\xf7\xe3\x37\x20\x65\x69\x45\xe8\x75\x73\x9f\x1c\x93\x64\x5d\x39\xcb\x4a\x5b\xf4\x03\xa9\x53\x75\xad\xed\x66\xd5\x62\xc3\x19\xdc\xde\x74\xfb\xfb\x04\xc0\x28\xbf\xd1\x36\x83\xbb\xe1\x1c\x58\x5b\x54\xf5\x05\xa5\x74\xcc\xce\x2c\xa4\xe9\x3b\xbb\x6e\xf9\x38\x98\x22\xfd\x3f\x66\x70\xf7\xc7\x24\xf6\xb6\x43\x1f\x3d\x97\xce\xd7\xe8\x33\xb8\xed\xf6\x40\xae\xd5\x35\xfc\x5a\x55\xd5\x25\x71\xd4\xaa\x35\x75\xad\x3a\x64\x50\xb6\xae\x7a\x8d\x3e\xf7\xab\xff\x74\xcd\xdb\x0c\x7e\xbf\x99\xbd\x7a\xa4\x5d\xcb\x74\xd5\xec\xdd\x47\x5f\xb7\x7f\x9e\x13\x4f\x8c\x2d\x77\xbc\xf0\x17\xd0\xac\x0d\x12\x2b\xd3\x65\x99\x6a\x78\x0c\x27\xa4\x8b\x96\x33\x10\x99\x88\x30\x83\x44\x6a\x83\x1f\x9f\x48\x18\x67\x1d\x75\xaa\xc2\x11\x49\xa8\x7c\xb5\xfd\xb6\xed\x29\x4e\x99\x8e\xa3\x20\xc3\x28\x14\x09\x80\xac\xf5\x3b\x54\xad\x22\xca\xc5\xfc\xdc\x22\x54\xce\x6b\xb1\xe1\x58\x38\x2f\xc5\xf7\x16\xc5\xf3\x00\x91\x69\xad\xdf\x8b\x64\xc2\x35\xce\x1b\x50\x15\x6b\x67\x73\x91\x9a\x30\x97\xb3\x08\xc0\x8b\x36\x08\x5e\xd9\x0d\x66\x20\xb5\xed\x76\x0c\x61\x37\x72\xc1\xb8\x67\x01\x56\x19\xcc\x05\x09\x78\x57\xed\x0e\xf3\xbe\x5f\xff\xed\xbc\x79\x66\xe5\xf9\x91\x8e\xc7\x02\x56\x9f\xb3\xf0\x82\xf5\x60\xeb\xc8\x91\xa5\x4f\x17\x07\xff\x86\xf9\xfa\xa2\xf9\xdb\x85\xcc\x80\x1f\x64\x4e\x19\xb4\x2b\x8d\xe6\x09\x2a\x9e\x2f\xc2\x4a\x43\x0a\x63\xa6\x27\xf1\xf4\x3d\xe8\x06\xd6\x0f\xde\x3b\x0f\xc7\xe3\x95\x68\x31\x94\x4e\xf2\xba\x16\xfb\x40\x1f\x65\x67\x5c\xe7\xb1\xe8\xfb\x28\x7d\x3c\xca\x34\x9c\x67\x37\x33\xb4\xef\x01\x6d\x1d\x5a\x9f\xfa\x79\x42\xea\x9c\x25\x9c\x2c\x9d\x36\x1d\xf7\xe5\xcb\x39\x78\x8a\x98\xf3\x41\xe8\xfb\xf8\xce\x8b\xfc\xfa\xc1\xb2\xd7\x48\xcb\xcd\xaf\xb5\x3a\xb9\x3c\x80\xa4\x4e\xd9\xa9\x3e\xef\x94\x28\xfa\x1e\xd6\x2f\xd3\xf1\x31\x28\xca\x34\x40\x3f\xe5\x8e\x8b\x16\x99\xc1\xc7\xe1\x1f\x72\xf6\x0a\xef\x2c\xd7\x25\xae\x6f\xe6\xf8\xb6\x43\xe2\x6b\x31\xd6\x58\xee\x36\x5f\x86\x38\xcc\xd9\x59\x77\x59\xb9\x1a\x27\xd8\xf0\x5b\x8c\xf6\x97\x36\x32\x0d\x90\xe2\x73\x77\xf3\x67\x99\xc6\xfd\xff\x19\x00\x00\xff\xff\xc4\xbf\x5e\x6b\x8e\x06\x00\x00")
+var _mixerHtmlTmpl = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x9c\x55\x4d\x6f\xe3\x36\x10\xbd\xeb\x57\xcc\xb2\x87\x5c\xd6\xd6\x26\x68\x81\x42\x2b\xa9\x87\x45\x0a\xb4\x68\x0e\x4d\xf2\x07\x28\x72\x64\x11\xa1\x48\x86\xa4\x1c\xbb\x82\xfe\x7b\x41\x7d\x59\x56\xec\xa4\x28\xe0\x0b\xc9\xf7\xe6\xbd\x79\x43\xca\xe9\x17\xae\x99\x3f\x1a\x84\xca\xd7\x32\x8f\x52\x29\xd4\x0b\x54\x16\xcb\x8c\x54\xde\x1b\x97\xc4\x71\xa9\x95\x77\xdb\x9d\xd6\x3b\x89\xd4\x08\xb7\x65\xba\x8e\x99\x73\xbf\x95\xb4\x16\xf2\x98\x3d\xea\x42\x7b\x4d\xc0\xa2\xcc\x88\xf3\x47\x89\xae\x42\xf4\x24\x8f\xa2\xd4\x0b\x2f\x31\x7f\x10\x07\xb4\x69\x3c\x2c\xa2\xb4\xc7\xe4\x51\x54\x68\x7e\x84\x36\x02\x08\x0a\x9b\xa1\x5a\x02\x37\x43\xbd\x9b\xaf\xe0\xa8\x72\x1b\x87\x56\x94\xdf\xa3\x2e\x8a\xb6\x4c\x2b\x4f\x85\x42\xdb\x93\x0c\xe5\x5c\xa8\xdd\x46\x62\xe9\x13\xb8\xfd\x66\x0e\xdf\x23\x80\x9a\xda\x9d\x50\x09\xdc\xf5\xeb\xc0\xaa\x90\xf2\x15\xa5\xd0\xde\xeb\xfa\x44\x9a\xf6\xbd\x36\xa7\xcd\xde\x94\x13\xff\x60\x02\x77\xbf\x4c\xc5\x5e\x1b\xb4\x83\xe7\x42\x5b\x8e\x36\x81\x5b\x73\x00\xa7\xa5\xe0\xf0\x13\x63\x6c\x4d\x1c\x6b\x71\xe1\x8c\xa4\xc7\x04\x0a\xa9\xd9\x4b\x00\xd5\xf4\xb0\x79\x13\xdc\x57\x09\xfc\xfc\x6d\xf6\x6a\xd1\x35\xd2\xbb\x8b\x66\xef\xde\xfb\xba\xfd\xf5\x9c\xb8\x30\x36\xd3\xd6\xfe\x02\xda\x8b\x1a\x9d\xa7\xb5\x49\x12\x5a\xfa\x31\x9c\x90\x2e\x2a\x9f\x00\x49\xc8\x00\xab\xd1\x39\xba\xc3\xf7\x23\x22\xb5\x56\xda\x19\xca\x70\x44\x3a\xa4\x96\x55\xff\xd9\xf6\x14\x67\x1a\x8f\x57\x21\x0d\x57\x21\x8f\x00\x52\x2e\xf6\xc0\x24\x75\x2e\x23\xf3\xb8\x49\x38\x39\x3f\x1b\x04\xc7\x83\xf3\xa3\x61\xde\x24\x7f\xea\x21\x69\xcc\xc5\x3e\x8f\x26\x5c\xa9\x6d\x0d\x94\x79\xa1\x55\x46\xe2\x5a\x1c\xe6\xea\xe1\xf7\x2c\x6a\x04\x4b\xd5\x0e\x13\x48\x85\x32\x8d\x87\xf0\x36\x32\xe2\xf1\xe0\x09\x28\x5a\x63\x46\x1c\x81\x3d\x95\x0d\x66\x6d\xbb\xfd\x5d\xdb\xfa\xc9\x53\xeb\x1f\x5c\xd7\xe5\xb0\xb9\xce\xc2\x15\xeb\x5e\xf1\x81\x93\x16\x36\x3e\x39\xf8\x8b\x16\x28\xdd\x07\xea\x72\xaa\x43\xc6\x42\x03\xa3\xeb\x08\x18\x49\x19\x56\x5a\x72\xb4\xd9\x8d\x43\xbb\x17\x0c\x33\xf2\x86\x05\xf9\x0a\xa8\xf6\x5f\x32\xc2\x71\x4f\x6e\xd6\x92\x7f\x87\x2b\xfd\x81\xe2\xeb\xca\x79\x8f\xef\x9d\x2f\x19\xae\x29\x6a\xe1\x67\x73\x4f\xab\xf9\xc4\x21\xf8\x71\x8c\x8b\x89\xb4\x2d\x88\x12\xb6\xf7\xd6\x6a\x0b\x5d\x77\x61\x9a\x18\x8e\x16\x23\xba\x34\xe9\x9e\x3e\x0e\x7a\xc6\x19\x8b\x79\xdb\x0e\xa5\xbb\x2e\x8d\xc3\x7a\xaa\x7f\x82\xb6\x2d\xa0\xe2\x41\x7a\xe9\xe7\x11\x9d\xd1\xca\xe1\x64\x69\xb5\xbd\xfd\xa1\xad\x6d\x8c\xff\x51\x35\xea\xc5\xfd\x7f\xdf\x7f\x28\xa6\x6b\x23\xd1\x23\x8c\x0f\x7f\xd5\xc4\x73\x85\x50\x6a\x29\xf5\x9b\x50\x3b\x60\x83\x2a\xb0\x41\xf6\x0d\x2d\x82\x7b\x11\xc6\x20\x4f\x66\x4a\xdb\x0e\x17\xf8\x73\xb7\x8b\x94\x60\x0b\xab\x88\x96\xc9\x7c\x16\xda\xb2\xb5\xb1\x8f\xb9\xf1\x4b\x6d\x3f\x9e\xf5\x1a\x5d\xf5\x7d\xaf\xbc\x15\x78\xe6\xf8\xbd\xd4\x22\x62\x80\xd4\x19\xaa\xa6\xf3\xf9\x23\x47\xfa\x0e\x9f\xa7\xe5\x43\xa8\x98\xc6\x01\x7a\x95\x3b\x7e\xf9\x06\x66\xf0\x71\xfc\xd3\x69\x75\x81\xb7\x48\x65\x1d\xd9\xf5\xc0\xe6\xeb\xf4\xda\xa0\xf3\x33\x7e\xd1\x1b\xc7\xa2\xd9\x7d\x18\x62\xff\x0a\xcf\xd4\x53\xa6\x39\x4e\xb0\xfe\x7f\x6a\xb0\x7f\x92\x49\xe3\x00\xc9\xaf\xbb\x9b\xb7\xd3\xb8\xd0\xfc\x98\x47\xff\x0e\x00\x57\xc6\x31\xc4\x1f\x08\x00\x00")
 
 func mixerHtmlTmplBytes() ([]byte, error) {
 	return bindataRead(
@@ -69,6 +69,24 @@ func (i *Index) Index(id string, data interface{}) error {
 	return i.index.Index(id, data)
 }
 
+// Contains returns whether the index holds a document with the supplied id.
+func (i *Index) Contains(id string) (bool, error) {
+	doc, err := i.index.Document(id)
+	if err != nil {
+		return false, fmt.Errorf("unable to look up document %s: %v", id, err)
+	}
+	return doc != nil, nil
+}
+
+// Count returns the number of documents held by the index.
+func (i *Index) Count() (uint64, error) {
+	count, err := i.index.DocCount()
+	if err != nil {
+		return 0, fmt.Errorf("unable to count documents: %v", err)
+	}
+	return count, nil
+}
+
 // Close releases any resources held by this instance. No other methods must
 // be called after this.
 func (i *Index) Close() error {
@@ -75,3 +75,24 @@ func TestSearch_QueryString(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(result))
 }
+
+func TestContainsAndCount(t *testing.T) {
+	index, err := NewIndex()
+	assert.NoError(t, err)
+	defer index.Close()
+
+	err = index.Index("id1", &data{Name: "foo"})
+	assert.NoError(t, err)
+
+	contains, err := index.Contains("id1")
+	assert.NoError(t, err)
+	assert.True(t, contains)
+
+	contains, err = index.Contains("id2")
+	assert.NoError(t, err)
+	assert.False(t, contains)
+
+	count, err := index.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), count)
+}
@@ -0,0 +1,295 @@
+// Package loki translates between the loki http api and almanac, such that loki clients can push
+// entries to the ingesters and grafana can query the mixers. Pushed lines are stored as the
+// message of an entry whose other top-level fields hold the labels of the stream.
+package loki
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dinowernli/almanac/pkg/storage"
+	"github.com/dinowernli/almanac/pkg/tenant"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+)
+
+const (
+	// PathPrefix is the common prefix of all paths of the loki api.
+	PathPrefix = "/loki/api/v1/"
+
+	PushPath        = PathPrefix + "push"
+	QueryRangePath  = PathPrefix + "query_range"
+	LabelsPath      = PathPrefix + "labels"
+	LabelValuesPath = PathPrefix + "label/"
+
+	// TenantHeader is the header loki clients name their tenant in.
+	TenantHeader = "X-Scope-OrgID"
+
+	messageField   = "message"
+	timestampField = "timestamp_ms"
+	nanosPerMilli  = 1000000
+)
+
+// Tenant returns the tenant of the supplied loki request, which may be named in either the
+// almanac or the loki tenant header. Returns an error if the headers name different tenants.
+func Tenant(request *http.Request) (string, error) {
+	result, err := tenant.FromHttp(request)
+	if err != nil {
+		return "", err
+	}
+	orgId := request.Header.Get(TenantHeader)
+	if orgId == "" {
+		return result, nil
+	}
+	if result != tenant.Default && result != orgId {
+		return "", fmt.Errorf("request is for tenant [%s], but %s names tenant [%s]", result, TenantHeader, orgId)
+	}
+	err = tenant.Validate(orgId)
+	if err != nil {
+		return "", err
+	}
+	return orgId, nil
+}
+
+// ParseLabels parses the labels of a loki stream, which are in the form {name="value", ...}.
+func ParseLabels(text string) (map[string]string, error) {
+	inner, rest, err := splitSelector(text)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(rest) != "" {
+		return nil, fmt.Errorf("unexpected %q after labels", rest)
+	}
+	selector, err := storage.ParseLabelSelector(inner)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]string{}
+	if selector == nil {
+		return result, nil
+	}
+	for _, m := range selector.Matchers {
+		if m.Type != pb_almanac.LabelMatcher_EQUAL {
+			return nil, fmt.Errorf("label %s must be set with =", m.Name)
+		}
+		result[m.Name] = m.Value
+	}
+	return result, nil
+}
+
+// Entry returns the json of the entry storing the supplied line, pushed at the supplied time in
+// nanoseconds. Labels of the stream and of the entry become top-level fields.
+func Entry(labels map[string]string, line string, timestampNs int64) (string, error) {
+	fields := map[string]interface{}{}
+	for name, value := range labels {
+		fields[name] = value
+	}
+	fields[messageField] = line
+	fields[timestampField] = timestampNs / nanosPerMilli
+
+	result, err := json.Marshal(fields)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal entry: %v", err)
+	}
+	return string(result), nil
+}
+
+// Query is a logql query translated into an almanac search.
+type Query struct {
+	Selector *pb_almanac.LabelSelector
+
+	// Text is the query string of the search, matching every entry if there are no line filters.
+	Text string
+
+	// LabelNames holds the names of the labels of the selector, which make up the streams of the
+	// results.
+	LabelNames []string
+}
+
+// ParseQuery translates the supplied logql query. Only stream selectors followed by any number
+// of |= and != line filters are supported. Line filters match phrases of whole words rather than
+// arbitrary substrings, since they are evaluated by the index.
+func ParseQuery(logql string) (*Query, error) {
+	inner, rest, err := splitSelector(strings.TrimSpace(logql))
+	if err != nil {
+		return nil, err
+	}
+	selector, err := storage.ParseLabelSelector(inner)
+	if err != nil {
+		return nil, err
+	}
+	if selector == nil {
+		return nil, fmt.Errorf("stream selector must have at least one matcher")
+	}
+
+	result := &Query{Selector: selector}
+	seen := map[string]bool{}
+	for _, m := range selector.Matchers {
+		if !seen[m.Name] {
+			seen[m.Name] = true
+			result.LabelNames = append(result.LabelNames, m.Name)
+		}
+	}
+
+	terms := []string{}
+	hasRequired := false
+	rest = strings.TrimSpace(rest)
+	for rest != "" {
+		operator := ""
+		if strings.HasPrefix(rest, "|=") {
+			operator = "+"
+			hasRequired = true
+		} else if strings.HasPrefix(rest, "!=") {
+			operator = "-"
+		} else {
+			return nil, fmt.Errorf("unsupported expression %q, only |= and != line filters are supported", rest)
+		}
+		rest = strings.TrimSpace(rest[2:])
+
+		end := quotedEnd(rest)
+		if end < 0 {
+			return nil, fmt.Errorf("expected quoted string after line filter, but got %q", rest)
+		}
+		value, err := strconv.Unquote(rest[:end])
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s: %v", rest[:end], err)
+		}
+		if strings.TrimSpace(value) != "" {
+			terms = append(terms, operator+strconv.Quote(value))
+		}
+		rest = strings.TrimSpace(rest[end:])
+	}
+	if !hasRequired {
+		terms = append([]string{"*"}, terms...)
+	}
+	result.Text = strings.Join(terms, " ")
+	return result, nil
+}
+
+// Stream is a stream in the results of a loki query, holding its values as pairs of timestamp in
+// nanoseconds and line.
+type Stream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// Streams groups the supplied entries into streams by the values of the supplied labels. The
+// line of an entry is its message if it has nothing else besides the labels and its timestamp,
+// and the json of its remaining fields otherwise. Streams are ordered by their first entry.
+func Streams(entries []*pb_almanac.LogEntry, labelNames []string) ([]*Stream, error) {
+	result := []*Stream{}
+	streams := map[string]*Stream{}
+	for _, entry := range entries {
+		fields := map[string]interface{}{}
+		err := json.Unmarshal([]byte(entry.EntryJson), &fields)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse entry %s: %v", entry.Id, err)
+		}
+		labels, err := storage.EntryLabels(entry.EntryJson, labelNames)
+		if err != nil {
+			return nil, err
+		}
+		for name := range labels {
+			delete(fields, name)
+		}
+		delete(fields, timestampField)
+
+		line, isText := fields[messageField].(string)
+		if !isText || len(fields) > 1 {
+			encoded, err := json.Marshal(fields)
+			if err != nil {
+				return nil, fmt.Errorf("unable to marshal entry %s: %v", entry.Id, err)
+			}
+			line = string(encoded)
+		}
+
+		key := storage.EncodeLabels(labels)
+		stream, ok := streams[key]
+		if !ok {
+			stream = &Stream{Stream: labels, Values: [][2]string{}}
+			streams[key] = stream
+			result = append(result, stream)
+		}
+		timestampNs := strconv.FormatInt(entry.TimestampMs*nanosPerMilli, 10)
+		stream.Values = append(stream.Values, [2]string{timestampNs, line})
+	}
+	return result, nil
+}
+
+// ParseTime parses a time passed to the loki api, which can be in nanoseconds since the epoch,
+// in possibly fractional seconds since the epoch, or in rfc 3339. Returns the supplied default if
+// the value is empty.
+func ParseTime(value string, defaultTime time.Time) (time.Time, error) {
+	if value == "" {
+		return defaultTime, nil
+	}
+	if nanos, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(0, nanos), nil
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Unix(0, int64(seconds*float64(time.Second))), nil
+	}
+	result, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", value)
+	}
+	return result, nil
+}
+
+// SortedKeys returns the keys of the supplied set in order.
+func SortedKeys(set map[string]bool) []string {
+	result := []string{}
+	for key := range set {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// splitSelector splits off the stream selector at the start of the supplied text, returning the
+// matchers between its braces and everything following it.
+func splitSelector(text string) (string, string, error) {
+	if !strings.HasPrefix(text, "{") {
+		return "", "", fmt.Errorf("expected stream selector starting with {")
+	}
+	i := 1
+	for i < len(text) && text[i] != '}' {
+		if text[i] == '"' || text[i] == '`' {
+			end := quotedEnd(text[i:])
+			if end < 0 {
+				return "", "", fmt.Errorf("unterminated string in stream selector")
+			}
+			i += end
+			continue
+		}
+		i++
+	}
+	if i == len(text) {
+		return "", "", fmt.Errorf("expected } at the end of stream selector")
+	}
+	return text[1:i], text[i+1:], nil
+}
+
+// quotedEnd returns the length of the double- or back-quoted string at the start of the supplied
+// text, or -1 if the text does not start with a terminated string.
+func quotedEnd(text string) int {
+	if text == "" || (text[0] != '"' && text[0] != '`') {
+		return -1
+	}
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		if quote == '"' && text[i] == '\\' {
+			i++
+			continue
+		}
+		if text[i] == quote {
+			return i + 1
+		}
+	}
+	return -1
+}
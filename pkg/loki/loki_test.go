@@ -0,0 +1,157 @@
+package loki
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels(`{job="varlogs", host="web-1"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"job": "varlogs", "host": "web-1"}, labels)
+
+	labels, err = ParseLabels(`{}`)
+	assert.NoError(t, err)
+	assert.Empty(t, labels)
+
+	for _, invalid := range []string{``, `job="varlogs"`, `{job=~"var.*"}`, `{job="varlogs"} extra`, `{job="unterminated}`} {
+		_, err := ParseLabels(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	query, err := ParseQuery(`{job="varlogs", host=~"web-.*"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "*", query.Text)
+	assert.Equal(t, []string{"job", "host"}, query.LabelNames)
+	assert.Equal(t, 2, len(query.Selector.Matchers))
+	assert.Equal(t, pb_almanac.LabelMatcher_REGEX, query.Selector.Matchers[1].Type)
+
+	query, err = ParseQuery("{job=\"varlogs\"} |= \"connection refused\" != `debug`")
+	assert.NoError(t, err)
+	assert.Equal(t, `+"connection refused" -"debug"`, query.Text)
+
+	query, err = ParseQuery(`{job="a}b"} != "debug"`)
+	assert.NoError(t, err)
+	assert.Equal(t, `* -"debug"`, query.Text)
+	assert.Equal(t, "a}b", query.Selector.Matchers[0].Value)
+
+	unsupported := []string{
+		``,
+		`{}`,
+		`{job="varlogs"} |~ "err.*"`,
+		`{job="varlogs"} | json`,
+		`rate({job="varlogs"}[5m])`,
+		`{job="varlogs"} |= unquoted`,
+	}
+	for _, logql := range unsupported {
+		_, err := ParseQuery(logql)
+		assert.Error(t, err, logql)
+	}
+}
+
+func TestEntryRoundTrip(t *testing.T) {
+	labels := map[string]string{"job": "varlogs", "host": "web-1"}
+	entryJson, err := Entry(labels, "connection refused", 1500000000123456789)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"job": "varlogs", "host": "web-1", "message": "connection refused", "timestamp_ms": 1500000000123}`, entryJson)
+
+	entries := []*pb_almanac.LogEntry{
+		{Id: "1", EntryJson: entryJson, TimestampMs: 1500000000123},
+		{Id: "2", EntryJson: `{"job": "varlogs", "host": "web-2", "level": "warn", "message": "slow"}`, TimestampMs: 1500000000124},
+		{Id: "3", EntryJson: `{"job": "varlogs", "host": "web-1", "message": "retrying"}`, TimestampMs: 1500000000125},
+	}
+	streams, err := Streams(entries, []string{"job", "host"})
+	assert.NoError(t, err)
+	assert.Equal(t, []*Stream{
+		{
+			Stream: map[string]string{"job": "varlogs", "host": "web-1"},
+			Values: [][2]string{{"1500000000123000000", "connection refused"}, {"1500000000125000000", "retrying"}},
+		},
+		{
+			Stream: map[string]string{"job": "varlogs", "host": "web-2"},
+			Values: [][2]string{{"1500000000124000000", `{"level":"warn","message":"slow"}`}},
+		},
+	}, streams)
+}
+
+func TestParseTime(t *testing.T) {
+	defaultTime := time.Unix(42, 0)
+	cases := map[string]time.Time{
+		"":                         defaultTime,
+		"1500000000123456789":      time.Unix(0, 1500000000123456789),
+		"1500000000.5":             time.Unix(1500000000, 500000000),
+		"2017-07-14T02:40:00.123Z": time.Date(2017, time.July, 14, 2, 40, 0, 123000000, time.UTC),
+	}
+	for value, expected := range cases {
+		result, err := ParseTime(value, defaultTime)
+		assert.NoError(t, err, value)
+		assert.True(t, expected.Equal(result), value)
+	}
+	_, err := ParseTime("yesterday", defaultTime)
+	assert.Error(t, err)
+}
+
+func TestTenant(t *testing.T) {
+	request, err := http.NewRequest("GET", QueryRangePath, nil)
+	assert.NoError(t, err)
+	result, err := Tenant(request)
+	assert.NoError(t, err)
+	assert.Equal(t, "", result)
+
+	request.Header.Set(TenantHeader, "acme")
+	result, err = Tenant(request)
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", result)
+
+	request.Header.Set("X-Almanac-Tenant", "other")
+	_, err = Tenant(request)
+	assert.Error(t, err)
+}
+
+func TestDecodePush(t *testing.T) {
+	body := `{"streams": [{"stream": {"job": "varlogs"}, "values": [["1500000000000000000", "foo"], ["1500000001000000000", "bar", {"trace_id": "abc"}]]}]}`
+	request, err := http.NewRequest("POST", PushPath, bytes.NewBufferString(body))
+	assert.NoError(t, err)
+	request.Header.Set("Content-Type", "application/json")
+	entries, err := DecodePush(request)
+	assert.NoError(t, err)
+	assert.Equal(t, []*PushedEntry{
+		{Labels: map[string]string{"job": "varlogs"}, TimestampNs: 1500000000000000000, Line: "foo"},
+		{Labels: map[string]string{"job": "varlogs", "trace_id": "abc"}, TimestampNs: 1500000001000000000, Line: "bar"},
+	}, entries)
+
+	push := &pb_almanac.LokiPushRequest{Streams: []*pb_almanac.LokiStream{{
+		Labels: `{job="varlogs"}`,
+		Entries: []*pb_almanac.LokiEntry{{
+			Timestamp:          &pb_almanac.LokiTimestamp{Seconds: 1500000000, Nanos: 5},
+			Line:               "foo",
+			StructuredMetadata: []*pb_almanac.LokiLabelPair{{Name: "trace_id", Value: "abc"}},
+		}},
+	}}}
+	encoded, err := proto.Marshal(push)
+	assert.NoError(t, err)
+	request, err = http.NewRequest("POST", PushPath, bytes.NewReader(snappy.Encode(nil, encoded)))
+	assert.NoError(t, err)
+	request.Header.Set("Content-Type", "application/x-protobuf")
+	entries, err = DecodePush(request)
+	assert.NoError(t, err)
+	assert.Equal(t, []*PushedEntry{
+		{Labels: map[string]string{"job": "varlogs", "trace_id": "abc"}, TimestampNs: 1500000000000000005, Line: "foo"},
+	}, entries)
+
+	request, err = http.NewRequest("POST", PushPath, bytes.NewBufferString(body))
+	assert.NoError(t, err)
+	request.Header.Set("Content-Type", "text/plain")
+	_, err = DecodePush(request)
+	assert.Error(t, err)
+}
@@ -0,0 +1,157 @@
+package loki
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+)
+
+const (
+	// maxPushBytes is the size of the largest push body accepted, after decompression.
+	maxPushBytes = 16 * 1024 * 1024
+
+	contentTypeJson     = "application/json"
+	contentTypeProtobuf = "application/x-protobuf"
+)
+
+// PushedEntry is a single entry of a push, along with the labels of its stream and its own.
+type PushedEntry struct {
+	Labels      map[string]string
+	TimestampNs int64
+	Line        string
+}
+
+// jsonPush is the json form of a push.
+type jsonPush struct {
+	Streams []struct {
+		Stream map[string]string   `json:"stream"`
+		Values [][]json.RawMessage `json:"values"`
+	} `json:"streams"`
+}
+
+// DecodePush returns the entries in the body of the supplied push request, which is either json
+// or snappy compressed protobuf depending on its content type. Json bodies may be gzipped.
+func DecodePush(request *http.Request) ([]*PushedEntry, error) {
+	contentType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid content type: %v", err)
+	}
+
+	var body io.Reader = request.Body
+	if contentType == contentTypeJson && request.Header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decompress body: %v", err)
+		}
+		defer reader.Close()
+		body = reader
+	}
+	contents, err := ioutil.ReadAll(io.LimitReader(body, maxPushBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read body: %v", err)
+	}
+	if len(contents) > maxPushBytes {
+		return nil, fmt.Errorf("body exceeds %d bytes", maxPushBytes)
+	}
+
+	switch contentType {
+	case contentTypeJson:
+		return decodeJsonPush(contents)
+	case contentTypeProtobuf:
+		return decodeProtobufPush(contents)
+	}
+	return nil, fmt.Errorf("unsupported content type %s, must be %s or %s", contentType, contentTypeJson, contentTypeProtobuf)
+}
+
+func decodeJsonPush(contents []byte) ([]*PushedEntry, error) {
+	push := &jsonPush{}
+	err := json.Unmarshal(contents, push)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse json: %v", err)
+	}
+
+	result := []*PushedEntry{}
+	for _, stream := range push.Streams {
+		for _, value := range stream.Values {
+			if len(value) != 2 && len(value) != 3 {
+				return nil, fmt.Errorf("expected [timestamp, line] or [timestamp, line, metadata], but got %d elements", len(value))
+			}
+			var timestamp, line string
+			err := json.Unmarshal(value[0], &timestamp)
+			if err != nil {
+				return nil, fmt.Errorf("timestamp must be a string: %v", err)
+			}
+			timestampNs, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp %q: %v", timestamp, err)
+			}
+			err = json.Unmarshal(value[1], &line)
+			if err != nil {
+				return nil, fmt.Errorf("line must be a string: %v", err)
+			}
+			metadata := map[string]string{}
+			if len(value) == 3 {
+				err := json.Unmarshal(value[2], &metadata)
+				if err != nil {
+					return nil, fmt.Errorf("metadata must be an object of strings: %v", err)
+				}
+			}
+			result = append(result, &PushedEntry{Labels: merge(stream.Stream, metadata), TimestampNs: timestampNs, Line: line})
+		}
+	}
+	return result, nil
+}
+
+func decodeProtobufPush(contents []byte) ([]*PushedEntry, error) {
+	decoded, err := snappy.Decode(nil, contents)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress body: %v", err)
+	}
+	push := &pb_almanac.LokiPushRequest{}
+	err = proto.Unmarshal(decoded, push)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse protobuf: %v", err)
+	}
+
+	result := []*PushedEntry{}
+	for _, stream := range push.Streams {
+		labels, err := ParseLabels(stream.Labels)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stream labels %s: %v", stream.Labels, err)
+		}
+		for _, entry := range stream.Entries {
+			metadata := map[string]string{}
+			for _, pair := range entry.StructuredMetadata {
+				metadata[pair.Name] = pair.Value
+			}
+			timestampNs := int64(0)
+			if entry.Timestamp != nil {
+				timestampNs = entry.Timestamp.Seconds*int64(1e9) + int64(entry.Timestamp.Nanos)
+			}
+			result = append(result, &PushedEntry{Labels: merge(labels, metadata), TimestampNs: timestampNs, Line: entry.Line})
+		}
+	}
+	return result, nil
+}
+
+// merge returns the labels of both supplied maps, with those of the second taking precedence.
+func merge(first map[string]string, second map[string]string) map[string]string {
+	result := map[string]string{}
+	for name, value := range first {
+		result[name] = value
+	}
+	for name, value := range second {
+		result[name] = value
+	}
+	return result
+}
@@ -0,0 +1,147 @@
+package mtls
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+const (
+	testReloadInterval = 10 * time.Millisecond
+)
+
+func TestHandshake(t *testing.T) {
+	dir := createTempDir(t)
+	defer os.RemoveAll(dir)
+	authority := createAuthority(t)
+
+	server := createReloader(t, authority, dir, "appender-0", IdentityAppender)
+	client := createReloader(t, authority, dir, "ingester-0", "ingester")
+	assert.NoError(t, handshake(server.serverConfig(), client.clientConfig(IdentityAppender)))
+}
+
+func TestRejectsServerWithoutIdentity(t *testing.T) {
+	dir := createTempDir(t)
+	defer os.RemoveAll(dir)
+	authority := createAuthority(t)
+
+	server := createReloader(t, authority, dir, "mixer-0", "mixer")
+	client := createReloader(t, authority, dir, "ingester-0", "ingester")
+	assert.Error(t, handshake(server.serverConfig(), client.clientConfig(IdentityAppender)))
+}
+
+func TestRejectsOtherAuthorities(t *testing.T) {
+	dir := createTempDir(t)
+	defer os.RemoveAll(dir)
+
+	server := createReloader(t, createAuthority(t), dir, "appender-0", IdentityAppender)
+	client := createReloader(t, createAuthority(t), dir, "ingester-0", "ingester")
+	assert.Error(t, handshake(server.serverConfig(), client.clientConfig(IdentityAppender)))
+
+	// Clients without a certificate are rejected as well.
+	assert.Error(t, handshake(server.serverConfig(), &tls.Config{InsecureSkipVerify: true}))
+}
+
+func TestReloadsRotatedFiles(t *testing.T) {
+	dir := createTempDir(t)
+	defer os.RemoveAll(dir)
+	oldAuthority := createAuthority(t)
+	newAuthority := createAuthority(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server := createReloader(t, oldAuthority, dir, "appender-0", IdentityAppender)
+	files, err := oldAuthority.WriteFiles(dir, "ingester-0", "ingester")
+	assert.NoError(t, err)
+	client, err := NewReloader(ctx, logrus.New(), files, testReloadInterval)
+	assert.NoError(t, err)
+	assert.NoError(t, handshake(server.serverConfig(), client.clientConfig(IdentityAppender)))
+
+	// Once the client's files are rotated to a new authority, the server no longer trusts it.
+	_, err = newAuthority.WriteFiles(dir, "ingester-0", "ingester")
+	assert.NoError(t, err)
+	deadline := time.Now().Add(5 * time.Second)
+	for handshake(server.serverConfig(), client.clientConfig(IdentityAppender)) == nil && time.Now().Before(deadline) {
+		time.Sleep(testReloadInterval)
+	}
+	assert.Error(t, handshake(server.serverConfig(), client.clientConfig(IdentityAppender)))
+
+	// Broken files are ignored, keeping the previous ones.
+	assert.NoError(t, ioutil.WriteFile(files.KeyFile, []byte("garbage"), 0600))
+	changed, err := client.reload()
+	assert.Error(t, err)
+	assert.False(t, changed)
+	assert.NotNil(t, client.currentCertificate())
+}
+
+func TestNewReloaderRequiresFiles(t *testing.T) {
+	_, err := NewReloader(context.Background(), logrus.New(), Files{CertFile: "/does/not/exist"}, time.Minute)
+	assert.Error(t, err)
+}
+
+// handshake connects a client and a server with the supplied configs over loopback, returning
+// the first error either of them encounters.
+func handshake(serverConfig *tls.Config, clientConfig *tls.Config) error {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer conn.Close()
+		serverErr <- tls.Server(conn, serverConfig).Handshake()
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	clientConn := tls.Client(conn, clientConfig)
+	err = clientConn.Handshake()
+	if err == nil {
+		// The server may only reject the client after the client considers the handshake done.
+		err = <-serverErr
+		if err == nil {
+			_, err = clientConn.Write([]byte("ping"))
+		}
+		return err
+	}
+	<-serverErr
+	return err
+}
+
+func createReloader(t *testing.T, authority *TestAuthority, dir string, name string, identities ...string) *Reloader {
+	files, err := authority.WriteFiles(dir, name, identities...)
+	assert.NoError(t, err)
+	result, err := NewReloader(context.Background(), logrus.New(), files, time.Minute)
+	assert.NoError(t, err)
+	return result
+}
+
+func createAuthority(t *testing.T) *TestAuthority {
+	result, err := NewTestAuthority()
+	assert.NoError(t, err)
+	return result
+}
+
+func createTempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "almanac-mtls-test")
+	assert.NoError(t, err)
+	return dir
+}
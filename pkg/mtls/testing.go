@@ -0,0 +1,95 @@
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"time"
+)
+
+// TestAuthority is a certificate authority held in memory. This should only be used for testing.
+type TestAuthority struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPem []byte
+}
+
+// NewTestAuthority returns a new authority with a freshly generated key.
+func NewTestAuthority() (*TestAuthority, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "almanac test authority"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse certificate: %v", err)
+	}
+	return &TestAuthority{cert: cert, key: key, certPem: encodePem("CERTIFICATE", der)}, nil
+}
+
+// WriteFiles issues a certificate named name with the supplied identities, and writes it along
+// with its key and the certificate of the authority to the supplied directory.
+func (a *TestAuthority) WriteFiles(dir string, name string, identities ...string) (Files, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return Files{}, fmt.Errorf("unable to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name, OrganizationalUnit: identities},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	if err != nil {
+		return Files{}, fmt.Errorf("unable to create certificate: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return Files{}, fmt.Errorf("unable to marshal key: %v", err)
+	}
+
+	result := Files{
+		CertFile: filepath.Join(dir, name+".crt"),
+		KeyFile:  filepath.Join(dir, name+".key"),
+		CaFile:   filepath.Join(dir, name+".ca.crt"),
+	}
+	contents := map[string][]byte{
+		result.CertFile: encodePem("CERTIFICATE", der),
+		result.KeyFile:  encodePem("EC PRIVATE KEY", keyDer),
+		result.CaFile:   a.certPem,
+	}
+	for path, c := range contents {
+		err := ioutil.WriteFile(path, c, 0600)
+		if err != nil {
+			return Files{}, fmt.Errorf("unable to write %s: %v", path, err)
+		}
+	}
+	return result, nil
+}
+
+func encodePem(blockType string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
@@ -0,0 +1,153 @@
+package otlp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	pb_almanac "github.com/dinowernli/almanac/proto"
+)
+
+const (
+	timestampField       = "timestamp_ms"
+	messageField         = "message"
+	bodyField            = "body"
+	severityField        = "severity"
+	severityNumberField  = "severity_number"
+	traceIdField         = "trace_id"
+	spanIdField          = "span_id"
+	eventNameField       = "event_name"
+	attributesField      = "attributes"
+	resourceField        = "resource"
+	scopeField           = "scope"
+	serviceNameField     = "service_name"
+	serviceNameAttribute = "service.name"
+
+	nanosPerMilli = 1000000
+)
+
+// severityNames holds the short names of the severity numbers of otlp, each of which covers four
+// consecutive numbers starting at 1.
+var severityNames = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+// entry returns the json of the entry storing the supplied record. The body becomes the message
+// if it is a string, and is stored as is otherwise. The attributes of the record, its resource
+// and its scope are kept in separate objects, and the service name of the resource is repeated
+// at the top level such that it can serve as a stream label.
+func entry(resource *pb_almanac.OtlpResource, scope *pb_almanac.OtlpInstrumentationScope, record *pb_almanac.OtlpLogRecord) (string, error) {
+	fields := map[string]interface{}{}
+
+	timestampNs := record.TimeUnixNano
+	if timestampNs == 0 {
+		timestampNs = record.ObservedTimeUnixNano
+	}
+	if timestampNs != 0 {
+		fields[timestampField] = timestampNs / nanosPerMilli
+	}
+
+	if record.SeverityText != "" {
+		fields[severityField] = record.SeverityText
+	} else if name := severityName(record.SeverityNumber); name != "" {
+		fields[severityField] = name
+	}
+	if record.SeverityNumber != 0 {
+		fields[severityNumberField] = record.SeverityNumber
+	}
+
+	if record.Body != nil {
+		if message, ok := record.Body.Value.(*pb_almanac.OtlpAnyValue_StringValue); ok {
+			fields[messageField] = message.StringValue
+		} else {
+			fields[bodyField] = value(record.Body)
+		}
+	}
+
+	if len(record.TraceId) > 0 {
+		fields[traceIdField] = hex.EncodeToString(record.TraceId)
+	}
+	if len(record.SpanId) > 0 {
+		fields[spanIdField] = hex.EncodeToString(record.SpanId)
+	}
+	if record.EventName != "" {
+		fields[eventNameField] = record.EventName
+	}
+	if len(record.Attributes) > 0 {
+		fields[attributesField] = attributes(record.Attributes)
+	}
+
+	if resource != nil && len(resource.Attributes) > 0 {
+		resourceAttributes := attributes(resource.Attributes)
+		fields[resourceField] = resourceAttributes
+		if name, ok := resourceAttributes[serviceNameAttribute].(string); ok {
+			fields[serviceNameField] = name
+		}
+	}
+	if scope != nil && (scope.Name != "" || scope.Version != "" || len(scope.Attributes) > 0) {
+		scopeFields := map[string]interface{}{}
+		if scope.Name != "" {
+			scopeFields["name"] = scope.Name
+		}
+		if scope.Version != "" {
+			scopeFields["version"] = scope.Version
+		}
+		if len(scope.Attributes) > 0 {
+			scopeFields[attributesField] = attributes(scope.Attributes)
+		}
+		fields[scopeField] = scopeFields
+	}
+
+	result, err := json.Marshal(fields)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal entry: %v", err)
+	}
+	return string(result), nil
+}
+
+// severityName returns the short name of the supplied severity number, e.g., "WARN" for 13
+// through 16, or the empty string if the number is out of range.
+func severityName(number int32) string {
+	if number < 1 || int(number) > 4*len(severityNames) {
+		return ""
+	}
+	return severityNames[(number-1)/4]
+}
+
+// attributes returns the supplied key value pairs as a map. Later pairs win over earlier pairs
+// with the same key.
+func attributes(pairs []*pb_almanac.OtlpKeyValue) map[string]interface{} {
+	result := map[string]interface{}{}
+	for _, pair := range pairs {
+		result[pair.Key] = value(pair.Value)
+	}
+	return result
+}
+
+// value returns the supplied value as something which marshals to the equivalent json. Bytes
+// become base64 strings, and doubles which json cannot represent become strings.
+func value(v *pb_almanac.OtlpAnyValue) interface{} {
+	switch x := v.GetValue().(type) {
+	case *pb_almanac.OtlpAnyValue_StringValue:
+		return x.StringValue
+	case *pb_almanac.OtlpAnyValue_BoolValue:
+		return x.BoolValue
+	case *pb_almanac.OtlpAnyValue_IntValue:
+		return x.IntValue
+	case *pb_almanac.OtlpAnyValue_DoubleValue:
+		if math.IsNaN(x.DoubleValue) || math.IsInf(x.DoubleValue, 0) {
+			return fmt.Sprint(x.DoubleValue)
+		}
+		return x.DoubleValue
+	case *pb_almanac.OtlpAnyValue_BytesValue:
+		return x.BytesValue
+	case *pb_almanac.OtlpAnyValue_ArrayValue:
+		result := []interface{}{}
+		for _, element := range x.ArrayValue.GetValues() {
+			result = append(result, value(element))
+		}
+		return result
+	case *pb_almanac.OtlpAnyValue_KvlistValue:
+		return attributes(x.KvlistValue.GetValues())
+	}
+	return nil
+}
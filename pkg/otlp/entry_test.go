@@ -0,0 +1,90 @@
+package otlp
+
+import (
+	"math"
+	"testing"
+
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntry(t *testing.T) {
+	resource := &pb_almanac.OtlpResource{Attributes: []*pb_almanac.OtlpKeyValue{
+		stringPair("service.name", "checkout"),
+		stringPair("host.name", "host-1"),
+	}}
+	scope := &pb_almanac.OtlpInstrumentationScope{Name: "net/http", Version: "1.2"}
+	record := &pb_almanac.OtlpLogRecord{
+		TimeUnixNano:         1500000000123456789,
+		ObservedTimeUnixNano: 1500000001000000000,
+		SeverityNumber:       17,
+		SeverityText:         "Error",
+		Body:                 stringValue("payment failed"),
+		TraceId:              []byte{0x5b, 0x8e, 0xff, 0xf7, 0x98, 0x03, 0x81, 0x03, 0xd2, 0x69, 0xb6, 0x33, 0x81, 0x3f, 0xc6, 0x0c},
+		SpanId:               []byte{0xee, 0xe1, 0x9b, 0x7e, 0xc3, 0xc1, 0xb1, 0x74},
+		Attributes: []*pb_almanac.OtlpKeyValue{
+			{Key: "attempt", Value: &pb_almanac.OtlpAnyValue{Value: &pb_almanac.OtlpAnyValue_IntValue{IntValue: 3}}},
+			{Key: "retry", Value: &pb_almanac.OtlpAnyValue{Value: &pb_almanac.OtlpAnyValue_BoolValue{BoolValue: true}}},
+		},
+	}
+
+	entryJson, err := entry(resource, scope, record)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"timestamp_ms": 1500000000123,
+		"severity": "Error",
+		"severity_number": 17,
+		"message": "payment failed",
+		"trace_id": "5b8efff798038103d269b633813fc60c",
+		"span_id": "eee19b7ec3c1b174",
+		"attributes": {"attempt": 3, "retry": true},
+		"resource": {"service.name": "checkout", "host.name": "host-1"},
+		"scope": {"name": "net/http", "version": "1.2"},
+		"service_name": "checkout"
+	}`, entryJson)
+}
+
+func TestEntryWithStructuredBody(t *testing.T) {
+	record := &pb_almanac.OtlpLogRecord{
+		ObservedTimeUnixNano: 2000000000,
+		SeverityNumber:       10,
+		Body: &pb_almanac.OtlpAnyValue{Value: &pb_almanac.OtlpAnyValue_KvlistValue{KvlistValue: &pb_almanac.OtlpKeyValueList{
+			Values: []*pb_almanac.OtlpKeyValue{
+				stringPair("user", "dino"),
+				{Key: "ratio", Value: &pb_almanac.OtlpAnyValue{Value: &pb_almanac.OtlpAnyValue_DoubleValue{DoubleValue: math.Inf(1)}}},
+				{Key: "raw", Value: &pb_almanac.OtlpAnyValue{Value: &pb_almanac.OtlpAnyValue_BytesValue{BytesValue: []byte("hi")}}},
+				{Key: "tags", Value: &pb_almanac.OtlpAnyValue{Value: &pb_almanac.OtlpAnyValue_ArrayValue{ArrayValue: &pb_almanac.OtlpArrayValue{
+					Values: []*pb_almanac.OtlpAnyValue{stringValue("a"), stringValue("b")},
+				}}}},
+			},
+		}}},
+	}
+
+	// Without a time, the observed time is used. The severity name is derived from the number.
+	entryJson, err := entry(nil, nil, record)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"timestamp_ms": 2000,
+		"severity": "INFO",
+		"severity_number": 10,
+		"body": {"user": "dino", "ratio": "+Inf", "raw": "aGk=", "tags": ["a", "b"]}
+	}`, entryJson)
+}
+
+func TestSeverityName(t *testing.T) {
+	assert.Equal(t, "", severityName(0))
+	assert.Equal(t, "TRACE", severityName(1))
+	assert.Equal(t, "DEBUG", severityName(8))
+	assert.Equal(t, "WARN", severityName(13))
+	assert.Equal(t, "FATAL", severityName(24))
+	assert.Equal(t, "", severityName(25))
+}
+
+func stringValue(value string) *pb_almanac.OtlpAnyValue {
+	return &pb_almanac.OtlpAnyValue{Value: &pb_almanac.OtlpAnyValue_StringValue{StringValue: value}}
+}
+
+func stringPair(key string, value string) *pb_almanac.OtlpKeyValue {
+	return &pb_almanac.OtlpKeyValue{Key: key, Value: stringValue(value)}
+}
@@ -0,0 +1,217 @@
+package otlp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	pb_almanac "github.com/dinowernli/almanac/proto"
+)
+
+// The types below mirror the json encoding of otlp, which differs from what encoding/json makes
+// of the generated protos: keys are in lower camel case, trace and span ids are hex rather than
+// base64, and 64 bit integers may be strings.
+
+type jsonRequest struct {
+	ResourceLogs []*jsonResourceLogs `json:"resourceLogs"`
+}
+
+type jsonResourceLogs struct {
+	Resource  *jsonResource    `json:"resource"`
+	ScopeLogs []*jsonScopeLogs `json:"scopeLogs"`
+	SchemaUrl string           `json:"schemaUrl"`
+}
+
+type jsonResource struct {
+	Attributes             []*jsonKeyValue `json:"attributes"`
+	DroppedAttributesCount uint32          `json:"droppedAttributesCount"`
+}
+
+type jsonScopeLogs struct {
+	Scope      *jsonScope       `json:"scope"`
+	LogRecords []*jsonLogRecord `json:"logRecords"`
+	SchemaUrl  string           `json:"schemaUrl"`
+}
+
+type jsonScope struct {
+	Name                   string          `json:"name"`
+	Version                string          `json:"version"`
+	Attributes             []*jsonKeyValue `json:"attributes"`
+	DroppedAttributesCount uint32          `json:"droppedAttributesCount"`
+}
+
+type jsonLogRecord struct {
+	TimeUnixNano           jsonUint64      `json:"timeUnixNano"`
+	ObservedTimeUnixNano   jsonUint64      `json:"observedTimeUnixNano"`
+	SeverityNumber         int32           `json:"severityNumber"`
+	SeverityText           string          `json:"severityText"`
+	Body                   *jsonAnyValue   `json:"body"`
+	Attributes             []*jsonKeyValue `json:"attributes"`
+	DroppedAttributesCount uint32          `json:"droppedAttributesCount"`
+	Flags                  uint32          `json:"flags"`
+	TraceId                string          `json:"traceId"`
+	SpanId                 string          `json:"spanId"`
+	EventName              string          `json:"eventName"`
+}
+
+type jsonAnyValue struct {
+	StringValue *string    `json:"stringValue"`
+	BoolValue   *bool      `json:"boolValue"`
+	IntValue    *jsonInt64 `json:"intValue"`
+	DoubleValue *float64   `json:"doubleValue"`
+	ArrayValue  *struct {
+		Values []*jsonAnyValue `json:"values"`
+	} `json:"arrayValue"`
+	KvlistValue *struct {
+		Values []*jsonKeyValue `json:"values"`
+	} `json:"kvlistValue"`
+	BytesValue []byte `json:"bytesValue"`
+}
+
+type jsonKeyValue struct {
+	Key   string        `json:"key"`
+	Value *jsonAnyValue `json:"value"`
+}
+
+type jsonResponse struct {
+	PartialSuccess *jsonPartialSuccess `json:"partialSuccess,omitempty"`
+}
+
+type jsonPartialSuccess struct {
+	RejectedLogRecords int64  `json:"rejectedLogRecords,string"`
+	ErrorMessage       string `json:"errorMessage,omitempty"`
+}
+
+// jsonInt64 is a signed 64 bit integer, encoded either as a number or as a string.
+type jsonInt64 int64
+
+func (n *jsonInt64) UnmarshalJSON(data []byte) error {
+	value, err := strconv.ParseInt(strings.Trim(string(data), `"`), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid integer %s", data)
+	}
+	*n = jsonInt64(value)
+	return nil
+}
+
+// jsonUint64 is an unsigned 64 bit integer, encoded either as a number or as a string.
+type jsonUint64 uint64
+
+func (n *jsonUint64) UnmarshalJSON(data []byte) error {
+	value, err := strconv.ParseUint(strings.Trim(string(data), `"`), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid unsigned integer %s", data)
+	}
+	*n = jsonUint64(value)
+	return nil
+}
+
+// decodeJson returns the export request encoded as json in the supplied bytes.
+func decodeJson(contents []byte) (*pb_almanac.OtlpExportLogsRequest, error) {
+	request := &jsonRequest{}
+	err := json.Unmarshal(contents, request)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse json: %v", err)
+	}
+
+	result := &pb_almanac.OtlpExportLogsRequest{}
+	for _, resourceLogs := range request.ResourceLogs {
+		resultResourceLogs := &pb_almanac.OtlpResourceLogs{SchemaUrl: resourceLogs.SchemaUrl}
+		if resource := resourceLogs.Resource; resource != nil {
+			resultResourceLogs.Resource = &pb_almanac.OtlpResource{
+				Attributes:             keyValues(resource.Attributes),
+				DroppedAttributesCount: resource.DroppedAttributesCount,
+			}
+		}
+		for _, scopeLogs := range resourceLogs.ScopeLogs {
+			resultScopeLogs := &pb_almanac.OtlpScopeLogs{SchemaUrl: scopeLogs.SchemaUrl}
+			if scope := scopeLogs.Scope; scope != nil {
+				resultScopeLogs.Scope = &pb_almanac.OtlpInstrumentationScope{
+					Name:                   scope.Name,
+					Version:                scope.Version,
+					Attributes:             keyValues(scope.Attributes),
+					DroppedAttributesCount: scope.DroppedAttributesCount,
+				}
+			}
+			for _, record := range scopeLogs.LogRecords {
+				resultRecord, err := record.proto()
+				if err != nil {
+					return nil, err
+				}
+				resultScopeLogs.LogRecords = append(resultScopeLogs.LogRecords, resultRecord)
+			}
+			resultResourceLogs.ScopeLogs = append(resultResourceLogs.ScopeLogs, resultScopeLogs)
+		}
+		result.ResourceLogs = append(result.ResourceLogs, resultResourceLogs)
+	}
+	return result, nil
+}
+
+func (r *jsonLogRecord) proto() (*pb_almanac.OtlpLogRecord, error) {
+	traceId, err := hex.DecodeString(r.TraceId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trace id %q: %v", r.TraceId, err)
+	}
+	spanId, err := hex.DecodeString(r.SpanId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid span id %q: %v", r.SpanId, err)
+	}
+	return &pb_almanac.OtlpLogRecord{
+		TimeUnixNano:           uint64(r.TimeUnixNano),
+		ObservedTimeUnixNano:   uint64(r.ObservedTimeUnixNano),
+		SeverityNumber:         r.SeverityNumber,
+		SeverityText:           r.SeverityText,
+		Body:                   r.Body.proto(),
+		Attributes:             keyValues(r.Attributes),
+		DroppedAttributesCount: r.DroppedAttributesCount,
+		Flags:                  r.Flags,
+		TraceId:                traceId,
+		SpanId:                 spanId,
+		EventName:              r.EventName,
+	}, nil
+}
+
+// proto returns the equivalent of the supplied value, which is nil if the value is nil.
+func (v *jsonAnyValue) proto() *pb_almanac.OtlpAnyValue {
+	switch {
+	case v == nil:
+		return nil
+	case v.StringValue != nil:
+		return &pb_almanac.OtlpAnyValue{Value: &pb_almanac.OtlpAnyValue_StringValue{StringValue: *v.StringValue}}
+	case v.BoolValue != nil:
+		return &pb_almanac.OtlpAnyValue{Value: &pb_almanac.OtlpAnyValue_BoolValue{BoolValue: *v.BoolValue}}
+	case v.IntValue != nil:
+		return &pb_almanac.OtlpAnyValue{Value: &pb_almanac.OtlpAnyValue_IntValue{IntValue: int64(*v.IntValue)}}
+	case v.DoubleValue != nil:
+		return &pb_almanac.OtlpAnyValue{Value: &pb_almanac.OtlpAnyValue_DoubleValue{DoubleValue: *v.DoubleValue}}
+	case v.ArrayValue != nil:
+		values := []*pb_almanac.OtlpAnyValue{}
+		for _, element := range v.ArrayValue.Values {
+			values = append(values, element.proto())
+		}
+		return &pb_almanac.OtlpAnyValue{Value: &pb_almanac.OtlpAnyValue_ArrayValue{ArrayValue: &pb_almanac.OtlpArrayValue{Values: values}}}
+	case v.KvlistValue != nil:
+		return &pb_almanac.OtlpAnyValue{Value: &pb_almanac.OtlpAnyValue_KvlistValue{KvlistValue: &pb_almanac.OtlpKeyValueList{Values: keyValues(v.KvlistValue.Values)}}}
+	case v.BytesValue != nil:
+		return &pb_almanac.OtlpAnyValue{Value: &pb_almanac.OtlpAnyValue_BytesValue{BytesValue: v.BytesValue}}
+	}
+	return &pb_almanac.OtlpAnyValue{}
+}
+
+func keyValues(pairs []*jsonKeyValue) []*pb_almanac.OtlpKeyValue {
+	result := []*pb_almanac.OtlpKeyValue{}
+	for _, pair := range pairs {
+		result = append(result, &pb_almanac.OtlpKeyValue{Key: pair.Key, Value: pair.Value.proto()})
+	}
+	return result
+}
+
+func jsonResponseOf(response *pb_almanac.OtlpExportLogsResponse) *jsonResponse {
+	result := &jsonResponse{}
+	if partial := response.PartialSuccess; partial != nil {
+		result.PartialSuccess = &jsonPartialSuccess{RejectedLogRecords: partial.RejectedLogRecords, ErrorMessage: partial.ErrorMessage}
+	}
+	return result
+}
@@ -0,0 +1,252 @@
+// Package otlp receives logs sent with the opentelemetry protocol, over grpc and over http with
+// either protobuf or json bodies, and ingests each log record as an entry.
+package otlp
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/dinowernli/almanac/pkg/tenant"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	// HttpPath is the path to which otlp exporters send logs over http.
+	HttpPath = "/v1/logs"
+
+	httpExportTimeout = 10 * time.Second
+
+	// maxRequestBytes is the size of the largest request body accepted, after decompression.
+	maxRequestBytes = 16 * 1024 * 1024
+
+	contentTypeJson     = "application/json"
+	contentTypeProtobuf = "application/x-protobuf"
+)
+
+var (
+	exportField = logrus.Fields{"method": "otlp.Export"}
+)
+
+// logsServer is the logs service of otlp, see serviceDesc.
+type logsServer interface {
+	Export(context.Context, *pb_almanac.OtlpExportLogsRequest) (*pb_almanac.OtlpExportLogsResponse, error)
+}
+
+// serviceDesc describes the logs service of otlp. The service lives in a proto package of its
+// own, so it cannot be generated alongside the messages mirroring those of otlp.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "opentelemetry.proto.collector.logs.v1.LogsService",
+	HandlerType: (*logsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Export",
+			Handler:    exportHandler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/otlp.proto",
+}
+
+func exportHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(pb_almanac.OtlpExportLogsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(logsServer).Export(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/opentelemetry.proto.collector.logs.v1.LogsService/Export",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(logsServer).Export(ctx, req.(*pb_almanac.OtlpExportLogsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Receiver implements the logs service of otlp by passing each log record on to an ingester.
+type Receiver struct {
+	logger   *logrus.Logger
+	ingester pb_almanac.IngesterServer
+}
+
+// New returns a receiver which ingests the records it receives using the supplied ingester.
+func New(logger *logrus.Logger, ingester pb_almanac.IngesterServer) *Receiver {
+	return &Receiver{logger: logger, ingester: ingester}
+}
+
+// RegisterGrpc registers the logs service on the supplied server.
+func (r *Receiver) RegisterGrpc(server *grpc.Server) {
+	server.RegisterService(&serviceDesc, r)
+}
+
+// RegisterHttp registers the http endpoint of the logs service on the supplied server.
+func (r *Receiver) RegisterHttp(server *http.ServeMux) {
+	server.HandleFunc(HttpPath, prometheus.InstrumentHandlerFunc(HttpPath, r.handleHttp))
+}
+
+// Export ingests the records of the supplied request into the tenant named in the incoming grpc
+// metadata.
+func (r *Receiver) Export(ctx context.Context, request *pb_almanac.OtlpExportLogsRequest) (*pb_almanac.OtlpExportLogsResponse, error) {
+	tenantId, err := tenant.Resolve(ctx, "")
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "unable to determine tenant: %v", err)
+	}
+	return r.export(ctx, tenantId, request)
+}
+
+// export ingests the records of the supplied request one by one. Records the ingester rejects as
+// invalid are counted in the partial success of the response, which exporters do not retry. Any
+// other failure fails the whole request with a code which exporters do retry, even though the
+// records before the failing one have been ingested already.
+func (r *Receiver) export(ctx context.Context, tenantId string, request *pb_almanac.OtlpExportLogsRequest) (*pb_almanac.OtlpExportLogsResponse, error) {
+	logger := r.logger.WithFields(exportField).WithFields(logrus.Fields{"tenant": tenantId})
+
+	ingested := 0
+	rejected := int64(0)
+	var firstRejection error
+	for _, resourceLogs := range request.ResourceLogs {
+		for _, scopeLogs := range resourceLogs.ScopeLogs {
+			for _, record := range scopeLogs.LogRecords {
+				entryJson, err := entry(resourceLogs.Resource, scopeLogs.Scope, record)
+				if err != nil {
+					err = grpc.Errorf(codes.InvalidArgument, "%v", err)
+				} else {
+					_, err = r.ingester.Ingest(ctx, &pb_almanac.IngestRequest{EntryJson: entryJson, Tenant: tenantId, Format: pb_almanac.IngestRequest_JSON})
+				}
+
+				switch grpc.Code(err) {
+				case codes.OK:
+					ingested++
+				case codes.InvalidArgument:
+					rejected++
+					if firstRejection == nil {
+						firstRejection = err
+					}
+				case codes.ResourceExhausted:
+					return nil, grpc.Errorf(codes.ResourceExhausted, "ingested %d records, then failed: %v", ingested, err)
+				default:
+					return nil, grpc.Errorf(codes.Unavailable, "ingested %d records, then failed: %v", ingested, err)
+				}
+			}
+		}
+	}
+
+	response := &pb_almanac.OtlpExportLogsResponse{}
+	if rejected > 0 {
+		response.PartialSuccess = &pb_almanac.OtlpExportLogsPartialSuccess{
+			RejectedLogRecords: rejected,
+			ErrorMessage:       fmt.Sprintf("rejected %d records, the first with: %v", rejected, grpc.ErrorDesc(firstRejection)),
+		}
+		logger.WithError(firstRejection).Warnf("Rejected %d of %d records", rejected, int64(ingested)+rejected)
+	}
+	return response, nil
+}
+
+// handleHttp serves export requests made over http, which have a protobuf or a json body,
+// possibly gzipped. The response has the same content type as the request.
+func (r *Receiver) handleHttp(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "export must use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	tenantId, err := tenant.FromHttp(request)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	contentType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("invalid content type: %v", err), http.StatusBadRequest)
+		return
+	}
+	if contentType != contentTypeJson && contentType != contentTypeProtobuf {
+		message := fmt.Sprintf("unsupported content type %s, must be %s or %s", contentType, contentTypeJson, contentTypeProtobuf)
+		http.Error(writer, message, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	contents, err := readBody(request)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	exportRequest := &pb_almanac.OtlpExportLogsRequest{}
+	if contentType == contentTypeJson {
+		exportRequest, err = decodeJson(contents)
+	} else {
+		err = proto.Unmarshal(contents, exportRequest)
+	}
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("unable to decode request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), httpExportTimeout)
+	defer cancel()
+	response, err := r.export(ctx, tenantId, exportRequest)
+	if err != nil {
+		http.Error(writer, err.Error(), httpStatus(err))
+		return
+	}
+
+	var result []byte
+	if contentType == contentTypeJson {
+		result, err = json.Marshal(jsonResponseOf(response))
+	} else {
+		result, err = proto.Marshal(response)
+	}
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("unable to encode response: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", contentType)
+	writer.Write(result)
+}
+
+// readBody returns the body of the supplied request, decompressed if necessary.
+func readBody(request *http.Request) ([]byte, error) {
+	var body io.Reader = request.Body
+	if request.Header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decompress body: %v", err)
+		}
+		defer reader.Close()
+		body = reader
+	}
+	contents, err := ioutil.ReadAll(io.LimitReader(body, maxRequestBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read body: %v", err)
+	}
+	if len(contents) > maxRequestBytes {
+		return nil, fmt.Errorf("body exceeds %d bytes", maxRequestBytes)
+	}
+	return contents, nil
+}
+
+// httpStatus returns the http status which tells exporters whether to retry after the supplied
+// error. Exporters retry on 429 and 503, but drop requests failing with other statuses.
+func httpStatus(err error) int {
+	switch grpc.Code(err) {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	}
+	return http.StatusServiceUnavailable
+}
@@ -0,0 +1,246 @@
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dinowernli/almanac/pkg/tenant"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	testTenant       = "telemetry"
+	exportFullMethod = "/opentelemetry.proto.collector.logs.v1.LogsService/Export"
+)
+
+func TestExportGrpc(t *testing.T) {
+	ingester := &fakeIngester{failures: map[string]error{"bad": grpc.Errorf(codes.InvalidArgument, "bad entry")}}
+	conn, stop := startGrpc(t, ingester)
+	defer stop()
+
+	request := exportRequest(stringValue("first"), stringValue("bad"), stringValue("second"))
+	response := &pb_almanac.OtlpExportLogsResponse{}
+	ctx := tenant.NewOutgoingContext(context.Background(), testTenant)
+	err := conn.Invoke(ctx, exportFullMethod, request, response)
+	assert.NoError(t, err)
+
+	// The rejected record is reported, the others are ingested.
+	assert.Equal(t, int64(1), response.PartialSuccess.RejectedLogRecords)
+	assert.Contains(t, response.PartialSuccess.ErrorMessage, "bad entry")
+	assert.Equal(t, 2, len(ingester.requests))
+	for _, r := range ingester.requests {
+		assert.Equal(t, testTenant, r.Tenant)
+	}
+	assert.JSONEq(t, `{"timestamp_ms": 1000, "message": "first", "service_name": "shop", "resource": {"service.name": "shop"}}`, ingester.requests[0].EntryJson)
+}
+
+func TestExportGrpcFailsRetryably(t *testing.T) {
+	ingester := &fakeIngester{failures: map[string]error{
+		"limited": grpc.Errorf(codes.ResourceExhausted, "too many entries"),
+		"broken":  grpc.Errorf(codes.Internal, "no appenders"),
+	}}
+	conn, stop := startGrpc(t, ingester)
+	defer stop()
+	ctx := tenant.NewOutgoingContext(context.Background(), testTenant)
+
+	err := conn.Invoke(ctx, exportFullMethod, exportRequest(stringValue("limited")), &pb_almanac.OtlpExportLogsResponse{})
+	assert.Equal(t, codes.ResourceExhausted, grpc.Code(err))
+
+	err = conn.Invoke(ctx, exportFullMethod, exportRequest(stringValue("first"), stringValue("broken")), &pb_almanac.OtlpExportLogsResponse{})
+	assert.Equal(t, codes.Unavailable, grpc.Code(err))
+	assert.Contains(t, grpc.ErrorDesc(err), "ingested 1 records")
+}
+
+func TestExportHttpProtobuf(t *testing.T) {
+	ingester := &fakeIngester{}
+	server := startHttp(ingester)
+	defer server.Close()
+
+	body, err := proto.Marshal(exportRequest(stringValue("hello")))
+	assert.NoError(t, err)
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write(body)
+	writer.Close()
+
+	request, err := http.NewRequest(http.MethodPost, server.URL+HttpPath, &compressed)
+	assert.NoError(t, err)
+	request.Header.Set("Content-Type", contentTypeProtobuf)
+	request.Header.Set("Content-Encoding", "gzip")
+	request.Header.Set(tenant.HttpHeader, testTenant)
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, contentTypeProtobuf, response.Header.Get("Content-Type"))
+	contents, err := ioutil.ReadAll(response.Body)
+	assert.NoError(t, err)
+	exportResponse := &pb_almanac.OtlpExportLogsResponse{}
+	assert.NoError(t, proto.Unmarshal(contents, exportResponse))
+	assert.Nil(t, exportResponse.PartialSuccess)
+
+	assert.Equal(t, 1, len(ingester.requests))
+	assert.Equal(t, testTenant, ingester.requests[0].Tenant)
+	assert.Contains(t, ingester.requests[0].EntryJson, `"message":"hello"`)
+}
+
+func TestExportHttpJson(t *testing.T) {
+	ingester := &fakeIngester{failures: map[string]error{"bad": grpc.Errorf(codes.InvalidArgument, "bad entry")}}
+	server := startHttp(ingester)
+	defer server.Close()
+
+	body := `{"resourceLogs": [{
+		"resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "shop"}}]},
+		"scopeLogs": [{
+			"scope": {"name": "logger"},
+			"logRecords": [{
+				"timeUnixNano": "1500000000123456789",
+				"severityNumber": 9,
+				"body": {"stringValue": "hello"},
+				"traceId": "5b8efff798038103d269b633813fc60c",
+				"spanId": "eee19b7ec3c1b174",
+				"attributes": [
+					{"key": "count", "value": {"intValue": "42"}},
+					{"key": "ratio", "value": {"doubleValue": 0.5}},
+					{"key": "tags", "value": {"arrayValue": {"values": [{"stringValue": "a"}]}}}
+				]
+			}, {
+				"body": {"stringValue": "bad"}
+			}]
+		}]
+	}]}`
+	response := postHttp(t, server, contentTypeJson, body)
+	defer response.Body.Close()
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	contents, err := ioutil.ReadAll(response.Body)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"partialSuccess": {"rejectedLogRecords": "1", "errorMessage": "rejected 1 records, the first with: bad entry"}}`, string(contents))
+
+	assert.Equal(t, 1, len(ingester.requests))
+	assert.JSONEq(t, `{
+		"timestamp_ms": 1500000000123,
+		"severity": "INFO",
+		"severity_number": 9,
+		"message": "hello",
+		"trace_id": "5b8efff798038103d269b633813fc60c",
+		"span_id": "eee19b7ec3c1b174",
+		"attributes": {"count": 42, "ratio": 0.5, "tags": ["a"]},
+		"resource": {"service.name": "shop"},
+		"scope": {"name": "logger"},
+		"service_name": "shop"
+	}`, ingester.requests[0].EntryJson)
+}
+
+func TestExportHttpStatus(t *testing.T) {
+	ingester := &fakeIngester{failures: map[string]error{
+		"limited": grpc.Errorf(codes.ResourceExhausted, "too many entries"),
+		"broken":  grpc.Errorf(codes.Internal, "no appenders"),
+	}}
+	server := startHttp(ingester)
+	defer server.Close()
+
+	cases := []struct {
+		contentType string
+		body        string
+		status      int
+	}{
+		{contentTypeJson, `{"resourceLogs": [{"scopeLogs": [{"logRecords": [{"body": {"stringValue": "limited"}}]}]}]}`, http.StatusTooManyRequests},
+		{contentTypeJson, `{"resourceLogs": [{"scopeLogs": [{"logRecords": [{"body": {"stringValue": "broken"}}]}]}]}`, http.StatusServiceUnavailable},
+		{contentTypeJson, `{"resourceLogs": [{"scopeLogs": [{"logRecords": [{"traceId": "xyz"}]}]}]}`, http.StatusBadRequest},
+		{contentTypeJson, `{"resourceLogs": [{"scopeLogs": [{"logRecords": [{"timeUnixNano": "soon"}]}]}]}`, http.StatusBadRequest},
+		{contentTypeProtobuf, "not a proto", http.StatusBadRequest},
+		{"text/plain", "hello", http.StatusUnsupportedMediaType},
+	}
+	for _, c := range cases {
+		response := postHttp(t, server, c.contentType, c.body)
+		response.Body.Close()
+		assert.Equal(t, c.status, response.StatusCode, c.body)
+	}
+	assert.Equal(t, 0, len(ingester.requests))
+}
+
+// exportRequest returns a request with a record for each of the supplied bodies, one second
+// apart, all of them from the same resource.
+func exportRequest(bodies ...*pb_almanac.OtlpAnyValue) *pb_almanac.OtlpExportLogsRequest {
+	scopeLogs := &pb_almanac.OtlpScopeLogs{}
+	for i, body := range bodies {
+		scopeLogs.LogRecords = append(scopeLogs.LogRecords, &pb_almanac.OtlpLogRecord{
+			TimeUnixNano: uint64(i+1) * 1000000000,
+			Body:         body,
+		})
+	}
+	return &pb_almanac.OtlpExportLogsRequest{ResourceLogs: []*pb_almanac.OtlpResourceLogs{{
+		Resource:  &pb_almanac.OtlpResource{Attributes: []*pb_almanac.OtlpKeyValue{stringPair("service.name", "shop")}},
+		ScopeLogs: []*pb_almanac.OtlpScopeLogs{scopeLogs},
+	}}}
+}
+
+// startGrpc serves the logs service on a local port and returns a connection to it, along with
+// a function which closes both.
+func startGrpc(t *testing.T, ingester *fakeIngester) (*grpc.ClientConn, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	server := grpc.NewServer()
+	New(logrus.New(), ingester).RegisterGrpc(server)
+	go server.Serve(listener)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	return conn, func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func startHttp(ingester *fakeIngester) *httptest.Server {
+	mux := http.NewServeMux()
+	New(logrus.New(), ingester).RegisterHttp(mux)
+	return httptest.NewServer(mux)
+}
+
+func postHttp(t *testing.T, server *httptest.Server, contentType string, body string) *http.Response {
+	request, err := http.NewRequest(http.MethodPost, server.URL+HttpPath, strings.NewReader(body))
+	assert.NoError(t, err)
+	request.Header.Set("Content-Type", contentType)
+	request.Header.Set(tenant.HttpHeader, testTenant)
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+	return response
+}
+
+// fakeIngester records the requests it accepts, and fails those whose entry contains one of the
+// keys of failures with the corresponding error.
+type fakeIngester struct {
+	failures map[string]error
+
+	mutex    sync.Mutex
+	requests []*pb_almanac.IngestRequest
+}
+
+func (i *fakeIngester) Ingest(ctx context.Context, request *pb_almanac.IngestRequest) (*pb_almanac.IngestResponse, error) {
+	for marker, err := range i.failures {
+		if strings.Contains(request.EntryJson, marker) {
+			return nil, err
+		}
+	}
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.requests = append(i.requests, request)
+	return &pb_almanac.IngestResponse{}, nil
+}
@@ -0,0 +1,154 @@
+package admin
+
+import (
+	"time"
+
+	st "github.com/dinowernli/almanac/pkg/storage"
+	"github.com/dinowernli/almanac/pkg/tenant"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+var (
+	purgeField            = logrus.Fields{"method": "admin.Purge"}
+	listTombstonesField   = logrus.Fields{"method": "admin.ListTombstones"}
+	listAuditRecordsField = logrus.Fields{"method": "admin.ListAuditRecords"}
+)
+
+// Admin is an implementation of the admin rpc service. It allows operators to purge log
+// entries from the system and to inspect the resulting audit trail. All calls act on the tenant
+// named in the grpc metadata.
+type Admin struct {
+	logger  *logrus.Logger
+	storage *st.Storage
+
+	// enablePurge is whether purges are accepted. Purges cannot be undone, so they should only be
+	// enabled once callers have to authenticate.
+	enablePurge bool
+}
+
+// New returns a new admin service backed by the supplied storage. Purges are refused unless
+// enablePurge is set.
+func New(logger *logrus.Logger, storage *st.Storage, enablePurge bool) *Admin {
+	return &Admin{logger: logger, storage: storage, enablePurge: enablePurge}
+}
+
+func (a *Admin) Purge(ctx context.Context, request *pb_almanac.PurgeRequest) (*pb_almanac.PurgeResponse, error) {
+	logger := a.logger.WithFields(purgeField)
+	if !a.enablePurge {
+		err := grpc.Errorf(codes.FailedPrecondition, "purges are disabled")
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+	tenantId, err := tenant.Resolve(ctx, tenant.Default)
+	if err != nil {
+		err := grpc.Errorf(codes.InvalidArgument, "unable to determine tenant: %v", err)
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+	logger = logger.WithFields(logrus.Fields{"tenant": tenantId})
+	if request.Query == "" {
+		err := grpc.Errorf(codes.InvalidArgument, "must supply a query")
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+	if request.EndMs != 0 && request.StartMs > request.EndMs {
+		err := grpc.Errorf(codes.InvalidArgument, "cannot purge, start(%d) is greater than end (%d)", request.StartMs, request.EndMs)
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+	if request.Action != pb_almanac.PurgeAction_DROP && request.Action != pb_almanac.PurgeAction_REDACT {
+		err := grpc.Errorf(codes.InvalidArgument, "unsupported action: %v", request.Action)
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+	if request.Action == pb_almanac.PurgeAction_REDACT && len(request.RedactFields) == 0 {
+		err := grpc.Errorf(codes.InvalidArgument, "must supply fields to redact")
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+
+	createdMs := time.Now().UnixNano() / int64(time.Millisecond)
+	tombstone := &pb_almanac.Tombstone{
+		Id:           st.NewTombstoneId(createdMs),
+		Query:        request.Query,
+		StartMs:      request.StartMs,
+		EndMs:        request.EndMs,
+		Action:       request.Action,
+		RedactFields: request.RedactFields,
+		Reason:       request.Reason,
+		CreatedMs:    createdMs,
+		Tenant:       tenantId,
+	}
+	logger = logger.WithFields(logrus.Fields{"tombstone": tombstone.Id, "reason": tombstone.Reason})
+
+	err = a.storage.StoreTombstone(ctx, tombstone)
+	if err != nil {
+		err := grpc.Errorf(codes.Internal, "unable to store tombstone: %v", err)
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+
+	logger.Infof("Handled")
+	return &pb_almanac.PurgeResponse{Tombstone: tombstone}, nil
+}
+
+func (a *Admin) ListTombstones(ctx context.Context, request *pb_almanac.ListTombstonesRequest) (*pb_almanac.ListTombstonesResponse, error) {
+	logger := a.logger.WithFields(listTombstonesField)
+	tenantId, err := tenant.Resolve(ctx, tenant.Default)
+	if err != nil {
+		err := grpc.Errorf(codes.InvalidArgument, "unable to determine tenant: %v", err)
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+	logger = logger.WithFields(logrus.Fields{"tenant": tenantId})
+
+	tombstones, err := a.storage.ListTombstones(ctx)
+	if err != nil {
+		err := grpc.Errorf(codes.Internal, "unable to list tombstones: %v", err)
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+
+	result := []*pb_almanac.Tombstone{}
+	for _, t := range tombstones {
+		if t.Tenant == tenantId {
+			result = append(result, t)
+		}
+	}
+
+	logger.Infof("Handled")
+	return &pb_almanac.ListTombstonesResponse{Tombstones: result}, nil
+}
+
+func (a *Admin) ListAuditRecords(ctx context.Context, request *pb_almanac.ListAuditRecordsRequest) (*pb_almanac.ListAuditRecordsResponse, error) {
+	logger := a.logger.WithFields(listAuditRecordsField)
+	tenantId, err := tenant.Resolve(ctx, tenant.Default)
+	if err != nil {
+		err := grpc.Errorf(codes.InvalidArgument, "unable to determine tenant: %v", err)
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+	logger = logger.WithFields(logrus.Fields{"tenant": tenantId})
+
+	records, err := a.storage.ListAuditRecords(ctx, request.TombstoneId)
+	if err != nil {
+		err := grpc.Errorf(codes.Internal, "unable to list audit records: %v", err)
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+
+	result := []*pb_almanac.AuditRecord{}
+	for _, r := range records {
+		if r.OldChunkId.GetTenant() == tenantId {
+			result = append(result, r)
+		}
+	}
+
+	logger.Infof("Handled")
+	return &pb_almanac.ListAuditRecordsResponse{Records: result}, nil
+}
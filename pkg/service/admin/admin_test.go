@@ -0,0 +1,89 @@
+package admin
+
+import (
+	"testing"
+
+	st "github.com/dinowernli/almanac/pkg/storage"
+	"github.com/dinowernli/almanac/pkg/tenant"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestPurgeRecordsTombstone(t *testing.T) {
+	storage, err := st.NewMemoryStorage()
+	assert.NoError(t, err)
+	admin := New(logrus.New(), storage, true)
+
+	response, err := admin.Purge(context.Background(), &pb_almanac.PurgeRequest{
+		Query:  "user:alice",
+		Action: pb_almanac.PurgeAction_DROP,
+		Reason: "ticket-123",
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, response.Tombstone.Id)
+	assert.Equal(t, "ticket-123", response.Tombstone.Reason)
+
+	listResponse, err := admin.ListTombstones(context.Background(), &pb_almanac.ListTombstonesRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, []*pb_almanac.Tombstone{response.Tombstone}, listResponse.Tombstones)
+}
+
+func TestTombstonesBelongToTenant(t *testing.T) {
+	storage, err := st.NewMemoryStorage()
+	assert.NoError(t, err)
+	admin := New(logrus.New(), storage, true)
+
+	acme := metadata.NewIncomingContext(context.Background(), metadata.Pairs(tenant.MetadataKey, "acme"))
+	response, err := admin.Purge(acme, &pb_almanac.PurgeRequest{Query: "user:alice", Action: pb_almanac.PurgeAction_DROP})
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", response.Tombstone.Tenant)
+
+	listResponse, err := admin.ListTombstones(acme, &pb_almanac.ListTombstonesRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, []*pb_almanac.Tombstone{response.Tombstone}, listResponse.Tombstones)
+
+	listResponse, err = admin.ListTombstones(context.Background(), &pb_almanac.ListTombstonesRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, listResponse.Tombstones)
+}
+
+func TestPurgeValidation(t *testing.T) {
+	storage, err := st.NewMemoryStorage()
+	assert.NoError(t, err)
+	admin := New(logrus.New(), storage, true)
+
+	_, err = admin.Purge(context.Background(), &pb_almanac.PurgeRequest{Action: pb_almanac.PurgeAction_DROP})
+	assert.Error(t, err)
+
+	_, err = admin.Purge(context.Background(), &pb_almanac.PurgeRequest{Query: "foo"})
+	assert.Error(t, err)
+
+	_, err = admin.Purge(context.Background(), &pb_almanac.PurgeRequest{Query: "foo", Action: pb_almanac.PurgeAction_REDACT})
+	assert.Error(t, err)
+
+	_, err = admin.Purge(context.Background(), &pb_almanac.PurgeRequest{Query: "foo", Action: pb_almanac.PurgeAction_DROP, StartMs: 10, EndMs: 5})
+	assert.Error(t, err)
+
+	listResponse, err := admin.ListTombstones(context.Background(), &pb_almanac.ListTombstonesRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, listResponse.Tombstones)
+}
+
+func TestPurgeDisabled(t *testing.T) {
+	storage, err := st.NewMemoryStorage()
+	assert.NoError(t, err)
+	admin := New(logrus.New(), storage, false)
+
+	_, err = admin.Purge(context.Background(), &pb_almanac.PurgeRequest{Query: "foo", Action: pb_almanac.PurgeAction_DROP})
+	assert.Equal(t, codes.FailedPrecondition, grpc.Code(err))
+
+	listResponse, err := admin.ListTombstones(context.Background(), &pb_almanac.ListTombstonesRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, listResponse.Tombstones)
+}
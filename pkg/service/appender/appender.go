@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/dinowernli/almanac/pkg/storage"
+	"github.com/dinowernli/almanac/pkg/tenant"
 	pb_almanac "github.com/dinowernli/almanac/proto"
 
 	"github.com/sirupsen/logrus"
@@ -19,6 +20,12 @@ const (
 	// them out to storage. This should be longer than the typical time it takes
 	// to serve a serach request on a mixer.
 	closedChunkGracePeriodMs = 1000
+
+	// Failed attempts to store a chunk are retried with exponential backoff. Once all attempts
+	// have failed, the chunk is spilled to local disk if possible, or retried indefinitely if not.
+	maxStoreAttempts    = 6
+	initialStoreBackoff = 100 * time.Millisecond
+	maxStoreBackoff     = 10 * time.Second
 )
 
 var (
@@ -30,21 +37,59 @@ var (
 // periodically decides that the open chunk is complete, at which point the
 // appender writes the chunk to storage. The appender also knows how to answer
 // search requests for the currently open chunk.
+//
+// Entries of different tenants never share a chunk, and searches only ever
+// see the chunks of the tenant they are made for.
+//
+// If stream labels are configured, entries are only ever added to chunks whose
+// labels match the values of those fields in the entry, such that each chunk
+// holds the entries of a single stream.
 type Appender struct {
-	logger  *logrus.Logger
-	storage *storage.Storage
+	logger       *logrus.Logger
+	storage      *storage.Storage
+	streamLabels []string
 
 	openChunks       []*openChunk
 	openChunksMutex  *sync.Mutex
 	closedChunksChan chan *openChunk
 
+	// pendingChunks tracks the chunks which have been opened but not yet written to storage.
+	// Once closing is set, guarded by openChunksMutex, no new chunks are opened.
+	pendingChunks *sync.WaitGroup
+	closing       bool
+
+	// droppedChunks counts the closed chunks which could not be turned into protos and were thus
+	// never stored, guarded by openChunksMutex.
+	droppedChunks int
+
+	// The limits applied to newly opened chunks, guarded by openChunksMutex.
 	maxChunkEntries  int
 	maxChunkSpread   time.Duration
 	maxChunkOpenTime time.Duration
+
+	// The entries held in memory, both in open chunks and in closed chunks not yet removed, and
+	// the limits beyond which new entries are rejected. Guarded by openChunksMutex.
+	numEntries       int
+	numBytes         int64
+	maxMemoryEntries int
+	maxMemoryBytes   int64
+
+	metrics *appenderMetrics
+
+	// spill holds the chunks which could not be written to storage, if set.
+	spill *Spill
+
+	// The retry behavior when storing chunks, see the constants above.
+	maxStoreAttempts    int
+	initialStoreBackoff time.Duration
 }
 
-// New returns a new appender backed by the supplied storage.
-func New(logger *logrus.Logger, storage *storage.Storage, maxChunkEntries int, maxChunkSpread time.Duration, maxChunkOpenTime time.Duration) (*Appender, error) {
+// New returns a new appender backed by the supplied storage. Open chunks are keyed by the values
+// of the top-level entry fields named in streamLabels. The appender rejects new entries while it
+// holds more than maxMemoryEntries entries or maxMemoryBytes bytes of entries in memory, e.g.,
+// because storage is slow. Chunks which repeatedly fail to be stored end up in the supplied spill,
+// unless it is nil.
+func New(logger *logrus.Logger, storage *storage.Storage, streamLabels []string, maxChunkEntries int, maxChunkSpread time.Duration, maxChunkOpenTime time.Duration, maxMemoryEntries int, maxMemoryBytes int64, spill *Spill) (*Appender, error) {
 	if maxChunkEntries < 1 {
 		return nil, fmt.Errorf("max entries per chunk must be greater than 0, but got %d", maxChunkEntries)
 	}
@@ -54,18 +99,46 @@ func New(logger *logrus.Logger, storage *storage.Storage, maxChunkEntries int, m
 	if maxChunkOpenTime <= 0 {
 		return nil, fmt.Errorf("must have positive max chunk open time, but got: %d", maxChunkOpenTime)
 	}
+	err := validateMemoryLimits(maxMemoryEntries, maxMemoryBytes)
+	if err != nil {
+		return nil, err
+	}
+	seenLabels := map[string]bool{}
+	for _, label := range streamLabels {
+		if label == "" {
+			return nil, fmt.Errorf("stream labels cannot be empty")
+		}
+		if seenLabels[label] {
+			return nil, fmt.Errorf("duplicate stream label %s", label)
+		}
+		seenLabels[label] = true
+	}
+	metrics, err := sharedMetrics()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create appender metrics: %v", err)
+	}
 
 	result := &Appender{
-		logger:  logger,
-		storage: storage,
+		logger:       logger,
+		storage:      storage,
+		streamLabels: streamLabels,
 
 		openChunks:       []*openChunk{},
 		openChunksMutex:  &sync.Mutex{},
 		closedChunksChan: make(chan *openChunk),
+		pendingChunks:    &sync.WaitGroup{},
 
 		maxChunkEntries:  maxChunkEntries,
 		maxChunkSpread:   maxChunkSpread,
 		maxChunkOpenTime: maxChunkOpenTime,
+
+		maxMemoryEntries: maxMemoryEntries,
+		maxMemoryBytes:   maxMemoryBytes,
+		metrics:          metrics,
+
+		spill:               spill,
+		maxStoreAttempts:    maxStoreAttempts,
+		initialStoreBackoff: initialStoreBackoff,
 	}
 
 	// Kick of the background goroutine which sends closed chunks to storage.
@@ -74,15 +147,80 @@ func New(logger *logrus.Logger, storage *storage.Storage, maxChunkEntries int, m
 	return result, nil
 }
 
+// SetChunkLimits changes the limits applied to chunks opened from now on. Chunks which are
+// already open keep the limits they were opened with.
+func (a *Appender) SetChunkLimits(maxChunkEntries int, maxChunkSpread time.Duration, maxChunkOpenTime time.Duration) error {
+	if maxChunkEntries < 1 {
+		return fmt.Errorf("max entries per chunk must be greater than 0, but got %d", maxChunkEntries)
+	}
+	if maxChunkSpread <= 0 {
+		return fmt.Errorf("must have positive chunk spread, but got: %d", maxChunkSpread)
+	}
+	if maxChunkOpenTime <= 0 {
+		return fmt.Errorf("must have positive max chunk open time, but got: %d", maxChunkOpenTime)
+	}
+
+	a.openChunksMutex.Lock()
+	defer a.openChunksMutex.Unlock()
+	a.maxChunkEntries = maxChunkEntries
+	a.maxChunkSpread = maxChunkSpread
+	a.maxChunkOpenTime = maxChunkOpenTime
+	return nil
+}
+
+// SetMemoryLimits changes how many entries and bytes of entries the appender holds in memory
+// before rejecting new entries.
+func (a *Appender) SetMemoryLimits(maxMemoryEntries int, maxMemoryBytes int64) error {
+	err := validateMemoryLimits(maxMemoryEntries, maxMemoryBytes)
+	if err != nil {
+		return err
+	}
+
+	a.openChunksMutex.Lock()
+	defer a.openChunksMutex.Unlock()
+	a.maxMemoryEntries = maxMemoryEntries
+	a.maxMemoryBytes = maxMemoryBytes
+	return nil
+}
+
+func validateMemoryLimits(maxMemoryEntries int, maxMemoryBytes int64) error {
+	if maxMemoryEntries < 1 {
+		return fmt.Errorf("max entries in memory must be greater than 0, but got %d", maxMemoryEntries)
+	}
+	if maxMemoryBytes < 1 {
+		return fmt.Errorf("max bytes in memory must be greater than 0, but got %d", maxMemoryBytes)
+	}
+	return nil
+}
+
 func (a *Appender) Search(ctx context.Context, request *pb_almanac.SearchRequest) (*pb_almanac.SearchResponse, error) {
 	logger := a.logger.WithFields(searchField)
 
+	tenantId, err := tenant.Resolve(ctx, request.Tenant)
+	if err != nil {
+		err := grpc.Errorf(codes.InvalidArgument, "unable to determine tenant: %v", err)
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+	logger = logger.WithFields(logrus.Fields{"tenant": tenantId})
+
+	selector, err := storage.NewLabelSelector(request.LabelSelector)
+	if err != nil {
+		err := grpc.Errorf(codes.InvalidArgument, "invalid label selector: %v", err)
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+
 	a.openChunksMutex.Lock()
 	defer a.openChunksMutex.Unlock()
 
 	results := []*pb_almanac.LogEntry{}
 	for _, chunk := range a.openChunks {
-		entries, err := chunk.search(ctx, request)
+		if chunk.tenant != tenantId {
+			continue
+		}
+
+		entries, err := chunk.search(ctx, request, selector)
 		if err != nil {
 			err := fmt.Errorf("unable to search open chunk: %v", err)
 			logger.WithError(err).Warnf("Failed")
@@ -117,13 +255,47 @@ func (a *Appender) Append(ctx context.Context, request *pb_almanac.AppendRequest
 	}
 	logger = logger.WithFields(logrus.Fields{"entry": entryId})
 
+	tenantId, err := tenant.Resolve(ctx, request.Tenant)
+	if err != nil {
+		err := grpc.Errorf(codes.InvalidArgument, "unable to determine tenant: %v", err)
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+	logger = logger.WithFields(logrus.Fields{"tenant": tenantId})
+
 	a.openChunksMutex.Lock()
 	defer a.openChunksMutex.Unlock()
 
-	// Try to find an open chunk which can accept the entry.
+	if a.closing {
+		err := grpc.Errorf(codes.Unavailable, "appender is shutting down")
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+
+	// Push back if we are holding too much in memory, such that the ingester tries elsewhere.
+	if a.numEntries+1 > a.maxMemoryEntries || a.numBytes+int64(len(entry.EntryJson)) > a.maxMemoryBytes {
+		a.metrics.numRejected.Inc()
+		err := grpc.Errorf(codes.ResourceExhausted, "holding %d entries (%d bytes) in memory, limits are %d entries (%d bytes)", a.numEntries, a.numBytes, a.maxMemoryEntries, a.maxMemoryBytes)
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+
+	labels, err := a.entryLabels(entry)
+	if err != nil {
+		err := grpc.Errorf(codes.InvalidArgument, "unable to extract stream labels: %v", err)
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+	streamKey := storage.EncodeLabels(labels)
+
+	// Try to find an open chunk of the same tenant and stream which can accept the entry.
 	done := false
 	for _, chunk := range a.openChunks {
-		added, err := chunk.tryAdd(entry)
+		if chunk.tenant != tenantId || chunk.streamKey != streamKey {
+			continue
+		}
+
+		added, err := a.addToChunk(chunk, entry)
 		if err != nil {
 			err := grpc.Errorf(codes.Internal, "error while adding entry to chunk: %v", err)
 			logger.WithError(err).Warnf("Failed")
@@ -138,19 +310,91 @@ func (a *Appender) Append(ctx context.Context, request *pb_almanac.AppendRequest
 
 	// Open a new chunk if necessary.
 	if !done {
-		newChunk, err := newOpenChunk(entry, a.maxChunkEntries, a.maxChunkSpread, a.maxChunkOpenTime, a.closedChunksChan)
+		newChunk, err := newOpenChunk(entry, tenantId, labels, a.maxChunkEntries, a.maxChunkSpread, a.maxChunkOpenTime, a.closedChunksChan, a.metrics)
 		if err != nil {
 			err := grpc.Errorf(codes.Internal, "error while creating new chunk: %v", err)
 			logger.WithError(err).Warnf("Failed")
 			return nil, err
 		}
 		a.openChunks = append(a.openChunks, newChunk)
+		a.pendingChunks.Add(1)
+
+		numEntries, numBytes := newChunk.size()
+		a.addMemoryUsage(numEntries, numBytes)
 	}
 
 	logger.Infof("Handled")
 	return &pb_almanac.AppendResponse{}, nil
 }
 
+// entryLabels returns the values of the configured stream labels in the supplied entry, or nil
+// if the appender has no stream labels.
+func (a *Appender) entryLabels(entry *pb_almanac.LogEntry) (map[string]string, error) {
+	if len(a.streamLabels) == 0 {
+		return nil, nil
+	}
+	return storage.EntryLabels(entry.EntryJson, a.streamLabels)
+}
+
+// addToChunk attempts to add the supplied entry to the supplied chunk, keeping track of the
+// memory used. Must be called with openChunksMutex held.
+func (a *Appender) addToChunk(chunk *openChunk, entry *pb_almanac.LogEntry) (bool, error) {
+	entriesBefore, bytesBefore := chunk.size()
+	added, err := chunk.tryAdd(entry)
+	if err != nil || !added {
+		return added, err
+	}
+
+	// The entry may have replaced an earlier one with the same id, so look at the actual change.
+	entriesAfter, bytesAfter := chunk.size()
+	a.addMemoryUsage(entriesAfter-entriesBefore, bytesAfter-bytesBefore)
+	return true, nil
+}
+
+// addMemoryUsage records a change in the entries held in memory. Must be called with
+// openChunksMutex held.
+func (a *Appender) addMemoryUsage(numEntries int, numBytes int64) {
+	a.numEntries += numEntries
+	a.numBytes += numBytes
+	a.metrics.numEntries.Add(float64(numEntries))
+	a.metrics.numBytes.Add(float64(numBytes))
+}
+
+// Close stops the appender from accepting new entries, closes all open chunks and waits for them
+// to be written to storage. Returns an error if the supplied context is done before all chunks
+// have been stored, in which case the remaining chunks are lost, or if any of the chunks could not
+// be stored at all.
+func (a *Appender) Close(ctx context.Context) error {
+	a.openChunksMutex.Lock()
+	a.closing = true
+	for _, chunk := range a.openChunks {
+		chunk.close()
+	}
+	numChunks := len(a.openChunks)
+	droppedBefore := a.droppedChunks
+	a.openChunksMutex.Unlock()
+
+	stored := make(chan struct{})
+	go func() {
+		a.pendingChunks.Wait()
+		close(stored)
+	}()
+
+	select {
+	case <-stored:
+		a.openChunksMutex.Lock()
+		dropped := a.droppedChunks - droppedBefore
+		a.openChunksMutex.Unlock()
+		if dropped > 0 {
+			return fmt.Errorf("unable to flush %d of %d open chunk(s) to storage", dropped, numChunks)
+		}
+		a.logger.Infof("Flushed %d open chunk(s) to storage", numChunks)
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("unable to flush open chunks to storage: %v", ctx.Err())
+	}
+}
+
 // storeClosedChunks takes all the chunk protos sent over the closed chunks
 // channel and writes them to storage. This method blocks and is not expected
 // to return for the lifetime of the appender, so it should be called in a
@@ -160,14 +404,20 @@ func (a *Appender) storeClosedChunks() {
 		// Write the chunk out to storage.
 		chunkProto, err := chunk.toProto()
 		if err != nil {
-			a.logger.WithError(err).Errorf("Failed to turn chunk into proto: %v", err)
+			a.logger.WithError(err).Errorf("Failed to turn chunk into proto, dropping it")
+			a.openChunksMutex.Lock()
+			a.droppedChunks++
+			a.openChunksMutex.Unlock()
+			a.metrics.numPendingChunks.Dec()
+			a.removeOpenChunk(chunk)
+			a.pendingChunks.Done()
 			continue
 		}
 
-		chunkId, err := a.storage.StoreChunk(context.TODO(), chunkProto)
-		if err != nil {
-			a.logger.WithError(err).Errorf("Failed to store chunk %v: %v", chunkProto.Id, err)
-		}
+		// Only returns once the chunk is stored or spilled, so the chunk is no longer pending.
+		a.storeChunk(chunkProto)
+		a.metrics.numPendingChunks.Dec()
+		a.pendingChunks.Done()
 
 		// Now, remove it from the appender's list. We do this only after a grace period in order
 		// to make sure that query mixers don't end up in the case where they hit storage *before*
@@ -175,8 +425,37 @@ func (a *Appender) storeClosedChunks() {
 		time.AfterFunc(time.Duration(closedChunkGracePeriodMs)*time.Millisecond, func() {
 			a.removeOpenChunk(chunk)
 		})
+	}
+}
 
-		a.logger.WithFields(logrus.Fields{"chunkId": chunkId}).Infof("Stored chunk with %d entries", len(chunkProto.Entries))
+// storeChunk writes the supplied chunk to storage, retrying with exponential backoff on failure.
+// If storage keeps failing, the chunk is written to the spill instead. Only returns once the
+// chunk is safely in one of the two places.
+func (a *Appender) storeChunk(chunk *pb_almanac.Chunk) {
+	backoff := a.initialStoreBackoff
+	for attempt := 1; ; attempt++ {
+		chunkId, err := a.storage.StoreChunk(context.TODO(), chunk)
+		if err == nil {
+			a.logger.WithFields(logrus.Fields{"chunkId": chunkId}).Infof("Stored chunk with %d entries", len(chunk.Entries))
+			return
+		}
+		a.logger.WithError(err).Warnf("Failed to store chunk %v in attempt %d", chunk.Id, attempt)
+
+		if attempt >= a.maxStoreAttempts && a.spill != nil {
+			err := a.spill.write(chunk)
+			if err == nil {
+				a.logger.Warnf("Spilled chunk %v with %d entries after %d failed attempts", chunk.Id, len(chunk.Entries), attempt)
+				return
+			}
+			a.logger.WithError(err).Errorf("Failed to spill chunk %v", chunk.Id)
+		}
+
+		a.metrics.numStoreRetries.Inc()
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxStoreBackoff {
+			backoff = maxStoreBackoff
+		}
 	}
 }
 
@@ -192,4 +471,7 @@ func (a *Appender) removeOpenChunk(chunk *openChunk) {
 		}
 	}
 	a.openChunks = newOpenChunks
+
+	numEntries, numBytes := chunk.size()
+	a.addMemoryUsage(-numEntries, -numBytes)
 }
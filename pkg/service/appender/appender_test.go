@@ -0,0 +1,200 @@
+package appender
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dinowernli/almanac/pkg/storage"
+	"github.com/dinowernli/almanac/pkg/tenant"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	maxMemoryEntries = 100
+	maxMemoryBytes   = 1024 * 1024
+)
+
+func TestRejectsEntriesBeyondMemoryLimits(t *testing.T) {
+	s, err := storage.NewMemoryStorage()
+	assert.NoError(t, err)
+
+	a, err := New(logrus.New(), s, nil, maxEntries, maxSpread, maxOpenTime, 2, maxMemoryBytes, nil)
+	assert.NoError(t, err)
+
+	_, err = a.Append(context.Background(), &pb_almanac.AppendRequest{Entry: initialEntry})
+	assert.NoError(t, err)
+	_, err = a.Append(context.Background(), &pb_almanac.AppendRequest{Entry: entry2})
+	assert.NoError(t, err)
+
+	_, err = a.Append(context.Background(), &pb_almanac.AppendRequest{Entry: entry3})
+	assert.Error(t, err)
+	assert.Equal(t, codes.ResourceExhausted, grpc.Code(err))
+
+	// Raising the limit makes room again.
+	assert.NoError(t, a.SetMemoryLimits(3, maxMemoryBytes))
+	_, err = a.Append(context.Background(), &pb_almanac.AppendRequest{Entry: entry3})
+	assert.NoError(t, err)
+
+	// The limit on bytes applies as well.
+	assert.NoError(t, a.SetMemoryLimits(maxMemoryEntries, 1))
+	_, err = a.Append(context.Background(), &pb_almanac.AppendRequest{Entry: entry4})
+	assert.Equal(t, codes.ResourceExhausted, grpc.Code(err))
+}
+
+func TestMemoryIsReleasedOnceStored(t *testing.T) {
+	s, err := storage.NewMemoryStorage()
+	assert.NoError(t, err)
+
+	a, err := New(logrus.New(), s, nil, maxEntries, maxSpread, maxOpenTime, maxMemoryEntries, maxMemoryBytes, nil)
+	assert.NoError(t, err)
+
+	// Appending the same entry twice only holds it in memory once.
+	_, err = a.Append(context.Background(), &pb_almanac.AppendRequest{Entry: initialEntry})
+	assert.NoError(t, err)
+	_, err = a.Append(context.Background(), &pb_almanac.AppendRequest{Entry: initialEntry})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, a.numEntries)
+	assert.Equal(t, int64(len(initialEntry.EntryJson)), a.numBytes)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, a.Close(ctx))
+
+	// Closed chunks stay around for a grace period after being stored.
+	time.Sleep(2 * closedChunkGracePeriodMs * time.Millisecond)
+	a.openChunksMutex.Lock()
+	defer a.openChunksMutex.Unlock()
+	assert.Equal(t, 0, a.numEntries)
+	assert.Equal(t, int64(0), a.numBytes)
+}
+
+func TestCloseFlushesOpenChunks(t *testing.T) {
+	s, err := storage.NewMemoryStorage()
+	assert.NoError(t, err)
+
+	a, err := New(logrus.New(), s, nil, maxEntries, maxSpread, maxOpenTime, maxMemoryEntries, maxMemoryBytes, nil)
+	assert.NoError(t, err)
+
+	// The entries are too far apart to share a chunk, so this leaves two open chunks.
+	_, err = a.Append(context.Background(), &pb_almanac.AppendRequest{Entry: initialEntry})
+	assert.NoError(t, err)
+	_, err = a.Append(context.Background(), &pb_almanac.AppendRequest{Entry: entry4})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, a.Close(ctx))
+
+	chunks, err := s.ListChunks(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(chunks))
+
+	// Entries are rejected once the appender is closed.
+	_, err = a.Append(context.Background(), &pb_almanac.AppendRequest{Entry: entry2})
+	assert.Error(t, err)
+	assert.Equal(t, codes.Unavailable, grpc.Code(err))
+}
+
+func TestKeepsStreamsInSeparateChunks(t *testing.T) {
+	s, err := storage.NewMemoryStorage()
+	assert.NoError(t, err)
+
+	a, err := New(logrus.New(), s, []string{"service", "env"}, maxEntries, maxSpread, maxOpenTime, maxMemoryEntries, maxMemoryBytes, nil)
+	assert.NoError(t, err)
+
+	entries := []*pb_almanac.LogEntry{
+		{Id: "a", TimestampMs: 1000, EntryJson: `{"service": "web", "env": "prod", "message": "one"}`},
+		{Id: "b", TimestampMs: 1001, EntryJson: `{"service": "db", "env": "prod", "message": "two"}`},
+		{Id: "c", TimestampMs: 1002, EntryJson: `{"env": "prod", "service": "web", "message": "three"}`},
+		{Id: "d", TimestampMs: 1003, EntryJson: `{"message": "four"}`},
+	}
+	for _, e := range entries {
+		_, err = a.Append(context.Background(), &pb_almanac.AppendRequest{Entry: e})
+		assert.NoError(t, err)
+	}
+
+	selector, err := storage.ParseLabelSelector(`service="db"`)
+	assert.NoError(t, err)
+	response, err := a.Search(context.Background(), &pb_almanac.SearchRequest{Query: "message:t*", Num: 10, LabelSelector: selector})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(response.Entries))
+	assert.Equal(t, "b", response.Entries[0].Id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, a.Close(ctx))
+
+	ids, err := s.ListChunks(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(ids))
+
+	numEntries := map[string]int{}
+	for _, id := range ids {
+		idProto, err := storage.ChunkIdProto(id)
+		assert.NoError(t, err)
+		chunk, err := s.LoadChunk(context.Background(), idProto)
+		assert.NoError(t, err)
+		numEntries[storage.EncodeLabels(idProto.Labels)] = len(chunk.Entries())
+		assert.NoError(t, chunk.Close())
+	}
+	assert.Equal(t, map[string]int{"env=prod&service=web": 2, "env=prod&service=db": 1, "": 1}, numEntries)
+}
+
+func TestKeepsTenantsApart(t *testing.T) {
+	s, err := storage.NewMemoryStorage()
+	assert.NoError(t, err)
+
+	a, err := New(logrus.New(), s, []string{}, maxEntries, maxSpread, maxOpenTime, maxMemoryEntries, maxMemoryBytes, nil)
+	assert.NoError(t, err)
+
+	// The tenant can be named in the request or in the metadata.
+	acme := metadata.NewIncomingContext(context.Background(), metadata.Pairs(tenant.MetadataKey, "acme"))
+	_, err = a.Append(acme, &pb_almanac.AppendRequest{Entry: &pb_almanac.LogEntry{Id: "a", TimestampMs: 1000, EntryJson: `{"message": "one"}`}})
+	assert.NoError(t, err)
+	_, err = a.Append(context.Background(), &pb_almanac.AppendRequest{Tenant: "other", Entry: &pb_almanac.LogEntry{Id: "b", TimestampMs: 1001, EntryJson: `{"message": "two"}`}})
+	assert.NoError(t, err)
+
+	// A request cannot claim a tenant other than the one in its metadata.
+	_, err = a.Append(acme, &pb_almanac.AppendRequest{Tenant: "other", Entry: &pb_almanac.LogEntry{Id: "c", TimestampMs: 1002, EntryJson: `{"message": "three"}`}})
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+
+	for tenantId, expected := range map[string][]string{"acme": {"a"}, "other": {"b"}, tenant.Default: {}} {
+		response, err := a.Search(context.Background(), &pb_almanac.SearchRequest{Tenant: tenantId, Query: "message:*", Num: 10})
+		assert.NoError(t, err)
+		ids := []string{}
+		for _, e := range response.Entries {
+			ids = append(ids, e.Id)
+		}
+		assert.Equal(t, expected, ids, tenantId)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, a.Close(ctx))
+
+	for _, tenantId := range []string{"acme", "other"} {
+		ids, err := s.ListChunks(context.Background(), tenantId, 0, 0, pb_almanac.ChunkId_SMALL)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(ids), tenantId)
+	}
+}
+
+func TestRejectsInvalidStreamLabels(t *testing.T) {
+	s, err := storage.NewMemoryStorage()
+	assert.NoError(t, err)
+
+	_, err = New(logrus.New(), s, []string{"service", "service"}, maxEntries, maxSpread, maxOpenTime, maxMemoryEntries, maxMemoryBytes, nil)
+	assert.Error(t, err)
+
+	a, err := New(logrus.New(), s, []string{"service"}, maxEntries, maxSpread, maxOpenTime, maxMemoryEntries, maxMemoryBytes, nil)
+	assert.NoError(t, err)
+	_, err = a.Append(context.Background(), &pb_almanac.AppendRequest{Entry: &pb_almanac.LogEntry{Id: "a", EntryJson: "not json"}})
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+}
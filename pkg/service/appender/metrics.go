@@ -0,0 +1,89 @@
+package appender
+
+import (
+	"sync"
+
+	"github.com/dinowernli/almanac/pkg/util"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// All appenders in a process share the same metrics, such that their values add up.
+	metricsOnce      sync.Once
+	metricsInstance  *appenderMetrics
+	metricsCreateErr error
+)
+
+type appenderMetrics struct {
+	numOpenChunks    prometheus.Gauge
+	numPendingChunks prometheus.Gauge
+	numEntries       prometheus.Gauge
+	numBytes         prometheus.Gauge
+	numRejected      prometheus.Counter
+	numStoreRetries  prometheus.Counter
+}
+
+// sharedMetrics returns the metrics of all appenders in this process, registering them in the
+// default registry the first time around.
+func sharedMetrics() (*appenderMetrics, error) {
+	metricsOnce.Do(func() {
+		metricsInstance, metricsCreateErr = newAppenderMetrics()
+	})
+	return metricsInstance, metricsCreateErr
+}
+
+// newAppenderMetrics returns a struct with metrics registered in the default registry.
+func newAppenderMetrics() (*appenderMetrics, error) {
+	result := &appenderMetrics{}
+
+	result.numOpenChunks = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "almanac_appender_open_chunks",
+		Help: "The number of chunks currently accepting entries",
+	})
+	if err := util.RegisterLenient(result.numOpenChunks); err != nil {
+		return nil, err
+	}
+
+	result.numPendingChunks = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "almanac_appender_pending_chunks",
+		Help: "The number of closed chunks waiting to be written to storage",
+	})
+	if err := util.RegisterLenient(result.numPendingChunks); err != nil {
+		return nil, err
+	}
+
+	result.numEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "almanac_appender_memory_entries",
+		Help: "The number of entries held in memory by appenders",
+	})
+	if err := util.RegisterLenient(result.numEntries); err != nil {
+		return nil, err
+	}
+
+	result.numBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "almanac_appender_memory_bytes",
+		Help: "The total size of the json of the entries held in memory by appenders",
+	})
+	if err := util.RegisterLenient(result.numBytes); err != nil {
+		return nil, err
+	}
+
+	result.numRejected = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "almanac_appender_rejected_entries",
+		Help: "The number of entries rejected because appenders held too much in memory",
+	})
+	if err := util.RegisterLenient(result.numRejected); err != nil {
+		return nil, err
+	}
+
+	result.numStoreRetries = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "almanac_appender_store_retries",
+		Help: "The number of times appenders retried storing a chunk after a failure",
+	})
+	if err := util.RegisterLenient(result.numStoreRetries); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
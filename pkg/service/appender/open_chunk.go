@@ -20,10 +20,18 @@ type openChunk struct {
 	index   *index.Index
 	chunkId *pb_almanac.ChunkId
 
+	// The tenant and the encoding of the stream labels, used to match entries to chunks.
+	tenant    string
+	streamKey string
+
 	closed      bool
 	closeTimer  *time.Timer
 	sinkChannel chan *openChunk
 	mutex       *sync.Mutex
+	metrics     *appenderMetrics
+
+	// numBytes is the total size of the json of all entries in this chunk.
+	numBytes int64
 
 	maxEntries int
 	maxSpread  time.Duration
@@ -31,24 +39,35 @@ type openChunk struct {
 
 // newOpenChunk creates a new openChunk instance containing the supplied log entry.
 //
+// - tenant is the tenant which owns the chunk, recorded in the chunk id.
+// - labels are the stream labels of the entry, recorded in the chunk id. May be nil.
 // - maxEntries is the maximum number of entries in this chunk before it gets closed.
 // - maxSpread is the maximum difference between the smallest and largest timestamp of entries in this chunk.
 // - maxOpenTimeMs is a maximum duration for which the chunk will stay open.
 // - sinkChannel is a channel the open chunk gets sent into once it is closed.
-func newOpenChunk(entry *pb_almanac.LogEntry, maxEntries int, maxSpread time.Duration, maxOpenTime time.Duration, sinkChannel chan *openChunk) (*openChunk, error) {
+// - metrics are updated as the chunk gets opened and closed.
+func newOpenChunk(entry *pb_almanac.LogEntry, tenant string, labels map[string]string, maxEntries int, maxSpread time.Duration, maxOpenTime time.Duration, sinkChannel chan *openChunk, metrics *appenderMetrics) (*openChunk, error) {
 	index, err := index.NewIndex()
 	if err != nil {
 		return nil, fmt.Errorf("unable to create index: %v", err)
 	}
+	chunkId := newChunkId()
+	chunkId.Tenant = tenant
+	if len(labels) > 0 {
+		chunkId.Labels = labels
+	}
 	result := &openChunk{
-		entries: map[string]*pb_almanac.LogEntry{},
-		index:   index,
-		chunkId: newChunkId(),
+		entries:   map[string]*pb_almanac.LogEntry{},
+		index:     index,
+		chunkId:   chunkId,
+		tenant:    tenant,
+		streamKey: storage.EncodeLabels(labels),
 
 		closed:      false,
 		closeTimer:  nil,
 		sinkChannel: sinkChannel,
 		mutex:       &sync.Mutex{},
+		metrics:     metrics,
 
 		maxEntries: maxEntries,
 		maxSpread:  maxSpread,
@@ -65,6 +84,7 @@ func newOpenChunk(entry *pb_almanac.LogEntry, maxEntries int, maxSpread time.Dur
 	}
 
 	// Make sure we respect the maximum lifetime of the open chunk.
+	metrics.numOpenChunks.Inc()
 	result.closeTimer = time.AfterFunc(maxOpenTime, result.close)
 
 	return result, nil
@@ -72,10 +92,13 @@ func newOpenChunk(entry *pb_almanac.LogEntry, maxEntries int, maxSpread time.Dur
 
 // search executes a search on the in-memory entries and return the matching results
 // (in arbitrary order).
-func (c *openChunk) search(ctx context.Context, request *pb_almanac.SearchRequest) ([]*pb_almanac.LogEntry, error) {
+func (c *openChunk) search(ctx context.Context, request *pb_almanac.SearchRequest, selector *storage.LabelSelector) ([]*pb_almanac.LogEntry, error) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	return storage.Search(ctx, c.index, c.entries, request.Query, request.Num, request.StartMs, request.EndMs)
+	if !selector.MatchesChunk(c.chunkId) {
+		return []*pb_almanac.LogEntry{}, nil
+	}
+	return storage.Search(ctx, c.index, c.entries, request.Query, request.Num, request.StartMs, request.EndMs, selector)
 }
 
 // tryAdd attempts to add the supplied entry to the chunk.
@@ -118,7 +141,11 @@ func (c *openChunk) tryAdd(entry *pb_almanac.LogEntry) (bool, error) {
 	if err != nil {
 		return false, fmt.Errorf("unable to index raw json entry: %v", err)
 	}
+	if previous, ok := c.entries[entry.Id]; ok {
+		c.numBytes -= int64(len(previous.EntryJson))
+	}
 	c.entries[entry.Id] = entry
+	c.numBytes += int64(len(entry.EntryJson))
 
 	// Update the timestamps.
 	c.chunkId.StartMs = newStartMs
@@ -151,12 +178,21 @@ func (c *openChunk) close() {
 	}
 
 	c.closed = true
+	c.metrics.numOpenChunks.Dec()
+	c.metrics.numPendingChunks.Inc()
 
 	go func() {
 		c.sinkChannel <- c
 	}()
 }
 
+// size returns the number of entries in this chunk and the total size of their json.
+func (c *openChunk) size() (int, int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.entries), c.numBytes
+}
+
 // toProto turns this instance into a chunk proto. This must only be called
 // after closing this openChunk.
 func (c *openChunk) toProto() (*pb_almanac.Chunk, error) {
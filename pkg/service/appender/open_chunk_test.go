@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/dinowernli/almanac/pkg/storage"
+	"github.com/dinowernli/almanac/pkg/tenant"
 	pb_almanac "github.com/dinowernli/almanac/proto"
 
 	"github.com/stretchr/testify/assert"
@@ -88,7 +89,7 @@ func TestSearch(t *testing.T) {
 		Num:     200,
 		StartMs: 3,
 		EndMs:   3000,
-	})
+	}, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(results))
 }
@@ -132,7 +133,7 @@ func TestRejectsEntryDueToSpread(t *testing.T) {
 
 func TestAutoCloses(t *testing.T) {
 	sink := make(chan *openChunk)
-	c, err := newOpenChunk(initialEntry, maxEntries, maxSpread, 10 /* maxOpenTimeMs */, sink)
+	c, err := newOpenChunk(initialEntry, tenant.Default, nil, maxEntries, maxSpread, 10 /* maxOpenTimeMs */, sink, newTestMetrics(t))
 	assert.NoError(t, err)
 
 	// Make sure that the chunk is closed.
@@ -142,7 +143,13 @@ func TestAutoCloses(t *testing.T) {
 
 func newChunk(t *testing.T) (*openChunk, chan *openChunk) {
 	sink := make(chan *openChunk)
-	c, err := newOpenChunk(initialEntry, maxEntries, maxSpread, maxOpenTime, sink)
+	c, err := newOpenChunk(initialEntry, tenant.Default, nil, maxEntries, maxSpread, maxOpenTime, sink, newTestMetrics(t))
 	assert.NoError(t, err)
 	return c, sink
 }
+
+func newTestMetrics(t *testing.T) *appenderMetrics {
+	metrics, err := sharedMetrics()
+	assert.NoError(t, err)
+	return metrics
+}
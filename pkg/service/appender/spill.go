@@ -0,0 +1,229 @@
+package appender
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dinowernli/almanac/pkg/storage"
+	"github.com/dinowernli/almanac/pkg/util"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+const (
+	spillPrefix        = "chunk-"
+	spillTmpPrefix     = ".tmp-"
+	spillCorruptPrefix = ".corrupt-"
+)
+
+type spillMetrics struct {
+	numChunks   prometheus.Gauge
+	numBytes    prometheus.Gauge
+	numSpills   prometheus.Counter
+	numUploads  prometheus.Counter
+	numFailures prometheus.Counter
+}
+
+// newSpillMetrics returns a struct with metrics registered in the default registry.
+func newSpillMetrics() (*spillMetrics, error) {
+	result := &spillMetrics{}
+
+	result.numChunks = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "almanac_spill_chunks",
+		Help: "The number of chunks in the spill directory waiting to be uploaded to storage",
+	})
+	if err := util.RegisterLenient(result.numChunks); err != nil {
+		return nil, err
+	}
+
+	result.numBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "almanac_spill_bytes",
+		Help: "The total size of the chunks in the spill directory",
+	})
+	if err := util.RegisterLenient(result.numBytes); err != nil {
+		return nil, err
+	}
+
+	result.numSpills = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "almanac_spill_writes",
+		Help: "The number of chunks written to the spill directory because storage was failing",
+	})
+	if err := util.RegisterLenient(result.numSpills); err != nil {
+		return nil, err
+	}
+
+	result.numUploads = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "almanac_spill_uploads",
+		Help: "The number of spilled chunks uploaded to storage",
+	})
+	if err := util.RegisterLenient(result.numUploads); err != nil {
+		return nil, err
+	}
+
+	result.numFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "almanac_spill_upload_failures",
+		Help: "The number of failed attempts to upload a spilled chunk to storage",
+	})
+	if err := util.RegisterLenient(result.numFailures); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Spill holds chunks on local disk which could not be written to storage, and uploads them to
+// storage in the background once storage has recovered. A single instance is meant to be shared
+// by all appenders in a process.
+type Spill struct {
+	logger  *logrus.Logger
+	storage *storage.Storage
+	path    string
+	metrics *spillMetrics
+
+	// mutex makes sure only one drain runs at a time.
+	mutex *sync.Mutex
+}
+
+// NewSpill returns a spill backed by the directory at the supplied path, which is created if
+// necessary. Any chunks already in the directory, e.g., from before a restart, are uploaded along
+// with newly spilled ones every drainInterval until the supplied context is done.
+func NewSpill(ctx context.Context, logger *logrus.Logger, storage *storage.Storage, path string, drainInterval time.Duration) (*Spill, error) {
+	if drainInterval <= 0 {
+		return nil, fmt.Errorf("drain interval must be positive, but got %v", drainInterval)
+	}
+	err := os.MkdirAll(path, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create spill directory %s: %v", path, err)
+	}
+	metrics, err := newSpillMetrics()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create spill metrics: %v", err)
+	}
+
+	result := &Spill{
+		logger:  logger,
+		storage: storage,
+		path:    path,
+		metrics: metrics,
+		mutex:   &sync.Mutex{},
+	}
+
+	go func() {
+		ticker := time.NewTicker(drainInterval)
+		for {
+			err := result.drain(ctx)
+			if err != nil {
+				logger.WithError(err).Warnf("Unable to drain spill directory")
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return result, nil
+}
+
+// write stores the supplied chunk in the spill directory. The chunk is written to a temporary
+// file first, such that a crash never leaves behind a partially written chunk.
+func (s *Spill) write(chunk *pb_almanac.Chunk) error {
+	id, err := storage.ChunkId(chunk.Id)
+	if err != nil {
+		return fmt.Errorf("unable to compute chunk id: %v", err)
+	}
+	bytes, err := proto.Marshal(chunk)
+	if err != nil {
+		return fmt.Errorf("unable to marshal chunk %s: %v", id, err)
+	}
+
+	tmpFile := filepath.Join(s.path, spillTmpPrefix+id)
+	err = ioutil.WriteFile(tmpFile, bytes, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write chunk %s: %v", id, err)
+	}
+	err = os.Rename(tmpFile, filepath.Join(s.path, spillPrefix+id))
+	if err != nil {
+		return fmt.Errorf("unable to move chunk %s into place: %v", id, err)
+	}
+
+	s.metrics.numSpills.Inc()
+	s.metrics.numChunks.Inc()
+	s.metrics.numBytes.Add(float64(len(bytes)))
+	return nil
+}
+
+// drain uploads all chunks in the spill directory to storage, removing each one once uploaded.
+// Stops at the first chunk which fails to upload, since storage is probably still unavailable.
+func (s *Spill) drain(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	files, err := ioutil.ReadDir(s.path)
+	if err != nil {
+		return fmt.Errorf("unable to list spill directory %s: %v", s.path, err)
+	}
+
+	spilled := []os.FileInfo{}
+	var numBytes int64
+	for _, f := range files {
+		if strings.HasPrefix(f.Name(), spillPrefix) {
+			spilled = append(spilled, f)
+			numBytes += f.Size()
+		}
+	}
+	s.metrics.numChunks.Set(float64(len(spilled)))
+	s.metrics.numBytes.Set(float64(numBytes))
+
+	for i, f := range spilled {
+		err := s.upload(ctx, filepath.Join(s.path, f.Name()))
+		if err != nil {
+			s.metrics.numFailures.Inc()
+			return fmt.Errorf("uploaded %d of %d spilled chunk(s): %v", i, len(spilled), err)
+		}
+		s.metrics.numUploads.Inc()
+		s.metrics.numChunks.Dec()
+		s.metrics.numBytes.Sub(float64(f.Size()))
+	}
+
+	if len(spilled) > 0 {
+		s.logger.Infof("Uploaded %d spilled chunk(s) to storage", len(spilled))
+	}
+	return nil
+}
+
+// upload writes the spilled chunk in the supplied file to storage, and then removes the file.
+func (s *Spill) upload(ctx context.Context, filename string) error {
+	bytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %v", filename, err)
+	}
+	chunk := &pb_almanac.Chunk{}
+	err = proto.Unmarshal(bytes, chunk)
+	if err != nil {
+		// Retrying will not help, so move the file out of the way for a human to look at.
+		s.logger.WithError(err).Errorf("Unable to unmarshal spilled chunk %s, moving it aside", filename)
+		return os.Rename(filename, filepath.Join(filepath.Dir(filename), spillCorruptPrefix+filepath.Base(filename)))
+	}
+
+	_, err = s.storage.StoreChunk(ctx, chunk)
+	if err != nil {
+		return fmt.Errorf("unable to store chunk from %s: %v", filename, err)
+	}
+	err = os.Remove(filename)
+	if err != nil {
+		return fmt.Errorf("unable to remove %s: %v", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package appender
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dinowernli/almanac/pkg/storage"
+	"github.com/dinowernli/almanac/pkg/tenant"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestSpillsChunksStorageRejects(t *testing.T) {
+	root, err := ioutil.TempDir("", "almanac-spill-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	storagePath := filepath.Join(root, "storage")
+	spillPath := filepath.Join(root, "spill")
+	assert.NoError(t, os.Mkdir(storagePath, 0755))
+	s, err := storage.NewDiskStorage(storagePath)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	spill, err := NewSpill(ctx, logrus.New(), s, spillPath, time.Hour)
+	assert.NoError(t, err)
+
+	a, err := New(logrus.New(), s, nil, maxEntries, maxSpread, maxOpenTime, maxMemoryEntries, maxMemoryBytes, spill)
+	assert.NoError(t, err)
+	a.maxStoreAttempts = 2
+	a.initialStoreBackoff = time.Millisecond
+
+	// Make storage fail by pulling the directory out from under it.
+	assert.NoError(t, os.RemoveAll(storagePath))
+
+	_, err = a.Append(context.Background(), &pb_almanac.AppendRequest{Entry: initialEntry})
+	assert.NoError(t, err)
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer closeCancel()
+	assert.NoError(t, a.Close(closeCtx))
+
+	spilled, err := ioutil.ReadDir(spillPath)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(spilled))
+
+	// Draining fails while storage is still broken, and leaves the chunk in place.
+	assert.Error(t, spill.drain(context.Background()))
+	spilled, err = ioutil.ReadDir(spillPath)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(spilled))
+
+	// Once storage recovers, the chunk makes it there.
+	assert.NoError(t, os.Mkdir(storagePath, 0755))
+	assert.NoError(t, spill.drain(context.Background()))
+
+	spilled, err = ioutil.ReadDir(spillPath)
+	assert.NoError(t, err)
+	assert.Empty(t, spilled)
+
+	chunks, err := s.ListChunks(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(chunks))
+}
+
+func TestDrainMovesCorruptChunksAside(t *testing.T) {
+	spillPath, err := ioutil.TempDir("", "almanac-spill-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(spillPath)
+
+	s, err := storage.NewMemoryStorage()
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	spill, err := NewSpill(ctx, logrus.New(), s, spillPath, time.Hour)
+	assert.NoError(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(spillPath, spillPrefix+"garbage"), []byte("garbage"), 0644))
+	assert.NoError(t, spill.drain(context.Background()))
+
+	_, err = os.Stat(filepath.Join(spillPath, spillCorruptPrefix+spillPrefix+"garbage"))
+	assert.NoError(t, err)
+}
@@ -2,44 +2,162 @@ package discovery
 
 import (
 	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
 
 	pb_almanac "github.com/dinowernli/almanac/proto"
 
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 )
 
 // Discovery can be used to find other services in the system.
 type Discovery struct {
-	appenders []pb_almanac.AppenderClient
+	appenders   []pb_almanac.AppenderClient
+	mutex       *sync.RWMutex
+	dialOptions []grpc.DialOption
+
+	// connections holds the connection to each appender endpoint when resolving appenders using
+	// dns. Only accessed by the goroutine doing the resolving.
+	connections map[string]*grpc.ClientConn
 }
 
-// New returns an instance which talks to appenders at the supplied addresses over grpc.
-func New(appenderEndpoints []string) (*Discovery, error) {
+// New returns an instance which talks to appenders at the supplied addresses over grpc, dialed
+// using the supplied options. If there are no options, connections are insecure.
+func New(appenderEndpoints []string, dialOptions []grpc.DialOption) (*Discovery, error) {
+	dialOptions = withDefaultDialOptions(dialOptions)
 	appenders := []pb_almanac.AppenderClient{}
+	connections := []*grpc.ClientConn{}
 	for _, endpoint := range appenderEndpoints {
-		connection, err := grpc.Dial(endpoint, grpc.WithInsecure())
+		connection, err := grpc.Dial(endpoint, dialOptions...)
 		if err != nil {
+			for _, c := range connections {
+				c.Close()
+			}
 			return nil, fmt.Errorf("unable to dial endpoint %s: %v", endpoint, err)
 		}
+		connections = append(connections, connection)
 		appenders = append(appenders, pb_almanac.NewAppenderClient(connection))
 	}
 
-	return &Discovery{appenders}, nil
+	return &Discovery{appenders: appenders, mutex: &sync.RWMutex{}, dialOptions: dialOptions}, nil
+}
+
+// NewFromDns returns an instance which talks to all appenders the supplied host resolves to,
+// e.g., a headless kubernetes service. The host is resolved again every refreshInterval until
+// the supplied context is done, such that appenders can come and go. Appenders are dialed as
+// described for New.
+func NewFromDns(ctx context.Context, logger *logrus.Logger, hostPort string, refreshInterval time.Duration, dialOptions []grpc.DialOption) (*Discovery, error) {
+	if refreshInterval <= 0 {
+		return nil, fmt.Errorf("refresh interval must be positive, but got %v", refreshInterval)
+	}
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse host and port from %s: %v", hostPort, err)
+	}
+
+	result := &Discovery{
+		appenders:   []pb_almanac.AppenderClient{},
+		mutex:       &sync.RWMutex{},
+		connections: map[string]*grpc.ClientConn{},
+		dialOptions: withDefaultDialOptions(dialOptions),
+	}
+	err = result.resolve(host, port)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve appenders: %v", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(refreshInterval)
+		for {
+			select {
+			case <-ticker.C:
+				err := result.resolve(host, port)
+				if err != nil {
+					logger.WithError(err).Warnf("Unable to resolve appenders")
+				}
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return result, nil
 }
 
 // NewForTesting resturns an instance which talks directly to the supplied appenders.
 // This should only be used for testing.
 func NewForTesting(appenders []pb_almanac.AppenderClient) *Discovery {
-	return &Discovery{appenders}
+	return &Discovery{appenders: appenders, mutex: &sync.RWMutex{}}
 }
 
 // ListAppenders returns a list of clients, one each per appender in the
 // system. The returned list if a snapshot of the discovery object's
 // canonical list, so callers my modify the returned list.
 func (d *Discovery) ListAppenders() []pb_almanac.AppenderClient {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
 	result := []pb_almanac.AppenderClient{}
 	for _, a := range d.appenders {
 		result = append(result, a)
 	}
 	return result
 }
+
+// resolve looks up the current addresses of the supplied host and replaces the list of appenders
+// with one appender per address. Connections to appenders which are still around are reused.
+func (d *Discovery) resolve(host string, port string) error {
+	addresses, err := net.LookupHost(host)
+	if err != nil {
+		return fmt.Errorf("unable to look up host %s: %v", host, err)
+	}
+
+	// Keep the order stable so that callers consistently pick the same appenders.
+	sort.Strings(addresses)
+
+	connections := map[string]*grpc.ClientConn{}
+	appenders := []pb_almanac.AppenderClient{}
+	for _, address := range addresses {
+		endpoint := net.JoinHostPort(address, port)
+		connection, ok := d.connections[endpoint]
+		if !ok {
+			connection, err = grpc.Dial(endpoint, d.dialOptions...)
+			if err != nil {
+				// Close the connections dialed so far, keeping those still in use.
+				for e, c := range connections {
+					if _, ok := d.connections[e]; !ok {
+						c.Close()
+					}
+				}
+				return fmt.Errorf("unable to dial endpoint %s: %v", endpoint, err)
+			}
+		}
+		connections[endpoint] = connection
+		appenders = append(appenders, pb_almanac.NewAppenderClient(connection))
+	}
+
+	// Swap in the new appenders before closing the connections to those which are gone, so that
+	// callers listing appenders meanwhile do not get closed connections.
+	d.mutex.Lock()
+	d.appenders = appenders
+	d.mutex.Unlock()
+
+	for endpoint, connection := range d.connections {
+		if _, ok := connections[endpoint]; !ok {
+			connection.Close()
+		}
+	}
+	d.connections = connections
+	return nil
+}
+
+func withDefaultDialOptions(dialOptions []grpc.DialOption) []grpc.DialOption {
+	if len(dialOptions) == 0 {
+		return []grpc.DialOption{grpc.WithInsecure()}
+	}
+	return dialOptions
+}
@@ -0,0 +1,24 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestNewFromDns(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d, err := NewFromDns(ctx, logrus.New(), "localhost:5001", time.Minute, nil)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, d.ListAppenders())
+}
+
+func TestNewFromDnsRequiresPort(t *testing.T) {
+	_, err := NewFromDns(context.Background(), logrus.New(), "localhost", time.Minute, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,261 @@
+package ingester
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dinowernli/almanac/pkg/tenant"
+	"github.com/dinowernli/almanac/pkg/util"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	// ElasticPathPrefix is the prefix of the paths of the elasticsearch api, which clients take as
+	// the base url of their cluster.
+	ElasticPathPrefix = "/elasticsearch/"
+
+	elasticBulkSuffix     = "_bulk"
+	elasticBulkTimeout    = 30 * time.Second
+	elasticMaxBulkBytes   = 64 * 1024 * 1024
+	elasticTimestampField = "@timestamp"
+	elasticIndexField     = "_index"
+
+	// elasticVersion is the version reported to clients, which some of them check before sending.
+	elasticVersion = "7.10.2"
+)
+
+// elasticAction is the action line preceding each document in a bulk request.
+type elasticAction map[string]struct {
+	Index string `json:"_index"`
+	Id    string `json:"_id"`
+}
+
+// elasticItem is the result of a single action of a bulk request.
+type elasticItem struct {
+	Index   string        `json:"_index"`
+	Id      string        `json:"_id"`
+	Version int           `json:"_version,omitempty"`
+	Result  string        `json:"result,omitempty"`
+	Status  int           `json:"status"`
+	Error   *elasticError `json:"error,omitempty"`
+}
+
+type elasticError struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+type elasticBulkResponse struct {
+	Took   int64                     `json:"took"`
+	Errors bool                      `json:"errors"`
+	Items  []map[string]*elasticItem `json:"items"`
+}
+
+// handleElastic serves the parts of the elasticsearch api needed by clients which ship documents
+// through the bulk api, such as filebeat, logstash and vector: the cluster info at the base url,
+// and bulk requests to /_bulk or /<index>/_bulk.
+func (i *Ingester) handleElastic(writer http.ResponseWriter, request *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(request.URL.Path, ElasticPathPrefix), "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "" && (request.Method == http.MethodGet || request.Method == http.MethodHead):
+		writeElastic(writer, http.StatusOK, map[string]interface{}{
+			"name":         "almanac",
+			"cluster_name": "almanac",
+			"version":      map[string]string{"number": elasticVersion},
+			"tagline":      "You Know, for Search",
+		})
+	case parts[len(parts)-1] == elasticBulkSuffix && len(parts) <= 2 && (request.Method == http.MethodPost || request.Method == http.MethodPut):
+		defaultIndex := ""
+		if len(parts) == 2 {
+			defaultIndex = parts[0]
+		}
+		i.handleElasticBulk(writer, request, defaultIndex)
+	default:
+		writeElasticError(writer, http.StatusNotFound, "unsupported_operation_exception", fmt.Sprintf("%s %s is not supported, only bulk requests are", request.Method, request.URL.Path))
+	}
+}
+
+// handleElasticBulk ingests the documents of the index and create actions of a bulk request,
+// reporting the result of each action in the same way as elasticsearch. Other actions fail.
+func (i *Ingester) handleElasticBulk(writer http.ResponseWriter, request *http.Request, defaultIndex string) {
+	start := time.Now()
+	tenantId, err := tenant.FromHttp(request)
+	if err != nil {
+		writeElasticError(writer, http.StatusBadRequest, "illegal_argument_exception", err.Error())
+		return
+	}
+
+	var body io.Reader = request.Body
+	if request.Header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			writeElasticError(writer, http.StatusBadRequest, "parse_exception", fmt.Sprintf("unable to decompress body: %v", err))
+			return
+		}
+		defer reader.Close()
+		body = reader
+	}
+	reader := bufio.NewReader(io.LimitReader(body, elasticMaxBulkBytes))
+
+	ctx, cancel := context.WithTimeout(context.Background(), elasticBulkTimeout)
+	defer cancel()
+	response := &elasticBulkResponse{Items: []map[string]*elasticItem{}}
+	for {
+		line, err := readElasticLine(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeElasticError(writer, http.StatusBadRequest, "parse_exception", err.Error())
+			return
+		}
+
+		action := elasticAction{}
+		err = json.Unmarshal(line, &action)
+		if err != nil || len(action) != 1 {
+			writeElasticError(writer, http.StatusBadRequest, "illegal_argument_exception", fmt.Sprintf("malformed action line: %s", line))
+			return
+		}
+		for name, target := range action {
+			item := &elasticItem{Index: target.Index, Id: target.Id}
+			if item.Index == "" {
+				item.Index = defaultIndex
+			}
+			if item.Id == "" {
+				item.Id = util.RandomString(20)
+			}
+			response.Items = append(response.Items, map[string]*elasticItem{name: item})
+
+			// All actions but delete are followed by a document, which must be consumed either way.
+			var document []byte
+			if name != "delete" {
+				document, err = readElasticLine(reader)
+				if err != nil {
+					writeElasticError(writer, http.StatusBadRequest, "parse_exception", fmt.Sprintf("expected document after %s action", name))
+					return
+				}
+			}
+
+			if name != "index" && name != "create" {
+				item.fail(http.StatusBadRequest, "illegal_argument_exception", fmt.Sprintf("action %s is not supported, only index and create are", name))
+				continue
+			}
+			entryJson, err := elasticEntry(document, item.Index)
+			if err != nil {
+				item.fail(http.StatusBadRequest, "mapper_parsing_exception", err.Error())
+				continue
+			}
+			_, err = i.Ingest(ctx, &pb_almanac.IngestRequest{EntryJson: entryJson, Tenant: tenantId, Format: pb_almanac.IngestRequest_JSON})
+			if err != nil {
+				item.failIngest(err)
+				continue
+			}
+			item.Version = 1
+			item.Result = "created"
+			item.Status = http.StatusCreated
+		}
+	}
+
+	for _, item := range response.Items {
+		for _, result := range item {
+			response.Errors = response.Errors || result.Error != nil
+		}
+	}
+	response.Took = int64(time.Since(start) / time.Millisecond)
+	writeElastic(writer, http.StatusOK, response)
+}
+
+// elasticEntry returns the json of the entry storing the supplied document of the supplied index.
+// The @timestamp of the document, either in rfc 3339 or in epoch milliseconds, becomes its
+// timestamp unless it already has one.
+func elasticEntry(document []byte, index string) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	fields := map[string]interface{}{}
+	err := decoder.Decode(&fields)
+	if err != nil {
+		return "", fmt.Errorf("document is not a json object: %v", err)
+	}
+
+	if _, ok := fields[timestampField]; !ok {
+		switch value := fields[elasticTimestampField].(type) {
+		case nil:
+		case json.Number:
+			fields[timestampField] = value
+		case string:
+			timestamp, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return "", fmt.Errorf("unable to parse %s [%s]: %v", elasticTimestampField, value, err)
+			}
+			fields[timestampField] = timestamp.UnixNano() / nanosPerMilli
+		default:
+			return "", fmt.Errorf("%s must be a string or a number", elasticTimestampField)
+		}
+	}
+	if _, ok := fields[elasticIndexField]; !ok && index != "" {
+		fields[elasticIndexField] = index
+	}
+
+	result, err := json.Marshal(fields)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal entry: %v", err)
+	}
+	return string(result), nil
+}
+
+func (item *elasticItem) fail(status int, errorType string, reason string) {
+	item.Status = status
+	item.Error = &elasticError{Type: errorType, Reason: reason}
+}
+
+// failIngest records the supplied ingest error. Clients retry items rejected with 429, which is
+// what rate limited entries and exhausted appenders get.
+func (item *elasticItem) failIngest(err error) {
+	switch grpc.Code(err) {
+	case codes.InvalidArgument:
+		item.fail(http.StatusBadRequest, "mapper_parsing_exception", err.Error())
+	case codes.ResourceExhausted:
+		item.fail(http.StatusTooManyRequests, "es_rejected_execution_exception", err.Error())
+	default:
+		item.fail(http.StatusInternalServerError, "exception", err.Error())
+	}
+}
+
+// readElasticLine returns the next non-empty line of a bulk request, without its newline.
+func readElasticLine(reader *bufio.Reader) ([]byte, error) {
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return nil, err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			return line, nil
+		}
+	}
+}
+
+func writeElastic(writer http.ResponseWriter, status int, body interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	json.NewEncoder(writer).Encode(body)
+}
+
+func writeElasticError(writer http.ResponseWriter, status int, errorType string, reason string) {
+	writeElastic(writer, status, map[string]interface{}{
+		"error":  map[string]string{"type": errorType, "reason": reason},
+		"status": status,
+	})
+}
@@ -0,0 +1,99 @@
+package ingester
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	dc "github.com/dinowernli/almanac/pkg/service/discovery"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestElasticEntry(t *testing.T) {
+	result, err := elasticEntry([]byte(`{"@timestamp": "2017-07-14T02:40:00.123Z", "message": "foo"}`), "filebeat")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"@timestamp": "2017-07-14T02:40:00.123Z", "message": "foo", "timestamp_ms": 1500000000123, "_index": "filebeat"}`, result)
+
+	result, err = elasticEntry([]byte(`{"@timestamp": 1500000000123, "timestamp_ms": 5000, "_index": "own"}`), "filebeat")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"@timestamp": 1500000000123, "timestamp_ms": 5000, "_index": "own"}`, result)
+
+	result, err = elasticEntry([]byte(`{"@timestamp": 1500000000123}`), "")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"@timestamp": 1500000000123, "timestamp_ms": 1500000000123}`, result)
+
+	for _, invalid := range []string{`[1, 2]`, `{"@timestamp": "yesterday"}`, `{"@timestamp": true}`} {
+		_, err := elasticEntry([]byte(invalid), "")
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestElasticBulk(t *testing.T) {
+	appender := newFakeAppender(nil)
+	ingester, err := New(logrus.New(), dc.NewForTesting([]pb_almanac.AppenderClient{appender}), 1, nil)
+	assert.NoError(t, err)
+
+	body := `{"index": {"_id": "a"}}
+{"@timestamp": "2017-07-14T02:40:00.123Z", "message": "foo"}
+{"create": {"_index": "other"}}
+{"message": "bar"}
+
+{"delete": {"_id": "b"}}
+{"update": {"_id": "c"}}
+{"doc": {"message": "baz"}}
+{"index": {}}
+{"@timestamp": "yesterday"}
+`
+	request, err := http.NewRequest("POST", ElasticPathPrefix+"logs/_bulk", bytes.NewBufferString(body))
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	ingester.handleElastic(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	response := &elasticBulkResponse{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), response))
+	assert.True(t, response.Errors)
+	assert.Equal(t, 5, len(response.Items))
+	assert.Equal(t, &elasticItem{Index: "logs", Id: "a", Version: 1, Result: "created", Status: http.StatusCreated}, response.Items[0]["index"])
+	assert.Equal(t, "other", response.Items[1]["create"].Index)
+	assert.Equal(t, http.StatusCreated, response.Items[1]["create"].Status)
+	assert.Equal(t, http.StatusBadRequest, response.Items[2]["delete"].Status)
+	assert.Equal(t, http.StatusBadRequest, response.Items[3]["update"].Status)
+	assert.Equal(t, "mapper_parsing_exception", response.Items[4]["index"].Error.Type)
+
+	assert.Equal(t, 2, len(appender.appended))
+	entry := <-appender.appended
+	assert.Equal(t, int64(1500000000123), entry.TimestampMs)
+	assert.JSONEq(t, `{"@timestamp": "2017-07-14T02:40:00.123Z", "message": "foo", "timestamp_ms": 1500000000123, "_index": "logs"}`, entry.EntryJson)
+}
+
+func TestElasticBulkRejectsMalformedRequests(t *testing.T) {
+	ingester, err := New(logrus.New(), dc.NewForTesting([]pb_almanac.AppenderClient{newFakeAppender(nil)}), 1, nil)
+	assert.NoError(t, err)
+
+	cases := map[string]int{
+		"/_bulk":         http.StatusBadRequest,
+		"/a/b/_bulk":     http.StatusNotFound,
+		"/logs/_search":  http.StatusNotFound,
+		"/logs/_doc/123": http.StatusNotFound,
+	}
+	for path, expected := range cases {
+		request, err := http.NewRequest("POST", ElasticPathPrefix[:len(ElasticPathPrefix)-1]+path, bytes.NewBufferString("not json\n"))
+		assert.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		ingester.handleElastic(recorder, request)
+		assert.Equal(t, expected, recorder.Code, path)
+	}
+
+	request, err := http.NewRequest("GET", ElasticPathPrefix, nil)
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	ingester.handleElastic(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), elasticVersion)
+}
@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	almHttp "github.com/dinowernli/almanac/pkg/http"
+	"github.com/dinowernli/almanac/pkg/loki"
 	dc "github.com/dinowernli/almanac/pkg/service/discovery"
+	"github.com/dinowernli/almanac/pkg/tenant"
 	"github.com/dinowernli/almanac/pkg/util"
 	pb_almanac "github.com/dinowernli/almanac/proto"
 
@@ -22,6 +25,7 @@ const (
 	httpUrl             = "/ingester"
 	httpIngestTimeoutMs = 300
 	urlParamContent     = "c"
+	urlParamFormat      = "f"
 
 	httpExampleEntry = `
 {
@@ -42,15 +46,21 @@ var (
 // Ingester is an implementation of the ingester service. It accepts log
 // entries entering the system and fans them out to appenders.
 type Ingester struct {
-	logger       *logrus.Logger
-	discovery    *dc.Discovery
+	logger    *logrus.Logger
+	discovery *dc.Discovery
+
+	// limiter rejects the entries of tenants which ingest too much, unless nil.
+	limiter *Limiter
+
 	ingestFanout int
+	fanoutMutex  *sync.RWMutex
 }
 
 // New returns a new Ingester backed by the supplied service discovery.
 // appenderFanout specifies how many appenders this ingester tries to inform of
-// a new log entry before declaring the entry ingested into the system.
-func New(logger *logrus.Logger, discovery *dc.Discovery, ingestFanout int) (*Ingester, error) {
+// a new log entry before declaring the entry ingested into the system. If the
+// supplied limiter is not nil, it decides which entries get ingested at all.
+func New(logger *logrus.Logger, discovery *dc.Discovery, ingestFanout int, limiter *Limiter) (*Ingester, error) {
 	if ingestFanout < 1 {
 		return nil, fmt.Errorf("ingestFanout must be at least 1")
 	}
@@ -58,83 +68,172 @@ func New(logger *logrus.Logger, discovery *dc.Discovery, ingestFanout int) (*Ing
 	return &Ingester{
 		logger:       logger,
 		discovery:    discovery,
+		limiter:      limiter,
 		ingestFanout: ingestFanout,
+		fanoutMutex:  &sync.RWMutex{},
 	}, nil
 }
 
-// RegisterHttp registers a page on the supplied server, used for ingesting entries.
+// SetFanout changes how many appenders subsequently ingested entries are sent to.
+func (i *Ingester) SetFanout(ingestFanout int) error {
+	if ingestFanout < 1 {
+		return fmt.Errorf("ingestFanout must be at least 1")
+	}
+
+	i.fanoutMutex.Lock()
+	defer i.fanoutMutex.Unlock()
+	i.ingestFanout = ingestFanout
+	return nil
+}
+
+// SetLimits changes the ingestion limits of all tenants, see Limiter.SetLimits. Has no effect if
+// the ingester was created without a limiter.
+func (i *Ingester) SetLimits(defaultLimits Limits, tenantLimits map[string]Limits) error {
+	if i.limiter == nil {
+		return nil
+	}
+	return i.limiter.SetLimits(defaultLimits, tenantLimits)
+}
+
+// RegisterHttp registers a page on the supplied server, used for ingesting entries, along with
+// the push endpoint of the loki api and the bulk endpoint of the elasticsearch api.
 func (i *Ingester) RegisterHttp(server *http.ServeMux) {
 	server.HandleFunc(httpUrl, prometheus.InstrumentHandlerFunc(httpUrl, i.handleHttp))
+	server.HandleFunc(loki.PushPath, prometheus.InstrumentHandlerFunc(loki.PushPath, i.handleLokiPush))
+	server.HandleFunc(ElasticPathPrefix, prometheus.InstrumentHandlerFunc(ElasticPathPrefix, i.handleElastic))
 }
 
 func (i *Ingester) Ingest(ctx context.Context, request *pb_almanac.IngestRequest) (*pb_almanac.IngestResponse, error) {
 	logger := i.logger.WithFields(ingestField)
 
-	// Parse the incoming raw log entry, extracting some structure.
-	entry, err := extractEntry(request.EntryJson)
+	tenantId, err := tenant.Resolve(ctx, request.Tenant)
 	if err != nil {
-		err := grpc.Errorf(codes.InvalidArgument, "unable to extract log entry from json: %v", err)
-		i.logger.WithError(err).Warnf("Failed")
+		err := grpc.Errorf(codes.InvalidArgument, "unable to determine tenant: %v", err)
+		logger.WithError(err).Warnf("Failed")
 		return nil, err
 	}
-	logger = logger.WithFields(logrus.Fields{"entry": entry.Id})
+	logger = logger.WithFields(logrus.Fields{"tenant": tenantId})
 
-	// Send an append request to a select bunch of appenders.
-	appenders, err := i.selectAppenders()
+	// Normalize the incoming raw log entry into json, then extract some structure.
+	entryJson, err := parseEntry(request.EntryJson, request.Format)
 	if err != nil {
-		err := grpc.Errorf(codes.Internal, "unable to select appenders: %v", err)
+		err := grpc.Errorf(codes.InvalidArgument, "%v", err)
 		logger.WithError(err).Warnf("Failed")
 		return nil, err
 	}
-
-	appendRequest := &pb_almanac.AppendRequest{Entry: entry}
-	resultChan := make(chan error, len(appenders))
-	for _, a := range appenders {
-		// Avoid capturing the loop variable.
-		appender := a
-		go func() {
-			_, err := appender.Append(ctx, appendRequest)
-			resultChan <- err
-		}()
+	entry, err := extractEntry(entryJson)
+	if err != nil {
+		err := grpc.Errorf(codes.InvalidArgument, "unable to extract log entry from json: %v", err)
+		i.logger.WithError(err).Warnf("Failed")
+		return nil, err
 	}
+	logger = logger.WithFields(logrus.Fields{"entry": entry.Id})
 
-	// TODO(dino): be smarter about how many append calls need to have succeeded. For now,
-	// we error out if any of the append calls fail.
-	for i := 0; i < len(appenders); i++ {
-		err := <-resultChan
+	if i.limiter != nil {
+		wait, err := i.limiter.Admit(tenantId, entry)
 		if err != nil {
-			err := grpc.Errorf(codes.Internal, "unable to send append request: %v", err)
+			if wait > 0 {
+				setRetryAfter(ctx, wait)
+			}
 			logger.WithError(err).Warnf("Failed")
 			return nil, err
 		}
 	}
 
+	// Send an append request to a select bunch of appenders.
+	fanout, appenders, err := i.selectAppenders()
+	if err != nil {
+		err := grpc.Errorf(codes.Internal, "unable to select appenders: %v", err)
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+
+	err = appendToAppenders(ctx, &pb_almanac.AppendRequest{Entry: entry, Tenant: tenantId}, fanout, appenders)
+	if err != nil {
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+
 	logger.Infof("Handled")
 	return &pb_almanac.IngestResponse{}, nil
 }
 
-// selectAppenders returns the appenders to whom the current log entry must be
-// sent to.
-func (i *Ingester) selectAppenders() ([]pb_almanac.AppenderClient, error) {
+// selectAppenders returns how many appenders the current log entry must be sent to, along with
+// the appenders to try in order of preference.
+func (i *Ingester) selectAppenders() (int, []pb_almanac.AppenderClient, error) {
+	i.fanoutMutex.RLock()
+	fanout := i.ingestFanout
+	i.fanoutMutex.RUnlock()
+
 	allAppenders := i.discovery.ListAppenders()
-	if i.ingestFanout > len(allAppenders) {
-		return nil, fmt.Errorf("cannot select %d appenders from a list of size %d", i.ingestFanout, len(allAppenders))
+	if fanout > len(allAppenders) {
+		return 0, nil, fmt.Errorf("cannot select %d appenders from a list of size %d", fanout, len(allAppenders))
 	}
 
 	// TODO(dino): Consider remembering the appenders last used and trying to find them.
 	// Shuffle the first time so that different ingesters use different subsets of appenders.
 
-	return allAppenders[0:i.ingestFanout], nil
+	return fanout, allAppenders, nil
+}
+
+// appendToAppenders sends the supplied request to fanout of the supplied appenders, in order.
+// Appenders which push back because they hold too much in memory are replaced by the next ones
+// in line. Any other failure fails the whole request.
+func appendToAppenders(ctx context.Context, request *pb_almanac.AppendRequest, fanout int, appenders []pb_almanac.AppenderClient) error {
+	next := 0
+	remaining := fanout
+	for remaining > 0 {
+		if next+remaining > len(appenders) {
+			return grpc.Errorf(codes.ResourceExhausted, "only %d of %d appenders accepted the entry, all others are exhausted", fanout-remaining, fanout)
+		}
+		batch := appenders[next : next+remaining]
+		next += remaining
+
+		resultChan := make(chan error, len(batch))
+		for _, a := range batch {
+			// Avoid capturing the loop variable.
+			appender := a
+			go func() {
+				_, err := appender.Append(ctx, request)
+				resultChan <- err
+			}()
+		}
+
+		for i := 0; i < len(batch); i++ {
+			err := <-resultChan
+			if err == nil {
+				remaining--
+				continue
+			}
+			if grpc.Code(err) == codes.ResourceExhausted {
+				continue
+			}
+			return grpc.Errorf(codes.Internal, "unable to send append request: %v", err)
+		}
+	}
+	return nil
 }
 
 // handleHttp serves a web page which can be used to ingest entries on this ingester.
 func (i *Ingester) handleHttp(writer http.ResponseWriter, request *http.Request) {
-	pageData := &almHttp.IngesterData{FormContent: request.FormValue(urlParamContent)}
+	pageData := &almHttp.IngesterData{
+		FormContent: request.FormValue(urlParamContent),
+		FormFormat:  request.FormValue(urlParamFormat),
+		Formats:     formatNames(),
+	}
 	if pageData.FormContent != "" {
 		ctx, cancel := context.WithTimeout(context.Background(), httpIngestTimeoutMs*time.Millisecond)
 		defer cancel()
 
-		_, pageData.Error = i.Ingest(ctx, &pb_almanac.IngestRequest{EntryJson: pageData.FormContent})
+		var tenantId string
+		tenantId, pageData.Error = tenant.FromHttp(request)
+		format, ok := pb_almanac.IngestRequest_Format_value[pageData.FormFormat]
+		if pageData.Error == nil && !ok && pageData.FormFormat != "" {
+			pageData.Error = fmt.Errorf("unknown format %s", pageData.FormFormat)
+		}
+		if pageData.Error == nil {
+			_, pageData.Error = i.Ingest(ctx, &pb_almanac.IngestRequest{EntryJson: pageData.FormContent, Tenant: tenantId, Format: pb_almanac.IngestRequest_Format(format)})
+		}
 		if pageData.Error == nil {
 			pageData.Result = "Successfully ingested entry"
 		}
@@ -0,0 +1,157 @@
+package ingester
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dinowernli/almanac/pkg/loki"
+	dc "github.com/dinowernli/almanac/pkg/service/discovery"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// fakeAppender records the entries appended to it, failing with a fixed error if one is set.
+type fakeAppender struct {
+	err      error
+	appended chan *pb_almanac.LogEntry
+}
+
+func newFakeAppender(err error) *fakeAppender {
+	return &fakeAppender{err: err, appended: make(chan *pb_almanac.LogEntry, 10)}
+}
+
+func (a *fakeAppender) Append(ctx context.Context, in *pb_almanac.AppendRequest, opts ...grpc.CallOption) (*pb_almanac.AppendResponse, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	a.appended <- in.Entry
+	return &pb_almanac.AppendResponse{}, nil
+}
+
+func (a *fakeAppender) Search(ctx context.Context, in *pb_almanac.SearchRequest, opts ...grpc.CallOption) (*pb_almanac.SearchResponse, error) {
+	return &pb_almanac.SearchResponse{}, nil
+}
+
+func TestAppendSkipsExhaustedAppenders(t *testing.T) {
+	exhausted := newFakeAppender(grpc.Errorf(codes.ResourceExhausted, "full"))
+	healthy1 := newFakeAppender(nil)
+	healthy2 := newFakeAppender(nil)
+	unused := newFakeAppender(nil)
+	appenders := []pb_almanac.AppenderClient{exhausted, healthy1, healthy2, unused}
+
+	request := &pb_almanac.AppendRequest{Entry: &pb_almanac.LogEntry{Id: "id1"}}
+	assert.NoError(t, appendToAppenders(context.Background(), request, 2, appenders))
+
+	assert.Equal(t, 1, len(healthy1.appended))
+	assert.Equal(t, 1, len(healthy2.appended))
+	assert.Equal(t, 0, len(unused.appended))
+}
+
+func TestAppendFailsIfTooManyAppendersExhausted(t *testing.T) {
+	exhausted := newFakeAppender(grpc.Errorf(codes.ResourceExhausted, "full"))
+	healthy := newFakeAppender(nil)
+	appenders := []pb_almanac.AppenderClient{exhausted, healthy}
+
+	request := &pb_almanac.AppendRequest{Entry: &pb_almanac.LogEntry{Id: "id1"}}
+	err := appendToAppenders(context.Background(), request, 2, appenders)
+	assert.Error(t, err)
+	assert.Equal(t, codes.ResourceExhausted, grpc.Code(err))
+}
+
+func TestAppendFailsOnOtherErrors(t *testing.T) {
+	broken := newFakeAppender(grpc.Errorf(codes.Internal, "broken"))
+	healthy := newFakeAppender(nil)
+	appenders := []pb_almanac.AppenderClient{broken, healthy, newFakeAppender(nil)}
+
+	request := &pb_almanac.AppendRequest{Entry: &pb_almanac.LogEntry{Id: "id1"}}
+	err := appendToAppenders(context.Background(), request, 2, appenders)
+	assert.Error(t, err)
+	assert.Equal(t, codes.Internal, grpc.Code(err))
+}
+
+func TestIngestAppliesLimits(t *testing.T) {
+	appender := newFakeAppender(nil)
+	limiter, _ := newTestLimiter(t, Limits{EntriesPerSecond: 1}, nil, nil)
+	ingester, err := New(logrus.New(), dc.NewForTesting([]pb_almanac.AppenderClient{appender}), 1, limiter)
+	assert.NoError(t, err)
+
+	_, err = ingester.Ingest(context.Background(), &pb_almanac.IngestRequest{EntryJson: `{"message": "foo"}`})
+	assert.NoError(t, err)
+	_, err = ingester.Ingest(context.Background(), &pb_almanac.IngestRequest{EntryJson: `{"message": "foo"}`})
+	assert.Equal(t, codes.ResourceExhausted, grpc.Code(err))
+
+	// Limits apply to each tenant separately.
+	_, err = ingester.Ingest(context.Background(), &pb_almanac.IngestRequest{EntryJson: `{"message": "foo"}`, Tenant: "acme"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(appender.appended))
+}
+
+func TestIngestParsesFormats(t *testing.T) {
+	appender := newFakeAppender(nil)
+	ingester, err := New(logrus.New(), dc.NewForTesting([]pb_almanac.AppenderClient{appender}), 1, nil)
+	assert.NoError(t, err)
+
+	_, err = ingester.Ingest(context.Background(), &pb_almanac.IngestRequest{EntryJson: `level=warn timestamp_ms=5000`, Format: pb_almanac.IngestRequest_LOGFMT})
+	assert.NoError(t, err)
+	_, err = ingester.Ingest(context.Background(), &pb_almanac.IngestRequest{EntryJson: `disk almost full`})
+	assert.NoError(t, err)
+	_, err = ingester.Ingest(context.Background(), &pb_almanac.IngestRequest{EntryJson: `disk almost full`, Format: pb_almanac.IngestRequest_JSON})
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+
+	assert.Equal(t, 2, len(appender.appended))
+	logfmtEntry := <-appender.appended
+	assert.JSONEq(t, `{"level": "warn", "timestamp_ms": 5000}`, logfmtEntry.EntryJson)
+	assert.Equal(t, int64(5000), logfmtEntry.TimestampMs)
+	textEntry := <-appender.appended
+	assert.JSONEq(t, `{"message": "disk almost full"}`, textEntry.EntryJson)
+}
+
+func TestLokiPush(t *testing.T) {
+	appender := newFakeAppender(nil)
+	ingester, err := New(logrus.New(), dc.NewForTesting([]pb_almanac.AppenderClient{appender}), 1, nil)
+	assert.NoError(t, err)
+
+	body := `{"streams": [{"stream": {"job": "varlogs"}, "values": [["1500000000000000000", "foo"], ["1500000001000000000", "bar"]]}]}`
+	request, err := http.NewRequest("POST", loki.PushPath, bytes.NewBufferString(body))
+	assert.NoError(t, err)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(loki.TenantHeader, "acme")
+
+	recorder := httptest.NewRecorder()
+	ingester.handleLokiPush(recorder, request)
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	assert.Equal(t, 2, len(appender.appended))
+	entry := <-appender.appended
+	assert.JSONEq(t, `{"job": "varlogs", "message": "foo", "timestamp_ms": 1500000000000}`, entry.EntryJson)
+	assert.Equal(t, int64(1500000000000), entry.TimestampMs)
+
+	request, err = http.NewRequest("POST", loki.PushPath, bytes.NewBufferString(`{"streams": `))
+	assert.NoError(t, err)
+	request.Header.Set("Content-Type", "application/json")
+	recorder = httptest.NewRecorder()
+	ingester.handleLokiPush(recorder, request)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestLokiPushReportsRetryableFailures(t *testing.T) {
+	limiter, _ := newTestLimiter(t, Limits{EntriesPerSecond: 1}, nil, nil)
+	ingester, err := New(logrus.New(), dc.NewForTesting([]pb_almanac.AppenderClient{newFakeAppender(nil)}), 1, limiter)
+	assert.NoError(t, err)
+
+	body := `{"streams": [{"stream": {"job": "varlogs"}, "values": [["1500000000000000000", "foo"], ["1500000001000000000", "bar"]]}]}`
+	request, err := http.NewRequest("POST", loki.PushPath, bytes.NewBufferString(body))
+	assert.NoError(t, err)
+	request.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	ingester.handleLokiPush(recorder, request)
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "ingested 1 of 2 entries")
+}
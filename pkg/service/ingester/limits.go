@@ -0,0 +1,312 @@
+package ingester
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dinowernli/almanac/pkg/storage"
+	"github.com/dinowernli/almanac/pkg/util"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// RetryAfterKey is the grpc trailer which tells clients of a rate limited request how many
+	// milliseconds to wait before trying again.
+	RetryAfterKey = "almanac-retry-after-ms"
+
+	// Buckets which have been full for this long are forgotten, since a new bucket is equivalent.
+	idleBucketTimeout = time.Minute
+
+	tenantLabel = "tenant"
+	reasonLabel = "reason"
+
+	reasonEntryRate = "entry_rate"
+	reasonByteRate  = "byte_rate"
+	reasonEntrySize = "entry_size"
+)
+
+var (
+	// All limiters in a process share the same metrics, such that their values add up.
+	limiterMetricsOnce      sync.Once
+	limiterMetricsInstance  *limiterMetrics
+	limiterMetricsCreateErr error
+)
+
+// Limits bounds what a single tenant, or a single stream of a tenant, may ingest. Zero values
+// mean unlimited.
+type Limits struct {
+	EntriesPerSecond float64
+	BytesPerSecond   float64
+	MaxEntryBytes    int
+}
+
+// Validate returns an error if any of the limits is negative.
+func (l Limits) Validate() error {
+	if l.EntriesPerSecond < 0 {
+		return fmt.Errorf("entries per second must not be negative, but got %v", l.EntriesPerSecond)
+	}
+	if l.BytesPerSecond < 0 {
+		return fmt.Errorf("bytes per second must not be negative, but got %v", l.BytesPerSecond)
+	}
+	if l.MaxEntryBytes < 0 {
+		return fmt.Errorf("max entry bytes must not be negative, but got %d", l.MaxEntryBytes)
+	}
+	return nil
+}
+
+// Limiter decides whether entries may be ingested, based on how much their tenant has ingested
+// recently. Rates are enforced using token buckets which hold up to one second worth of entries
+// and bytes. If stream labels are set, each stream of a tenant gets buckets of its own.
+type Limiter struct {
+	streamLabels []string
+	metrics      *limiterMetrics
+
+	// now returns the current time, replaced in tests.
+	now func() time.Time
+
+	mutex         sync.Mutex
+	defaultLimits Limits
+	tenantLimits  map[string]Limits
+	buckets       map[string]*limiterBuckets
+	lastSweep     time.Time
+}
+
+// limiterBuckets holds the token buckets of a single tenant or stream.
+type limiterBuckets struct {
+	entries *tokenBucket
+	bytes   *tokenBucket
+}
+
+// NewLimiter returns a limiter which applies the supplied limits to the tenants listed in
+// tenantLimits, and the default limits to all other tenants. If streamLabels is not empty, the
+// limits apply to each distinct combination of values of those entry fields within a tenant.
+func NewLimiter(defaultLimits Limits, tenantLimits map[string]Limits, streamLabels []string) (*Limiter, error) {
+	metrics, err := sharedLimiterMetrics()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create limiter metrics: %v", err)
+	}
+	result := &Limiter{
+		streamLabels: streamLabels,
+		metrics:      metrics,
+		now:          time.Now,
+		buckets:      map[string]*limiterBuckets{},
+	}
+	err = result.SetLimits(defaultLimits, tenantLimits)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetLimits replaces the limits of all tenants. All buckets start out full under the new limits.
+func (l *Limiter) SetLimits(defaultLimits Limits, tenantLimits map[string]Limits) error {
+	err := defaultLimits.Validate()
+	if err != nil {
+		return fmt.Errorf("invalid default limits: %v", err)
+	}
+	for tenant, limits := range tenantLimits {
+		err := limits.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid limits for tenant [%s]: %v", tenant, err)
+		}
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.defaultLimits = defaultLimits
+	l.tenantLimits = tenantLimits
+	l.buckets = map[string]*limiterBuckets{}
+	return nil
+}
+
+// Admit returns nil if the supplied entry of the supplied tenant may be ingested, accounting for
+// it in the process. Otherwise, returns a grpc error. Entries which are too large are rejected
+// with InvalidArgument, entries exceeding a rate with ResourceExhausted, in which case the
+// returned duration is how long to wait before retrying.
+func (l *Limiter) Admit(tenant string, entry *pb_almanac.LogEntry) (time.Duration, error) {
+	numBytes := len(entry.EntryJson)
+	key := tenant
+	if len(l.streamLabels) > 0 {
+		labels, err := storage.EntryLabels(entry.EntryJson, l.streamLabels)
+		if err != nil {
+			return 0, grpc.Errorf(codes.InvalidArgument, "unable to extract stream labels: %v", err)
+		}
+		key = tenant + "/" + storage.EncodeLabels(labels)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	limits, ok := l.tenantLimits[tenant]
+	if !ok {
+		limits = l.defaultLimits
+	}
+	if limits.MaxEntryBytes > 0 && numBytes > limits.MaxEntryBytes {
+		l.metrics.numLimited.With(prometheus.Labels{tenantLabel: tenant, reasonLabel: reasonEntrySize}).Inc()
+		return 0, grpc.Errorf(codes.InvalidArgument, "entry has %d bytes, tenant [%s] allows at most %d", numBytes, tenant, limits.MaxEntryBytes)
+	}
+
+	now := l.now()
+	l.sweep(now)
+	buckets, ok := l.buckets[key]
+	if !ok {
+		buckets = &limiterBuckets{
+			entries: newTokenBucket(limits.EntriesPerSecond, now),
+			bytes:   newTokenBucket(limits.BytesPerSecond, now),
+		}
+		l.buckets[key] = buckets
+	}
+
+	// Only take from either bucket once both have room, such that rejected entries are free.
+	if wait := buckets.entries.wait(now); wait > 0 {
+		l.metrics.numLimited.With(prometheus.Labels{tenantLabel: tenant, reasonLabel: reasonEntryRate}).Inc()
+		return wait, grpc.Errorf(codes.ResourceExhausted, "tenant [%s] exceeded %v entries per second, retry after %v", tenant, limits.EntriesPerSecond, wait)
+	}
+	if wait := buckets.bytes.wait(now); wait > 0 {
+		l.metrics.numLimited.With(prometheus.Labels{tenantLabel: tenant, reasonLabel: reasonByteRate}).Inc()
+		return wait, grpc.Errorf(codes.ResourceExhausted, "tenant [%s] exceeded %v bytes per second, retry after %v", tenant, limits.BytesPerSecond, wait)
+	}
+	buckets.entries.take(1)
+	buckets.bytes.take(float64(numBytes))
+
+	l.metrics.numEntries.With(prometheus.Labels{tenantLabel: tenant}).Inc()
+	l.metrics.numBytes.With(prometheus.Labels{tenantLabel: tenant}).Add(float64(numBytes))
+	return 0, nil
+}
+
+// sweep forgets the buckets which have been full for a while. Must be called with mutex held.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleBucketTimeout {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.entries.idleSince(now) > idleBucketTimeout && b.bytes.idleSince(now) > idleBucketTimeout {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// tokenBucket is refilled at a constant rate and holds at most one second worth of tokens. Its
+// level may become negative, such that an entry larger than the capacity still gets through once
+// the bucket is full, but delays all entries after it accordingly. A zero rate is unlimited.
+type tokenBucket struct {
+	rate       float64
+	tokens     float64
+	lastUpdate time.Time
+}
+
+func newTokenBucket(rate float64, now time.Time) *tokenBucket {
+	return &tokenBucket{rate: rate, tokens: rate, lastUpdate: now}
+}
+
+// wait refills the bucket and returns how long it takes until it holds tokens again.
+func (b *tokenBucket) wait(now time.Time) time.Duration {
+	if b.rate == 0 {
+		return 0
+	}
+	elapsed := now.Sub(b.lastUpdate).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(b.rate, b.tokens+elapsed*b.rate)
+		b.lastUpdate = now
+	}
+	if b.tokens > 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(-b.tokens/b.rate*float64(time.Second))) + time.Nanosecond
+}
+
+func (b *tokenBucket) take(tokens float64) {
+	if b.rate == 0 {
+		return
+	}
+	b.tokens -= tokens
+}
+
+// idleSince returns how long the bucket has been full, or zero if it is not full.
+func (b *tokenBucket) idleSince(now time.Time) time.Duration {
+	if b.rate == 0 {
+		return now.Sub(b.lastUpdate)
+	}
+	missing := b.rate - b.tokens
+	full := b.lastUpdate.Add(time.Duration(missing / b.rate * float64(time.Second)))
+	if now.Before(full) {
+		return 0
+	}
+	return now.Sub(full)
+}
+
+// setRetryAfter tells the client of the current grpc call how long to wait before retrying. Has
+// no effect outside of grpc calls.
+func setRetryAfter(ctx context.Context, wait time.Duration) {
+	millis := int64(math.Ceil(float64(wait) / float64(time.Millisecond)))
+	grpc.SetTrailer(ctx, metadata.Pairs(RetryAfterKey, strconv.FormatInt(millis, 10)))
+}
+
+// RetryAfter returns the wait time sent by an ingester in the supplied trailer of a rate limited
+// call, or zero if there is none.
+func RetryAfter(trailer metadata.MD) time.Duration {
+	values := trailer[RetryAfterKey]
+	if len(values) == 0 {
+		return 0
+	}
+	millis, err := strconv.ParseInt(values[0], 10, 64)
+	if err != nil || millis < 0 {
+		return 0
+	}
+	return time.Duration(millis) * time.Millisecond
+}
+
+type limiterMetrics struct {
+	numEntries *prometheus.CounterVec
+	numBytes   *prometheus.CounterVec
+	numLimited *prometheus.CounterVec
+}
+
+// sharedLimiterMetrics returns the metrics of all limiters in this process, registering them in
+// the default registry the first time around.
+func sharedLimiterMetrics() (*limiterMetrics, error) {
+	limiterMetricsOnce.Do(func() {
+		limiterMetricsInstance, limiterMetricsCreateErr = newLimiterMetrics()
+	})
+	return limiterMetricsInstance, limiterMetricsCreateErr
+}
+
+func newLimiterMetrics() (*limiterMetrics, error) {
+	result := &limiterMetrics{}
+
+	result.numEntries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "almanac_ingester_admitted_entries",
+		Help: "The number of entries admitted for ingestion, by tenant",
+	}, []string{tenantLabel})
+	if err := util.RegisterLenient(result.numEntries); err != nil {
+		return nil, err
+	}
+
+	result.numBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "almanac_ingester_admitted_bytes",
+		Help: "The total size of the json of the entries admitted for ingestion, by tenant",
+	}, []string{tenantLabel})
+	if err := util.RegisterLenient(result.numBytes); err != nil {
+		return nil, err
+	}
+
+	result.numLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "almanac_ingester_limited_entries",
+		Help: "The number of entries rejected because their tenant exceeded a limit, by tenant and limit",
+	}, []string{tenantLabel, reasonLabel})
+	if err := util.RegisterLenient(result.numLimited); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
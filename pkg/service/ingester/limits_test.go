@@ -0,0 +1,112 @@
+package ingester
+
+import (
+	"testing"
+	"time"
+
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakeClock is a clock which only moves when told to.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestLimiter(t *testing.T, defaultLimits Limits, tenantLimits map[string]Limits, streamLabels []string) (*Limiter, *fakeClock) {
+	limiter, err := NewLimiter(defaultLimits, tenantLimits, streamLabels)
+	assert.NoError(t, err)
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	limiter.now = clock.Now
+	return limiter, clock
+}
+
+func testEntry(entryJson string) *pb_almanac.LogEntry {
+	return &pb_almanac.LogEntry{Id: "id", EntryJson: entryJson}
+}
+
+func TestLimiterEnforcesEntryRate(t *testing.T) {
+	limiter, clock := newTestLimiter(t, Limits{EntriesPerSecond: 2}, nil, nil)
+
+	for i := 0; i < 2; i++ {
+		_, err := limiter.Admit("acme", testEntry(`{}`))
+		assert.NoError(t, err)
+	}
+	wait, err := limiter.Admit("acme", testEntry(`{}`))
+	assert.Equal(t, codes.ResourceExhausted, grpc.Code(err))
+	assert.True(t, wait > 0 && wait <= 500*time.Millisecond, "%v", wait)
+
+	// Other tenants have buckets of their own.
+	_, err = limiter.Admit("other", testEntry(`{}`))
+	assert.NoError(t, err)
+
+	clock.now = clock.now.Add(wait)
+	_, err = limiter.Admit("acme", testEntry(`{}`))
+	assert.NoError(t, err)
+}
+
+func TestLimiterEnforcesByteRate(t *testing.T) {
+	limiter, clock := newTestLimiter(t, Limits{BytesPerSecond: 10}, nil, nil)
+
+	// An entry larger than the rate gets through, but delays the ones after it.
+	_, err := limiter.Admit("acme", testEntry(`{"message": "twenty bytes"}`))
+	assert.NoError(t, err)
+	wait, err := limiter.Admit("acme", testEntry(`{}`))
+	assert.Equal(t, codes.ResourceExhausted, grpc.Code(err))
+	assert.True(t, wait > time.Second && wait <= 2*time.Second, "%v", wait)
+
+	clock.now = clock.now.Add(wait)
+	_, err = limiter.Admit("acme", testEntry(`{}`))
+	assert.NoError(t, err)
+}
+
+func TestLimiterRejectsLargeEntries(t *testing.T) {
+	limiter, _ := newTestLimiter(t, Limits{}, map[string]Limits{"acme": {MaxEntryBytes: 5}}, nil)
+
+	wait, err := limiter.Admit("acme", testEntry(`{"message": "too long"}`))
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+	assert.Equal(t, time.Duration(0), wait)
+
+	// Tenants without limits of their own get the unlimited defaults.
+	_, err = limiter.Admit("other", testEntry(`{"message": "too long"}`))
+	assert.NoError(t, err)
+}
+
+func TestLimiterSeparatesStreams(t *testing.T) {
+	limiter, _ := newTestLimiter(t, Limits{EntriesPerSecond: 1}, nil, []string{"service"})
+
+	_, err := limiter.Admit("acme", testEntry(`{"service": "web"}`))
+	assert.NoError(t, err)
+	_, err = limiter.Admit("acme", testEntry(`{"service": "db"}`))
+	assert.NoError(t, err)
+	_, err = limiter.Admit("acme", testEntry(`{"service": "web"}`))
+	assert.Equal(t, codes.ResourceExhausted, grpc.Code(err))
+}
+
+func TestLimiterSetLimits(t *testing.T) {
+	limiter, _ := newTestLimiter(t, Limits{EntriesPerSecond: 1}, nil, nil)
+
+	_, err := limiter.Admit("acme", testEntry(`{}`))
+	assert.NoError(t, err)
+	_, err = limiter.Admit("acme", testEntry(`{}`))
+	assert.Error(t, err)
+
+	assert.Error(t, limiter.SetLimits(Limits{EntriesPerSecond: -1}, nil))
+	assert.NoError(t, limiter.SetLimits(Limits{}, nil))
+	_, err = limiter.Admit("acme", testEntry(`{}`))
+	assert.NoError(t, err)
+}
+
+func TestRetryAfter(t *testing.T) {
+	assert.Equal(t, 1500*time.Millisecond, RetryAfter(metadata.Pairs(RetryAfterKey, "1500")))
+	assert.Equal(t, time.Duration(0), RetryAfter(metadata.Pairs(RetryAfterKey, "soon")))
+	assert.Equal(t, time.Duration(0), RetryAfter(metadata.MD{}))
+}
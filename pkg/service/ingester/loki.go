@@ -0,0 +1,64 @@
+package ingester
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dinowernli/almanac/pkg/loki"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	lokiPushTimeout = 10 * time.Second
+)
+
+// handleLokiPush ingests the entries pushed by loki clients such as promtail. Each line becomes
+// the message of an entry, with the labels of its stream as further top-level fields.
+func (i *Ingester) handleLokiPush(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "push must use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	tenantId, err := loki.Tenant(request)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := loki.DecodePush(request)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lokiPushTimeout)
+	defer cancel()
+	for n, entry := range entries {
+		entryJson, err := loki.Entry(entry.Labels, entry.Line, entry.TimestampNs)
+		if err == nil {
+			_, err = i.Ingest(ctx, &pb_almanac.IngestRequest{EntryJson: entryJson, Tenant: tenantId, Format: pb_almanac.IngestRequest_JSON})
+		}
+		if err != nil {
+			message := fmt.Sprintf("ingested %d of %d entries, then failed: %v", n, len(entries), err)
+			http.Error(writer, message, lokiStatus(err))
+			return
+		}
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// lokiStatus returns the http status which tells loki clients whether to retry after the
+// supplied error. Clients retry on 429 and 5xx, but drop pushes failing with other statuses.
+func lokiStatus(err error) int {
+	switch grpc.Code(err) {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
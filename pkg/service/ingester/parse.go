@@ -0,0 +1,198 @@
+package ingester
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	pb_almanac "github.com/dinowernli/almanac/proto"
+)
+
+const (
+	messageField = "message"
+)
+
+var (
+	// parsers holds the parser of each format which can be ingested.
+	parsers = map[pb_almanac.IngestRequest_Format]Parser{
+		pb_almanac.IngestRequest_JSON:   &jsonParser{},
+		pb_almanac.IngestRequest_LOGFMT: &logfmtParser{},
+		pb_almanac.IngestRequest_TEXT:   &textParser{},
+	}
+)
+
+// Parser turns raw entries of a single format into json objects, the form in which all entries
+// are stored. Supporting another format takes a new IngestRequest.Format and an entry in parsers.
+type Parser interface {
+	// Parse returns the supplied raw entry as a json object, or an error if the entry is not in
+	// the format of this parser.
+	Parse(raw string) (string, error)
+}
+
+// parseEntry returns the supplied raw entry in the supplied format as a json object, detecting
+// the format first if it is AUTO.
+func parseEntry(raw string, format pb_almanac.IngestRequest_Format) (string, error) {
+	if format == pb_almanac.IngestRequest_AUTO {
+		format = detectFormat(raw)
+	}
+	parser, ok := parsers[format]
+	if !ok {
+		return "", fmt.Errorf("unsupported format %v", format)
+	}
+	result, err := parser.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse entry as %v: %v", format, err)
+	}
+	return result, nil
+}
+
+// detectFormat returns the format the supplied raw entry is most likely in. Entries which look
+// like json objects are taken to be json, even if they turn out to be malformed, such that they
+// are rejected rather than silently stored as text.
+func detectFormat(raw string) pb_almanac.IngestRequest_Format {
+	if strings.HasPrefix(strings.TrimSpace(raw), "{") {
+		return pb_almanac.IngestRequest_JSON
+	}
+	if pairs, complete, err := splitLogfmt(raw); err == nil && complete && len(pairs) > 0 {
+		return pb_almanac.IngestRequest_LOGFMT
+	}
+	return pb_almanac.IngestRequest_TEXT
+}
+
+// jsonParser accepts json objects, which it leaves exactly as they are.
+type jsonParser struct{}
+
+func (p *jsonParser) Parse(raw string) (string, error) {
+	var object map[string]*json.RawMessage
+	err := json.Unmarshal([]byte(raw), &object)
+	if err != nil {
+		return "", fmt.Errorf("not a json object: %v", err)
+	}
+	return raw, nil
+}
+
+// logfmtParser accepts lines of key=value pairs, where values containing spaces are quoted, e.g.,
+// level=info msg="user logged in" user_id=17. Keys without a value get an empty value. All values
+// become strings, except the timestamp which becomes a number if it is one.
+type logfmtParser struct{}
+
+func (p *logfmtParser) Parse(raw string) (string, error) {
+	pairs, _, err := splitLogfmt(raw)
+	if err != nil {
+		return "", err
+	}
+	if len(pairs) == 0 {
+		return "", fmt.Errorf("no key=value pairs found")
+	}
+
+	object := map[string]interface{}{}
+	for _, pair := range pairs {
+		object[pair[0]] = pair[1]
+		if pair[0] == timestampField {
+			if timestampMs, err := strconv.ParseInt(pair[1], 10, 64); err == nil {
+				object[pair[0]] = timestampMs
+			}
+		}
+	}
+	result, err := json.Marshal(object)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal entry: %v", err)
+	}
+	return string(result), nil
+}
+
+// textParser accepts anything, storing it as the message of an entry.
+type textParser struct{}
+
+func (p *textParser) Parse(raw string) (string, error) {
+	result, err := json.Marshal(map[string]string{messageField: strings.TrimRight(raw, "\r\n")})
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal entry: %v", err)
+	}
+	return string(result), nil
+}
+
+// splitLogfmt returns the key and value of each pair in the supplied logfmt line, in order, and
+// whether every key had a value. Quoted values are unquoted.
+func splitLogfmt(line string) ([][2]string, bool, error) {
+	result := [][2]string{}
+	complete := true
+	i := 0
+	for {
+		for i < len(line) && isLogfmtSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return result, complete, nil
+		}
+
+		start := i
+		for i < len(line) && line[i] != '=' && !isLogfmtSpace(line[i]) {
+			if line[i] == '"' {
+				return nil, false, fmt.Errorf("unexpected quote in key at position %d", i)
+			}
+			i++
+		}
+		key := line[start:i]
+		if key == "" {
+			return nil, false, fmt.Errorf("missing key at position %d", i)
+		}
+		if i == len(line) || line[i] != '=' {
+			complete = false
+			result = append(result, [2]string{key, ""})
+			continue
+		}
+		i++
+
+		value := ""
+		if i < len(line) && line[i] == '"' {
+			end := i + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, false, fmt.Errorf("unterminated quote in value of key %s", key)
+			}
+			unquoted, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid quoted value of key %s: %v", key, err)
+			}
+			value = unquoted
+			i = end + 1
+			if i < len(line) && !isLogfmtSpace(line[i]) {
+				return nil, false, fmt.Errorf("expected space after value of key %s", key)
+			}
+		} else {
+			start := i
+			for i < len(line) && !isLogfmtSpace(line[i]) {
+				i++
+			}
+			value = line[start:i]
+		}
+		result = append(result, [2]string{key, value})
+	}
+}
+
+// formatNames returns the names of all formats, ordered by their values.
+func formatNames() []string {
+	values := []int{}
+	for _, value := range pb_almanac.IngestRequest_Format_value {
+		values = append(values, int(value))
+	}
+	sort.Ints(values)
+
+	result := []string{}
+	for _, value := range values {
+		result = append(result, pb_almanac.IngestRequest_Format_name[int32(value)])
+	}
+	return result
+}
+
+func isLogfmtSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
@@ -0,0 +1,78 @@
+package ingester
+
+import (
+	"testing"
+
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectFormat(t *testing.T) {
+	cases := map[string]pb_almanac.IngestRequest_Format{
+		`{"message": "foo"}`:                  pb_almanac.IngestRequest_JSON,
+		`  {"message": `:                      pb_almanac.IngestRequest_JSON,
+		`level=info msg="user logged in"`:     pb_almanac.IngestRequest_LOGFMT,
+		`user logged in`:                      pb_almanac.IngestRequest_TEXT,
+		`user logged in id=17`:                pb_almanac.IngestRequest_TEXT,
+		`broken="quote`:                       pb_almanac.IngestRequest_TEXT,
+		`GET /index.html HTTP/1.1 status=200`: pb_almanac.IngestRequest_TEXT,
+	}
+	for raw, expected := range cases {
+		assert.Equal(t, expected, detectFormat(raw), raw)
+	}
+}
+
+func TestParseJson(t *testing.T) {
+	raw := `{ "message": "foo", "timestamp_ms": 5000 }`
+	result, err := parseEntry(raw, pb_almanac.IngestRequest_JSON)
+	assert.NoError(t, err)
+	assert.Equal(t, raw, result)
+
+	_, err = parseEntry(`[1, 2]`, pb_almanac.IngestRequest_JSON)
+	assert.Error(t, err)
+	_, err = parseEntry(`message=foo`, pb_almanac.IngestRequest_JSON)
+	assert.Error(t, err)
+
+	// Malformed json is not silently stored as text.
+	_, err = parseEntry(`{"message": `, pb_almanac.IngestRequest_AUTO)
+	assert.Error(t, err)
+}
+
+func TestParseLogfmt(t *testing.T) {
+	result, err := parseEntry(`level=info msg="user \"bob\" logged in" timestamp_ms=5000 verbose empty=`, pb_almanac.IngestRequest_LOGFMT)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"level": "info", "msg": "user \"bob\" logged in", "timestamp_ms": 5000, "verbose": "", "empty": ""}`, result)
+
+	entry, err := extractEntry(result)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5000), entry.TimestampMs)
+
+	for _, invalid := range []string{``, `msg="unterminated`, `"key"=value`, `=value`, `msg="a"b`} {
+		_, err := parseEntry(invalid, pb_almanac.IngestRequest_LOGFMT)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseText(t *testing.T) {
+	result, err := parseEntry("user \"bob\" logged in\n", pb_almanac.IngestRequest_TEXT)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"message": "user \"bob\" logged in"}`, result)
+
+	result, err = parseEntry(`{"message": "foo"}`, pb_almanac.IngestRequest_TEXT)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"message": "{\"message\": \"foo\"}"}`, result)
+
+	result, err = parseEntry(`user logged in`, pb_almanac.IngestRequest_AUTO)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"message": "user logged in"}`, result)
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	_, err := parseEntry(`{}`, pb_almanac.IngestRequest_Format(42))
+	assert.Error(t, err)
+}
+
+func TestFormatNames(t *testing.T) {
+	assert.Equal(t, []string{"AUTO", "JSON", "LOGFMT", "TEXT"}, formatNames())
+}
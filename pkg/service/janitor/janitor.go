@@ -2,49 +2,170 @@ package janitor
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	st "github.com/dinowernli/almanac/pkg/storage"
-	"github.com/dinowernli/almanac/pkg/util"
 	pb_almanac "github.com/dinowernli/almanac/proto"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
+	"golang.org/x/sync/errgroup"
 )
 
 // Janitor periodically takes a look at the contents in storage and may rewrite them to
 // make queries cheaper and more efficient. This is intended to run as a singleton service.
 type Janitor struct {
-	ctx               context.Context
-	logger            *logrus.Logger
-	storage           *st.Storage
-	cleanupInterval   time.Duration
-	bigChunkMaxSpread time.Duration
+	ctx             context.Context
+	cancel          context.CancelFunc
+	logger          *logrus.Logger
+	storage         *st.Storage
+	cleanupInterval time.Duration
+
+	// numWorkers bounds the number of chunks loaded or deleted concurrently.
+	numWorkers int
+
+	// checker scrubs storage every scrubInterval, unless the interval is zero.
+	checker       *Checker
+	scrubInterval time.Duration
+
+	// The settings below can change while the janitor is running, see Reconfigure and SetQuotas.
+	// The policy decides which chunks get compacted. The maxCompactionBytes bound the total decoded
+	// size of the entries merged into a single big chunk, which in turn bounds the memory used by a
+	// compaction. Problems found while scrubbing are only repaired if scrubRepair is set. Tenants
+	// storing more than their quotas per day are reported.
+	settingsMutex      sync.Mutex
+	policy             Policy
+	maxCompactionBytes int64
+	scrubRepair        bool
+	quotas             Quotas
+	quotaMetrics       *quotaMetrics
+
+	// purgedChunks holds a key for every pair of tombstone and chunk which has already been
+	// purged. Only accessed from the janitor's loop.
+	purgedChunks map[string]struct{}
+
+	// oversizedChunks maps the ids of big chunks which cannot be merged with their neighbours to
+	// the compaction budget they exceeded. These are left out of compactions until the budget
+	// grows. Only accessed from the janitor's loop.
+	oversizedChunks map[string]int64
+
+	// stop is closed to ask the janitor's loop to exit, which closes stopped once it has.
+	stop     chan struct{}
+	stopOnce sync.Once
+	stopped  chan struct{}
 }
 
-// New creates a new Janitor instance which periodically compacts the supplied storage until
-// the supplied context is done.
-func New(ctx context.Context, logger *logrus.Logger, storage *st.Storage, cleanupInterval time.Duration, bigChunkMaxSpread time.Duration) (*Janitor, error) {
+// New creates a new Janitor instance which periodically compacts the supplied storage until the
+// supplied context is done or Stop is called. The supplied policy decides which chunks get
+// compacted. If the entries of the selected chunks exceed maxCompactionBytes, they are split up
+// into multiple big chunks. If scrubInterval is positive, the janitor also periodically checks the
+// consistency of storage.
+func New(ctx context.Context, logger *logrus.Logger, storage *st.Storage, cleanupInterval time.Duration, policy Policy, numWorkers int, maxCompactionBytes int64, scrubInterval time.Duration, scrubRepair bool) (*Janitor, error) {
 	if cleanupInterval <= 0 {
 		return nil, fmt.Errorf("cleanup interval must be positive, but got %v", cleanupInterval)
 	}
-	if bigChunkMaxSpread <= 0 {
-		return nil, fmt.Errorf("big chunk spread must be positive, but got: %v", bigChunkMaxSpread)
+	err := validateSettings(policy, maxCompactionBytes)
+	if err != nil {
+		return nil, err
+	}
+	if numWorkers <= 0 {
+		return nil, fmt.Errorf("number of workers must be positive, but got: %d", numWorkers)
+	}
+	if scrubInterval < 0 {
+		return nil, fmt.Errorf("scrub interval must not be negative, but got %v", scrubInterval)
 	}
+	checker, err := NewChecker(logger, storage, numWorkers)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create checker: %v", err)
+	}
+	quotaMetrics, err := sharedQuotaMetrics()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create quota metrics: %v", err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
 	result := &Janitor{
-		ctx:               ctx,
-		logger:            logger,
-		storage:           storage,
-		cleanupInterval:   cleanupInterval,
-		bigChunkMaxSpread: bigChunkMaxSpread,
+		ctx:             ctx,
+		cancel:          cancel,
+		logger:          logger,
+		storage:         storage,
+		cleanupInterval: cleanupInterval,
+		policy:          policy,
+
+		numWorkers:         numWorkers,
+		maxCompactionBytes: maxCompactionBytes,
+		checker:            checker,
+		scrubInterval:      scrubInterval,
+		scrubRepair:        scrubRepair,
+		quotaMetrics:       quotaMetrics,
+		purgedChunks:       map[string]struct{}{},
+		stop:               make(chan struct{}),
+		stopped:            make(chan struct{}),
 	}
 	result.start()
 	return result, nil
 }
 
+// Reconfigure replaces the compaction policy, the memory budget of compactions and whether scrubs
+// repair the problems they find. The new settings apply from the next compaction or scrub on.
+func (j *Janitor) Reconfigure(policy Policy, maxCompactionBytes int64, scrubRepair bool) error {
+	err := validateSettings(policy, maxCompactionBytes)
+	if err != nil {
+		return err
+	}
+
+	j.settingsMutex.Lock()
+	defer j.settingsMutex.Unlock()
+	j.policy = policy
+	j.maxCompactionBytes = maxCompactionBytes
+	j.scrubRepair = scrubRepair
+	return nil
+}
+
+// Stop asks the janitor to exit once any compaction, purge or scrub in progress is done, and waits
+// for it to do so. If the supplied context is done first, the work in progress is aborted without
+// waiting for it to wind down, which leaves storage consistent but may leave behind chunks for a
+// later compaction or scrub to clean up.
+func (j *Janitor) Stop(ctx context.Context) error {
+	j.stopOnce.Do(func() {
+		close(j.stop)
+	})
+	defer j.cancel()
+
+	select {
+	case <-j.stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("aborted janitor while stopping: %v", ctx.Err())
+	}
+}
+
+func validateSettings(policy Policy, maxCompactionBytes int64) error {
+	if policy == nil {
+		return fmt.Errorf("must supply a compaction policy")
+	}
+	if maxCompactionBytes <= 0 {
+		return fmt.Errorf("max compaction bytes must be positive, but got: %d", maxCompactionBytes)
+	}
+	return nil
+}
+
 func (j *Janitor) start() {
 	ticker := time.NewTicker(j.cleanupInterval)
+
+	// Scrubs run on the same goroutine as compactions, such that the two never see each other's
+	// intermediate state. A nil channel never fires, which disables scrubbing.
+	var scrubTicker *time.Ticker
+	var scrubs <-chan time.Time
+	if j.scrubInterval > 0 {
+		scrubTicker = time.NewTicker(j.scrubInterval)
+		scrubs = scrubTicker.C
+	}
+
 	go func() {
+		defer close(j.stopped)
 		for {
 			select {
 			case <-ticker.C:
@@ -52,8 +173,30 @@ func (j *Janitor) start() {
 				if err != nil {
 					j.logger.WithError(err).Warn("Compaction failed")
 				}
+				err = j.executePurge()
+				if err != nil {
+					j.logger.WithError(err).Warn("Purge failed")
+				}
+				err = j.executeQuotaCheck()
+				if err != nil {
+					j.logger.WithError(err).Warn("Quota check failed")
+				}
+			case <-scrubs:
+				err := j.executeScrub()
+				if err != nil {
+					j.logger.WithError(err).Warn("Scrub failed")
+				}
+			case <-j.stop:
+				ticker.Stop()
+				if scrubTicker != nil {
+					scrubTicker.Stop()
+				}
+				return
 			case <-j.ctx.Done():
 				ticker.Stop()
+				if scrubTicker != nil {
+					scrubTicker.Stop()
+				}
 				return
 			}
 		}
@@ -65,130 +208,341 @@ func (j *Janitor) executeCompaction() error {
 	defer cancel()
 	start := time.Now()
 
-	chunks, err := j.storage.ListChunks(ctx, 0, 0, pb_almanac.ChunkId_SMALL)
+	j.settingsMutex.Lock()
+	policy := j.policy
+	maxCompactionBytes := j.maxCompactionBytes
+	j.settingsMutex.Unlock()
+
+	tenants, err := j.storage.ListTenants(ctx)
 	if err != nil {
-		return fmt.Errorf("unable to list chunks during compaction: %v", err)
+		return fmt.Errorf("unable to list tenants during compaction: %v", err)
 	}
-	j.logger.Infof("Found %d small chunk(s) in storage", len(chunks))
 
-	selectedChunkIds, err := j.selectSmallChunks(chunks)
-	if err != nil {
-		return fmt.Errorf("unable to select small chunks during compaction: %v", err)
+	// Only chunks still in storage stay marked as oversized, so start with an empty set.
+	oversized := j.oversizedChunks
+	j.oversizedChunks = map[string]int64{}
+
+	// Chunks of different tenants must never be compacted together, so select for each tenant
+	// separately.
+	groups := [][]*pb_almanac.ChunkId{}
+	for _, tenantId := range tenants {
+		smallChunks, err := j.storage.ListChunkInfos(ctx, tenantId, 0, 0, pb_almanac.ChunkId_SMALL)
+		if err != nil {
+			return fmt.Errorf("unable to list small chunks during compaction: %v", err)
+		}
+		bigChunks, err := j.storage.ListChunkInfos(ctx, tenantId, 0, 0, pb_almanac.ChunkId_BIG)
+		if err != nil {
+			return fmt.Errorf("unable to list big chunks during compaction: %v", err)
+		}
+		j.logger.WithFields(logrus.Fields{"tenant": tenantId}).Infof("Found %d small chunk(s) and %d big chunk(s) in storage", len(smallChunks), len(bigChunks))
+
+		bigChunks, err = j.excludeOversized(oversized, bigChunks, maxCompactionBytes)
+		if err != nil {
+			return fmt.Errorf("unable to exclude oversized chunks: %v", err)
+		}
+		tenantChunks := append(smallChunks, bigChunks...)
+		tenantGroups, err := policy.Select(start, tenantChunks)
+		if err != nil {
+			return fmt.Errorf("unable to select chunks during compaction: %v", err)
+		}
+		groups = append(groups, tenantGroups...)
 	}
-	if len(selectedChunkIds) == 0 {
+	if len(groups) == 0 {
 		// Nothing to compact.
 		return nil
 	}
-	j.logger.Infof("Selected %d small chunk(s) to compact", len(selectedChunkIds))
+	j.logger.Infof("Selected %d group(s) of chunks to compact", len(groups))
 
-	bigChunk, err := j.constructBigChunk(j.ctx, selectedChunkIds)
-	if err != nil {
-		return fmt.Errorf("unable to construct big chunk during compaction: %v", err)
+	batches := [][]*pb_almanac.ChunkId{}
+	for _, group := range groups {
+		batches = append(batches, batchChunks(group)...)
 	}
-	j.logger.Infof("Constructed big chunk with %d entries", len(bigChunk.Entries))
 
-	_, err = j.storage.StoreChunk(j.ctx, bigChunk)
-	if err != nil {
-		return fmt.Errorf("unable to store big chunk during compaction: %v", err)
-	}
-	j.logger.Infof("Stored big chunk")
+	// Batches are compacted one at a time so that we never hold more than one batch worth of
+	// entries in memory.
+	for i, batch := range batches {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("compaction aborted after %d of %d batch(es): %v", i, len(batches), err)
+		}
 
-	err = j.deleteSmallChunks(j.ctx, selectedChunkIds)
-	if err != nil {
-		return fmt.Errorf("unable to delete small chunks during compaction: %v", err)
+		// Leave the remaining batches to the next compaction if we have been asked to stop.
+		select {
+		case <-j.stop:
+			j.logger.Infof("Stopping compaction after %d of %d batch(es)", i, len(batches))
+			return nil
+		default:
+		}
+
+		err := j.compactBatch(ctx, batch, maxCompactionBytes)
+		if err != nil {
+			return fmt.Errorf("unable to compact batch %d of %d: %v", i+1, len(batches), err)
+		}
 	}
-	j.logger.Infof("Deleted %d small chunk(s) which have become redundant", len(selectedChunkIds))
 
-	j.logger.Infof("Compaction successful, took %v", time.Since(start))
+	j.logger.Infof("Compaction of %d batch(es) successful, took %v", len(batches), time.Since(start))
 	return nil
 }
 
-// selectSmallChunks takes a list of id strings of small chunks and returns the ids which are to
-// make up a new big chunk. All elements in the returned small chunks belong in the big chunk.
-func (j *Janitor) selectSmallChunks(chunkIds []string) ([]*pb_almanac.ChunkId, error) {
-	result := []*pb_almanac.ChunkId{}
+// excludeOversized returns the supplied big chunks, leaving out those previously found to be
+// too big to merge with their neighbours within the supplied budget. These carry over from the
+// supplied previous set of oversized chunks.
+func (j *Janitor) excludeOversized(previous map[string]int64, bigChunks []*st.ChunkInfo, maxBytes int64) ([]*st.ChunkInfo, error) {
+	result := []*st.ChunkInfo{}
+	for _, c := range bigChunks {
+		id, err := st.ChunkId(c.Id)
+		if err != nil {
+			return nil, fmt.Errorf("unable to compute chunk id: %v", err)
+		}
+		if exceeded, ok := previous[id]; ok && maxBytes <= exceeded {
+			j.oversizedChunks[id] = exceeded
+			continue
+		}
+		result = append(result, c)
+	}
+	return result, nil
+}
 
-	// Holds the start time of the big chunk we are writing.
-	var startMs *int64
+// batchChunks splits the supplied selected chunks into batches by stream, since chunks of
+// different streams must never end up in the same big chunk. Each batch is sorted by start time.
+func batchChunks(selectedChunkIds []*pb_almanac.ChunkId) [][]*pb_almanac.ChunkId {
+	streamKeys := map[*pb_almanac.ChunkId]string{}
+	for _, c := range selectedChunkIds {
+		streamKeys[c] = st.EncodeLabels(c.Labels)
+	}
+	sorted := make([]*pb_almanac.ChunkId, len(selectedChunkIds))
+	copy(sorted, selectedChunkIds)
+	sort.Slice(sorted, func(i, j int) bool {
+		if streamKeys[sorted[i]] != streamKeys[sorted[j]] {
+			return streamKeys[sorted[i]] < streamKeys[sorted[j]]
+		}
+		return sorted[i].StartMs < sorted[j].StartMs
+	})
 
-	// Holds the maximum end time of our new big chunk. This is used to determing when to stop
-	// considering small chunks.
-	var maxEndTime time.Time
+	result := [][]*pb_almanac.ChunkId{}
+	batch := []*pb_almanac.ChunkId{}
+	for _, c := range sorted {
+		if len(batch) > 0 && streamKeys[batch[0]] != streamKeys[c] {
+			result = append(result, batch)
+			batch = []*pb_almanac.ChunkId{}
+		}
+		batch = append(batch, c)
+	}
+	if len(batch) > 0 {
+		result = append(result, batch)
+	}
+	return result
+}
 
-	now := time.Now()
-	for _, c := range chunkIds {
-		idProto, err := st.ChunkIdProto(c)
+// compactBatch replaces the supplied chunks, which must belong to the same stream and be sorted
+// by start time, with big chunks holding all their entries. Consecutive chunks share a big chunk
+// as long as their decoded entries fit into the supplied budget. A big chunk which ends up on its
+// own is left as is and marked as oversized, since it cannot be merged with its neighbours.
+func (j *Janitor) compactBatch(ctx context.Context, chunkIds []*pb_almanac.ChunkId, maxBytes int64) error {
+	if len(chunkIds) == 1 && chunkIds[0].Type == pb_almanac.ChunkId_BIG {
+		// Rewriting a single big chunk on its own is pointless.
+		return nil
+	}
+
+	for len(chunkIds) > 0 {
+		bigChunk, consumed, rest, err := j.constructBigChunk(ctx, chunkIds, maxBytes)
 		if err != nil {
-			return nil, fmt.Errorf("unable to convert chunk %s id to proto: %v", c, err)
+			return fmt.Errorf("unable to construct big chunk: %v", err)
 		}
-		if idProto.Type != pb_almanac.ChunkId_SMALL {
-			return nil, fmt.Errorf("expected small chunk but found type: %v", idProto.Type)
+		chunkIds = rest
+		if bigChunk == nil {
+			// All the chunks turned out to be corrupt and have been quarantined.
+			continue
 		}
 
-		cStart := util.TimeMs(idProto.StartMs)
-		cEnd := util.TimeMs(idProto.EndMs)
+		if len(consumed) == 1 && consumed[0].Type == pb_almanac.ChunkId_BIG {
+			id, err := st.ChunkId(consumed[0])
+			if err != nil {
+				return fmt.Errorf("unable to compute chunk id: %v", err)
+			}
+			j.logger.Warnf("Skipping big chunk %s, which cannot be merged with its neighbours within the compaction budget of %d bytes", id, maxBytes)
+			j.oversizedChunks[id] = maxBytes
+			continue
+		}
+		j.logger.Infof("Constructed big chunk with %d entries from %d chunk(s)", len(bigChunk.Entries), len(consumed))
 
-		// Only consider small chunks that have started sufficiently far in the past in
-		// order to avoid creating big chunks for periods of time that are still actively
-		// being written to.
-		// TODO(dino): Introduce a separate duration rather than using bigChunkMaxSpread.
-		if cStart.After(now.Add(-j.bigChunkMaxSpread)) {
-			// Chunks are sorted by start, so no need to consider the next chunks.
-			break
+		_, err = j.storage.StoreChunk(ctx, bigChunk)
+		if err != nil {
+			return fmt.Errorf("unable to store big chunk: %v", err)
 		}
+		j.logger.Infof("Stored big chunk")
 
-		if startMs == nil {
-			startMs = &idProto.StartMs
-			maxEndTime = util.TimeMs(*startMs).Add(j.bigChunkMaxSpread)
-		} else {
-			// Stop once the current small chunk starts after the maximum end time of
-			// the big chunk we are trying to build.
-			if cStart.After(maxEndTime) {
-				break
-			}
+		err = j.deleteChunks(ctx, consumed)
+		if err != nil {
+			return fmt.Errorf("unable to delete chunks: %v", err)
 		}
+		j.logger.Infof("Deleted %d chunk(s) which have become redundant", len(consumed))
+	}
+	return nil
+}
 
-		// Even though the small chunk starts before our maximum end time, we don't want to
-		// consider it unless it fits entirely inside the new big chunk.
-		if cEnd.After(maxEndTime) {
-			continue
+// constructBigChunk fetches the entries of the longest prefix of the supplied chunks whose decoded
+// entries fit into maxBytes, and returns a big chunk holding them. Also returns the ids of the
+// chunks whose data the big chunk contains and of those left over. The chunks must belong to the
+// same stream. A first chunk exceeding the budget makes up a big chunk on its own. Chunks are
+// loaded numWorkers at a time, so at most that many chunks beyond the budget are held in memory.
+// Corrupt chunks are quarantined and left out. Returns a nil chunk if all the chunks loaded turn
+// out to be corrupt.
+func (j *Janitor) constructBigChunk(ctx context.Context, chunkIds []*pb_almanac.ChunkId, maxBytes int64) (*pb_almanac.Chunk, []*pb_almanac.ChunkId, []*pb_almanac.ChunkId, error) {
+	allEntries := []*pb_almanac.LogEntry{}
+	consumed := []*pb_almanac.ChunkId{}
+	var numBytes int64
+	full := false
+	for len(chunkIds) > 0 && !full {
+		numLoaded := j.numWorkers
+		if numLoaded > len(chunkIds) {
+			numLoaded = len(chunkIds)
 		}
+		loaded := chunkIds[:numLoaded]
+		chunkIds = chunkIds[numLoaded:]
 
-		result = append(result, idProto)
+		chunkEntries, corrupt, err := j.loadEntries(ctx, loaded)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for i, entries := range chunkEntries {
+			if corrupt[i] {
+				continue
+			}
+			size := entriesBytes(entries)
+			if len(consumed) > 0 && numBytes+size > maxBytes {
+				// Leave this chunk and all later ones to the next big chunk, apart from those
+				// which have been quarantined.
+				rest := []*pb_almanac.ChunkId{}
+				for k := i; k < len(loaded); k++ {
+					if !corrupt[k] {
+						rest = append(rest, loaded[k])
+					}
+				}
+				chunkIds = append(rest, chunkIds...)
+				full = true
+				break
+			}
+			allEntries = append(allEntries, entries...)
+			consumed = append(consumed, loaded[i])
+			numBytes += size
+		}
 	}
-	return result, nil
+	if len(consumed) == 0 {
+		return nil, nil, chunkIds, nil
+	}
+
+	chunk, err := st.ChunkProto(allEntries, pb_almanac.ChunkId_BIG)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("unable to create large chunk: %v", err)
+	}
+	chunk.Id.Labels = consumed[0].Labels
+	chunk.Id.Tenant = consumed[0].Tenant
+	return chunk, consumed, chunkIds, nil
 }
 
-// constructBigChunk fetches all the data from the specified small chunks and returns a big chunk.
-func (j *Janitor) constructBigChunk(ctx context.Context, smallChunkIds []*pb_almanac.ChunkId) (*pb_almanac.Chunk, error) {
-	// TODO(dino): Parallelize this in a controlled way.
-	allEntries := []*pb_almanac.LogEntry{}
-	for _, c := range smallChunkIds {
+// loadEntries loads the entries of the supplied chunks, quarantining those which turn out to be
+// corrupt. Returns the entries of each chunk, along with whether it was corrupt.
+func (j *Janitor) loadEntries(ctx context.Context, chunkIds []*pb_almanac.ChunkId) ([][]*pb_almanac.LogEntry, []bool, error) {
+	// Each worker writes to its own slot, so no synchronization is required.
+	chunkEntries := make([][]*pb_almanac.LogEntry, len(chunkIds))
+	corrupt := make([]bool, len(chunkIds))
+	err := forEachChunk(ctx, j.numWorkers, chunkIds, func(ctx context.Context, i int, c *pb_almanac.ChunkId) error {
 		chunk, err := j.storage.LoadChunk(ctx, c)
+		if corruptErr := st.IsCorruptChunk(err); corruptErr != nil {
+			corrupt[i] = true
+			return j.quarantineChunk(ctx, corruptErr)
+		}
 		if err != nil {
-			return nil, fmt.Errorf("unable to load chunk %v: %v", c, err)
+			return fmt.Errorf("unable to load chunk %v: %v", c, err)
 		}
 		err = chunk.Close()
 		if err != nil {
-			return nil, fmt.Errorf("unable to close chunk: %v", err)
+			return fmt.Errorf("unable to close chunk: %v", err)
 		}
-		allEntries = append(allEntries, chunk.Entries()...)
+		chunkEntries[i] = chunk.Entries()
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
+	return chunkEntries, corrupt, nil
+}
 
-	chunk, err := st.ChunkProto(allEntries, pb_almanac.ChunkId_BIG)
+// entriesBytes returns the decoded size of the supplied entries, which approximates the memory
+// they take up.
+func entriesBytes(entries []*pb_almanac.LogEntry) int64 {
+	var result int64
+	for _, e := range entries {
+		result += int64(proto.Size(e))
+	}
+	return result
+}
+
+// quarantineChunk moves a chunk which turned out to be corrupt out of the way, such that it no
+// longer disrupts compactions and searches.
+func (j *Janitor) quarantineChunk(ctx context.Context, corruptErr *st.CorruptChunkError) error {
+	j.logger.WithError(corruptErr).Warnf("Quarantining corrupt chunk")
+	err := j.storage.QuarantineChunk(ctx, corruptErr.ChunkId)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create large chunk: %v", err)
+		return fmt.Errorf("unable to quarantine chunk %v: %v", corruptErr.ChunkId, err)
 	}
-	return chunk, nil
+	return nil
 }
 
-func (j *Janitor) deleteSmallChunks(ctx context.Context, smallChunkIds []*pb_almanac.ChunkId) error {
-	// TODO(dino): Parallelize this in a controlled way.
-	for _, c := range smallChunkIds {
+func (j *Janitor) deleteChunks(ctx context.Context, chunkIds []*pb_almanac.ChunkId) error {
+	return forEachChunk(ctx, j.numWorkers, chunkIds, func(ctx context.Context, i int, c *pb_almanac.ChunkId) error {
 		err := j.storage.DeleteChunk(ctx, c)
 		if err != nil {
 			return fmt.Errorf("failed to delete chunk: %v", err)
 		}
+		return nil
+	})
+}
+
+// listChunkInfos returns information about all stored chunks, small and big, of all tenants.
+func listChunkInfos(ctx context.Context, storage *st.Storage) ([]*st.ChunkInfo, error) {
+	tenants, err := storage.ListTenants(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list tenants: %v", err)
 	}
-	return nil
+	result := []*st.ChunkInfo{}
+	for _, tenantId := range tenants {
+		smallChunks, err := storage.ListChunkInfos(ctx, tenantId, 0, 0, pb_almanac.ChunkId_SMALL)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list small chunks: %v", err)
+		}
+		bigChunks, err := storage.ListChunkInfos(ctx, tenantId, 0, 0, pb_almanac.ChunkId_BIG)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list big chunks: %v", err)
+		}
+		result = append(result, smallChunks...)
+		result = append(result, bigChunks...)
+	}
+	return result, nil
+}
+
+// forEachChunk runs the supplied function for every chunk, using at most numWorkers concurrent
+// workers. Returns the first error encountered, in which case the remaining work is cancelled.
+func forEachChunk(ctx context.Context, numWorkers int, chunkIds []*pb_almanac.ChunkId, fn func(context.Context, int, *pb_almanac.ChunkId) error) error {
+	g, groupCtx := errgroup.WithContext(ctx)
+	workers := make(chan struct{}, numWorkers)
+	for i, c := range chunkIds {
+		i, c := i, c
+		select {
+		case workers <- struct{}{}:
+		case <-groupCtx.Done():
+		}
+		if groupCtx.Err() != nil {
+			// Some worker failed or we were cancelled, stop handing out work.
+			break
+		}
+		g.Go(func() error {
+			defer func() { <-workers }()
+			return fn(groupCtx, i, c)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	return ctx.Err()
 }
@@ -1,10 +1,16 @@
 package janitor
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	st "github.com/dinowernli/almanac/pkg/storage"
+	"github.com/dinowernli/almanac/pkg/tenant"
 	pb_almanac "github.com/dinowernli/almanac/proto"
 
 	"github.com/sirupsen/logrus"
@@ -15,6 +21,9 @@ import (
 const (
 	compactionInterval = 1 * time.Second
 	bigChunkMaxSpread  = 10 * time.Millisecond
+	settleDelay        = 1 * time.Minute
+	numWorkers         = 2
+	maxCompactionBytes = 1024 * 1024
 )
 
 var (
@@ -30,18 +39,21 @@ func TestCompaction(t *testing.T) {
 	storage := createStorage(t)
 
 	// Make sure there are no big chunks to start with.
-	bigChunks, err := storage.ListChunks(context.Background(), 0, 0, pb_almanac.ChunkId_BIG)
+	bigChunks, err := storage.ListChunks(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_BIG)
 	assert.NoError(t, err)
 	assert.Empty(t, bigChunks)
 
-	_, err = New(context.Background(), logrus.New(), storage, compactionInterval, bigChunkMaxSpread)
+	policy, err := NewTieredPolicy([]Level{{Spread: bigChunkMaxSpread}}, settleDelay)
+	assert.NoError(t, err)
+
+	_, err = New(context.Background(), logrus.New(), storage, compactionInterval, policy, numWorkers, maxCompactionBytes, 0, false)
 	assert.NoError(t, err)
 
 	// Give the janitor enough time to compact.
 	time.Sleep(4 * compactionInterval)
 
 	// Check that we have big chunks now.
-	bigChunks, err = storage.ListChunks(context.Background(), 0, 0, pb_almanac.ChunkId_BIG)
+	bigChunks, err = storage.ListChunks(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_BIG)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(bigChunks))
 
@@ -56,6 +68,149 @@ func TestCompaction(t *testing.T) {
 	assert.Equal(t, int64(4), bigChunk.Id().EndMs)
 }
 
+func TestCompactionSplitsIntoBatches(t *testing.T) {
+	storage := createStorage(t)
+
+	policy, err := NewTieredPolicy([]Level{{Spread: bigChunkMaxSpread}}, settleDelay)
+	assert.NoError(t, err)
+
+	smallChunks, err := storage.ListChunkInfos(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+
+	// A budget of a single byte forces every chunk into a batch of its own.
+	j := &Janitor{ctx: context.Background(), logger: logrus.New(), storage: storage, policy: policy, numWorkers: numWorkers, maxCompactionBytes: 1}
+	err = j.executeCompaction()
+	assert.NoError(t, err)
+
+	bigChunks, err := storage.ListChunks(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_BIG)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(bigChunks))
+
+	remaining, err := storage.ListChunkInfos(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	assert.Equal(t, len(smallChunks)-2, len(remaining))
+}
+
+func TestCompactionBudgetsDecodedSize(t *testing.T) {
+	storage, err := st.NewMemoryStorage()
+	assert.NoError(t, err)
+
+	// The entries compress well, so both chunks together take up far less than the budget in
+	// storage, but not once decoded.
+	message := strings.Repeat(strings.Repeat("a", 1000)+" ", 100)
+	for i := int64(1); i <= 2; i++ {
+		entry := &pb_almanac.LogEntry{Id: fmt.Sprintf("id%d", i), TimestampMs: i, EntryJson: fmt.Sprintf(`{"message": %q}`, message)}
+		chunk, err := st.ChunkProto([]*pb_almanac.LogEntry{entry}, pb_almanac.ChunkId_SMALL)
+		assert.NoError(t, err)
+		_, err = storage.StoreChunk(context.Background(), chunk)
+		assert.NoError(t, err)
+	}
+	smallChunks, err := storage.ListChunkInfos(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	maxBytes := int64(150 * 1024)
+	assert.True(t, smallChunks[0].SizeBytes+smallChunks[1].SizeBytes < maxBytes)
+
+	policy, err := NewTieredPolicy([]Level{{Spread: bigChunkMaxSpread}}, settleDelay)
+	assert.NoError(t, err)
+	j := &Janitor{ctx: context.Background(), logger: logrus.New(), storage: storage, policy: policy, numWorkers: numWorkers, maxCompactionBytes: maxBytes}
+	assert.NoError(t, j.executeCompaction())
+
+	bigChunks, err := storage.ListChunks(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_BIG)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(bigChunks))
+}
+
+func TestCompactionSkipsOversizedBigChunks(t *testing.T) {
+	storage, err := st.NewMemoryStorage()
+	assert.NoError(t, err)
+
+	// An undersized big chunk sits in the same window as two small chunks, but is too big to be
+	// merged with them.
+	message := strings.Repeat("hello ", 2*1024)
+	big, err := st.ChunkProto([]*pb_almanac.LogEntry{{Id: "big", TimestampMs: 1, EntryJson: fmt.Sprintf(`{"message": %q}`, message)}}, pb_almanac.ChunkId_BIG)
+	assert.NoError(t, err)
+	bigId, err := storage.StoreChunk(context.Background(), big)
+	assert.NoError(t, err)
+	for _, entries := range [][]*pb_almanac.LogEntry{{entry2}, {entry3, entry4}} {
+		chunk, err := st.ChunkProto(entries, pb_almanac.ChunkId_SMALL)
+		assert.NoError(t, err)
+		_, err = storage.StoreChunk(context.Background(), chunk)
+		assert.NoError(t, err)
+	}
+
+	policy, err := NewTieredPolicy([]Level{{Spread: bigChunkMaxSpread, MinBytes: 1024 * 1024}}, settleDelay)
+	assert.NoError(t, err)
+	j := &Janitor{ctx: context.Background(), logger: logrus.New(), storage: storage, policy: policy, numWorkers: numWorkers, maxCompactionBytes: 1024}
+	assert.NoError(t, j.executeCompaction())
+
+	// The small chunks make up a new big chunk, and the oversized one is left alone.
+	bigChunks, err := storage.ListChunkInfos(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_BIG)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(bigChunks))
+	smallChunks, err := storage.ListChunks(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	assert.Empty(t, smallChunks)
+	assert.Equal(t, map[string]int64{bigId: 1024}, j.oversizedChunks)
+
+	// Later compactions leave it out, so the new big chunk is not selected either.
+	groups, err := j.policy.Select(time.Now(), bigChunks)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(groups))
+	assert.NoError(t, j.executeCompaction())
+	assert.Equal(t, map[string]int64{bigId: 1024}, j.oversizedChunks)
+	after, err := storage.ListChunks(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_BIG)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(after))
+
+	// A larger budget makes it eligible again.
+	j.maxCompactionBytes = maxCompactionBytes
+	assert.NoError(t, j.executeCompaction())
+	assert.Empty(t, j.oversizedChunks)
+	after, err = storage.ListChunks(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_BIG)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(after))
+}
+
+func TestCompactionCancelled(t *testing.T) {
+	storage := createStorage(t)
+
+	policy, err := NewTieredPolicy([]Level{{Spread: bigChunkMaxSpread}}, settleDelay)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	j := &Janitor{ctx: ctx, logger: logrus.New(), storage: storage, policy: policy, numWorkers: numWorkers, maxCompactionBytes: maxCompactionBytes}
+	err = j.executeCompaction()
+	assert.Error(t, err)
+
+	bigChunks, err := storage.ListChunks(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_BIG)
+	assert.NoError(t, err)
+	assert.Empty(t, bigChunks)
+}
+
+func TestStop(t *testing.T) {
+	storage := createStorage(t)
+
+	policy, err := NewTieredPolicy([]Level{{Spread: bigChunkMaxSpread}}, settleDelay)
+	assert.NoError(t, err)
+
+	j, err := New(context.Background(), logrus.New(), storage, compactionInterval, policy, numWorkers, maxCompactionBytes, 0, false)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, j.Stop(ctx))
+
+	// Stopping is idempotent, and no compactions happen once stopped.
+	assert.NoError(t, j.Stop(ctx))
+	time.Sleep(2 * compactionInterval)
+
+	bigChunks, err := storage.ListChunks(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_BIG)
+	assert.NoError(t, err)
+	assert.Empty(t, bigChunks)
+}
+
 func createStorage(t *testing.T) *st.Storage {
 	storage, err := st.NewMemoryStorage()
 	assert.NoError(t, err)
@@ -77,3 +232,121 @@ func createStorage(t *testing.T) *st.Storage {
 
 	return storage
 }
+
+func TestCompactionQuarantinesCorruptChunks(t *testing.T) {
+	path, err := ioutil.TempDir("", "almanac-janitor-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(path)
+
+	storage, err := st.NewDiskStorage(path)
+	assert.NoError(t, err)
+
+	chunk1, err := st.ChunkProto([]*pb_almanac.LogEntry{entry1, entry2}, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	_, err = storage.StoreChunk(context.Background(), chunk1)
+	assert.NoError(t, err)
+
+	chunk2, err := st.ChunkProto([]*pb_almanac.LogEntry{entry3, entry4}, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	corruptId, err := storage.StoreChunk(context.Background(), chunk2)
+	assert.NoError(t, err)
+
+	// Truncate the second chunk on disk, as if its write had been interrupted.
+	filename := filepath.Join(path, "chunk-"+corruptId)
+	contents, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filename, contents[:len(contents)/2], 0644))
+
+	policy, err := NewTieredPolicy([]Level{{Spread: bigChunkMaxSpread}}, settleDelay)
+	assert.NoError(t, err)
+
+	j := &Janitor{ctx: context.Background(), logger: logrus.New(), storage: storage, policy: policy, numWorkers: numWorkers, maxCompactionBytes: maxCompactionBytes}
+	assert.NoError(t, j.executeCompaction())
+
+	bigChunks, err := storage.ListChunkInfos(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_BIG)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(bigChunks))
+	assert.Equal(t, int64(1), bigChunks[0].Id.StartMs)
+	assert.Equal(t, int64(2), bigChunks[0].Id.EndMs)
+
+	quarantined, err := storage.ListQuarantinedChunks(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{corruptId}, quarantined)
+}
+
+func TestCompactionKeepsStreamsApart(t *testing.T) {
+	storage, err := st.NewMemoryStorage()
+	assert.NoError(t, err)
+
+	web := map[string]string{"service": "web"}
+	db := map[string]string{"service": "db"}
+	for _, c := range []struct {
+		entries []*pb_almanac.LogEntry
+		labels  map[string]string
+	}{
+		{[]*pb_almanac.LogEntry{entry1, entry2}, web},
+		{[]*pb_almanac.LogEntry{entry3, entry4}, db},
+	} {
+		chunk, err := st.ChunkProto(c.entries, pb_almanac.ChunkId_SMALL)
+		assert.NoError(t, err)
+		chunk.Id.Labels = c.labels
+		_, err = storage.StoreChunk(context.Background(), chunk)
+		assert.NoError(t, err)
+	}
+
+	policy, err := NewTieredPolicy([]Level{{Spread: bigChunkMaxSpread}}, settleDelay)
+	assert.NoError(t, err)
+
+	j := &Janitor{ctx: context.Background(), logger: logrus.New(), storage: storage, policy: policy, numWorkers: numWorkers, maxCompactionBytes: maxCompactionBytes}
+	assert.NoError(t, j.executeCompaction())
+
+	bigChunks, err := storage.ListChunkInfos(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_BIG)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(bigChunks))
+	for _, c := range bigChunks {
+		chunk, err := storage.LoadChunk(context.Background(), c.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, c.Id, chunk.Id())
+		assert.Equal(t, 2, len(chunk.Entries()))
+		if c.Id.Labels["service"] == "web" {
+			assert.Equal(t, "id1", chunk.Entries()[0].Id)
+		} else {
+			assert.Equal(t, db, c.Id.Labels)
+			assert.Equal(t, "id3", chunk.Entries()[0].Id)
+		}
+		assert.NoError(t, chunk.Close())
+	}
+}
+
+func TestCompactionKeepsTenantsApart(t *testing.T) {
+	storage, err := st.NewMemoryStorage()
+	assert.NoError(t, err)
+
+	for _, tenantId := range []string{"acme", "other"} {
+		for _, entries := range [][]*pb_almanac.LogEntry{{entry1, entry2}, {entry3, entry4}} {
+			chunk, err := st.ChunkProto(entries, pb_almanac.ChunkId_SMALL)
+			assert.NoError(t, err)
+			chunk.Id.Tenant = tenantId
+			_, err = storage.StoreChunk(context.Background(), chunk)
+			assert.NoError(t, err)
+		}
+	}
+
+	policy, err := NewTieredPolicy([]Level{{Spread: bigChunkMaxSpread}}, settleDelay)
+	assert.NoError(t, err)
+
+	j := &Janitor{ctx: context.Background(), logger: logrus.New(), storage: storage, policy: policy, numWorkers: numWorkers, maxCompactionBytes: maxCompactionBytes}
+	assert.NoError(t, j.executeCompaction())
+
+	for _, tenantId := range []string{"acme", "other"} {
+		bigChunks, err := storage.ListChunkInfos(context.Background(), tenantId, 0, 0, pb_almanac.ChunkId_BIG)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(bigChunks))
+		assert.Equal(t, tenantId, bigChunks[0].Id.Tenant)
+
+		chunk, err := storage.LoadChunk(context.Background(), bigChunks[0].Id)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, len(chunk.Entries()))
+		assert.NoError(t, chunk.Close())
+	}
+}
@@ -0,0 +1,177 @@
+package janitor
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	st "github.com/dinowernli/almanac/pkg/storage"
+	"github.com/dinowernli/almanac/pkg/util"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+)
+
+// Policy decides which of the chunks currently in storage the janitor should merge into new
+// big chunks.
+type Policy interface {
+	// Select returns groups of chunk ids, where the chunks in each group are to be merged into
+	// a single new big chunk. A chunk appears in at most one group. Returns an empty slice if
+	// there is nothing to compact.
+	Select(now time.Time, chunks []*st.ChunkInfo) ([][]*pb_almanac.ChunkId, error)
+}
+
+// Level describes one tier of big chunks produced by a tiered policy.
+type Level struct {
+	// Spread is the maximum spread of a big chunk produced on this level. Big chunks on this
+	// level are aligned to multiples of the spread since the epoch, e.g., to full hours.
+	Spread time.Duration
+
+	// MinBytes is the size below which a big chunk is considered undersized. Undersized
+	// big chunks are merged with their neighbours on the same level.
+	MinBytes int64
+}
+
+// tieredPolicy merges small chunks into big chunks, and then merges big chunks into
+// progressively bigger ones according to a list of levels, e.g., hourly and then daily.
+type tieredPolicy struct {
+	levels      []Level
+	settleDelay time.Duration
+}
+
+// NewTieredPolicy returns a policy which compacts chunks according to the supplied levels.
+// The levels must be ordered by strictly increasing spread. Chunks are only compacted once
+// they have ended at least settleDelay in the past.
+func NewTieredPolicy(levels []Level, settleDelay time.Duration) (Policy, error) {
+	if len(levels) == 0 {
+		return nil, fmt.Errorf("must supply at least one level")
+	}
+	for i, l := range levels {
+		if l.Spread <= 0 {
+			return nil, fmt.Errorf("spread of level %d must be positive, but got: %v", i, l.Spread)
+		}
+		if l.MinBytes < 0 {
+			return nil, fmt.Errorf("min bytes of level %d must be non-negative, but got: %d", i, l.MinBytes)
+		}
+		if i > 0 && l.Spread <= levels[i-1].Spread {
+			return nil, fmt.Errorf("spread of level %d must be larger than %v, but got: %v", i, levels[i-1].Spread, l.Spread)
+		}
+	}
+	if settleDelay < 0 {
+		return nil, fmt.Errorf("settle delay must be non-negative, but got: %v", settleDelay)
+	}
+	return &tieredPolicy{levels: levels, settleDelay: settleDelay}, nil
+}
+
+func (p *tieredPolicy) Select(now time.Time, chunks []*st.ChunkInfo) ([][]*pb_almanac.ChunkId, error) {
+	// Only consider chunks that have ended sufficiently far in the past in order to avoid
+	// compacting periods of time that are still actively being written to.
+	settled := []*st.ChunkInfo{}
+	for _, c := range chunks {
+		if c.Id.Type != pb_almanac.ChunkId_SMALL && c.Id.Type != pb_almanac.ChunkId_BIG {
+			return nil, fmt.Errorf("unexpected chunk type: %v", c.Id.Type)
+		}
+		if util.TimeMs(c.Id.EndMs).After(now.Add(-p.settleDelay)) {
+			continue
+		}
+		settled = append(settled, c)
+	}
+
+	// The storage makes no guarantees about the order of the listed chunks.
+	sort.Slice(settled, func(i, j int) bool {
+		return settled[i].Id.StartMs < settled[j].Id.StartMs
+	})
+
+	// Lower levels take precedence, so that big chunks are always built bottom-up. Chunks
+	// selected on a lower level are not considered again on higher levels.
+	result := [][]*pb_almanac.ChunkId{}
+	used := map[*st.ChunkInfo]struct{}{}
+	for i := range p.levels {
+		candidates := []*st.ChunkInfo{}
+		for _, c := range settled {
+			if _, ok := used[c]; ok {
+				continue
+			}
+			if p.isCandidate(i, c) {
+				candidates = append(candidates, c)
+			}
+		}
+
+		for _, group := range p.selectForLevel(i, now, candidates) {
+			ids := []*pb_almanac.ChunkId{}
+			for _, c := range group {
+				used[c] = struct{}{}
+				ids = append(ids, c.Id)
+			}
+			result = append(result, ids)
+		}
+	}
+	return result, nil
+}
+
+// selectForLevel groups the supplied candidates, which must be sorted by start time, into the
+// aligned windows of the supplied level. Returns the groups worth compacting.
+func (p *tieredPolicy) selectForLevel(level int, now time.Time, candidates []*st.ChunkInfo) [][]*st.ChunkInfo {
+	spreadMs := int64(p.levels[level].Spread / time.Millisecond)
+
+	// Group the candidates by the window they start in. Chunks which don't fit entirely
+	// inside the window they start in are left for a higher level.
+	windows := map[int64][]*st.ChunkInfo{}
+	windowStarts := []int64{}
+	for _, c := range candidates {
+		windowStart := c.Id.StartMs - mod(c.Id.StartMs, spreadMs)
+		if c.Id.EndMs >= windowStart+spreadMs {
+			continue
+		}
+		if _, ok := windows[windowStart]; !ok {
+			windowStarts = append(windowStarts, windowStart)
+		}
+		windows[windowStart] = append(windows[windowStart], c)
+	}
+
+	result := [][]*st.ChunkInfo{}
+	for _, windowStart := range windowStarts {
+		// Small chunks are compacted as soon as they have settled, but promoting big chunks
+		// only makes sense once their window has been filled.
+		windowEnd := util.TimeMs(windowStart + spreadMs)
+		if level > 0 && windowEnd.After(now.Add(-p.settleDelay)) {
+			continue
+		}
+
+		group := windows[windowStart]
+		containsSmall := false
+		for _, c := range group {
+			if c.Id.Type == pb_almanac.ChunkId_SMALL {
+				containsSmall = true
+			}
+		}
+
+		// Rewriting a single big chunk on its own is pointless.
+		if containsSmall || len(group) > 1 {
+			result = append(result, group)
+		}
+	}
+	return result
+}
+
+// isCandidate returns whether the supplied chunk should be merged into a big chunk on the
+// supplied level.
+func (p *tieredPolicy) isCandidate(level int, chunk *st.ChunkInfo) bool {
+	if chunk.Id.Type == pb_almanac.ChunkId_SMALL {
+		return true
+	}
+
+	chunkSpread := util.TimeMs(chunk.Id.EndMs).Sub(util.TimeMs(chunk.Id.StartMs))
+
+	// Big chunks produced by the level below are always promoted.
+	if level > 0 && chunkSpread <= p.levels[level-1].Spread {
+		return true
+	}
+
+	// Big chunks which belong on this level are only merged with their neighbours if they
+	// are undersized, e.g., because of late arrivals.
+	return chunkSpread <= p.levels[level].Spread && chunk.SizeBytes < p.levels[level].MinBytes
+}
+
+// mod returns the non-negative remainder of dividing a by b.
+func mod(a int64, b int64) int64 {
+	return ((a % b) + b) % b
+}
@@ -0,0 +1,154 @@
+package janitor
+
+import (
+	"testing"
+	"time"
+
+	st "github.com/dinowernli/almanac/pkg/storage"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testLevels = []Level{
+		{Spread: time.Hour, MinBytes: 100},
+		{Spread: 24 * time.Hour, MinBytes: 1000},
+	}
+	testSettleDelay = 5 * time.Minute
+	testNow         = time.Date(2018, 1, 10, 0, 0, 0, 0, time.UTC)
+	testDay         = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func TestTieredPolicyValidation(t *testing.T) {
+	_, err := NewTieredPolicy([]Level{}, testSettleDelay)
+	assert.Error(t, err)
+
+	_, err = NewTieredPolicy([]Level{{Spread: 0}}, testSettleDelay)
+	assert.Error(t, err)
+
+	_, err = NewTieredPolicy([]Level{{Spread: time.Hour, MinBytes: -1}}, testSettleDelay)
+	assert.Error(t, err)
+
+	_, err = NewTieredPolicy([]Level{{Spread: time.Hour}, {Spread: time.Hour}}, testSettleDelay)
+	assert.Error(t, err)
+
+	_, err = NewTieredPolicy(testLevels, -time.Minute)
+	assert.Error(t, err)
+
+	_, err = NewTieredPolicy(testLevels, testSettleDelay)
+	assert.NoError(t, err)
+}
+
+func TestTieredPolicyMergesSmallChunks(t *testing.T) {
+	policy, err := NewTieredPolicy(testLevels, testSettleDelay)
+	assert.NoError(t, err)
+
+	chunks := []*st.ChunkInfo{
+		chunkInfo(pb_almanac.ChunkId_SMALL, 10*time.Minute, 20*time.Minute, 10),
+		chunkInfo(pb_almanac.ChunkId_SMALL, 0, 5*time.Minute, 10),
+		chunkInfo(pb_almanac.ChunkId_SMALL, 30*time.Minute, 2*time.Hour, 10),
+		chunkInfo(pb_almanac.ChunkId_SMALL, 3*time.Hour, 4*time.Hour, 10),
+	}
+	selected, err := policy.Select(testNow, chunks)
+	assert.NoError(t, err)
+
+	// The last two chunks cross an hour boundary, so they end up in a daily chunk.
+	assert.Equal(t, [][]*pb_almanac.ChunkId{
+		{chunks[1].Id, chunks[0].Id},
+		{chunks[2].Id, chunks[3].Id},
+	}, selected)
+}
+
+func TestTieredPolicyAlignsToWindows(t *testing.T) {
+	policy, err := NewTieredPolicy(testLevels, testSettleDelay)
+	assert.NoError(t, err)
+
+	chunks := []*st.ChunkInfo{
+		chunkInfo(pb_almanac.ChunkId_SMALL, 75*time.Minute, 85*time.Minute, 10),
+		chunkInfo(pb_almanac.ChunkId_SMALL, 50*time.Minute, 55*time.Minute, 10),
+		chunkInfo(pb_almanac.ChunkId_SMALL, 65*time.Minute, 70*time.Minute, 10),
+		chunkInfo(pb_almanac.ChunkId_SMALL, 26*time.Hour, 27*time.Hour-time.Millisecond, 10),
+	}
+	selected, err := policy.Select(testNow, chunks)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]*pb_almanac.ChunkId{
+		{chunks[1].Id},
+		{chunks[2].Id, chunks[0].Id},
+		{chunks[3].Id},
+	}, selected)
+}
+
+func TestTieredPolicyPromotesBigChunks(t *testing.T) {
+	policy, err := NewTieredPolicy(testLevels, testSettleDelay)
+	assert.NoError(t, err)
+
+	chunks := []*st.ChunkInfo{
+		chunkInfo(pb_almanac.ChunkId_BIG, 0, time.Hour, 500),
+		chunkInfo(pb_almanac.ChunkId_BIG, 2*time.Hour, 3*time.Hour, 500),
+		chunkInfo(pb_almanac.ChunkId_BIG, 25*time.Hour, 26*time.Hour, 500),
+	}
+	selected, err := policy.Select(testNow, chunks)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]*pb_almanac.ChunkId{{chunks[0].Id, chunks[1].Id}}, selected)
+}
+
+func TestTieredPolicyPromotesOnlyCompleteWindows(t *testing.T) {
+	policy, err := NewTieredPolicy(testLevels, testSettleDelay)
+	assert.NoError(t, err)
+
+	// The day of these chunks is still in progress.
+	today := testNow.Sub(testDay) - 24*time.Hour + 2*time.Hour
+	chunks := []*st.ChunkInfo{
+		chunkInfo(pb_almanac.ChunkId_BIG, today-2*time.Hour, today-time.Hour-time.Millisecond, 500),
+		chunkInfo(pb_almanac.ChunkId_BIG, today-time.Hour, today-time.Millisecond, 500),
+	}
+	selected, err := policy.Select(testNow, chunks)
+	assert.NoError(t, err)
+	assert.Empty(t, selected)
+}
+
+func TestTieredPolicyMergesUndersizedBigChunks(t *testing.T) {
+	policy, err := NewTieredPolicy(testLevels, testSettleDelay)
+	assert.NoError(t, err)
+
+	// Two daily chunks, one of which is undersized, and a late arrival on the next day.
+	chunks := []*st.ChunkInfo{
+		chunkInfo(pb_almanac.ChunkId_BIG, 0, 20*time.Hour, 5000),
+		chunkInfo(pb_almanac.ChunkId_BIG, 24*time.Hour, 40*time.Hour, 50),
+		chunkInfo(pb_almanac.ChunkId_BIG, 42*time.Hour, 45*time.Hour, 50),
+	}
+	selected, err := policy.Select(testNow, chunks)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]*pb_almanac.ChunkId{{chunks[1].Id, chunks[2].Id}}, selected)
+
+	// Big chunks of the right size are left alone.
+	selected, err = policy.Select(testNow, chunks[:1])
+	assert.NoError(t, err)
+	assert.Empty(t, selected)
+}
+
+func TestTieredPolicySkipsRecentChunks(t *testing.T) {
+	policy, err := NewTieredPolicy(testLevels, testSettleDelay)
+	assert.NoError(t, err)
+
+	recent := testNow.Sub(testDay) - testSettleDelay
+	chunks := []*st.ChunkInfo{
+		chunkInfo(pb_almanac.ChunkId_SMALL, recent-time.Minute, recent+time.Millisecond, 10),
+		chunkInfo(pb_almanac.ChunkId_SMALL, recent+time.Minute, recent+2*time.Minute, 10),
+	}
+	selected, err := policy.Select(testNow, chunks)
+	assert.NoError(t, err)
+	assert.Empty(t, selected)
+}
+
+func chunkInfo(chunkType pb_almanac.ChunkId_Type, start time.Duration, end time.Duration, sizeBytes int64) *st.ChunkInfo {
+	return &st.ChunkInfo{
+		Id: &pb_almanac.ChunkId{
+			Type:    chunkType,
+			StartMs: testDay.Add(start).UnixNano() / int64(time.Millisecond),
+			EndMs:   testDay.Add(end).UnixNano() / int64(time.Millisecond),
+		},
+		SizeBytes: sizeBytes,
+	}
+}
@@ -0,0 +1,173 @@
+package janitor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	st "github.com/dinowernli/almanac/pkg/storage"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"golang.org/x/net/context"
+)
+
+// executePurge physically removes the entries matched by tombstones from all stored chunks,
+// recording an audit record for every chunk it rewrites.
+func (j *Janitor) executePurge() error {
+	ctx, cancel := context.WithCancel(j.ctx)
+	defer cancel()
+	start := time.Now()
+
+	tombstones, err := j.storage.ListTombstones(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to list tombstones during purge: %v", err)
+	}
+	if len(tombstones) == 0 {
+		// Nothing to purge.
+		return nil
+	}
+
+	allChunks, err := listChunkInfos(ctx, j.storage)
+	if err != nil {
+		return fmt.Errorf("unable to list chunks during purge: %v", err)
+	}
+
+	// Chunks are immutable, so a chunk only ever needs to be checked once against a tombstone.
+	retained := map[string]struct{}{}
+	chunkIds := []*pb_almanac.ChunkId{}
+	chunkTombstones := [][]*pb_almanac.Tombstone{}
+	for _, c := range allChunks {
+		id, err := st.ChunkId(c.Id)
+		if err != nil {
+			return fmt.Errorf("unable to compute chunk id: %v", err)
+		}
+
+		relevant := []*pb_almanac.Tombstone{}
+		for _, t := range tombstones {
+			// Tombstones only ever apply to the entries of their own tenant.
+			if t.Tenant != c.Id.Tenant || !st.TombstoneOverlaps(t, c.Id.StartMs, c.Id.EndMs) {
+				continue
+			}
+			key := purgeKey(t, id)
+			if _, ok := j.purgedChunks[key]; ok {
+				retained[key] = struct{}{}
+			} else {
+				relevant = append(relevant, t)
+			}
+		}
+		if len(relevant) > 0 {
+			chunkIds = append(chunkIds, c.Id)
+			chunkTombstones = append(chunkTombstones, relevant)
+		}
+	}
+
+	// Forget about chunks which no longer exist.
+	j.purgedChunks = retained
+	if len(chunkIds) == 0 {
+		return nil
+	}
+	j.logger.Infof("Checking %d chunk(s) against %d tombstone(s)", len(chunkIds), len(tombstones))
+
+	var mutex sync.Mutex
+	rewritten := 0
+	err = forEachChunk(ctx, j.numWorkers, chunkIds, func(ctx context.Context, i int, c *pb_almanac.ChunkId) error {
+		changed, err := j.purgeChunk(ctx, c, chunkTombstones[i])
+		if err != nil {
+			return fmt.Errorf("unable to purge chunk %v: %v", c, err)
+		}
+		if changed {
+			mutex.Lock()
+			rewritten++
+			mutex.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Only remember the chunks once they have all been dealt with successfully.
+	for i, c := range chunkIds {
+		id, err := st.ChunkId(c)
+		if err != nil {
+			return fmt.Errorf("unable to compute chunk id: %v", err)
+		}
+		for _, t := range chunkTombstones[i] {
+			j.purgedChunks[purgeKey(t, id)] = struct{}{}
+		}
+	}
+
+	j.logger.Infof("Purge successful, rewrote %d chunk(s), took %v", rewritten, time.Since(start))
+	return nil
+}
+
+// purgeChunk applies the supplied tombstones to a single chunk. If any entries are affected, the
+// chunk is replaced by a new chunk without them. Returns whether the chunk was replaced.
+func (j *Janitor) purgeChunk(ctx context.Context, chunkId *pb_almanac.ChunkId, tombstones []*pb_almanac.Tombstone) (bool, error) {
+	chunk, err := j.storage.LoadChunk(ctx, chunkId)
+	if corruptErr := st.IsCorruptChunk(err); corruptErr != nil {
+		return false, j.quarantineChunk(ctx, corruptErr)
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to load chunk: %v", err)
+	}
+	err = chunk.Close()
+	if err != nil {
+		return false, fmt.Errorf("unable to close chunk: %v", err)
+	}
+
+	kept, affected, err := st.ApplyTombstones(ctx, chunk.Entries(), tombstones)
+	if err != nil {
+		return false, fmt.Errorf("unable to apply tombstones: %v", err)
+	}
+	if len(affected) == 0 {
+		return false, nil
+	}
+
+	// Store the replacement before deleting anything so that we never lose entries which
+	// were not purged.
+	var newChunkId *pb_almanac.ChunkId
+	if len(kept) > 0 {
+		newChunk, err := st.ChunkProto(kept, chunkId.Type)
+		if err != nil {
+			return false, fmt.Errorf("unable to create replacement chunk: %v", err)
+		}
+		newChunk.Id.Labels = chunkId.Labels
+		newChunk.Id.Tenant = chunkId.Tenant
+		_, err = j.storage.StoreChunk(ctx, newChunk)
+		if err != nil {
+			return false, fmt.Errorf("unable to store replacement chunk: %v", err)
+		}
+		newChunkId = newChunk.Id
+	}
+
+	nowMs := time.Now().UnixNano() / int64(time.Millisecond)
+	for _, t := range tombstones {
+		entryIds, ok := affected[t.Id]
+		if !ok {
+			continue
+		}
+		err := j.storage.StoreAuditRecord(ctx, &pb_almanac.AuditRecord{
+			TombstoneId: t.Id,
+			OldChunkId:  chunkId,
+			NewChunkId:  newChunkId,
+			EntryIds:    entryIds,
+			Action:      t.Action,
+			TimestampMs: nowMs,
+		})
+		if err != nil {
+			return false, fmt.Errorf("unable to store audit record: %v", err)
+		}
+	}
+
+	err = j.storage.DeleteChunk(ctx, chunkId)
+	if err != nil {
+		return false, fmt.Errorf("unable to delete purged chunk: %v", err)
+	}
+	j.logger.Infof("Purged chunk %v on behalf of %d tombstone(s)", chunkId, len(affected))
+	return true, nil
+}
+
+func purgeKey(tombstone *pb_almanac.Tombstone, chunkId string) string {
+	return tombstone.Id + "/" + chunkId
+}
@@ -0,0 +1,83 @@
+package janitor
+
+import (
+	"testing"
+
+	st "github.com/dinowernli/almanac/pkg/storage"
+	"github.com/dinowernli/almanac/pkg/tenant"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestPurge(t *testing.T) {
+	storage, err := st.NewMemoryStorage()
+	assert.NoError(t, err)
+
+	alice := &pb_almanac.LogEntry{Id: "id1", TimestampMs: 1, EntryJson: `{"user":"alice"}`}
+	bob := &pb_almanac.LogEntry{Id: "id2", TimestampMs: 2, EntryJson: `{"user":"bob"}`}
+	chunk, err := st.ChunkProto([]*pb_almanac.LogEntry{alice, bob}, pb_almanac.ChunkId_BIG)
+	assert.NoError(t, err)
+	_, err = storage.StoreChunk(context.Background(), chunk)
+	assert.NoError(t, err)
+
+	tombstone := &pb_almanac.Tombstone{Id: "t1", Query: "alice", Action: pb_almanac.PurgeAction_DROP}
+	assert.NoError(t, storage.StoreTombstone(context.Background(), tombstone))
+
+	j := &Janitor{ctx: context.Background(), logger: logrus.New(), storage: storage, numWorkers: numWorkers}
+	assert.NoError(t, j.executePurge())
+
+	// The old chunk must have been replaced by one without the purged entry.
+	chunks, err := storage.ListChunkInfos(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_BIG)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(chunks))
+	assert.NotEqual(t, chunk.Id.Uid, chunks[0].Id.Uid)
+
+	newChunk, err := storage.LoadChunk(context.Background(), chunks[0].Id)
+	assert.NoError(t, err)
+	defer newChunk.Close()
+	assert.Equal(t, []*pb_almanac.LogEntry{bob}, newChunk.Entries())
+
+	records, err := storage.ListAuditRecords(context.Background(), "t1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(records))
+	assert.Equal(t, []string{"id1"}, records[0].EntryIds)
+	assert.Equal(t, chunk.Id, records[0].OldChunkId)
+	assert.Equal(t, chunks[0].Id, records[0].NewChunkId)
+
+	// Running again must not touch the chunk.
+	assert.NoError(t, j.executePurge())
+	again, err := storage.ListChunkInfos(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_BIG)
+	assert.NoError(t, err)
+	assert.Equal(t, chunks[0].Id, again[0].Id)
+}
+
+func TestPurgeOnlyAffectsOwnTenant(t *testing.T) {
+	storage, err := st.NewMemoryStorage()
+	assert.NoError(t, err)
+
+	alice := &pb_almanac.LogEntry{Id: "id1", TimestampMs: 1, EntryJson: `{"user":"alice"}`}
+	for _, tenantId := range []string{"acme", "other"} {
+		chunk, err := st.ChunkProto([]*pb_almanac.LogEntry{alice}, pb_almanac.ChunkId_BIG)
+		assert.NoError(t, err)
+		chunk.Id.Tenant = tenantId
+		_, err = storage.StoreChunk(context.Background(), chunk)
+		assert.NoError(t, err)
+	}
+
+	tombstone := &pb_almanac.Tombstone{Id: "t1", Query: "alice", Action: pb_almanac.PurgeAction_DROP, Tenant: "acme"}
+	assert.NoError(t, storage.StoreTombstone(context.Background(), tombstone))
+
+	j := &Janitor{ctx: context.Background(), logger: logrus.New(), storage: storage, numWorkers: numWorkers}
+	assert.NoError(t, j.executePurge())
+
+	purged, err := storage.ListChunkInfos(context.Background(), "acme", 0, 0, pb_almanac.ChunkId_BIG)
+	assert.NoError(t, err)
+	assert.Empty(t, purged)
+
+	kept, err := storage.ListChunkInfos(context.Background(), "other", 0, 0, pb_almanac.ChunkId_BIG)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(kept))
+}
@@ -0,0 +1,163 @@
+package janitor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dinowernli/almanac/pkg/util"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+const (
+	tenantLabel = "tenant"
+)
+
+var (
+	// All janitors in a process share the same metrics.
+	quotaMetricsOnce      sync.Once
+	quotaMetricsInstance  *quotaMetrics
+	quotaMetricsCreateErr error
+)
+
+// Quotas bounds how many bytes of chunks each tenant may store per day. Tenants not listed in
+// TenantDailyBytes get DefaultDailyBytes. Zero means unlimited.
+type Quotas struct {
+	DefaultDailyBytes int64
+	TenantDailyBytes  map[string]int64
+}
+
+// Validate returns an error if any of the quotas is negative.
+func (q Quotas) Validate() error {
+	if q.DefaultDailyBytes < 0 {
+		return fmt.Errorf("default daily bytes must not be negative, but got %d", q.DefaultDailyBytes)
+	}
+	for tenant, bytes := range q.TenantDailyBytes {
+		if bytes < 0 {
+			return fmt.Errorf("daily bytes of tenant [%s] must not be negative, but got %d", tenant, bytes)
+		}
+	}
+	return nil
+}
+
+// dailyBytes returns the quota of the supplied tenant.
+func (q Quotas) dailyBytes(tenant string) int64 {
+	if bytes, ok := q.TenantDailyBytes[tenant]; ok {
+		return bytes
+	}
+	return q.DefaultDailyBytes
+}
+
+// SetQuotas replaces the daily storage quotas of all tenants, taking effect from the next check.
+func (j *Janitor) SetQuotas(quotas Quotas) error {
+	err := quotas.Validate()
+	if err != nil {
+		return err
+	}
+
+	j.settingsMutex.Lock()
+	defer j.settingsMutex.Unlock()
+	j.quotas = quotas
+	return nil
+}
+
+// executeQuotaCheck compares how much each tenant has stored today against its quota.
+func (j *Janitor) executeQuotaCheck() error {
+	ctx, cancel := context.WithCancel(j.ctx)
+	defer cancel()
+
+	_, err := j.checkQuotas(ctx, time.Now())
+	return err
+}
+
+// checkQuotas returns the bytes stored by each tenant in chunks overlapping the UTC day of the
+// supplied time, exporting them as metrics and warning about every tenant over its quota. Big
+// chunks can span more than a day, in which case they count towards every day they overlap.
+func (j *Janitor) checkQuotas(ctx context.Context, now time.Time) (map[string]int64, error) {
+	j.settingsMutex.Lock()
+	quotas := j.quotas
+	j.settingsMutex.Unlock()
+
+	infos, err := listChunkInfos(ctx, j.storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list chunks during quota check: %v", err)
+	}
+
+	dayStart := now.UTC().Truncate(24 * time.Hour)
+	dayStartMs := dayStart.UnixNano() / int64(time.Millisecond)
+	dayEndMs := dayStart.Add(24*time.Hour).UnixNano()/int64(time.Millisecond) - 1
+
+	result := map[string]int64{}
+	for _, info := range infos {
+		if _, ok := result[info.Id.Tenant]; !ok {
+			result[info.Id.Tenant] = 0
+		}
+		if info.Id.EndMs >= dayStartMs && info.Id.StartMs <= dayEndMs {
+			result[info.Id.Tenant] += info.SizeBytes
+		}
+	}
+
+	for tenant, bytes := range result {
+		quota := quotas.dailyBytes(tenant)
+		labels := prometheus.Labels{tenantLabel: tenant}
+		j.quotaMetrics.dailyBytes.With(labels).Set(float64(bytes))
+		j.quotaMetrics.dailyQuota.With(labels).Set(float64(quota))
+
+		overQuota := quota > 0 && bytes > quota
+		if overQuota {
+			j.quotaMetrics.overQuota.With(labels).Set(1)
+			j.logger.WithFields(logrus.Fields{"tenant": tenant}).Warnf("Tenant stored %d bytes today, exceeding its quota of %d bytes", bytes, quota)
+		} else {
+			j.quotaMetrics.overQuota.With(labels).Set(0)
+		}
+	}
+	return result, nil
+}
+
+type quotaMetrics struct {
+	dailyBytes *prometheus.GaugeVec
+	dailyQuota *prometheus.GaugeVec
+	overQuota  *prometheus.GaugeVec
+}
+
+// sharedQuotaMetrics returns the quota metrics of this process, registering them in the default
+// registry the first time around.
+func sharedQuotaMetrics() (*quotaMetrics, error) {
+	quotaMetricsOnce.Do(func() {
+		quotaMetricsInstance, quotaMetricsCreateErr = newQuotaMetrics()
+	})
+	return quotaMetricsInstance, quotaMetricsCreateErr
+}
+
+func newQuotaMetrics() (*quotaMetrics, error) {
+	result := &quotaMetrics{}
+
+	result.dailyBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "almanac_tenant_daily_bytes",
+		Help: "The stored size of the chunks of each tenant overlapping the current UTC day",
+	}, []string{tenantLabel})
+	if err := util.RegisterLenient(result.dailyBytes); err != nil {
+		return nil, err
+	}
+
+	result.dailyQuota = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "almanac_tenant_daily_quota_bytes",
+		Help: "The number of bytes each tenant may store per day, zero if unlimited",
+	}, []string{tenantLabel})
+	if err := util.RegisterLenient(result.dailyQuota); err != nil {
+		return nil, err
+	}
+
+	result.overQuota = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "almanac_tenant_over_quota",
+		Help: "Whether each tenant has stored more than its quota today, 1 if so and 0 otherwise",
+	}, []string{tenantLabel})
+	if err := util.RegisterLenient(result.overQuota); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,67 @@
+package janitor
+
+import (
+	"testing"
+	"time"
+
+	st "github.com/dinowernli/almanac/pkg/storage"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+const (
+	dayMs = int64(24 * time.Hour / time.Millisecond)
+)
+
+func TestQuotaCheck(t *testing.T) {
+	storage, err := st.NewMemoryStorage()
+	assert.NoError(t, err)
+
+	today := []*pb_almanac.LogEntry{
+		{Id: "id1", TimestampMs: dayMs + 1, EntryJson: `{}`},
+		{Id: "id2", TimestampMs: dayMs + 2, EntryJson: `{}`},
+	}
+	yesterday := []*pb_almanac.LogEntry{
+		{Id: "id3", TimestampMs: 1, EntryJson: `{}`},
+		{Id: "id4", TimestampMs: 2, EntryJson: `{}`},
+	}
+	for _, tenantId := range []string{"acme", "other"} {
+		chunk, err := st.ChunkProto(today, pb_almanac.ChunkId_SMALL)
+		assert.NoError(t, err)
+		chunk.Id.Tenant = tenantId
+		_, err = storage.StoreChunk(context.Background(), chunk)
+		assert.NoError(t, err)
+	}
+	chunk, err := st.ChunkProto(yesterday, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	chunk.Id.Tenant = "acme"
+	_, err = storage.StoreChunk(context.Background(), chunk)
+	assert.NoError(t, err)
+
+	todayBytes := map[string]int64{}
+	for _, tenantId := range []string{"acme", "other"} {
+		infos, err := storage.ListChunkInfos(context.Background(), tenantId, 0, 0, pb_almanac.ChunkId_SMALL)
+		assert.NoError(t, err)
+		for _, info := range infos {
+			if info.Id.StartMs >= dayMs {
+				todayBytes[tenantId] = info.SizeBytes
+			}
+		}
+	}
+
+	metrics, err := sharedQuotaMetrics()
+	assert.NoError(t, err)
+	j := &Janitor{ctx: context.Background(), logger: logrus.New(), storage: storage, quotaMetrics: metrics}
+	assert.Error(t, j.SetQuotas(Quotas{DefaultDailyBytes: -1}))
+	assert.NoError(t, j.SetQuotas(Quotas{DefaultDailyBytes: 1, TenantDailyBytes: map[string]int64{"acme": 0}}))
+
+	now := time.Unix(0, (dayMs+dayMs/2)*int64(time.Millisecond))
+	usage, err := j.checkQuotas(context.Background(), now)
+	assert.NoError(t, err)
+
+	// Only the chunks overlapping the current day count.
+	assert.Equal(t, todayBytes, usage)
+}
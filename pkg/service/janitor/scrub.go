@@ -0,0 +1,452 @@
+package janitor
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	st "github.com/dinowernli/almanac/pkg/storage"
+	"github.com/dinowernli/almanac/pkg/util"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// The kinds of problems a check of storage can find.
+const (
+	// ProblemCorrupt means the stored bytes of a chunk cannot be loaded.
+	ProblemCorrupt = "corrupt"
+
+	// ProblemEmpty means a chunk holds no entries.
+	ProblemEmpty = "empty"
+
+	// ProblemWrongId means the id of a chunk does not match its contents.
+	ProblemWrongId = "wrong_id"
+
+	// ProblemIndex means the index of a chunk does not hold exactly the entries of the chunk.
+	ProblemIndex = "index"
+
+	// ProblemOrphaned means all the entries of a chunk are also held by a big chunk, which
+	// happens if a compaction stores a big chunk but fails to delete the chunks it replaces.
+	ProblemOrphaned = "orphaned"
+
+	// ProblemOverlap means two big chunks hold some of the same entries.
+	ProblemOverlap = "overlap"
+
+	problemKindLabel = "kind"
+)
+
+var (
+	problemKinds = []string{ProblemCorrupt, ProblemEmpty, ProblemWrongId, ProblemIndex, ProblemOrphaned, ProblemOverlap}
+)
+
+// Problem describes a single inconsistency found in storage.
+type Problem struct {
+	ChunkId  *pb_almanac.ChunkId
+	Kind     string
+	Details  string
+	Repaired bool
+}
+
+func (p *Problem) String() string {
+	return fmt.Sprintf("%s %v: %s (repaired: %t)", p.Kind, p.ChunkId, p.Details, p.Repaired)
+}
+
+// Report summarizes a single check of the contents of storage.
+type Report struct {
+	NumChunks int
+	Problems  []*Problem
+
+	// NumQuarantined is the number of chunks in quarantine once the check is done.
+	NumQuarantined int
+}
+
+// NumUnrepaired returns the number of problems which have not been repaired.
+func (r *Report) NumUnrepaired() int {
+	result := 0
+	for _, p := range r.Problems {
+		if !p.Repaired {
+			result++
+		}
+	}
+	return result
+}
+
+type checkerMetrics struct {
+	numChecked  prometheus.Counter
+	numProblems *prometheus.GaugeVec
+	numRepairs  *prometheus.CounterVec
+}
+
+// newCheckerMetrics returns a struct with metrics registered in the default registry.
+func newCheckerMetrics() (*checkerMetrics, error) {
+	result := &checkerMetrics{}
+
+	result.numChecked = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "almanac_fsck_chunks_checked",
+		Help: "The number of chunks checked for consistency",
+	})
+	if err := util.RegisterLenient(result.numChecked); err != nil {
+		return nil, err
+	}
+
+	result.numProblems = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "almanac_fsck_problems",
+		Help: "The number of problems found in storage by the most recent check",
+	}, []string{problemKindLabel})
+	if err := util.RegisterLenient(result.numProblems); err != nil {
+		return nil, err
+	}
+
+	result.numRepairs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "almanac_fsck_repairs",
+		Help: "The number of problems in storage which have been repaired",
+	}, []string{problemKindLabel})
+	if err := util.RegisterLenient(result.numRepairs); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Checker walks all the chunks in storage and verifies that they are consistent, optionally
+// repairing the problems it finds.
+type Checker struct {
+	logger     *logrus.Logger
+	storage    *st.Storage
+	numWorkers int
+	metrics    *checkerMetrics
+}
+
+// NewChecker returns a checker for the supplied storage which loads at most numWorkers chunks
+// concurrently.
+func NewChecker(logger *logrus.Logger, storage *st.Storage, numWorkers int) (*Checker, error) {
+	if numWorkers <= 0 {
+		return nil, fmt.Errorf("number of workers must be positive, but got: %d", numWorkers)
+	}
+	metrics, err := newCheckerMetrics()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create checker metrics: %v", err)
+	}
+	return &Checker{logger: logger, storage: storage, numWorkers: numWorkers, metrics: metrics}, nil
+}
+
+// checkedChunk holds the outcome of checking a single chunk on its own.
+type checkedChunk struct {
+	// id is the id under which the chunk is stored once the check is done, or nil if the chunk
+	// is no longer stored.
+	id       *pb_almanac.ChunkId
+	entryIds map[string]struct{}
+	problems []*Problem
+}
+
+// Check verifies every chunk in storage and returns a report of all the problems found. If
+// repair is set, the problems are fixed where possible: corrupt and empty chunks are
+// quarantined, chunks with a wrong id or index are rebuilt from their entries and orphaned
+// chunks are deleted. Overlapping big chunks are left for later compactions to merge.
+func (c *Checker) Check(ctx context.Context, repair bool) (*Report, error) {
+	start := time.Now()
+
+	infos, err := listChunkInfos(ctx, c.storage)
+	if err != nil {
+		return nil, err
+	}
+	chunkIds := []*pb_almanac.ChunkId{}
+	for _, info := range infos {
+		chunkIds = append(chunkIds, info.Id)
+	}
+
+	// Each worker writes to its own slot, so no synchronization is required.
+	checked := make([]*checkedChunk, len(chunkIds))
+	err = forEachChunk(ctx, c.numWorkers, chunkIds, func(ctx context.Context, i int, chunkId *pb_almanac.ChunkId) error {
+		result, err := c.checkChunk(ctx, chunkId, repair)
+		if err != nil {
+			return fmt.Errorf("unable to check chunk %v: %v", chunkId, err)
+		}
+		checked[i] = result
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{NumChunks: len(chunkIds), Problems: []*Problem{}}
+	for _, result := range checked {
+		report.Problems = append(report.Problems, result.problems...)
+	}
+
+	// Different tenants can have entries with the same ids, so only compare chunks of one tenant.
+	tenants := []string{}
+	checkedByTenant := map[string][]*checkedChunk{}
+	for i, result := range checked {
+		tenantId := chunkIds[i].Tenant
+		if _, ok := checkedByTenant[tenantId]; !ok {
+			tenants = append(tenants, tenantId)
+		}
+		checkedByTenant[tenantId] = append(checkedByTenant[tenantId], result)
+	}
+	for _, tenantId := range tenants {
+		overlapProblems, err := c.checkOverlaps(ctx, checkedByTenant[tenantId], repair)
+		if err != nil {
+			return nil, fmt.Errorf("unable to check for overlapping chunks: %v", err)
+		}
+		report.Problems = append(report.Problems, overlapProblems...)
+	}
+
+	quarantined, err := c.storage.ListQuarantinedChunks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list quarantined chunks: %v", err)
+	}
+	report.NumQuarantined = len(quarantined)
+
+	c.recordMetrics(report)
+	c.logger.Infof("Checked %d chunk(s), found %d problem(s), took %v", report.NumChunks, len(report.Problems), time.Since(start))
+	return report, nil
+}
+
+// checkChunk verifies a single chunk on its own, repairing it if requested.
+func (c *Checker) checkChunk(ctx context.Context, chunkId *pb_almanac.ChunkId, repair bool) (*checkedChunk, error) {
+	result := &checkedChunk{id: chunkId}
+	chunk, err := c.storage.LoadChunk(ctx, chunkId)
+	if corruptErr := st.IsCorruptChunk(err); corruptErr != nil {
+		problem := &Problem{ChunkId: chunkId, Kind: ProblemCorrupt, Details: corruptErr.Err.Error()}
+		result.problems = append(result.problems, problem)
+		return result, c.quarantine(ctx, result, problem, repair)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to load chunk: %v", err)
+	}
+	defer chunk.Close()
+
+	entries := chunk.Entries()
+	if len(entries) == 0 {
+		problem := &Problem{ChunkId: chunkId, Kind: ProblemEmpty, Details: "chunk holds no entries"}
+		result.problems = append(result.problems, problem)
+		return result, c.quarantine(ctx, result, problem, repair)
+	}
+
+	var minMs int64 = math.MaxInt64
+	var maxMs int64 = math.MinInt64
+	result.entryIds = map[string]struct{}{}
+	for _, e := range entries {
+		if e.TimestampMs < minMs {
+			minMs = e.TimestampMs
+		}
+		if e.TimestampMs > maxMs {
+			maxMs = e.TimestampMs
+		}
+		result.entryIds[e.Id] = struct{}{}
+	}
+
+	if !proto.Equal(chunk.Id(), chunkId) {
+		result.problems = append(result.problems, &Problem{
+			ChunkId: chunkId,
+			Kind:    ProblemWrongId,
+			Details: fmt.Sprintf("chunk holds id [%v]", chunk.Id()),
+		})
+	} else if minMs != chunkId.StartMs || maxMs != chunkId.EndMs {
+		result.problems = append(result.problems, &Problem{
+			ChunkId: chunkId,
+			Kind:    ProblemWrongId,
+			Details: fmt.Sprintf("id spans [%d, %d] but entries span [%d, %d]", chunkId.StartMs, chunkId.EndMs, minMs, maxMs),
+		})
+	}
+
+	missing := 0
+	for id := range result.entryIds {
+		contains, err := chunk.Index().Contains(id)
+		if err != nil {
+			return nil, fmt.Errorf("unable to look up entry in index: %v", err)
+		}
+		if !contains {
+			missing++
+		}
+	}
+	count, err := chunk.Index().Count()
+	if err != nil {
+		return nil, fmt.Errorf("unable to count index entries: %v", err)
+	}
+	if missing > 0 || count != uint64(len(result.entryIds)) {
+		result.problems = append(result.problems, &Problem{
+			ChunkId: chunkId,
+			Kind:    ProblemIndex,
+			Details: fmt.Sprintf("index is missing %d of %d entries and holds %d document(s)", missing, len(result.entryIds), count),
+		})
+	}
+
+	if len(result.problems) == 0 || !repair {
+		return result, nil
+	}
+
+	// Rebuilding the chunk from its entries fixes both its id and its index. The replacement is
+	// stored before deleting anything, so that we never lose entries.
+	newChunk, err := st.ChunkProto(entries, chunkId.Type)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create replacement chunk: %v", err)
+	}
+	newChunk.Id.Labels = chunkId.Labels
+	newChunk.Id.Tenant = chunkId.Tenant
+	_, err = c.storage.StoreChunk(ctx, newChunk)
+	if err != nil {
+		return nil, fmt.Errorf("unable to store replacement chunk: %v", err)
+	}
+	err = c.storage.DeleteChunk(ctx, chunkId)
+	if err != nil {
+		return nil, fmt.Errorf("unable to delete rebuilt chunk: %v", err)
+	}
+	result.id = newChunk.Id
+	for _, p := range result.problems {
+		p.Repaired = true
+	}
+	return result, nil
+}
+
+// quarantine moves the checked chunk into quarantine if repair is set, marking the supplied
+// problem as repaired.
+func (c *Checker) quarantine(ctx context.Context, result *checkedChunk, problem *Problem, repair bool) error {
+	if !repair {
+		return nil
+	}
+	err := c.storage.QuarantineChunk(ctx, result.id)
+	if err != nil {
+		return fmt.Errorf("unable to quarantine chunk: %v", err)
+	}
+	result.id = nil
+	problem.Repaired = true
+	return nil
+}
+
+// checkOverlaps looks for chunks whose entries are all held by a big chunk, as well as for big
+// chunks which share some of their entries.
+func (c *Checker) checkOverlaps(ctx context.Context, checked []*checkedChunk, repair bool) ([]*Problem, error) {
+	// Order the chunks such that a chunk can only be orphaned by a chunk which comes later. Of a
+	// set of identical chunks, this keeps the last one around.
+	live := []*checkedChunk{}
+	for _, result := range checked {
+		if result.id != nil {
+			live = append(live, result)
+		}
+	}
+	sortKeys := make(map[*checkedChunk]string, len(live))
+	for _, result := range live {
+		id, err := st.ChunkId(result.id)
+		if err != nil {
+			return nil, fmt.Errorf("unable to compute chunk id: %v", err)
+		}
+		sortKeys[result] = id
+	}
+	sort.Slice(live, func(a, b int) bool {
+		if live[a].id.Type != live[b].id.Type {
+			return live[a].id.Type == pb_almanac.ChunkId_SMALL
+		}
+		if len(live[a].entryIds) != len(live[b].entryIds) {
+			return len(live[a].entryIds) < len(live[b].entryIds)
+		}
+		return sortKeys[live[a]] < sortKeys[live[b]]
+	})
+
+	bigChunksByEntry := map[string][]int{}
+	for i, result := range live {
+		if result.id.Type != pb_almanac.ChunkId_BIG {
+			continue
+		}
+		for id := range result.entryIds {
+			bigChunksByEntry[id] = append(bigChunksByEntry[id], i)
+		}
+	}
+
+	// For every chunk, count the entries it shares with each big chunk.
+	shared := make([]map[int]int, len(live))
+	orphaned := make([]bool, len(live))
+	for i, result := range live {
+		shared[i] = map[int]int{}
+		for id := range result.entryIds {
+			for _, b := range bigChunksByEntry[id] {
+				if b != i {
+					shared[i][b]++
+				}
+			}
+		}
+		for b, n := range shared[i] {
+			if b > i && n == len(result.entryIds) {
+				orphaned[i] = true
+			}
+		}
+	}
+
+	problems := []*Problem{}
+	for i, result := range live {
+		if orphaned[i] {
+			problem := &Problem{ChunkId: result.id, Kind: ProblemOrphaned, Details: "all entries are held by a big chunk"}
+			problems = append(problems, problem)
+			if repair {
+				err := c.storage.DeleteChunk(ctx, result.id)
+				if err != nil {
+					return nil, fmt.Errorf("unable to delete orphaned chunk %v: %v", result.id, err)
+				}
+				problem.Repaired = true
+			}
+			continue
+		}
+		if result.id.Type != pb_almanac.ChunkId_BIG {
+			continue
+		}
+
+		others := []int{}
+		for b := range shared[i] {
+			if b > i && !orphaned[b] {
+				others = append(others, b)
+			}
+		}
+		sort.Ints(others)
+		for _, b := range others {
+			problems = append(problems, &Problem{
+				ChunkId: result.id,
+				Kind:    ProblemOverlap,
+				Details: fmt.Sprintf("shares %d entries with chunk %s", shared[i][b], sortKeys[live[b]]),
+			})
+		}
+	}
+	return problems, nil
+}
+
+func (c *Checker) recordMetrics(report *Report) {
+	c.metrics.numChecked.Add(float64(report.NumChunks))
+
+	problems := map[string]int{}
+	for _, kind := range problemKinds {
+		problems[kind] = 0
+	}
+	for _, p := range report.Problems {
+		problems[p.Kind]++
+		if p.Repaired {
+			c.metrics.numRepairs.With(prometheus.Labels{problemKindLabel: p.Kind}).Inc()
+		}
+	}
+	for kind, n := range problems {
+		c.metrics.numProblems.With(prometheus.Labels{problemKindLabel: kind}).Set(float64(n))
+	}
+}
+
+// executeScrub checks the contents of storage, repairing any problems if configured to do so.
+func (j *Janitor) executeScrub() error {
+	ctx, cancel := context.WithCancel(j.ctx)
+	defer cancel()
+
+	j.settingsMutex.Lock()
+	repair := j.scrubRepair
+	j.settingsMutex.Unlock()
+
+	report, err := j.checker.Check(ctx, repair)
+	if err != nil {
+		return fmt.Errorf("unable to check storage: %v", err)
+	}
+	for _, p := range report.Problems {
+		j.logger.Warnf("Found problem in storage: %v", p)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package janitor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	st "github.com/dinowernli/almanac/pkg/storage"
+	"github.com/dinowernli/almanac/pkg/tenant"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+var (
+	entryA = &pb_almanac.LogEntry{Id: "a", TimestampMs: 10, EntryJson: `{}`}
+	entryB = &pb_almanac.LogEntry{Id: "b", TimestampMs: 11, EntryJson: `{}`}
+	entryC = &pb_almanac.LogEntry{Id: "c", TimestampMs: 20, EntryJson: `{}`}
+	entryD = &pb_almanac.LogEntry{Id: "d", TimestampMs: 21, EntryJson: `{}`}
+	entryE = &pb_almanac.LogEntry{Id: "e", TimestampMs: 30, EntryJson: `{}`}
+	entryF = &pb_almanac.LogEntry{Id: "f", TimestampMs: 31, EntryJson: `{}`}
+	entryG = &pb_almanac.LogEntry{Id: "g", TimestampMs: 40, EntryJson: `{}`}
+)
+
+func TestCheckHealthyStorage(t *testing.T) {
+	checker, err := NewChecker(logrus.New(), createStorage(t), numWorkers)
+	assert.NoError(t, err)
+
+	report, err := checker.Check(context.Background(), false)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.NumChunks)
+	assert.Empty(t, report.Problems)
+}
+
+func TestCheckFindsAndRepairsProblems(t *testing.T) {
+	path, err := ioutil.TempDir("", "almanac-scrub-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(path)
+
+	storage, err := st.NewDiskStorage(path)
+	assert.NoError(t, err)
+
+	// A chunk whose id claims a longer time range than its entries span.
+	wrongId, err := st.ChunkProto([]*pb_almanac.LogEntry{entryA, entryB}, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	wrongId.Id.EndMs = 100
+	storeChunk(t, storage, wrongId)
+
+	// A chunk whose index is missing one of its entries.
+	badIndex, err := st.ChunkProto([]*pb_almanac.LogEntry{entryC, entryD}, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	partial, err := st.ChunkProto([]*pb_almanac.LogEntry{entryC}, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	badIndex.Index = partial.Index
+	storeChunk(t, storage, badIndex)
+
+	// A small chunk left behind by a compaction which failed to delete it.
+	orphan, err := st.ChunkProto([]*pb_almanac.LogEntry{entryE}, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	storeChunk(t, storage, orphan)
+	big, err := st.ChunkProto([]*pb_almanac.LogEntry{entryE, entryF}, pb_almanac.ChunkId_BIG)
+	assert.NoError(t, err)
+	storeChunk(t, storage, big)
+
+	// A chunk which has been truncated on disk.
+	corrupt, err := st.ChunkProto([]*pb_almanac.LogEntry{entryG}, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	corruptId := storeChunk(t, storage, corrupt)
+	filename := filepath.Join(path, "chunk-"+corruptId)
+	contents, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filename, contents[:len(contents)/2], 0644))
+
+	checker, err := NewChecker(logrus.New(), storage, numWorkers)
+	assert.NoError(t, err)
+
+	// Without repairing, the problems are only reported.
+	report, err := checker.Check(context.Background(), false)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, report.NumChunks)
+	assert.Equal(t, []string{ProblemCorrupt, ProblemIndex, ProblemOrphaned, ProblemWrongId}, problemKindsOf(report))
+	assert.Equal(t, 4, report.NumUnrepaired())
+	assert.Equal(t, 0, report.NumQuarantined)
+
+	report, err = checker.Check(context.Background(), true)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(report.Problems))
+	assert.Equal(t, 0, report.NumUnrepaired())
+	assert.Equal(t, 1, report.NumQuarantined)
+
+	// Everything has been fixed, and no entries have been lost.
+	report, err = checker.Check(context.Background(), false)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.NumChunks)
+	assert.Empty(t, report.Problems)
+
+	smallChunks, err := storage.ListChunkInfos(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	spans := map[int64]int64{}
+	for _, c := range smallChunks {
+		spans[c.Id.StartMs] = c.Id.EndMs
+	}
+	assert.Equal(t, map[int64]int64{10: 11, 20: 21}, spans)
+}
+
+func TestCheckOverlappingBigChunks(t *testing.T) {
+	storage, err := st.NewMemoryStorage()
+	assert.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		duplicate, err := st.ChunkProto([]*pb_almanac.LogEntry{entryA, entryB}, pb_almanac.ChunkId_BIG)
+		assert.NoError(t, err)
+		storeChunk(t, storage, duplicate)
+	}
+	overlap1, err := st.ChunkProto([]*pb_almanac.LogEntry{entryC, entryD}, pb_almanac.ChunkId_BIG)
+	assert.NoError(t, err)
+	storeChunk(t, storage, overlap1)
+	overlap2, err := st.ChunkProto([]*pb_almanac.LogEntry{entryD, entryE}, pb_almanac.ChunkId_BIG)
+	assert.NoError(t, err)
+	storeChunk(t, storage, overlap2)
+
+	checker, err := NewChecker(logrus.New(), storage, numWorkers)
+	assert.NoError(t, err)
+
+	report, err := checker.Check(context.Background(), true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{ProblemOrphaned, ProblemOverlap}, problemKindsOf(report))
+
+	// Only one of the identical chunks gets deleted, overlaps are left for compactions to merge.
+	report, err = checker.Check(context.Background(), true)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.NumChunks)
+	assert.Equal(t, []string{ProblemOverlap}, problemKindsOf(report))
+}
+
+func storeChunk(t *testing.T, storage *st.Storage, chunk *pb_almanac.Chunk) string {
+	id, err := storage.StoreChunk(context.Background(), chunk)
+	assert.NoError(t, err)
+	return id
+}
+
+// problemKindsOf returns the sorted kinds of all problems in the supplied report.
+func problemKindsOf(report *Report) []string {
+	result := []string{}
+	for _, p := range report.Problems {
+		result = append(result, p.Kind)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -42,9 +42,14 @@ type heapItem interface {
 type chunkHeapItem struct {
 	chunkIdProto  *pb_almanac.ChunkId
 	searchRequest *pb_almanac.SearchRequest
+	selector      *st.LabelSelector
 	ctx           context.Context
 	storage       *st.Storage
 
+	// onCorrupt is called if the chunk turns out to be corrupt, in which case the chunk is
+	// treated as if it had no entries.
+	onCorrupt func(*st.CorruptChunkError)
+
 	entries []*pb_almanac.LogEntry
 	idx     int
 	loaded  bool
@@ -95,11 +100,16 @@ func (i *chunkHeapItem) ensureChunkLoaded() error {
 	}
 
 	chunk, err := i.storage.LoadChunk(i.ctx, i.chunkIdProto)
+	if corruptErr := st.IsCorruptChunk(err); corruptErr != nil {
+		i.onCorrupt(corruptErr)
+		i.loaded = true
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("unable to load chunk from storage: %v", err)
 	}
 
-	entries, err := chunk.Search(i.ctx, i.searchRequest.Query, i.searchRequest.Num, i.searchRequest.StartMs, i.searchRequest.EndMs)
+	entries, err := chunk.Search(i.ctx, i.searchRequest.Query, i.searchRequest.Num, i.searchRequest.StartMs, i.searchRequest.EndMs, i.selector)
 	if err != nil {
 		return fmt.Errorf("unable to search chunk: %v", err)
 	}
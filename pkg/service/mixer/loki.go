@@ -0,0 +1,217 @@
+package mixer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dinowernli/almanac/pkg/loki"
+	"github.com/dinowernli/almanac/pkg/storage"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	lokiDefaultRange = time.Hour
+	lokiDefaultLimit = 100
+	lokiMaxLimit     = 5000
+	lokiTimeout      = 30 * time.Second
+
+	lokiDirectionBackward = "backward"
+	lokiDirectionForward  = "forward"
+	lokiLabelValuesSuffix = "/values"
+)
+
+// lokiResponse is the envelope of all responses of the loki api.
+type lokiResponse struct {
+	Status string      `json:"status"`
+	Data   interface{} `json:"data"`
+}
+
+type lokiStreams struct {
+	ResultType string         `json:"resultType"`
+	Result     []*loki.Stream `json:"result"`
+}
+
+// handleLokiQueryRange serves logql queries made up of a stream selector and line filters. The
+// search returns the first entries within the range, which are listed newest first unless the
+// direction is forward.
+func (m *Mixer) handleLokiQueryRange(writer http.ResponseWriter, request *http.Request) {
+	tenantId, err := loki.Tenant(request)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query, err := loki.ParseQuery(request.FormValue("query"))
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("unable to parse query: %v", err), http.StatusBadRequest)
+		return
+	}
+	startMs, endMs, err := lokiRange(request)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit := lokiDefaultLimit
+	if value := request.FormValue("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > lokiMaxLimit {
+			http.Error(writer, fmt.Sprintf("limit must be between 1 and %d, but got %q", lokiMaxLimit, value), http.StatusBadRequest)
+			return
+		}
+	}
+	direction := request.FormValue("direction")
+	if direction != "" && direction != lokiDirectionBackward && direction != lokiDirectionForward {
+		http.Error(writer, fmt.Sprintf("unknown direction %q", direction), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lokiTimeout)
+	defer cancel()
+	response, err := m.Search(ctx, &pb_almanac.SearchRequest{
+		Tenant:        tenantId,
+		Query:         query.Text,
+		Num:           int32(limit),
+		StartMs:       startMs,
+		EndMs:         endMs,
+		LabelSelector: query.Selector,
+	})
+	if err != nil {
+		http.Error(writer, err.Error(), lokiStatus(err))
+		return
+	}
+
+	entries := response.Entries
+	if direction != lokiDirectionForward {
+		entries = make([]*pb_almanac.LogEntry, len(response.Entries))
+		for i, entry := range response.Entries {
+			entries[len(entries)-1-i] = entry
+		}
+	}
+	streams, err := loki.Streams(entries, query.LabelNames)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeLoki(writer, &lokiStreams{ResultType: "streams", Result: streams})
+}
+
+// handleLokiLabels lists the names of the stream labels of the chunks in storage overlapping the
+// requested range. Entries still held by the appenders are not taken into account.
+func (m *Mixer) handleLokiLabels(writer http.ResponseWriter, request *http.Request) {
+	labels, err := m.lokiChunkLabels(request)
+	if err != nil {
+		http.Error(writer, err.Error(), lokiStatus(err))
+		return
+	}
+	names := map[string]bool{}
+	for _, l := range labels {
+		for name := range l {
+			names[name] = true
+		}
+	}
+	writeLoki(writer, loki.SortedKeys(names))
+}
+
+// handleLokiLabelValues lists the values of a single stream label, in the same way as
+// handleLokiLabels lists the names.
+func (m *Mixer) handleLokiLabelValues(writer http.ResponseWriter, request *http.Request) {
+	name := strings.TrimPrefix(request.URL.Path, loki.LabelValuesPath)
+	if !strings.HasSuffix(name, lokiLabelValuesSuffix) || strings.Contains(strings.TrimSuffix(name, lokiLabelValuesSuffix), "/") {
+		http.NotFound(writer, request)
+		return
+	}
+	name = strings.TrimSuffix(name, lokiLabelValuesSuffix)
+
+	labels, err := m.lokiChunkLabels(request)
+	if err != nil {
+		http.Error(writer, err.Error(), lokiStatus(err))
+		return
+	}
+	values := map[string]bool{}
+	for _, l := range labels {
+		if value, ok := l[name]; ok {
+			values[value] = true
+		}
+	}
+	writeLoki(writer, loki.SortedKeys(values))
+}
+
+// lokiChunkLabels returns the stream labels of all chunks of the tenant of the supplied request
+// which overlap with its range.
+func (m *Mixer) lokiChunkLabels(request *http.Request) ([]map[string]string, error) {
+	tenantId, err := loki.Tenant(request)
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	startMs, endMs, err := lokiRange(request)
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lokiTimeout)
+	defer cancel()
+	result := []map[string]string{}
+	for _, chunkType := range []pb_almanac.ChunkId_Type{pb_almanac.ChunkId_SMALL, pb_almanac.ChunkId_BIG} {
+		ids, err := m.storage.ListChunks(ctx, tenantId, startMs, endMs, chunkType)
+		if err != nil {
+			return nil, grpc.Errorf(codes.Internal, "unable to list chunks: %v", err)
+		}
+		for _, id := range ids {
+			idProto, err := storage.ChunkIdProto(id)
+			if err != nil {
+				return nil, grpc.Errorf(codes.Internal, "unable to compute chunk id proto: %v", err)
+			}
+			if idProto.EndMs >= startMs && idProto.StartMs <= endMs && len(idProto.Labels) > 0 {
+				result = append(result, idProto.Labels)
+			}
+		}
+	}
+	return result, nil
+}
+
+// lokiRange returns the range of the supplied request in milliseconds. Without a start, the
+// range covers the hour before its end, or the duration passed as "since". Without an end, it
+// ends now.
+func lokiRange(request *http.Request) (int64, int64, error) {
+	end, err := loki.ParseTime(request.FormValue("end"), time.Now())
+	if err != nil {
+		return 0, 0, err
+	}
+	since := lokiDefaultRange
+	if value := request.FormValue("since"); value != "" {
+		since, err = time.ParseDuration(value)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid duration %q", value)
+		}
+	}
+	start, err := loki.ParseTime(request.FormValue("start"), end.Add(-since))
+	if err != nil {
+		return 0, 0, err
+	}
+	if start.After(end) {
+		return 0, 0, fmt.Errorf("start %v is after end %v", start, end)
+	}
+	return start.UnixNano() / int64(time.Millisecond), end.UnixNano() / int64(time.Millisecond), nil
+}
+
+func writeLoki(writer http.ResponseWriter, data interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(writer).Encode(&lokiResponse{Status: "success", Data: data})
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("unable to encode response: %v", err), http.StatusInternalServerError)
+	}
+}
+
+func lokiStatus(err error) int {
+	if grpc.Code(err) == codes.InvalidArgument {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -7,10 +7,13 @@ import (
 	"time"
 
 	almHttp "github.com/dinowernli/almanac/pkg/http"
+	"github.com/dinowernli/almanac/pkg/loki"
 	"github.com/dinowernli/almanac/pkg/service/discovery"
 	"github.com/dinowernli/almanac/pkg/storage"
+	"github.com/dinowernli/almanac/pkg/tenant"
 	pb_almanac "github.com/dinowernli/almanac/proto"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
@@ -22,6 +25,7 @@ import (
 const (
 	httpUrl             = "/mixer"
 	urlParamQuery       = "q"
+	urlParamLabels      = "l"
 	urlParamStartMs     = "s"
 	urlParamEndMs       = "e"
 	httpSearchTimeoutMs = 3000
@@ -32,21 +36,27 @@ var (
 )
 
 // Mixer is an implementation of the mixer rpc service. It provides global
-// search functionality across the entire system.
+// search functionality across the entire system. Every search is confined to
+// the data of a single tenant.
 type Mixer struct {
-	logger    *logrus.Logger
-	storage   *storage.Storage
-	discovery *discovery.Discovery
+	logger     *logrus.Logger
+	storage    *storage.Storage
+	discovery  *discovery.Discovery
+	tombstones *tombstoneCache
 }
 
 // New returns a new mixer backed by the supplied storage.
 func New(logger *logrus.Logger, storage *storage.Storage, discovery *discovery.Discovery) *Mixer {
-	return &Mixer{logger: logger, storage: storage, discovery: discovery}
+	return &Mixer{logger: logger, storage: storage, discovery: discovery, tombstones: newTombstoneCache(storage)}
 }
 
-// RegisterHttp registers a page on the supplied server, used for executing searches.
+// RegisterHttp registers a page on the supplied server, used for executing searches, along with
+// the query endpoints of the loki api.
 func (m *Mixer) RegisterHttp(server *http.ServeMux) {
 	server.HandleFunc(httpUrl, prometheus.InstrumentHandlerFunc(httpUrl, m.handleHttp))
+	server.HandleFunc(loki.QueryRangePath, prometheus.InstrumentHandlerFunc(loki.QueryRangePath, m.handleLokiQueryRange))
+	server.HandleFunc(loki.LabelsPath, prometheus.InstrumentHandlerFunc(loki.LabelsPath, m.handleLokiLabels))
+	server.HandleFunc(loki.LabelValuesPath, prometheus.InstrumentHandlerFunc(loki.LabelValuesPath, m.handleLokiLabelValues))
 }
 
 func (m *Mixer) Search(ctx context.Context, request *pb_almanac.SearchRequest) (*pb_almanac.SearchResponse, error) {
@@ -56,18 +66,42 @@ func (m *Mixer) Search(ctx context.Context, request *pb_almanac.SearchRequest) (
 		logger.WithError(err).Warnf("Failed")
 		return nil, err
 	}
+	tenantId, err := tenant.Resolve(ctx, request.Tenant)
+	if err != nil {
+		err := grpc.Errorf(codes.InvalidArgument, "unable to determine tenant: %v", err)
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+	logger = logger.WithFields(logrus.Fields{"tenant": tenantId})
+	selector, err := storage.NewLabelSelector(request.LabelSelector)
+	if err != nil {
+		err := grpc.Errorf(codes.InvalidArgument, "invalid label selector: %v", err)
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+
+	// Corrupt chunks are skipped and reported rather than failing the entire search.
+	corruptChunks := []*pb_almanac.ChunkId{}
+	onCorrupt := func(err *storage.CorruptChunkError) {
+		logger.WithError(err).Warnf("Skipping corrupt chunk")
+		corruptChunks = append(corruptChunks, err.ChunkId)
+	}
 
 	searchHeap := &searchHeap{}
 	heap.Init(searchHeap)
 	g, _ := errgroup.WithContext(ctx)
 
+	// The tenant may only have been present in the metadata, so name it explicitly for appenders.
+	appenderRequest := proto.Clone(request).(*pb_almanac.SearchRequest)
+	appenderRequest.Tenant = tenantId
+
 	// Compute one heap item for every appender.
 	for _, a := range m.discovery.ListAppenders() {
 		// Copy the iteration variable here because otherwise, all instances of the func below end up
 		// using the same appender because golang loop variables are by reference.
 		appender := a
 		g.Go(func() error {
-			response, err := appender.Search(ctx, request)
+			response, err := appender.Search(ctx, appenderRequest)
 			if err != nil {
 				return fmt.Errorf("unable to search appender: %v", err)
 			}
@@ -78,13 +112,15 @@ func (m *Mixer) Search(ctx context.Context, request *pb_almanac.SearchRequest) (
 		})
 	}
 
-	// Compute a heap item for every chunk whose time span overlaps with our query.
+	// Compute a heap item for every chunk whose time span overlaps with our query, skipping the
+	// chunks whose stream labels rule out any matches.
+	numSkipped := 0
 	g.Go(func() error {
-		smallChunkIds, err := m.storage.ListChunks(ctx, request.StartMs, request.EndMs, pb_almanac.ChunkId_SMALL)
+		smallChunkIds, err := m.storage.ListChunks(ctx, tenantId, request.StartMs, request.EndMs, pb_almanac.ChunkId_SMALL)
 		if err != nil {
 			return fmt.Errorf("unable to list small chunks: %v", err)
 		}
-		bigChunkIds, err := m.storage.ListChunks(ctx, request.StartMs, request.EndMs, pb_almanac.ChunkId_BIG)
+		bigChunkIds, err := m.storage.ListChunks(ctx, tenantId, request.StartMs, request.EndMs, pb_almanac.ChunkId_BIG)
 		if err != nil {
 			return fmt.Errorf("unable to list big chunks: %v", err)
 		}
@@ -95,7 +131,11 @@ func (m *Mixer) Search(ctx context.Context, request *pb_almanac.SearchRequest) (
 			if err != nil {
 				return fmt.Errorf("unable to compute chunk id proto: %v", err)
 			}
-			heap.Push(searchHeap, &chunkHeapItem{chunkIdProto: idProto, searchRequest: request, ctx: ctx, storage: m.storage})
+			if !selector.MatchesChunk(idProto) {
+				numSkipped++
+				continue
+			}
+			heap.Push(searchHeap, &chunkHeapItem{chunkIdProto: idProto, searchRequest: request, selector: selector, ctx: ctx, storage: m.storage, onCorrupt: onCorrupt})
 		}
 		return nil
 	})
@@ -106,8 +146,17 @@ func (m *Mixer) Search(ctx context.Context, request *pb_almanac.SearchRequest) (
 		return nil, err
 	}
 
-	// Start assembling results by repeatedly grabbing the next one from the heap.
+	tombstones, err := m.listTombstones(ctx, tenantId, request)
+	if err != nil {
+		err := grpc.Errorf(codes.Internal, "unable to list tombstones: %v", err)
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+
+	// Start assembling results by repeatedly grabbing the next one from the heap. Entries are
+	// collected in a pending list first because purged entries don't count towards the result.
 	result := []*pb_almanac.LogEntry{}
+	pending := []*pb_almanac.LogEntry{}
 	seen := map[string]struct{}{}
 	for searchHeap.Len() > 0 {
 		item := heap.Pop(searchHeap).(heapItem)
@@ -125,9 +174,19 @@ func (m *Mixer) Search(ctx context.Context, request *pb_almanac.SearchRequest) (
 		// Incorporate the entry into our result set (including deduping).
 		if _, ok := seen[entry.Id]; !ok {
 			seen[entry.Id] = struct{}{}
-			result = append(result, entry)
-			if len(result) >= int(request.Num) {
-				break
+			pending = append(pending, entry)
+			if len(result)+len(pending) >= int(request.Num) {
+				kept, _, err := storage.ApplyTombstones(ctx, pending, tombstones)
+				if err != nil {
+					err := grpc.Errorf(codes.Internal, "unable to apply tombstones: %v", err)
+					logger.WithError(err).Warnf("Failed")
+					return nil, err
+				}
+				result = append(result, kept...)
+				pending = []*pb_almanac.LogEntry{}
+				if len(result) >= int(request.Num) {
+					break
+				}
 			}
 		}
 
@@ -143,20 +202,46 @@ func (m *Mixer) Search(ctx context.Context, request *pb_almanac.SearchRequest) (
 		}
 	}
 
-	logger = logger.WithFields(logrus.Fields{"hits": len(result)})
+	kept, _, err := storage.ApplyTombstones(ctx, pending, tombstones)
+	if err != nil {
+		err := grpc.Errorf(codes.Internal, "unable to apply tombstones: %v", err)
+		logger.WithError(err).Warnf("Failed")
+		return nil, err
+	}
+	result = append(result, kept...)
+
+	logger = logger.WithFields(logrus.Fields{"hits": len(result), "corrupt": len(corruptChunks), "skipped": numSkipped})
 	logger.Infof("Handled")
-	return &pb_almanac.SearchResponse{Entries: result}, nil
+	return &pb_almanac.SearchResponse{Entries: result, CorruptChunks: corruptChunks}, nil
+}
+
+// listTombstones returns the tombstones of the supplied tenant which can affect the results of
+// the supplied request.
+func (m *Mixer) listTombstones(ctx context.Context, tenantId string, request *pb_almanac.SearchRequest) ([]*pb_almanac.Tombstone, error) {
+	tombstones, err := m.tombstones.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*pb_almanac.Tombstone{}
+	for _, t := range tombstones {
+		if t.Tenant == tenantId && storage.TombstoneOverlaps(t, request.StartMs, request.EndMs) {
+			result = append(result, t)
+		}
+	}
+	return result, nil
 }
 
 // handleHttp serves a web page which can be used to execute queries on this mixer.
 func (m *Mixer) handleHttp(writer http.ResponseWriter, request *http.Request) {
 	pageData := &almHttp.MixerData{
 		FormQuery:   request.FormValue(urlParamQuery),
+		FormLabels:  request.FormValue(urlParamLabels),
 		FormStartMs: request.FormValue(urlParamStartMs),
 		FormEndMs:   request.FormValue(urlParamEndMs),
 	}
 
-	if pageData.FormQuery == "" && pageData.FormStartMs == "" && pageData.FormEndMs == "" {
+	if pageData.FormQuery == "" && pageData.FormLabels == "" && pageData.FormStartMs == "" && pageData.FormEndMs == "" {
 		err := pageData.Render(writer)
 		if err != nil {
 			fmt.Fprintf(writer, "failed to render empty mixer page: %v", err)
@@ -164,18 +249,35 @@ func (m *Mixer) handleHttp(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	pageData.Request = &pb_almanac.SearchRequest{
-		Query:   pageData.FormQuery,
-		Num:     100,
-		StartMs: almHttp.ParseTimestamp(pageData.FormStartMs, 0),
-		EndMs:   almHttp.ParseTimestamp(pageData.FormEndMs, 0),
+	tenantId, err := tenant.FromHttp(request)
+	if err != nil {
+		pageData.Error = err
+		err = pageData.Render(writer)
+		if err != nil {
+			fmt.Fprintf(writer, "failed to render mixer page: %v", err)
+		}
+		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), httpSearchTimeoutMs*time.Millisecond)
-	defer cancel()
-	pageData.Response, pageData.Error = m.Search(ctx, pageData.Request)
+	selector, err := storage.ParseLabelSelector(pageData.FormLabels)
+	if err != nil {
+		pageData.Error = err
+	} else {
+		pageData.Request = &pb_almanac.SearchRequest{
+			Tenant:        tenantId,
+			Query:         pageData.FormQuery,
+			Num:           100,
+			StartMs:       almHttp.ParseTimestamp(pageData.FormStartMs, 0),
+			EndMs:         almHttp.ParseTimestamp(pageData.FormEndMs, 0),
+			LabelSelector: selector,
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), httpSearchTimeoutMs*time.Millisecond)
+		defer cancel()
+		pageData.Response, pageData.Error = m.Search(ctx, pageData.Request)
+	}
 
-	err := pageData.Render(writer)
+	err = pageData.Render(writer)
 	if err != nil {
 		fmt.Fprintf(writer, "failed to render mixer page: %v", err)
 	}
@@ -1,19 +1,26 @@
 package mixer
 
 import (
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/dinowernli/almanac/pkg/loki"
 	"github.com/dinowernli/almanac/pkg/service/discovery"
 	st "github.com/dinowernli/almanac/pkg/storage"
+	"github.com/dinowernli/almanac/pkg/tenant"
 	pb_almanac "github.com/dinowernli/almanac/proto"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 var (
@@ -82,15 +89,249 @@ func TestSearchNoResults(t *testing.T) {
 	assert.Empty(t, response.Entries)
 }
 
+func TestSearchAppliesTombstones(t *testing.T) {
+	entry2 := &pb_almanac.LogEntry{Id: "id2", EntryJson: `{ "message": "foo bar" }`, TimestampMs: int64(50)}
+	chunk, err := st.ChunkProto([]*pb_almanac.LogEntry{entry1, entry2}, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+
+	storage, err := st.NewMemoryStorage()
+	assert.NoError(t, err)
+
+	_, err = storage.StoreChunk(context.Background(), chunk)
+	assert.NoError(t, err)
+
+	err = storage.StoreTombstone(context.Background(), &pb_almanac.Tombstone{
+		Id:     "t1",
+		Query:  "bar",
+		Action: pb_almanac.PurgeAction_DROP,
+	})
+	assert.NoError(t, err)
+
+	appenders := []pb_almanac.AppenderClient{&fakeAppender{}}
+	mixer := New(logrus.New(), storage, discovery.NewForTesting(appenders))
+
+	// Ask for a single result. The purged entry is the oldest, so it must get skipped.
+	response, err := mixer.Search(context.Background(), &pb_almanac.SearchRequest{Query: "foo", Num: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(response.Entries))
+	assert.Equal(t, entry1.Id, response.Entries[0].Id)
+}
+
+func TestSearchReportsCorruptChunks(t *testing.T) {
+	path, err := ioutil.TempDir("", "almanac-mixer-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(path)
+
+	storage, err := st.NewDiskStorage(path)
+	assert.NoError(t, err)
+
+	chunk, err := st.ChunkProto([]*pb_almanac.LogEntry{entry1}, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	chunkId, err := storage.StoreChunk(context.Background(), chunk)
+	assert.NoError(t, err)
+
+	// Truncate the chunk on disk, as if its write had been interrupted.
+	filename := filepath.Join(path, "chunk-"+chunkId)
+	contents, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filename, contents[:len(contents)/2], 0644))
+
+	appenders := []pb_almanac.AppenderClient{&fakeAppender{}}
+	mixer := New(logrus.New(), storage, discovery.NewForTesting(appenders))
+
+	response, err := mixer.Search(context.Background(), &pb_almanac.SearchRequest{Query: "foo", Num: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, response.Entries)
+	assert.Equal(t, []*pb_almanac.ChunkId{chunk.Id}, response.CorruptChunks)
+}
+
+func TestSearchPrunesChunksByLabels(t *testing.T) {
+	path, err := ioutil.TempDir("", "almanac-mixer-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(path)
+
+	storage, err := st.NewDiskStorage(path)
+	assert.NoError(t, err)
+
+	web := &pb_almanac.LogEntry{Id: "web", EntryJson: `{"service": "web", "message": "foo"}`, TimestampMs: 10}
+	db := &pb_almanac.LogEntry{Id: "db", EntryJson: `{"service": "db", "message": "foo"}`, TimestampMs: 20}
+	unlabeled := &pb_almanac.LogEntry{Id: "unlabeled", EntryJson: `{"service": "web", "message": "foo"}`, TimestampMs: 30}
+	other := &pb_almanac.LogEntry{Id: "other", EntryJson: `{"service": "db", "message": "foo"}`, TimestampMs: 40}
+
+	webChunk, err := st.ChunkProto([]*pb_almanac.LogEntry{web}, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	webChunk.Id.Labels = map[string]string{"service": "web"}
+	_, err = storage.StoreChunk(context.Background(), webChunk)
+	assert.NoError(t, err)
+
+	// Corrupt the db chunk, such that searching it would show up in the response.
+	dbChunk, err := st.ChunkProto([]*pb_almanac.LogEntry{db}, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	dbChunk.Id.Labels = map[string]string{"service": "db"}
+	dbChunkId, err := storage.StoreChunk(context.Background(), dbChunk)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(path, "chunk-"+dbChunkId), []byte("garbage"), 0644))
+
+	// Chunks without labels are searched, but only their matching entries are returned.
+	unlabeledChunk, err := st.ChunkProto([]*pb_almanac.LogEntry{unlabeled, other}, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	_, err = storage.StoreChunk(context.Background(), unlabeledChunk)
+	assert.NoError(t, err)
+
+	appenders := []pb_almanac.AppenderClient{&fakeAppender{}}
+	mixer := New(logrus.New(), storage, discovery.NewForTesting(appenders))
+
+	selector, err := st.ParseLabelSelector(`service="web"`)
+	assert.NoError(t, err)
+	response, err := mixer.Search(context.Background(), &pb_almanac.SearchRequest{Query: "foo", Num: 10, LabelSelector: selector})
+	assert.NoError(t, err)
+	assert.Empty(t, response.CorruptChunks)
+	assert.Equal(t, 2, len(response.Entries))
+	assert.Equal(t, web.Id, response.Entries[0].Id)
+	assert.Equal(t, unlabeled.Id, response.Entries[1].Id)
+
+	// Invalid selectors are rejected.
+	_, err = mixer.Search(context.Background(), &pb_almanac.SearchRequest{
+		Query:         "foo",
+		Num:           10,
+		LabelSelector: &pb_almanac.LabelSelector{Matchers: []*pb_almanac.LabelMatcher{{Name: "service"}}},
+	})
+	assert.Error(t, err)
+}
+
+func TestHttpLabels(t *testing.T) {
+	storage, err := st.NewMemoryStorage()
+	assert.NoError(t, err)
+
+	appenders := []pb_almanac.AppenderClient{&fakeAppender{}}
+	mixer := New(logrus.New(), storage, discovery.NewForTesting(appenders))
+
+	request, err := http.NewRequest("GET", "/mixer?q=foo&l=service%3D%3D", nil)
+	assert.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	mixer.handleHttp(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "unable to parse label selector")
+	assert.Equal(t, 0, appenders[0].(*fakeAppender).searchCalls)
+}
+
+func TestSearchIsScopedToTenant(t *testing.T) {
+	storage, err := st.NewMemoryStorage()
+	assert.NoError(t, err)
+
+	entry2 := &pb_almanac.LogEntry{Id: "id2", EntryJson: `{ "message": "foo" }`, TimestampMs: int64(50)}
+	for tenantId, entry := range map[string]*pb_almanac.LogEntry{"acme": entry1, tenant.Default: entry2} {
+		chunk, err := st.ChunkProto([]*pb_almanac.LogEntry{entry}, pb_almanac.ChunkId_SMALL)
+		assert.NoError(t, err)
+		chunk.Id.Tenant = tenantId
+		_, err = storage.StoreChunk(context.Background(), chunk)
+		assert.NoError(t, err)
+	}
+
+	// A tombstone of the default tenant must not affect the entries of other tenants.
+	err = storage.StoreTombstone(context.Background(), &pb_almanac.Tombstone{Id: "t1", Query: "foo", Action: pb_almanac.PurgeAction_DROP})
+	assert.NoError(t, err)
+
+	appender := &fakeAppender{}
+	mixer := New(logrus.New(), storage, discovery.NewForTesting([]pb_almanac.AppenderClient{appender}))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(tenant.MetadataKey, "acme"))
+	response, err := mixer.Search(ctx, &pb_almanac.SearchRequest{Query: "foo", Num: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []*pb_almanac.LogEntry{entry1}, response.Entries)
+	assert.Equal(t, "acme", appender.lastTenant)
+
+	response, err = mixer.Search(context.Background(), &pb_almanac.SearchRequest{Query: "foo", Num: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, response.Entries)
+}
+
 type fakeAppender struct {
 	searchCalls int
+	lastTenant  string
 }
 
 func (a *fakeAppender) Search(ctx context.Context, request *pb_almanac.SearchRequest, options ...grpc.CallOption) (*pb_almanac.SearchResponse, error) {
 	a.searchCalls++
+	a.lastTenant = request.Tenant
 	return &pb_almanac.SearchResponse{}, nil
 }
 
 func (a *fakeAppender) Append(ctx context.Context, request *pb_almanac.AppendRequest, options ...grpc.CallOption) (*pb_almanac.AppendResponse, error) {
 	return &pb_almanac.AppendResponse{}, nil
 }
+
+func TestLokiQueryRange(t *testing.T) {
+	storage, err := st.NewMemoryStorage()
+	assert.NoError(t, err)
+
+	entries := []*pb_almanac.LogEntry{
+		{Id: "1", EntryJson: `{"job": "varlogs", "message": "connection refused", "timestamp_ms": 1000}`, TimestampMs: 1000},
+		{Id: "2", EntryJson: `{"job": "varlogs", "message": "connection established", "timestamp_ms": 2000}`, TimestampMs: 2000},
+		{Id: "3", EntryJson: `{"job": "other", "message": "connection refused", "timestamp_ms": 3000}`, TimestampMs: 3000},
+	}
+	chunk, err := st.ChunkProto(entries, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	_, err = storage.StoreChunk(context.Background(), chunk)
+	assert.NoError(t, err)
+
+	mixer := New(logrus.New(), storage, discovery.NewForTesting([]pb_almanac.AppenderClient{&fakeAppender{}}))
+	query := func(params string) *httptest.ResponseRecorder {
+		request, err := http.NewRequest("GET", loki.QueryRangePath+"?"+params, nil)
+		assert.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		mixer.handleLokiQueryRange(recorder, request)
+		return recorder
+	}
+
+	recorder := query(`query={job="varlogs"}&start=0&end=10000000000`)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"status": "success", "data": {"resultType": "streams", "result": [
+		{"stream": {"job": "varlogs"}, "values": [["2000000000", "connection established"], ["1000000000", "connection refused"]]}
+	]}}`, recorder.Body.String())
+
+	recorder = query(`query={job="varlogs"} != "refused"&start=0&end=10000000000&direction=forward`)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"status": "success", "data": {"resultType": "streams", "result": [
+		{"stream": {"job": "varlogs"}, "values": [["2000000000", "connection established"]]}
+	]}}`, recorder.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, query(`query={job="varlogs"} |~ "ref.*"`).Code)
+	assert.Equal(t, http.StatusBadRequest, query(`query={job="varlogs"}&limit=0`).Code)
+	assert.Equal(t, http.StatusBadRequest, query(`query={job="varlogs"}&start=2000000000&end=1000000000`).Code)
+}
+
+func TestLokiLabels(t *testing.T) {
+	storage, err := st.NewMemoryStorage()
+	assert.NoError(t, err)
+	for i, labels := range []map[string]string{{"job": "varlogs", "host": "web-1"}, {"job": "syslog"}} {
+		entry := &pb_almanac.LogEntry{Id: fmt.Sprint(i), EntryJson: `{"message": "foo"}`, TimestampMs: int64(1000 * (i + 1))}
+		chunk, err := st.ChunkProto([]*pb_almanac.LogEntry{entry}, pb_almanac.ChunkId_SMALL)
+		assert.NoError(t, err)
+		chunk.Id.Labels = labels
+		_, err = storage.StoreChunk(context.Background(), chunk)
+		assert.NoError(t, err)
+	}
+
+	mixer := New(logrus.New(), storage, discovery.NewForTesting([]pb_almanac.AppenderClient{}))
+	get := func(handler http.HandlerFunc, path string) *httptest.ResponseRecorder {
+		request, err := http.NewRequest("GET", path, nil)
+		assert.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		handler(recorder, request)
+		return recorder
+	}
+
+	recorder := get(mixer.handleLokiLabels, loki.LabelsPath+"?start=0&end=10000000000")
+	assert.JSONEq(t, `{"status": "success", "data": ["host", "job"]}`, recorder.Body.String())
+
+	recorder = get(mixer.handleLokiLabelValues, loki.LabelValuesPath+"job/values?start=0&end=10000000000")
+	assert.JSONEq(t, `{"status": "success", "data": ["syslog", "varlogs"]}`, recorder.Body.String())
+
+	// Chunks outside the range are left out.
+	recorder = get(mixer.handleLokiLabelValues, loki.LabelValuesPath+"job/values?start=1500000000&end=10000000000")
+	assert.JSONEq(t, `{"status": "success", "data": ["syslog"]}`, recorder.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, get(mixer.handleLokiLabelValues, loki.LabelValuesPath+"job").Code)
+}
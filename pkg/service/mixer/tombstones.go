@@ -0,0 +1,49 @@
+package mixer
+
+import (
+	"sync"
+
+	"github.com/dinowernli/almanac/pkg/storage"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"golang.org/x/net/context"
+)
+
+// tombstoneCache holds the tombstones read from storage. Tombstones never change once stored, so
+// each search only lists the ids in storage and reads the tombstones it has not seen before. This
+// way, new tombstones apply to the very next search without reading all of them every time.
+type tombstoneCache struct {
+	storage *storage.Storage
+
+	mutex      sync.Mutex
+	tombstones map[string]*pb_almanac.Tombstone
+}
+
+func newTombstoneCache(storage *storage.Storage) *tombstoneCache {
+	return &tombstoneCache{storage: storage, tombstones: map[string]*pb_almanac.Tombstone{}}
+}
+
+// get returns all tombstones currently in storage.
+func (c *tombstoneCache) get(ctx context.Context) ([]*pb_almanac.Tombstone, error) {
+	ids, err := c.storage.ListTombstoneIds(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	result := []*pb_almanac.Tombstone{}
+	for _, id := range ids {
+		tombstone, ok := c.tombstones[id]
+		if !ok {
+			tombstone, err = c.storage.ReadTombstone(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			c.tombstones[id] = tombstone
+		}
+		result = append(result, tombstone)
+	}
+	return result, nil
+}
@@ -0,0 +1,34 @@
+package mixer
+
+import (
+	"testing"
+
+	st "github.com/dinowernli/almanac/pkg/storage"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestTombstoneCache(t *testing.T) {
+	storage, err := st.NewMemoryStorage()
+	assert.NoError(t, err)
+	cache := newTombstoneCache(storage)
+
+	tombstones, err := cache.get(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, tombstones)
+
+	// New tombstones show up right away, and are only read once.
+	tombstone := &pb_almanac.Tombstone{Id: "t1", Query: "foo", Action: pb_almanac.PurgeAction_DROP}
+	assert.NoError(t, storage.StoreTombstone(context.Background(), tombstone))
+	tombstones, err = cache.get(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []*pb_almanac.Tombstone{tombstone}, tombstones)
+	assert.Equal(t, map[string]*pb_almanac.Tombstone{"t1": tombstone}, cache.tombstones)
+
+	cached := cache.tombstones["t1"]
+	tombstones, err = cache.get(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, cached == tombstones[0])
+}
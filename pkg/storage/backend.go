@@ -7,6 +7,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"golang.org/x/net/context"
 )
@@ -20,8 +21,9 @@ type backend interface {
 	// write stores the supplied bytes under the supplied id.
 	write(ctx context.Context, id string, contents []byte) error
 
-	// list returns all keys which start with the supplied prefix.
-	list(ctx context.Context, prefix string) ([]string, error)
+	// list returns all keys which start with the supplied prefix, mapped to the size in
+	// bytes of their contents.
+	list(ctx context.Context, prefix string) (map[string]int64, error)
 
 	// delete removes the bytes associated with the given key.
 	delete(ctx context.Context, id string) error
@@ -40,15 +42,19 @@ func (b *diskBackend) write(ctx context.Context, id string, contents []byte) err
 	return ioutil.WriteFile(b.filename(id), contents, 0644)
 }
 
-func (b *diskBackend) list(ctx context.Context, prefix string) ([]string, error) {
+func (b *diskBackend) list(ctx context.Context, prefix string) (map[string]int64, error) {
 	matches, err := filepath.Glob(filepath.Join(b.path, prefix+"*"))
 	if err != nil {
 		return nil, fmt.Errorf("unable to glob files: %v", err)
 	}
 
-	results := []string{}
+	results := map[string]int64{}
 	for _, m := range matches {
-		results = append(results, filepath.Base(m))
+		info, err := os.Stat(m)
+		if err != nil {
+			return nil, fmt.Errorf("unable to stat file %s: %v", m, err)
+		}
+		results[filepath.Base(m)] = info.Size()
 	}
 	return results, nil
 }
@@ -69,12 +75,15 @@ func (b *diskBackend) filename(id string) string {
 	return path.Join(b.path, id)
 }
 
-// memoryBackend is a storage backend backed by memory.
+// memoryBackend is a storage backend backed by memory. Safe for concurrent use.
 type memoryBackend struct {
-	data map[string][]byte
+	mutex sync.RWMutex
+	data  map[string][]byte
 }
 
 func (b *memoryBackend) read(ctx context.Context, id string) ([]byte, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
 	result := b.data[id]
 	if result == nil {
 		return nil, fmt.Errorf("value %s does not exist", id)
@@ -83,21 +92,27 @@ func (b *memoryBackend) read(ctx context.Context, id string) ([]byte, error) {
 }
 
 func (b *memoryBackend) write(ctx context.Context, id string, contents []byte) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
 	b.data[id] = contents
 	return nil
 }
 
-func (b *memoryBackend) list(ctx context.Context, prefix string) ([]string, error) {
-	result := []string{}
-	for k := range b.data {
+func (b *memoryBackend) list(ctx context.Context, prefix string) (map[string]int64, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	result := map[string]int64{}
+	for k, v := range b.data {
 		if strings.HasPrefix(k, prefix) {
-			result = append(result, k)
+			result[k] = int64(len(v))
 		}
 	}
 	return result, nil
 }
 
 func (b *memoryBackend) delete(ctx context.Context, id string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
 	_, ok := b.data[id]
 	if !ok {
 		return fmt.Errorf("key %s not found", id)
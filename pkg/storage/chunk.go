@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/dinowernli/almanac/pkg/index"
+	"github.com/dinowernli/almanac/pkg/tenant"
 	"github.com/dinowernli/almanac/pkg/util"
 	pb_almanac "github.com/dinowernli/almanac/proto"
 
@@ -14,9 +15,10 @@ import (
 )
 
 const (
-	chunkIdFormat    = "%3s-%d-%d-%s"
-	chunkIdSeparator = "-"
-	chunkUidLength   = 5
+	chunkIdFormat          = "%3s-%d-%d-%s"
+	chunkIdSeparator       = "-"
+	chunkIdTenantSeparator = "."
+	chunkUidLength         = 5
 )
 
 var (
@@ -34,6 +36,10 @@ func ChunkId(idProto *pb_almanac.ChunkId) (string, error) {
 		return "", fmt.Errorf("chunk uid cannot contain '-', but got: %s", idProto.Uid)
 	}
 
+	if strings.Contains(idProto.Uid, chunkIdTenantSeparator) {
+		return "", fmt.Errorf("chunk uid cannot contain '.', but got: %s", idProto.Uid)
+	}
+
 	if idProto.StartMs > idProto.EndMs {
 		return "", fmt.Errorf("invalid start and end times: start=%d, end=%d", idProto.StartMs, idProto.EndMs)
 	}
@@ -43,20 +49,41 @@ func ChunkId(idProto *pb_almanac.ChunkId) (string, error) {
 		return "", fmt.Errorf("unknown chunk type: %v", idProto.Type)
 	}
 
-	return fmt.Sprintf(chunkIdFormat, typeString, idProto.StartMs, idProto.EndMs, idProto.Uid), nil
+	err := tenant.Validate(idProto.Tenant)
+	if err != nil {
+		return "", fmt.Errorf("unable to create chunk id: %v", err)
+	}
+
+	result := tenantPrefix(idProto.Tenant) + fmt.Sprintf(chunkIdFormat, typeString, idProto.StartMs, idProto.EndMs, idProto.Uid)
+	if len(idProto.Labels) > 0 {
+		result += chunkIdSeparator + EncodeLabels(idProto.Labels)
+	}
+	return result, nil
 }
 
 // ChunkIdProto returns the structured representation of the supplied chunk id.
 func ChunkIdProto(chunkId string) (*pb_almanac.ChunkId, error) {
+	tenantId, unprefixed := splitTenant(chunkId)
+
 	var uid string
 	var startMs int64
 	var endMs int64
 	var chunkType string
-	_, err := fmt.Sscanf(chunkId, chunkIdFormat, &chunkType, &startMs, &endMs, &uid)
+	_, err := fmt.Sscanf(unprefixed, chunkIdFormat, &chunkType, &startMs, &endMs, &uid)
 	if err != nil {
 		return nil, fmt.Errorf("unable to parse id [%s] with format [%s]: %v", chunkId, chunkIdFormat, err)
 	}
 
+	// The uid cannot contain the separator, so anything after it holds the labels.
+	var labels map[string]string
+	if i := strings.Index(uid, chunkIdSeparator); i >= 0 {
+		labels, err = decodeLabels(uid[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse labels of id [%s]: %v", chunkId, err)
+		}
+		uid = uid[:i]
+	}
+
 	typeEnum, ok := chunkTypeValue[chunkType]
 	if !ok {
 		return nil, fmt.Errorf("unknown chunk type string: %s", chunkType)
@@ -67,6 +94,8 @@ func ChunkIdProto(chunkId string) (*pb_almanac.ChunkId, error) {
 		EndMs:   endMs,
 		Uid:     uid,
 		Type:    typeEnum,
+		Labels:  labels,
+		Tenant:  tenantId,
 	}, nil
 }
 
@@ -147,12 +176,12 @@ func openChunk(chunkProto *pb_almanac.Chunk) (*Chunk, error) {
 	return &Chunk{id: chunkProto.Id, index: idx, entryMap: entryMap, entries: chunkProto.Entries}, nil
 }
 
-// Search returns all log entries in the chunk matching the supplied query, in ascending order by timestamp.
-func (c *Chunk) Search(ctx context.Context, query string, num int32, startMs int64, endMs int64) ([]*pb_almanac.LogEntry, error) {
+// Search returns all log entries in the chunk matching the supplied query and selector, in ascending order by timestamp.
+func (c *Chunk) Search(ctx context.Context, query string, num int32, startMs int64, endMs int64, selector *LabelSelector) ([]*pb_almanac.LogEntry, error) {
 	if c.closed {
 		return nil, fmt.Errorf("cannot execute search on closed chunk")
 	}
-	return Search(ctx, c.index, c.entryMap, query, num, startMs, endMs)
+	return Search(ctx, c.index, c.entryMap, query, num, startMs, endMs, selector)
 }
 
 // Entries returns all the entries in this chunk. Callers must not modify the return value.
@@ -165,6 +194,11 @@ func (c *Chunk) Id() *pb_almanac.ChunkId {
 	return c.id
 }
 
+// Index returns the index of this chunk. Callers must not modify or close the return value.
+func (c *Chunk) Index() *index.Index {
+	return c.index
+}
+
 // Close releases any resources associated with this chunk.
 func (c *Chunk) Close() error {
 	err := c.index.Close()
@@ -176,7 +210,8 @@ func (c *Chunk) Close() error {
 }
 
 // Search executes a search on a given index and entry map. Results are returned in ascending order by timestamp.
-func Search(ctx context.Context, idx *index.Index, entries map[string]*pb_almanac.LogEntry, query string, num int32, startMs int64, endMs int64) ([]*pb_almanac.LogEntry, error) {
+// The selector may be nil, in which case entries are not filtered by their labels.
+func Search(ctx context.Context, idx *index.Index, entries map[string]*pb_almanac.LogEntry, query string, num int32, startMs int64, endMs int64, selector *LabelSelector) ([]*pb_almanac.LogEntry, error) {
 	ids, err := idx.Search(ctx, query, num)
 	if err != nil {
 		return nil, fmt.Errorf("unable to search index: %v", err)
@@ -195,6 +230,13 @@ func Search(ctx context.Context, idx *index.Index, entries map[string]*pb_almana
 		if endMs != 0 && entry.TimestampMs > endMs {
 			continue
 		}
+		matches, err := selector.MatchesEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		if !matches {
+			continue
+		}
 		result = append(result, entry)
 		if int32(len(result)) >= num {
 			break
@@ -216,3 +258,28 @@ func (c *Chunk) fetchEntry(id string) (*pb_almanac.LogEntry, error) {
 	}
 	return result, nil
 }
+
+// tenantPrefix returns the string which precedes the ids of all chunks of the supplied tenant.
+func tenantPrefix(tenantId string) string {
+	if tenantId == tenant.Default {
+		return ""
+	}
+	return tenantId + chunkIdTenantSeparator
+}
+
+// splitTenant returns the tenant of the supplied chunk id and the remainder of the id. Chunks of
+// the default tenant start with their type, all others with the tenant and a separator. Tenants
+// cannot contain the separator, so a prefix only names a tenant if a chunk type follows it.
+func splitTenant(chunkId string) (string, string) {
+	i := strings.Index(chunkId, chunkIdTenantSeparator)
+	if i < 1 || tenant.Validate(chunkId[:i]) != nil {
+		return tenant.Default, chunkId
+	}
+	remainder := chunkId[i+1:]
+	for _, typeString := range chunkTypeString {
+		if strings.HasPrefix(remainder, typeString+chunkIdSeparator) {
+			return chunkId[:i], remainder
+		}
+	}
+	return tenant.Default, chunkId
+}
@@ -45,6 +45,60 @@ func TestRoundtrip(t *testing.T) {
 	assert.Equal(t, id, id2)
 }
 
+func TestRoundtripWithLabels(t *testing.T) {
+	idProto := &pb_almanac.ChunkId{
+		StartMs: 3,
+		EndMs:   7,
+		Uid:     "asdf",
+		Type:    pb_almanac.ChunkId_SMALL,
+		Labels:  map[string]string{"service": "web-frontend", "env": "prod/eu"},
+	}
+
+	id, err := ChunkId(idProto)
+	assert.NoError(t, err)
+	assert.Equal(t, "sml-3-7-asdf-env=prod%2Feu&service=web-frontend", id)
+
+	parsed, err := ChunkIdProto(id)
+	assert.NoError(t, err)
+	assert.Equal(t, idProto, parsed)
+
+	_, err = ChunkIdProto("sml-3-7-asdf-")
+	assert.Error(t, err)
+}
+
+func TestRoundtripWithTenant(t *testing.T) {
+	idProto := &pb_almanac.ChunkId{
+		StartMs: 3,
+		EndMs:   7,
+		Uid:     "asdf",
+		Type:    pb_almanac.ChunkId_BIG,
+		Labels:  map[string]string{"host": "web.eu"},
+		Tenant:  "acme",
+	}
+
+	id, err := ChunkId(idProto)
+	assert.NoError(t, err)
+	assert.Equal(t, "acme.big-3-7-asdf-host=web.eu", id)
+
+	parsed, err := ChunkIdProto(id)
+	assert.NoError(t, err)
+	assert.Equal(t, idProto, parsed)
+
+	// Tenants may look like chunk types, and labels may contain the tenant separator.
+	for _, tenantId := range []string{"sml-a", "big", ""} {
+		idProto.Tenant = tenantId
+		id, err := ChunkId(idProto)
+		assert.NoError(t, err)
+		parsed, err := ChunkIdProto(id)
+		assert.NoError(t, err)
+		assert.Equal(t, idProto, parsed, id)
+	}
+
+	idProto.Tenant = "Not.Valid"
+	_, err = ChunkId(idProto)
+	assert.Error(t, err)
+}
+
 func TestChunkProtoCreating(t *testing.T) {
 	entriesInput := []*pb_almanac.LogEntry{entry2, entry1}
 
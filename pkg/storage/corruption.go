@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	quarantinePrefix = "quarantine-"
+)
+
+// CorruptChunkError is returned when the stored bytes of a chunk cannot be turned back into a
+// chunk, e.g., because they were truncated or have been altered since being stored.
+type CorruptChunkError struct {
+	ChunkId *pb_almanac.ChunkId
+	Err     error
+}
+
+func (e *CorruptChunkError) Error() string {
+	return fmt.Sprintf("chunk %v is corrupt: %v", e.ChunkId, e.Err)
+}
+
+// IsCorruptChunk returns the corruption error if the supplied error was caused by a corrupt
+// chunk, or nil otherwise.
+func IsCorruptChunk(err error) *CorruptChunkError {
+	corruptErr, ok := err.(*CorruptChunkError)
+	if !ok {
+		return nil
+	}
+	return corruptErr
+}
+
+// QuarantineChunk moves the supplied chunk out of the searchable namespace, such that it no
+// longer shows up when listing chunks. The stored bytes are kept for later inspection.
+func (s *Storage) QuarantineChunk(ctx context.Context, chunkIdProto *pb_almanac.ChunkId) error {
+	chunkId, err := ChunkId(chunkIdProto)
+	if err != nil {
+		return fmt.Errorf("unable to extract chunk id: %v", err)
+	}
+
+	bytes, err := s.backend.read(ctx, chunkKey(chunkId))
+	s.metrics.numReads.Inc()
+	if err != nil {
+		return fmt.Errorf("unable to read chunk %s: %v", chunkId, err)
+	}
+
+	err = s.backend.write(ctx, quarantinePrefix+chunkId, bytes)
+	s.metrics.numWrites.Inc()
+	if err != nil {
+		return fmt.Errorf("unable to write quarantined chunk %s: %v", chunkId, err)
+	}
+
+	err = s.backend.delete(ctx, chunkKey(chunkId))
+	s.metrics.numDeletes.Inc()
+	if err != nil {
+		return fmt.Errorf("unable to delete chunk %s: %v", chunkId, err)
+	}
+	s.metrics.numQuarantines.Inc()
+	return nil
+}
+
+// ListQuarantinedChunks returns the ids of all chunks which have been quarantined.
+func (s *Storage) ListQuarantinedChunks(ctx context.Context) ([]string, error) {
+	keys, err := s.backend.list(ctx, quarantinePrefix)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list quarantined chunks: %v", err)
+	}
+
+	result := []string{}
+	for key := range keys {
+		result = append(result, strings.TrimPrefix(key, quarantinePrefix))
+	}
+	return result, nil
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/dinowernli/almanac/pkg/tenant"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestLoadCorruptChunk(t *testing.T) {
+	storage, chunkProto := storeCorruptChunk(t)
+
+	_, err := storage.LoadChunk(context.Background(), chunkProto.Id)
+	assert.Error(t, err)
+
+	corruptErr := IsCorruptChunk(err)
+	assert.NotNil(t, corruptErr)
+	assert.Equal(t, chunkProto.Id, corruptErr.ChunkId)
+}
+
+func TestLoadMissingChunkIsNotCorrupt(t *testing.T) {
+	storage, err := NewMemoryStorage()
+	assert.NoError(t, err)
+
+	chunkProto, err := ChunkProto([]*pb_almanac.LogEntry{entry}, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+
+	_, err = storage.LoadChunk(context.Background(), chunkProto.Id)
+	assert.Error(t, err)
+	assert.Nil(t, IsCorruptChunk(err))
+}
+
+func TestQuarantineChunk(t *testing.T) {
+	storage, chunkProto := storeCorruptChunk(t)
+
+	err := storage.QuarantineChunk(context.Background(), chunkProto.Id)
+	assert.NoError(t, err)
+
+	chunks, err := storage.ListChunks(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	assert.Empty(t, chunks)
+
+	chunkId, err := ChunkId(chunkProto.Id)
+	assert.NoError(t, err)
+
+	quarantined, err := storage.ListQuarantinedChunks(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{chunkId}, quarantined)
+}
+
+// storeCorruptChunk stores a chunk and then flips a bit in the middle of its stored bytes.
+func storeCorruptChunk(t *testing.T) (*Storage, *pb_almanac.Chunk) {
+	storage, err := NewMemoryStorage()
+	assert.NoError(t, err)
+
+	chunkProto, err := ChunkProto([]*pb_almanac.LogEntry{entry}, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+
+	chunkId, err := storage.StoreChunk(context.Background(), chunkProto)
+	assert.NoError(t, err)
+
+	bytes, err := storage.backend.read(context.Background(), chunkKey(chunkId))
+	assert.NoError(t, err)
+	bytes[len(bytes)/2] ^= 0x01
+	assert.NoError(t, storage.backend.write(context.Background(), chunkKey(chunkId), bytes))
+
+	return storage, chunkProto
+}
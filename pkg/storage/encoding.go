@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Chunks are stored in an envelope with the following layout:
+//
+//	magic (4 bytes) | version (1 byte) | codec (1 byte) | checksum (4 bytes) | body
+//
+// where the body is laid out as:
+//
+//	index length (uvarint) | index | entries
+//
+// The index is the marshalled BleveIndex proto, stored as is because it is already compressed.
+// The entries are the marshalled Chunk proto without its index, compressed using the codec. The
+// checksum is the big-endian CRC-32C of the version, the codec and the body. Chunks stored before
+// the envelope was introduced are plain marshalled Chunk protos. These can never start with the
+// magic bytes, so both kinds can be read side by side.
+const (
+	chunkMagic         = "ALMC"
+	chunkFormatVersion = 1
+
+	CodecNone   = "none"
+	CodecSnappy = "snappy"
+	CodecZstd   = "zstd"
+)
+
+// codec compresses and decompresses the entry payload of a chunk.
+type codec interface {
+	compress(data []byte) ([]byte, error)
+	decompress(data []byte) ([]byte, error)
+}
+
+var (
+	// codecIds holds the values used to identify each codec in the envelope. These must never
+	// change, since they are persisted.
+	codecIds = map[string]byte{CodecNone: 0, CodecSnappy: 1, CodecZstd: 2}
+	codecs   = map[byte]codec{0: &noneCodec{}, 1: &snappyCodec{}, 2: newZstdCodec()}
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// Codecs returns the names of all supported codecs.
+func Codecs() []string {
+	return []string{CodecNone, CodecSnappy, CodecZstd}
+}
+
+// encodeChunk returns the bytes to persist for the supplied chunk, using the supplied codec.
+func encodeChunk(chunk *pb_almanac.Chunk, codecName string) ([]byte, error) {
+	codecId, ok := codecIds[codecName]
+	if !ok {
+		return nil, fmt.Errorf("unknown codec: %s", codecName)
+	}
+
+	indexBytes, err := proto.Marshal(chunk.Index)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal index: %v", err)
+	}
+	entryBytes, err := proto.Marshal(&pb_almanac.Chunk{Id: chunk.Id, Entries: chunk.Entries})
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal entries: %v", err)
+	}
+	compressed, err := codecs[codecId].compress(entryBytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to compress entries with codec %s: %v", codecName, err)
+	}
+
+	lengthBytes := make([]byte, binary.MaxVarintLen64)
+	lengthBytes = lengthBytes[:binary.PutUvarint(lengthBytes, uint64(len(indexBytes)))]
+
+	body := bytes.NewBuffer(lengthBytes)
+	body.Write(indexBytes)
+	body.Write(compressed)
+
+	header := []byte{chunkFormatVersion, codecId}
+	checksum := make([]byte, 4)
+	binary.BigEndian.PutUint32(checksum, chunkChecksum(header, body.Bytes()))
+
+	result := bytes.NewBufferString(chunkMagic)
+	result.Write(header)
+	result.Write(checksum)
+	result.Write(body.Bytes())
+	return result.Bytes(), nil
+}
+
+// decodeChunk parses the supplied persisted bytes into a chunk proto. Supports both chunks in an
+// envelope and chunks stored before the envelope was introduced.
+func decodeChunk(data []byte) (*pb_almanac.Chunk, error) {
+	if !bytes.HasPrefix(data, []byte(chunkMagic)) {
+		chunk := &pb_almanac.Chunk{}
+		err := proto.Unmarshal(data, chunk)
+		if err != nil {
+			return nil, fmt.Errorf("unable to unmarshal legacy chunk: %v", err)
+		}
+		return chunk, nil
+	}
+
+	rest := data[len(chunkMagic):]
+	if len(rest) < 6 {
+		return nil, fmt.Errorf("chunk header truncated")
+	}
+	header := rest[:2]
+	expected := binary.BigEndian.Uint32(rest[2:6])
+	rest = rest[6:]
+
+	version, codecId := header[0], header[1]
+	if version != chunkFormatVersion {
+		return nil, fmt.Errorf("unsupported chunk format version: %d", version)
+	}
+	if actual := chunkChecksum(header, rest); actual != expected {
+		return nil, fmt.Errorf("checksum mismatch: expected %08x, got %08x", expected, actual)
+	}
+	c, ok := codecs[codecId]
+	if !ok {
+		return nil, fmt.Errorf("unknown codec id: %d", codecId)
+	}
+
+	indexLength, n := binary.Uvarint(rest)
+	if n <= 0 || uint64(len(rest)-n) < indexLength {
+		return nil, fmt.Errorf("invalid index length in chunk header")
+	}
+	rest = rest[n:]
+
+	index := &pb_almanac.BleveIndex{}
+	err := proto.Unmarshal(rest[:indexLength], index)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal index: %v", err)
+	}
+
+	entryBytes, err := c.decompress(rest[indexLength:])
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress entries: %v", err)
+	}
+	chunk := &pb_almanac.Chunk{}
+	err = proto.Unmarshal(entryBytes, chunk)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal entries: %v", err)
+	}
+	chunk.Index = index
+	return chunk, nil
+}
+
+// chunkChecksum returns the checksum of the supplied envelope header and body.
+func chunkChecksum(header []byte, body []byte) uint32 {
+	return crc32.Update(crc32.Checksum(header, crcTable), crcTable, body)
+}
+
+type noneCodec struct{}
+
+func (c *noneCodec) compress(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (c *noneCodec) decompress(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+type snappyCodec struct{}
+
+func (c *snappyCodec) compress(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+func (c *snappyCodec) decompress(data []byte) ([]byte, error) {
+	return snappy.Decode(nil, data)
+}
+
+// zstdCodec holds a single encoder and decoder, both of which are safe for concurrent use
+// through EncodeAll and DecodeAll.
+type zstdCodec struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+func newZstdCodec() *zstdCodec {
+	// These only fail for invalid options.
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		panic(err)
+	}
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		panic(err)
+	}
+	return &zstdCodec{encoder: encoder, decoder: decoder}
+}
+
+func (c *zstdCodec) compress(data []byte) ([]byte, error) {
+	return c.encoder.EncodeAll(data, nil), nil
+}
+
+func (c *zstdCodec) decompress(data []byte) ([]byte, error) {
+	return c.decoder.DecodeAll(data, nil)
+}
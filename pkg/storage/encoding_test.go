@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodingRoundTrip(t *testing.T) {
+	chunk, err := ChunkProto(realisticEntries(20), pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+
+	for _, codec := range Codecs() {
+		encoded, err := encodeChunk(chunk, codec)
+		assert.NoError(t, err)
+
+		decoded, err := decodeChunk(encoded)
+		assert.NoError(t, err)
+		assert.True(t, proto.Equal(chunk, decoded), "codec %s", codec)
+	}
+}
+
+func TestEncodingReadsLegacyChunks(t *testing.T) {
+	chunk, err := ChunkProto(realisticEntries(5), pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+
+	legacy, err := proto.Marshal(chunk)
+	assert.NoError(t, err)
+
+	decoded, err := decodeChunk(legacy)
+	assert.NoError(t, err)
+	assert.True(t, proto.Equal(chunk, decoded))
+}
+
+func TestEncodingRejectsBadInput(t *testing.T) {
+	chunk, err := ChunkProto(realisticEntries(5), pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+
+	_, err = encodeChunk(chunk, "lzma")
+	assert.Error(t, err)
+
+	encoded, err := encodeChunk(chunk, CodecZstd)
+	assert.NoError(t, err)
+
+	_, err = decodeChunk(encoded[:len(chunkMagic)+1])
+	assert.Error(t, err)
+
+	_, err = decodeChunk(encoded[:len(encoded)/2])
+	assert.Error(t, err)
+
+	flipped := append([]byte{}, encoded...)
+	flipped[len(flipped)-1] ^= 0x01
+	_, err = decodeChunk(flipped)
+	assert.Error(t, err)
+
+	// The checksum covers the codec, so a flipped codec is reported as a mismatch.
+	flippedCodec := append([]byte{}, encoded...)
+	flippedCodec[len(chunkMagic)+1] = codecIds[CodecSnappy]
+	_, err = decodeChunk(flippedCodec)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "checksum mismatch")
+
+	unknownVersion := append([]byte{}, encoded...)
+	unknownVersion[len(chunkMagic)] = chunkFormatVersion + 1
+	_, err = decodeChunk(unknownVersion)
+	assert.Error(t, err)
+}
+
+func TestStorageCodec(t *testing.T) {
+	storage, err := NewMemoryStorage()
+	assert.NoError(t, err)
+	assert.Error(t, storage.SetCodec("lzma"))
+	assert.NoError(t, storage.SetCodec(CodecSnappy))
+}
+
+func BenchmarkEncodeChunk(b *testing.B) {
+	chunk, err := ChunkProto(realisticEntries(1000), pb_almanac.ChunkId_BIG)
+	if err != nil {
+		b.Fatal(err)
+	}
+	legacy, err := proto.Marshal(chunk)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, codec := range Codecs() {
+		b.Run(codec, func(b *testing.B) {
+			var encoded []byte
+			b.SetBytes(int64(len(legacy)))
+			for i := 0; i < b.N; i++ {
+				encoded, err = encodeChunk(chunk, codec)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(legacy))/float64(len(encoded)), "ratio")
+		})
+	}
+}
+
+func BenchmarkDecodeChunk(b *testing.B) {
+	chunk, err := ChunkProto(realisticEntries(1000), pb_almanac.ChunkId_BIG)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, codec := range Codecs() {
+		encoded, err := encodeChunk(chunk, codec)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(codec, func(b *testing.B) {
+			b.SetBytes(int64(len(encoded)))
+			for i := 0; i < b.N; i++ {
+				_, err := decodeChunk(encoded)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// realisticEntries returns entries resembling the structured logs of a typical web service.
+func realisticEntries(num int) []*pb_almanac.LogEntry {
+	levels := []string{"INFO", "INFO", "INFO", "WARN", "ERROR"}
+	paths := []string{"/api/v1/users", "/api/v1/orders", "/healthz", "/api/v1/search"}
+	result := []*pb_almanac.LogEntry{}
+	for i := 0; i < num; i++ {
+		timestampMs := int64(1517000000000 + i*37)
+		entryJson := fmt.Sprintf(
+			`{"timestamp_ms":%d,"level":"%s","logger":"com.example.frontend.RequestHandler","message":"Handled request","method":"GET","path":"%s","status":%d,"latency_ms":%d,"request_id":"req-%08x","user_id":"user-%d","host":"frontend-%d.prod.example.com"}`,
+			timestampMs, levels[i%len(levels)], paths[i%len(paths)], 200+(i%3)*100, (i*7)%250, i*2654435761, i%97, i%5)
+		result = append(result, &pb_almanac.LogEntry{
+			Id:          fmt.Sprintf("%d-%03d", timestampMs, i%1000),
+			TimestampMs: timestampMs,
+			EntryJson:   entryJson,
+		})
+	}
+	return result
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"fmt"
+	"hash/crc32"
 	"io/ioutil"
 	"time"
 
@@ -48,21 +49,33 @@ func (b *gcsBackend) write(ctx context.Context, id string, contents []byte) erro
 	defer f()
 
 	w := b.bucket.Object(id).NewWriter(c)
-	defer w.Close()
+
+	// Have gcs verify the contents server-side, so that a corrupted upload is rejected.
+	w.CRC32C = crc32.Checksum(contents, crc32.MakeTable(crc32.Castagnoli))
+	w.SendCRC32C = true
 
 	_, err := w.Write(contents)
 	if err != nil {
+		// Cancelling the context before closing aborts the upload rather than finalizing it.
+		f()
+		w.Close()
 		return fmt.Errorf("unable to write to object %s: %v", id, err)
 	}
+
+	// The object only exists once the writer has been closed successfully.
+	err = w.Close()
+	if err != nil {
+		return fmt.Errorf("unable to finalize object %s: %v", id, err)
+	}
 	return nil
 }
 
-func (b *gcsBackend) list(ctx context.Context, prefix string) ([]string, error) {
+func (b *gcsBackend) list(ctx context.Context, prefix string) (map[string]int64, error) {
 	c, f := context.WithTimeout(ctx, gcsListTimeout)
 	defer f()
 
 	it := b.bucket.Objects(c, &storage.Query{Prefix: prefix})
-	result := []string{}
+	result := map[string]int64{}
 	for {
 		attributes, err := it.Next()
 		if err == iterator.Done {
@@ -71,7 +84,7 @@ func (b *gcsBackend) list(ctx context.Context, prefix string) ([]string, error)
 		if err != nil {
 			return nil, fmt.Errorf("unable to list objects with prefix %s: %v", prefix, err)
 		}
-		result = append(result, attributes.Name)
+		result[attributes.Name] = attributes.Size
 	}
 	return result, nil
 }
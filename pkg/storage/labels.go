@@ -0,0 +1,316 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/scanner"
+	"unicode"
+
+	pb_almanac "github.com/dinowernli/almanac/proto"
+)
+
+var (
+	matcherTypeOperator = map[string]pb_almanac.LabelMatcher_Type{
+		"=":     pb_almanac.LabelMatcher_EQUAL,
+		"!=":    pb_almanac.LabelMatcher_NOT_EQUAL,
+		"=~":    pb_almanac.LabelMatcher_REGEX,
+		"!~":    pb_almanac.LabelMatcher_NOT_REGEX,
+		"in":    pb_almanac.LabelMatcher_IN,
+		"notin": pb_almanac.LabelMatcher_NOT_IN,
+	}
+)
+
+// EncodeLabels returns a canonical string representation of the supplied stream labels, such
+// that two sets of labels are equal if and only if their encodings are equal.
+func EncodeLabels(labels map[string]string) string {
+	values := url.Values{}
+	for name, value := range labels {
+		values.Set(name, value)
+	}
+	return values.Encode()
+}
+
+func decodeLabels(encoded string) (map[string]string, error) {
+	values, err := url.ParseQuery(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no labels in [%s]", encoded)
+	}
+
+	result := map[string]string{}
+	for name, v := range values {
+		if len(v) != 1 {
+			return nil, fmt.Errorf("expected a single value for label %s, but got %d", name, len(v))
+		}
+		result[name] = v[0]
+	}
+	return result, nil
+}
+
+// EntryLabels returns the values of the top-level fields with the supplied names in the supplied
+// json entry. Fields which are missing, or do not hold a string, number or boolean, are left out.
+func EntryLabels(entryJson string, names []string) (map[string]string, error) {
+	fields := map[string]interface{}{}
+	err := json.Unmarshal([]byte(entryJson), &fields)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse raw json: %v", err)
+	}
+
+	result := map[string]string{}
+	for _, name := range names {
+		switch value := fields[name].(type) {
+		case string:
+			result[name] = value
+		case float64:
+			result[name] = strconv.FormatFloat(value, 'f', -1, 64)
+		case bool:
+			result[name] = strconv.FormatBool(value)
+		}
+	}
+	return result, nil
+}
+
+// LabelSelector is the validated form of a label selector proto, ready to be evaluated against
+// chunks and entries. A nil selector matches everything.
+type LabelSelector struct {
+	names    []string
+	matchers []*labelMatcher
+}
+
+type labelMatcher struct {
+	name     string
+	negated  bool
+	value    string
+	regex    *regexp.Regexp
+	valueSet map[string]bool
+}
+
+// NewLabelSelector validates the supplied selector proto and returns an instance which can be
+// used for matching. Returns nil if the proto is nil or has no matchers.
+func NewLabelSelector(selectorProto *pb_almanac.LabelSelector) (*LabelSelector, error) {
+	if selectorProto == nil || len(selectorProto.Matchers) == 0 {
+		return nil, nil
+	}
+
+	result := &LabelSelector{}
+	seenNames := map[string]bool{}
+	for _, m := range selectorProto.Matchers {
+		if m.Name == "" {
+			return nil, fmt.Errorf("label matcher must have a name")
+		}
+		matcher := &labelMatcher{name: m.Name, value: m.Value}
+		switch m.Type {
+		case pb_almanac.LabelMatcher_EQUAL:
+		case pb_almanac.LabelMatcher_NOT_EQUAL:
+			matcher.negated = true
+		case pb_almanac.LabelMatcher_REGEX, pb_almanac.LabelMatcher_NOT_REGEX:
+			regex, err := regexp.Compile("^(?:" + m.Value + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex for label %s: %v", m.Name, err)
+			}
+			matcher.regex = regex
+			matcher.negated = m.Type == pb_almanac.LabelMatcher_NOT_REGEX
+		case pb_almanac.LabelMatcher_IN, pb_almanac.LabelMatcher_NOT_IN:
+			if len(m.Values) == 0 {
+				return nil, fmt.Errorf("set matcher for label %s must have at least one value", m.Name)
+			}
+			matcher.valueSet = map[string]bool{}
+			for _, v := range m.Values {
+				matcher.valueSet[v] = true
+			}
+			matcher.negated = m.Type == pb_almanac.LabelMatcher_NOT_IN
+		default:
+			return nil, fmt.Errorf("unknown type for matcher of label %s: %v", m.Name, m.Type)
+		}
+
+		result.matchers = append(result.matchers, matcher)
+		if !seenNames[m.Name] {
+			seenNames[m.Name] = true
+			result.names = append(result.names, m.Name)
+		}
+	}
+	return result, nil
+}
+
+// MatchesChunk returns false if the supplied chunk cannot hold any matching entries, based on its
+// stream labels. Matchers on labels which the chunk does not have cannot rule the chunk out.
+func (s *LabelSelector) MatchesChunk(chunkId *pb_almanac.ChunkId) bool {
+	if s == nil {
+		return true
+	}
+	for _, m := range s.matchers {
+		value, ok := chunkId.Labels[m.name]
+		if ok && !m.matches(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchesEntry returns whether the supplied entry matches all matchers of this selector.
+func (s *LabelSelector) MatchesEntry(entry *pb_almanac.LogEntry) (bool, error) {
+	if s == nil {
+		return true, nil
+	}
+	labels, err := EntryLabels(entry.EntryJson, s.names)
+	if err != nil {
+		return false, fmt.Errorf("unable to extract labels of entry %s: %v", entry.Id, err)
+	}
+	for _, m := range s.matchers {
+		if !m.matches(labels[m.name]) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (m *labelMatcher) matches(value string) bool {
+	var result bool
+	if m.regex != nil {
+		result = m.regex.MatchString(value)
+	} else if m.valueSet != nil {
+		result = m.valueSet[value]
+	} else {
+		result = value == m.value
+	}
+	return result != m.negated
+}
+
+// ParseLabelSelector parses the text form of a label selector, a comma-separated list of
+// matchers such as:
+//
+//	service="web", env!="dev", host=~"web-[0-9]+", region in ("eu", "us")
+//
+// The supported operators are =, !=, =~, !~, in and notin. Values which are plain identifiers or
+// numbers need not be quoted. Returns nil for an empty string.
+func ParseLabelSelector(text string) (*pb_almanac.LabelSelector, error) {
+	p := &selectorParser{}
+	p.scanner.Init(strings.NewReader(text))
+	p.scanner.Mode = scanner.ScanIdents | scanner.ScanInts | scanner.ScanFloats | scanner.ScanStrings | scanner.ScanRawStrings
+	p.scanner.IsIdentRune = func(ch rune, i int) bool {
+		return ch == '_' || unicode.IsLetter(ch) || (i > 0 && (unicode.IsDigit(ch) || ch == '.'))
+	}
+	p.scanner.Error = func(s *scanner.Scanner, msg string) {
+		p.fail("%s", msg)
+	}
+	p.next()
+
+	result := &pb_almanac.LabelSelector{}
+	for p.token != scanner.EOF && p.err == nil {
+		if len(result.Matchers) > 0 {
+			p.expect(",")
+		}
+		matcher := p.parseMatcher()
+		if p.err == nil {
+			result.Matchers = append(result.Matchers, matcher)
+		}
+	}
+	if p.err != nil {
+		return nil, fmt.Errorf("unable to parse label selector [%s]: %v", text, p.err)
+	}
+	if len(result.Matchers) == 0 {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// selectorParser is a recursive descent parser for the text form of label selectors. Once the
+// first error is recorded, all further parsing is a no-op.
+type selectorParser struct {
+	scanner scanner.Scanner
+	token   rune
+	text    string
+	err     error
+}
+
+func (p *selectorParser) parseMatcher() *pb_almanac.LabelMatcher {
+	if p.token != scanner.Ident {
+		p.fail("expected label name, but got %q", p.text)
+		return nil
+	}
+	result := &pb_almanac.LabelMatcher{Name: p.text}
+	p.next()
+
+	operator := p.text
+	if operator == "=" || operator == "!" {
+		// The scanner returns single characters, so look for the second half of the operator.
+		if following := p.scanner.Peek(); following == '=' || following == '~' {
+			p.next()
+			operator += p.text
+		}
+		p.next()
+	} else if p.token == scanner.Ident {
+		p.next()
+	}
+	matcherType, ok := matcherTypeOperator[operator]
+	if !ok {
+		p.fail("unknown operator %q for label %s", operator, result.Name)
+		return nil
+	}
+	result.Type = matcherType
+
+	if matcherType != pb_almanac.LabelMatcher_IN && matcherType != pb_almanac.LabelMatcher_NOT_IN {
+		result.Value = p.parseValue()
+		return result
+	}
+
+	p.expect("(")
+	for p.err == nil {
+		result.Values = append(result.Values, p.parseValue())
+		if p.text != "," {
+			break
+		}
+		p.next()
+	}
+	p.expect(")")
+	return result
+}
+
+func (p *selectorParser) parseValue() string {
+	switch p.token {
+	case scanner.Ident, scanner.Int, scanner.Float:
+		result := p.text
+		p.next()
+		return result
+	case scanner.String, scanner.RawString:
+		result, err := strconv.Unquote(p.text)
+		if err != nil {
+			p.fail("invalid string %s: %v", p.text, err)
+		}
+		p.next()
+		return result
+	}
+	p.fail("expected value, but got %q", p.text)
+	return ""
+}
+
+func (p *selectorParser) expect(text string) {
+	if p.text != text {
+		p.fail("expected %q, but got %q", text, p.text)
+		return
+	}
+	p.next()
+}
+
+func (p *selectorParser) next() {
+	if p.err != nil {
+		return
+	}
+	p.token = p.scanner.Scan()
+	p.text = p.scanner.TokenText()
+	if p.token == scanner.EOF {
+		p.text = "end of input"
+	}
+}
+
+func (p *selectorParser) fail(format string, args ...interface{}) {
+	if p.err == nil {
+		p.err = fmt.Errorf("at %d: %s", p.scanner.Position.Offset, fmt.Sprintf(format, args...))
+	}
+}
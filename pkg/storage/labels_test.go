@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"testing"
+
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntryLabels(t *testing.T) {
+	labels, err := EntryLabels(`{"service": "web", "code": 404, "ok": false, "nested": {"a": 1}}`, []string{"service", "code", "ok", "nested", "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"service": "web", "code": "404", "ok": "false"}, labels)
+
+	_, err = EntryLabels("not json", []string{"service"})
+	assert.Error(t, err)
+}
+
+func TestParseLabelSelector(t *testing.T) {
+	selector, err := ParseLabelSelector(`service="web", env!=dev, host=~"web-[0-9]+", host!~` + "`web-0`" + `, region in ("eu", us), zone notin (a)`)
+	assert.NoError(t, err)
+	assert.Equal(t, []*pb_almanac.LabelMatcher{
+		{Name: "service", Type: pb_almanac.LabelMatcher_EQUAL, Value: "web"},
+		{Name: "env", Type: pb_almanac.LabelMatcher_NOT_EQUAL, Value: "dev"},
+		{Name: "host", Type: pb_almanac.LabelMatcher_REGEX, Value: "web-[0-9]+"},
+		{Name: "host", Type: pb_almanac.LabelMatcher_NOT_REGEX, Value: "web-0"},
+		{Name: "region", Type: pb_almanac.LabelMatcher_IN, Values: []string{"eu", "us"}},
+		{Name: "zone", Type: pb_almanac.LabelMatcher_NOT_IN, Values: []string{"a"}},
+	}, selector.Matchers)
+
+	selector, err = ParseLabelSelector("  ")
+	assert.NoError(t, err)
+	assert.Nil(t, selector)
+
+	for _, text := range []string{`service`, `service=`, `service == "web"`, `service="web" env="prod"`, `region in ("eu"`, `="web"`, `service="web`} {
+		_, err := ParseLabelSelector(text)
+		assert.Error(t, err, text)
+	}
+}
+
+func TestLabelSelectorMatchesEntry(t *testing.T) {
+	selector := mustSelector(t, `service=~"web|api", env!="dev", code notin (500, 503)`)
+
+	for json, expected := range map[string]bool{
+		`{"service": "web", "env": "prod", "code": 200}`: true,
+		`{"service": "api"}`:                             true,
+		`{"service": "webby", "env": "prod"}`:            false,
+		`{"service": "web", "env": "dev"}`:               false,
+		`{"service": "web", "code": 503}`:                false,
+		`{"env": "prod"}`:                                false,
+	} {
+		matches, err := selector.MatchesEntry(&pb_almanac.LogEntry{Id: "id", EntryJson: json})
+		assert.NoError(t, err)
+		assert.Equal(t, expected, matches, json)
+	}
+
+	// A nil selector matches everything.
+	var empty *LabelSelector
+	matches, err := empty.MatchesEntry(&pb_almanac.LogEntry{EntryJson: "not json"})
+	assert.NoError(t, err)
+	assert.True(t, matches)
+}
+
+func TestLabelSelectorMatchesChunk(t *testing.T) {
+	selector := mustSelector(t, `service="web", env in (prod, staging)`)
+
+	assert.True(t, selector.MatchesChunk(&pb_almanac.ChunkId{Labels: map[string]string{"service": "web", "env": "prod"}}))
+	assert.False(t, selector.MatchesChunk(&pb_almanac.ChunkId{Labels: map[string]string{"service": "db", "env": "prod"}}))
+	assert.False(t, selector.MatchesChunk(&pb_almanac.ChunkId{Labels: map[string]string{"env": "dev"}}))
+
+	// Chunks without the labels may still hold matching entries.
+	assert.True(t, selector.MatchesChunk(&pb_almanac.ChunkId{Labels: map[string]string{"env": "staging"}}))
+	assert.True(t, selector.MatchesChunk(&pb_almanac.ChunkId{}))
+}
+
+func TestNewLabelSelectorValidates(t *testing.T) {
+	for _, matcher := range []*pb_almanac.LabelMatcher{
+		{Type: pb_almanac.LabelMatcher_EQUAL, Value: "web"},
+		{Name: "service", Value: "web"},
+		{Name: "service", Type: pb_almanac.LabelMatcher_REGEX, Value: "(web"},
+		{Name: "service", Type: pb_almanac.LabelMatcher_IN},
+	} {
+		_, err := NewLabelSelector(&pb_almanac.LabelSelector{Matchers: []*pb_almanac.LabelMatcher{matcher}})
+		assert.Error(t, err, matcher.String())
+	}
+
+	selector, err := NewLabelSelector(&pb_almanac.LabelSelector{})
+	assert.NoError(t, err)
+	assert.Nil(t, selector)
+}
+
+func mustSelector(t *testing.T, text string) *LabelSelector {
+	selectorProto, err := ParseLabelSelector(text)
+	assert.NoError(t, err)
+	selector, err := NewLabelSelector(selectorProto)
+	assert.NoError(t, err)
+	return selector
+}
@@ -4,12 +4,13 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
 
+	"github.com/dinowernli/almanac/pkg/tenant"
 	"github.com/dinowernli/almanac/pkg/util"
 	pb_almanac "github.com/dinowernli/almanac/proto"
 
-	"github.com/golang/protobuf/proto"
 	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/net/context"
 )
@@ -28,6 +29,9 @@ type storageMetrics struct {
 	numReads   prometheus.Counter
 	numWrites  prometheus.Counter
 	numDeletes prometheus.Counter
+
+	numCorruptions prometheus.Counter
+	numQuarantines prometheus.Counter
 }
 
 // newStorageMetrics returns a struct with metrics registered in the default registry.
@@ -66,6 +70,22 @@ func newStorageMetrics() (*storageMetrics, error) {
 		return nil, err
 	}
 
+	result.numCorruptions = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "almanac_storage_corrupt_chunks",
+		Help: "The number of times a chunk loaded from the storage backend turned out to be corrupt",
+	})
+	if err := util.RegisterLenient(result.numCorruptions); err != nil {
+		return nil, err
+	}
+
+	result.numQuarantines = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "almanac_storage_quarantines",
+		Help: "The number of chunks moved into quarantine",
+	})
+	if err := util.RegisterLenient(result.numQuarantines); err != nil {
+		return nil, err
+	}
+
 	return result, nil
 }
 
@@ -74,31 +94,103 @@ func newStorageMetrics() (*storageMetrics, error) {
 type Storage struct {
 	backend backend
 	metrics *storageMetrics
+
+	// codec is the name of the codec used to compress newly stored chunks.
+	codec string
+}
+
+// ChunkInfo holds information about a stored chunk which is available without
+// loading the chunk.
+type ChunkInfo struct {
+	Id        *pb_almanac.ChunkId
+	SizeBytes int64
+}
+
+// ListChunks returns the ids of all stored chunks of the supplied tenant which
+// overlap with the supplied time range (inclusive on both ends).
+func (s *Storage) ListChunks(ctx context.Context, tenantId string, startMs int64, endMs int64, chunkType pb_almanac.ChunkId_Type) ([]string, error) {
+	chunkSizes, err := s.listChunkSizes(ctx, tenantId, chunkType)
+	if err != nil {
+		return nil, err
+	}
+	results := []string{}
+	for chunkId := range chunkSizes {
+		results = append(results, chunkId)
+	}
+	return results, nil
+}
+
+// ListChunkInfos returns information about all stored chunks of the supplied
+// tenant which overlap with the supplied time range (inclusive on both ends).
+func (s *Storage) ListChunkInfos(ctx context.Context, tenantId string, startMs int64, endMs int64, chunkType pb_almanac.ChunkId_Type) ([]*ChunkInfo, error) {
+	chunkSizes, err := s.listChunkSizes(ctx, tenantId, chunkType)
+	if err != nil {
+		return nil, err
+	}
+	results := []*ChunkInfo{}
+	for chunkId, size := range chunkSizes {
+		idProto, err := ChunkIdProto(chunkId)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse chunk id %s: %v", chunkId, err)
+		}
+		results = append(results, &ChunkInfo{Id: idProto, SizeBytes: size})
+	}
+	return results, nil
+}
+
+// ListTenants returns all tenants which have at least one stored chunk.
+func (s *Storage) ListTenants(ctx context.Context) ([]string, error) {
+	chunkPaths, err := s.backend.list(ctx, chunkPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list chunks: %v", err)
+	}
+	seen := map[string]bool{}
+	results := []string{}
+	for path := range chunkPaths {
+		tenantId, _ := splitTenant(strings.TrimPrefix(path, chunkPrefix))
+		if !seen[tenantId] {
+			seen[tenantId] = true
+			results = append(results, tenantId)
+		}
+	}
+	sort.Strings(results)
+	return results, nil
 }
 
-// ListChunks returns the ids of all stored chunks which overlap with the
-// supplied time range (inclusive on both ends).
-func (s *Storage) ListChunks(ctx context.Context, startMs int64, endMs int64, chunkType pb_almanac.ChunkId_Type) ([]string, error) {
+// listChunkSizes returns the ids of all stored chunks of the supplied tenant
+// and type, mapped to their size in bytes.
+func (s *Storage) listChunkSizes(ctx context.Context, tenantId string, chunkType pb_almanac.ChunkId_Type) (map[string]int64, error) {
 	chunkTypeString, ok := chunkTypeString[chunkType]
 	if !ok {
 		return nil, fmt.Errorf("unknown chunk type: %v", chunkType)
 	}
+	err := tenant.Validate(tenantId)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list chunks: %v", err)
+	}
 
 	// TODO(dino): Actually respect the start and end times. For now, return all chunks.
-	chunkPaths, err := s.backend.list(ctx, chunkPrefix+chunkTypeString)
+	chunkPaths, err := s.backend.list(ctx, chunkPrefix+tenantPrefix(tenantId)+chunkTypeString+chunkIdSeparator)
 	s.metrics.numLists.With(prometheus.Labels{chunkTypeLabel: chunkTypeString})
 	if err != nil {
 		return nil, fmt.Errorf("unable to list chunks: %v", err)
 	}
-	results := []string{}
-	for _, path := range chunkPaths {
-		results = append(results, strings.TrimPrefix(path, chunkPrefix))
+	results := map[string]int64{}
+	for path, size := range chunkPaths {
+		chunkId := strings.TrimPrefix(path, chunkPrefix)
+
+		// A tenant such as "sml-a" shares its prefix with the default tenant, so check explicitly.
+		if owner, _ := splitTenant(chunkId); owner != tenantId {
+			continue
+		}
+		results[chunkId] = size
 	}
 	return results, nil
 }
 
 // LoadChunk loads the chunk with the supplied id. The returned chunk uses
-// resources which must be freed once it is no longer in use.
+// resources which must be freed once it is no longer in use. If the stored
+// chunk is corrupt, the returned error is a *CorruptChunkError.
 func (s *Storage) LoadChunk(ctx context.Context, chunkIdProto *pb_almanac.ChunkId) (*Chunk, error) {
 	chunkId, err := ChunkId(chunkIdProto)
 	if err != nil {
@@ -111,12 +203,28 @@ func (s *Storage) LoadChunk(ctx context.Context, chunkIdProto *pb_almanac.ChunkI
 		return nil, fmt.Errorf("failed to read chunk %s: %v", chunkId, err)
 	}
 
-	chunk := &pb_almanac.Chunk{}
-	err = proto.Unmarshal(bytes, chunk)
+	chunk, err := decodeChunk(bytes)
+	if err != nil {
+		s.metrics.numCorruptions.Inc()
+		return nil, &CorruptChunkError{ChunkId: chunkIdProto, Err: fmt.Errorf("failed to decode: %v", err)}
+	}
+	result, err := openChunk(chunk)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal chunk %s: %v", chunkId, err)
+		s.metrics.numCorruptions.Inc()
+		return nil, &CorruptChunkError{ChunkId: chunkIdProto, Err: fmt.Errorf("failed to open: %v", err)}
 	}
-	return openChunk(chunk)
+	return result, nil
+}
+
+// SetCodec changes the codec used to compress chunks stored from now on. Chunks which have
+// already been stored remain readable regardless of the codec. Must not be called concurrently
+// with storing chunks.
+func (s *Storage) SetCodec(codecName string) error {
+	if _, ok := codecIds[codecName]; !ok {
+		return fmt.Errorf("unknown codec: %s", codecName)
+	}
+	s.codec = codecName
+	return nil
 }
 
 // StoreChunk persists the supplied chunk proto in storage. Returns the id used
@@ -127,9 +235,9 @@ func (s *Storage) StoreChunk(ctx context.Context, chunkProto *pb_almanac.Chunk)
 		return "", fmt.Errorf("unable to extract chunk id: %v", err)
 	}
 
-	bytes, err := proto.Marshal(chunkProto)
+	bytes, err := encodeChunk(chunkProto, s.codec)
 	if err != nil {
-		return "", fmt.Errorf("unable to marshal chunk proto: %v", err)
+		return "", fmt.Errorf("unable to encode chunk proto: %v", err)
 	}
 
 	err = s.backend.write(ctx, chunkKey(chunkId), bytes)
@@ -167,6 +275,32 @@ func NewDiskStorage(path string) (*Storage, error) {
 	return newStorage(&diskBackend{path: path})
 }
 
+// Open returns a storage of the supplied type which may already hold chunks stored previously,
+// e.g., by a running system. Memory storage cannot be opened since it never outlives a process.
+func Open(storageType string, gcsBucket string, diskPath string) (*Storage, error) {
+	switch storageType {
+	case StorageTypeDisk:
+		return OpenDiskStorage(diskPath)
+	case StorageTypeGcs:
+		return NewGcsStorage(gcsBucket)
+	default:
+		return nil, fmt.Errorf("cannot open existing storage of type: %s", storageType)
+	}
+}
+
+// OpenDiskStorage returns a storage backed by an existing root directory on disk, which may
+// already hold chunks stored previously.
+func OpenDiskStorage(path string) (*Storage, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to stat path %s: %v", path, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("expected path %s to be a directory, but wasn't", path)
+	}
+	return newStorage(&diskBackend{path: path})
+}
+
 // NewInMemoryStorage returns a storage backed by an in-memory map.
 func NewMemoryStorage() (*Storage, error) {
 	return newStorage(&memoryBackend{data: map[string][]byte{}})
@@ -186,7 +320,7 @@ func newStorage(b backend) (*Storage, error) {
 	if err != nil {
 		return nil, fmt.Errorf("unable to create storage metrics: %v", err)
 	}
-	return &Storage{metrics: m, backend: b}, nil
+	return &Storage{metrics: m, backend: b, codec: CodecZstd}, nil
 }
 
 func chunkKey(chunkId string) string {
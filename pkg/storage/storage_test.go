@@ -3,6 +3,7 @@ package storage
 import (
 	"testing"
 
+	"github.com/dinowernli/almanac/pkg/tenant"
 	pb_almanac "github.com/dinowernli/almanac/proto"
 
 	"reflect"
@@ -50,15 +51,57 @@ func TestList(t *testing.T) {
 	_, err = storage.StoreChunk(context.Background(), chunkProto)
 	assert.NoError(t, err)
 
-	smallChunks, err := storage.ListChunks(context.Background(), 0, 0, pb_almanac.ChunkId_SMALL)
+	smallChunks, err := storage.ListChunks(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_SMALL)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(smallChunks))
 
-	bigChunks, err := storage.ListChunks(context.Background(), 0, 0, pb_almanac.ChunkId_BIG)
+	bigChunks, err := storage.ListChunks(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_BIG)
 	assert.NoError(t, err)
 	assert.Empty(t, bigChunks)
 }
 
+func TestListChunkInfos(t *testing.T) {
+	storage, err := NewMemoryStorage()
+	assert.NoError(t, err)
+
+	chunkProto, err := ChunkProto([]*pb_almanac.LogEntry{entry}, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+
+	_, err = storage.StoreChunk(context.Background(), chunkProto)
+	assert.NoError(t, err)
+
+	infos, err := storage.ListChunkInfos(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(infos))
+	assert.Equal(t, chunkProto.Id, infos[0].Id)
+	assert.True(t, infos[0].SizeBytes > 0)
+}
+
+func TestListSeparatesTenants(t *testing.T) {
+	storage, err := NewMemoryStorage()
+	assert.NoError(t, err)
+
+	ids := map[string]string{}
+	for _, tenantId := range []string{tenant.Default, "acme", "sml-a"} {
+		chunkProto, err := ChunkProto([]*pb_almanac.LogEntry{entry}, pb_almanac.ChunkId_SMALL)
+		assert.NoError(t, err)
+		chunkProto.Id.Tenant = tenantId
+
+		ids[tenantId], err = storage.StoreChunk(context.Background(), chunkProto)
+		assert.NoError(t, err)
+	}
+
+	for tenantId, id := range ids {
+		chunks, err := storage.ListChunks(context.Background(), tenantId, 0, 0, pb_almanac.ChunkId_SMALL)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{id}, chunks, tenantId)
+	}
+
+	tenants, err := storage.ListTenants(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{tenant.Default, "acme", "sml-a"}, tenants)
+}
+
 func TestDelete(t *testing.T) {
 	storage, err := NewMemoryStorage()
 	assert.NoError(t, err)
@@ -73,7 +116,7 @@ func TestDelete(t *testing.T) {
 	_, err = storage.StoreChunk(context.Background(), chunkProto)
 	assert.NoError(t, err)
 
-	smallChunks, err := storage.ListChunks(context.Background(), 0, 0, pb_almanac.ChunkId_SMALL)
+	smallChunks, err := storage.ListChunks(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_SMALL)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(smallChunks))
 
@@ -81,7 +124,7 @@ func TestDelete(t *testing.T) {
 	err = storage.DeleteChunk(context.Background(), chunkProto.Id)
 	assert.NoError(t, err)
 
-	smallChunks, err = storage.ListChunks(context.Background(), 0, 0, pb_almanac.ChunkId_SMALL)
+	smallChunks, err = storage.ListChunks(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_SMALL)
 	assert.NoError(t, err)
 	assert.Empty(t, smallChunks)
 }
@@ -0,0 +1,253 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/dinowernli/almanac/pkg/index"
+	"github.com/dinowernli/almanac/pkg/util"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+)
+
+const (
+	tombstonePrefix   = "tombstone-"
+	auditRecordPrefix = "audit-"
+
+	// RedactedValue replaces the values of redacted fields.
+	RedactedValue = "REDACTED"
+)
+
+// StoreTombstone persists the supplied tombstone.
+func (s *Storage) StoreTombstone(ctx context.Context, tombstone *pb_almanac.Tombstone) error {
+	if tombstone.Id == "" {
+		return fmt.Errorf("cannot store tombstone with empty id")
+	}
+	return s.writeProto(ctx, tombstonePrefix+tombstone.Id, tombstone)
+}
+
+// ListTombstones returns all stored tombstones.
+func (s *Storage) ListTombstones(ctx context.Context) ([]*pb_almanac.Tombstone, error) {
+	ids, err := s.ListTombstoneIds(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*pb_almanac.Tombstone{}
+	for _, id := range ids {
+		tombstone, err := s.ReadTombstone(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, tombstone)
+	}
+	return result, nil
+}
+
+// ListTombstoneIds returns the ids of all stored tombstones, without reading them.
+func (s *Storage) ListTombstoneIds(ctx context.Context) ([]string, error) {
+	keys, err := s.backend.list(ctx, tombstonePrefix)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list tombstones: %v", err)
+	}
+
+	result := []string{}
+	for key := range keys {
+		result = append(result, strings.TrimPrefix(key, tombstonePrefix))
+	}
+	return result, nil
+}
+
+// ReadTombstone returns the stored tombstone with the supplied id.
+func (s *Storage) ReadTombstone(ctx context.Context, id string) (*pb_almanac.Tombstone, error) {
+	tombstone := &pb_almanac.Tombstone{}
+	err := s.readProto(ctx, tombstonePrefix+id, tombstone)
+	if err != nil {
+		return nil, err
+	}
+	return tombstone, nil
+}
+
+// StoreAuditRecord persists the supplied audit record.
+func (s *Storage) StoreAuditRecord(ctx context.Context, record *pb_almanac.AuditRecord) error {
+	oldChunkId, err := ChunkId(record.OldChunkId)
+	if err != nil {
+		return fmt.Errorf("unable to extract chunk id: %v", err)
+	}
+	return s.writeProto(ctx, auditRecordPrefix+record.TombstoneId+"-"+oldChunkId, record)
+}
+
+// ListAuditRecords returns all stored audit records. If the supplied tombstone id is not empty,
+// only the records caused by that tombstone are returned.
+func (s *Storage) ListAuditRecords(ctx context.Context, tombstoneId string) ([]*pb_almanac.AuditRecord, error) {
+	prefix := auditRecordPrefix
+	if tombstoneId != "" {
+		prefix = auditRecordPrefix + tombstoneId + "-"
+	}
+	keys, err := s.backend.list(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list audit records: %v", err)
+	}
+
+	result := []*pb_almanac.AuditRecord{}
+	for key := range keys {
+		record := &pb_almanac.AuditRecord{}
+		err := s.readProto(ctx, key, record)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, record)
+	}
+	return result, nil
+}
+
+func (s *Storage) writeProto(ctx context.Context, key string, message proto.Message) error {
+	bytes, err := proto.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("unable to marshal proto: %v", err)
+	}
+	err = s.backend.write(ctx, key, bytes)
+	if err != nil {
+		return fmt.Errorf("unable to write %s to backend: %v", key, err)
+	}
+	return nil
+}
+
+func (s *Storage) readProto(ctx context.Context, key string, message proto.Message) error {
+	bytes, err := s.backend.read(ctx, key)
+	if err != nil {
+		return fmt.Errorf("unable to read %s from backend: %v", key, err)
+	}
+	err = proto.Unmarshal(bytes, message)
+	if err != nil {
+		return fmt.Errorf("unable to unmarshal %s: %v", key, err)
+	}
+	return nil
+}
+
+// TombstoneOverlaps returns whether the supplied tombstone can affect entries in the supplied
+// time range (inclusive on both ends). An end of zero means the range is unbounded.
+func TombstoneOverlaps(tombstone *pb_almanac.Tombstone, startMs int64, endMs int64) bool {
+	if endMs != 0 && tombstone.StartMs > endMs {
+		return false
+	}
+	if tombstone.EndMs != 0 && tombstone.EndMs < startMs {
+		return false
+	}
+	return true
+}
+
+// ApplyTombstones returns the supplied entries with all tombstones applied, i.e., with matching
+// entries dropped or redacted. The order of the entries is preserved. Also returns the ids of
+// the affected entries, keyed by the id of the tombstone responsible. Entries which are already
+// in their purged state are not reported as affected.
+func ApplyTombstones(ctx context.Context, entries []*pb_almanac.LogEntry, tombstones []*pb_almanac.Tombstone) ([]*pb_almanac.LogEntry, map[string][]string, error) {
+	affected := map[string][]string{}
+	if len(entries) == 0 || len(tombstones) == 0 {
+		return entries, affected, nil
+	}
+
+	idx, err := index.NewIndex()
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create index: %v", err)
+	}
+	defer idx.Close()
+
+	entryMap := map[string]*pb_almanac.LogEntry{}
+	for _, e := range entries {
+		entryMap[e.Id] = e
+		err := idx.Index(e.Id, e.EntryJson)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to index entry: %v", err)
+		}
+	}
+
+	dropped := map[string]struct{}{}
+	redacted := map[string]*pb_almanac.LogEntry{}
+	for _, t := range tombstones {
+		matches, err := Search(ctx, idx, entryMap, t.Query, int32(len(entries)), t.StartMs, t.EndMs, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to search for tombstone %s: %v", t.Id, err)
+		}
+
+		for _, m := range matches {
+			if _, ok := dropped[m.Id]; ok {
+				continue
+			}
+			switch t.Action {
+			case pb_almanac.PurgeAction_DROP:
+				dropped[m.Id] = struct{}{}
+				affected[t.Id] = append(affected[t.Id], m.Id)
+			case pb_almanac.PurgeAction_REDACT:
+				current, ok := redacted[m.Id]
+				if !ok {
+					current = m
+				}
+				result, err := redactEntry(current, t.RedactFields)
+				if err != nil {
+					return nil, nil, fmt.Errorf("unable to redact entry %s: %v", m.Id, err)
+				}
+				if result.EntryJson != current.EntryJson {
+					redacted[m.Id] = result
+					affected[t.Id] = append(affected[t.Id], m.Id)
+				}
+			default:
+				return nil, nil, fmt.Errorf("unknown action %v in tombstone %s", t.Action, t.Id)
+			}
+		}
+	}
+
+	result := []*pb_almanac.LogEntry{}
+	for _, e := range entries {
+		if _, ok := dropped[e.Id]; ok {
+			continue
+		}
+		if r, ok := redacted[e.Id]; ok {
+			result = append(result, r)
+			continue
+		}
+		result = append(result, e)
+	}
+	return result, affected, nil
+}
+
+// redactEntry returns a copy of the supplied entry with the values of all the supplied
+// top-level fields replaced.
+func redactEntry(entry *pb_almanac.LogEntry, fields []string) (*pb_almanac.LogEntry, error) {
+	var rawEntry map[string]*json.RawMessage
+	err := json.Unmarshal([]byte(entry.EntryJson), &rawEntry)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse entry json: %v", err)
+	}
+
+	redactedValue := json.RawMessage(fmt.Sprintf("%q", RedactedValue))
+	changed := false
+	for _, f := range fields {
+		value, ok := rawEntry[f]
+		if !ok {
+			continue
+		}
+		if value != nil && strings.TrimSpace(string(*value)) == string(redactedValue) {
+			continue
+		}
+		rawEntry[f] = &redactedValue
+		changed = true
+	}
+	if !changed {
+		return entry, nil
+	}
+
+	bytes, err := json.Marshal(rawEntry)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal redacted entry: %v", err)
+	}
+	return &pb_almanac.LogEntry{Id: entry.Id, TimestampMs: entry.TimestampMs, EntryJson: string(bytes)}, nil
+}
+
+// NewTombstoneId returns a unique id for a tombstone created at the supplied time.
+func NewTombstoneId(createdMs int64) string {
+	return fmt.Sprintf("%d-%s", createdMs, util.RandomString(chunkUidLength))
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/dinowernli/almanac/pkg/tenant"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+var (
+	aliceEntry = &pb_almanac.LogEntry{Id: "id1", TimestampMs: 100, EntryJson: `{"user":"alice","message":"foo"}`}
+	bobEntry   = &pb_almanac.LogEntry{Id: "id2", TimestampMs: 200, EntryJson: `{"user":"bob","message":"foo"}`}
+	lateEntry  = &pb_almanac.LogEntry{Id: "id3", TimestampMs: 900, EntryJson: `{"user":"alice","message":"bar"}`}
+)
+
+func TestTombstoneRoundTrip(t *testing.T) {
+	storage, err := NewMemoryStorage()
+	assert.NoError(t, err)
+
+	tombstone := &pb_almanac.Tombstone{Id: NewTombstoneId(123), Query: "alice", Action: pb_almanac.PurgeAction_DROP}
+	assert.NoError(t, storage.StoreTombstone(context.Background(), tombstone))
+
+	tombstones, err := storage.ListTombstones(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []*pb_almanac.Tombstone{tombstone}, tombstones)
+
+	ids, err := storage.ListTombstoneIds(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{tombstone.Id}, ids)
+	read, err := storage.ReadTombstone(context.Background(), tombstone.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, tombstone, read)
+
+	// Tombstones must not show up as chunks.
+	chunks, err := storage.ListChunks(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	assert.Empty(t, chunks)
+}
+
+func TestAuditRecordRoundTrip(t *testing.T) {
+	storage, err := NewMemoryStorage()
+	assert.NoError(t, err)
+
+	chunkId := &pb_almanac.ChunkId{StartMs: 1, EndMs: 2, Uid: "abcde", Type: pb_almanac.ChunkId_SMALL}
+	record1 := &pb_almanac.AuditRecord{TombstoneId: "1-aaaaa", OldChunkId: chunkId, EntryIds: []string{"id1"}}
+	record2 := &pb_almanac.AuditRecord{TombstoneId: "2-bbbbb", OldChunkId: chunkId, EntryIds: []string{"id2"}}
+	assert.NoError(t, storage.StoreAuditRecord(context.Background(), record1))
+	assert.NoError(t, storage.StoreAuditRecord(context.Background(), record2))
+
+	records, err := storage.ListAuditRecords(context.Background(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(records))
+
+	records, err = storage.ListAuditRecords(context.Background(), "2-bbbbb")
+	assert.NoError(t, err)
+	assert.Equal(t, []*pb_almanac.AuditRecord{record2}, records)
+}
+
+func TestApplyTombstonesDrop(t *testing.T) {
+	tombstone := &pb_almanac.Tombstone{Id: "t1", Query: "alice", StartMs: 0, EndMs: 500, Action: pb_almanac.PurgeAction_DROP}
+
+	entries := []*pb_almanac.LogEntry{aliceEntry, bobEntry, lateEntry}
+	kept, affected, err := ApplyTombstones(context.Background(), entries, []*pb_almanac.Tombstone{tombstone})
+	assert.NoError(t, err)
+	assert.Equal(t, []*pb_almanac.LogEntry{bobEntry, lateEntry}, kept)
+	assert.Equal(t, map[string][]string{"t1": {"id1"}}, affected)
+}
+
+func TestApplyTombstonesRedact(t *testing.T) {
+	tombstone := &pb_almanac.Tombstone{Id: "t1", Query: "alice", Action: pb_almanac.PurgeAction_REDACT, RedactFields: []string{"user"}}
+
+	entries := []*pb_almanac.LogEntry{aliceEntry, bobEntry}
+	kept, affected, err := ApplyTombstones(context.Background(), entries, []*pb_almanac.Tombstone{tombstone})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(kept))
+	assert.Equal(t, `{"message":"foo","user":"REDACTED"}`, kept[0].EntryJson)
+	assert.Equal(t, aliceEntry.Id, kept[0].Id)
+	assert.Equal(t, bobEntry, kept[1])
+	assert.Equal(t, map[string][]string{"t1": {"id1"}}, affected)
+
+	// Applying the tombstone again must not report any changes.
+	_, affected, err = ApplyTombstones(context.Background(), kept, []*pb_almanac.Tombstone{tombstone})
+	assert.NoError(t, err)
+	assert.Empty(t, affected)
+}
+
+func TestTombstoneOverlaps(t *testing.T) {
+	tombstone := &pb_almanac.Tombstone{StartMs: 100, EndMs: 200}
+	assert.True(t, TombstoneOverlaps(tombstone, 150, 300))
+	assert.True(t, TombstoneOverlaps(tombstone, 0, 0))
+	assert.False(t, TombstoneOverlaps(tombstone, 201, 300))
+	assert.False(t, TombstoneOverlaps(tombstone, 10, 99))
+
+	unbounded := &pb_almanac.Tombstone{StartMs: 100}
+	assert.True(t, TombstoneOverlaps(unbounded, 5000, 6000))
+}
@@ -0,0 +1,210 @@
+package syslog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	nilValue = "-"
+
+	// rfc3164Timestamp is the layout of the timestamps of rfc 3164 messages, which have no year.
+	rfc3164Timestamp = time.Stamp
+)
+
+var (
+	facilities = []string{
+		"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron",
+		"authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+		"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+	}
+	severities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+)
+
+// message is a parsed syslog message. Fields absent from the message are empty.
+type message struct {
+	Facility       string                       `json:"facility"`
+	Severity       string                       `json:"severity"`
+	TimestampMs    int64                        `json:"timestamp_ms,omitempty"`
+	Hostname       string                       `json:"hostname,omitempty"`
+	AppName        string                       `json:"app_name,omitempty"`
+	ProcId         string                       `json:"proc_id,omitempty"`
+	MsgId          string                       `json:"msg_id,omitempty"`
+	StructuredData map[string]map[string]string `json:"structured_data,omitempty"`
+	Message        string                       `json:"message"`
+}
+
+// parseMessage parses the supplied syslog message, which can be in the format of either rfc 5424
+// or rfc 3164. The latter is parsed leniently, since senders rarely stick to it. The supplied
+// time is used to complete rfc 3164 timestamps, which have no year.
+func parseMessage(raw string, now time.Time) (*message, error) {
+	raw = strings.TrimRight(raw, "\r\n\x00")
+	if !strings.HasPrefix(raw, "<") {
+		return nil, fmt.Errorf("message does not start with a priority")
+	}
+	end := strings.Index(raw, ">")
+	if end < 2 || end > 4 {
+		return nil, fmt.Errorf("invalid priority")
+	}
+	priority, err := strconv.Atoi(raw[1:end])
+	if err != nil || priority < 0 || priority >= 8*len(facilities) {
+		return nil, fmt.Errorf("invalid priority %s", raw[1:end])
+	}
+
+	result := &message{Facility: facilities[priority/8], Severity: severities[priority%8]}
+	rest := raw[end+1:]
+	if strings.HasPrefix(rest, "1 ") {
+		err = parseRfc5424(rest[2:], result)
+	} else {
+		parseRfc3164(rest, now, result)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// parseRfc5424 parses everything following the version of an rfc 5424 message into the supplied
+// message.
+func parseRfc5424(rest string, result *message) error {
+	fields := strings.SplitN(rest, " ", 6)
+	if len(fields) < 6 {
+		return fmt.Errorf("expected timestamp, hostname, app name, proc id, msg id and structured data")
+	}
+
+	if fields[0] != nilValue {
+		timestamp, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return fmt.Errorf("invalid timestamp: %v", err)
+		}
+		result.TimestampMs = timestamp.UnixNano() / int64(time.Millisecond)
+	}
+	result.Hostname = nilToEmpty(fields[1])
+	result.AppName = nilToEmpty(fields[2])
+	result.ProcId = nilToEmpty(fields[3])
+	result.MsgId = nilToEmpty(fields[4])
+
+	data, msg, err := parseStructuredData(fields[5])
+	if err != nil {
+		return fmt.Errorf("invalid structured data: %v", err)
+	}
+	result.StructuredData = data
+
+	// The message may start with a byte order mark if it is utf-8.
+	result.Message = strings.TrimPrefix(msg, "\ufeff")
+	return nil
+}
+
+// parseStructuredData parses the structured data at the start of the supplied string, returning
+// it along with the message following it.
+func parseStructuredData(rest string) (map[string]map[string]string, string, error) {
+	if rest == nilValue || strings.HasPrefix(rest, nilValue+" ") {
+		return nil, strings.TrimPrefix(strings.TrimPrefix(rest, nilValue), " "), nil
+	}
+
+	result := map[string]map[string]string{}
+	i := 0
+	for i < len(rest) && rest[i] == '[' {
+		i++
+		start := i
+		for i < len(rest) && rest[i] != ' ' && rest[i] != ']' {
+			i++
+		}
+		id := rest[start:i]
+		if id == "" || i == len(rest) {
+			return nil, "", fmt.Errorf("invalid element at position %d", start)
+		}
+		params := map[string]string{}
+		for i < len(rest) && rest[i] == ' ' {
+			i++
+			start := i
+			for i < len(rest) && rest[i] != '=' {
+				i++
+			}
+			if i+1 >= len(rest) || rest[i+1] != '"' {
+				return nil, "", fmt.Errorf("invalid parameter at position %d", start)
+			}
+			name := rest[start:i]
+			i += 2
+
+			value := []byte{}
+			for i < len(rest) && rest[i] != '"' {
+				// Only ", \ and ] are escaped, other backslashes are kept as they are.
+				if rest[i] == '\\' && i+1 < len(rest) && strings.IndexByte(`"\]`, rest[i+1]) >= 0 {
+					i++
+				}
+				value = append(value, rest[i])
+				i++
+			}
+			if i == len(rest) {
+				return nil, "", fmt.Errorf("unterminated value of parameter %s", name)
+			}
+			i++
+			params[name] = string(value)
+		}
+		if i == len(rest) || rest[i] != ']' {
+			return nil, "", fmt.Errorf("unterminated element %s", id)
+		}
+		i++
+		result[id] = params
+	}
+	if i == 0 {
+		return nil, "", fmt.Errorf("expected [ or -")
+	}
+	if i < len(rest) && rest[i] != ' ' {
+		return nil, "", fmt.Errorf("expected space after structured data")
+	}
+	if i < len(rest) {
+		i++
+	}
+	return result, rest[i:], nil
+}
+
+// parseRfc3164 parses everything following the priority of an rfc 3164 message into the supplied
+// message. Anything which does not fit the format ends up in the message.
+func parseRfc3164(rest string, now time.Time, result *message) {
+	if len(rest) < len(rfc3164Timestamp)+1 {
+		result.Message = rest
+		return
+	}
+	timestamp, err := time.ParseInLocation(rfc3164Timestamp, rest[:len(rfc3164Timestamp)], now.Location())
+	if err != nil {
+		result.Message = rest
+		return
+	}
+
+	// Pick the year which puts the timestamp closest to now, such that messages sent just before
+	// new year are not placed in the future.
+	timestamp = timestamp.AddDate(now.Year(), 0, 0)
+	if timestamp.After(now.AddDate(0, 1, 0)) {
+		timestamp = timestamp.AddDate(-1, 0, 0)
+	}
+	result.TimestampMs = timestamp.UnixNano() / int64(time.Millisecond)
+
+	rest = strings.TrimPrefix(rest[len(rfc3164Timestamp):], " ")
+	if space := strings.Index(rest, " "); space > 0 && !strings.HasSuffix(rest[:space], ":") {
+		result.Hostname = rest[:space]
+		rest = rest[space+1:]
+	}
+
+	// The tag is the name of the sending program, optionally followed by its pid in brackets.
+	if colon := strings.Index(rest, ": "); colon > 0 && !strings.Contains(rest[:colon], " ") {
+		tag := rest[:colon]
+		if open := strings.Index(tag, "["); open > 0 && strings.HasSuffix(tag, "]") {
+			result.ProcId = tag[open+1 : len(tag)-1]
+			tag = tag[:open]
+		}
+		result.AppName = tag
+		rest = rest[colon+2:]
+	}
+	result.Message = rest
+}
+
+func nilToEmpty(value string) string {
+	if value == nilValue {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,90 @@
+package syslog
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	now = time.Date(2018, time.January, 2, 10, 0, 0, 0, time.UTC)
+)
+
+func TestParseRfc5424(t *testing.T) {
+	raw := `<165>1 2018-01-02T09:30:00.123Z router1 sshd 4242 ID47 [exampleSDID@32473 iut="3" eventSource="App\"lication"][meta seq="1"] ` + "\ufeff" + `user logged in` + "\n"
+	m, err := parseMessage(raw, now)
+	assert.NoError(t, err)
+
+	entry, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"facility": "local4",
+		"severity": "notice",
+		"timestamp_ms": 1514885400123,
+		"hostname": "router1",
+		"app_name": "sshd",
+		"proc_id": "4242",
+		"msg_id": "ID47",
+		"structured_data": {
+			"exampleSDID@32473": {"iut": "3", "eventSource": "App\"lication"},
+			"meta": {"seq": "1"}
+		},
+		"message": "user logged in"
+	}`, string(entry))
+}
+
+func TestParseRfc5424NilValues(t *testing.T) {
+	m, err := parseMessage(`<14>1 - - - - - -`, now)
+	assert.NoError(t, err)
+	assert.Equal(t, &message{Facility: "user", Severity: "info"}, m)
+
+	m, err = parseMessage(`<14>1 - host - - - - hello world`, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "host", m.Hostname)
+	assert.Equal(t, "hello world", m.Message)
+}
+
+func TestParseRfc3164(t *testing.T) {
+	m, err := parseMessage(`<34>Jan  2 09:30:00 mymachine su[230]: 'su root' failed for lonvick`, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "auth", m.Facility)
+	assert.Equal(t, "crit", m.Severity)
+	assert.Equal(t, time.Date(2018, time.January, 2, 9, 30, 0, 0, time.UTC).UnixNano()/int64(time.Millisecond), m.TimestampMs)
+	assert.Equal(t, "mymachine", m.Hostname)
+	assert.Equal(t, "su", m.AppName)
+	assert.Equal(t, "230", m.ProcId)
+	assert.Equal(t, "'su root' failed for lonvick", m.Message)
+
+	// Messages sent just before new year belong to the previous year.
+	m, err = parseMessage(`<13>Dec 31 23:59:59 host cron: tick`, now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2017, time.December, 31, 23, 59, 59, 0, time.UTC).UnixNano()/int64(time.Millisecond), m.TimestampMs)
+	assert.Equal(t, "cron", m.AppName)
+
+	// Anything which does not fit the format ends up in the message.
+	m, err = parseMessage(`<13>something happened`, now)
+	assert.NoError(t, err)
+	assert.Equal(t, &message{Facility: "user", Severity: "notice", Message: "something happened"}, m)
+}
+
+func TestParseInvalid(t *testing.T) {
+	invalid := []string{
+		``,
+		`no priority`,
+		`<>1 - - - - - -`,
+		`<192>1 - - - - - -`,
+		`<abc>1 - - - - - -`,
+		`<14>1 - - -`,
+		`<14>1 yesterday - - - - -`,
+		`<14>1 - - - - - [unterminated`,
+		`<14>1 - - - - - [id key="unterminated]`,
+		`<14>1 - - - - - [id key=unquoted]`,
+		`<14>1 - - - - - nodata`,
+	}
+	for _, raw := range invalid {
+		_, err := parseMessage(raw, now)
+		assert.Error(t, err, raw)
+	}
+}
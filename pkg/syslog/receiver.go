@@ -0,0 +1,293 @@
+// Package syslog receives syslog messages over udp, tcp and tcp with tls, and ingests each of
+// them as an entry whose fields hold the parts of the message.
+package syslog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dinowernli/almanac/pkg/util"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+const (
+	// maxMessageBytes is the size of the largest message accepted, which is also the largest
+	// payload of a udp datagram.
+	maxMessageBytes = 64 * 1024
+
+	transportLabel = "transport"
+	transportUdp   = "udp"
+	transportTcp   = "tcp"
+)
+
+var (
+	// All receivers in a process share the same metrics, such that their values add up.
+	metricsOnce      sync.Once
+	metricsInstance  *receiverMetrics
+	metricsCreateErr error
+)
+
+type receiverMetrics struct {
+	numReceived *prometheus.CounterVec
+	numRejected *prometheus.CounterVec
+}
+
+// sharedMetrics returns the metrics of all receivers in this process, registering them in the
+// default registry the first time around.
+func sharedMetrics() (*receiverMetrics, error) {
+	metricsOnce.Do(func() {
+		metricsInstance, metricsCreateErr = newReceiverMetrics()
+	})
+	return metricsInstance, metricsCreateErr
+}
+
+// newReceiverMetrics returns a struct with metrics registered in the default registry.
+func newReceiverMetrics() (*receiverMetrics, error) {
+	result := &receiverMetrics{}
+
+	result.numReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "almanac_syslog_received_messages",
+		Help: "The number of syslog messages received",
+	}, []string{transportLabel})
+	if err := util.RegisterLenient(result.numReceived); err != nil {
+		return nil, err
+	}
+
+	result.numRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "almanac_syslog_rejected_messages",
+		Help: "The number of syslog messages which could not be parsed or ingested",
+	}, []string{transportLabel})
+	if err := util.RegisterLenient(result.numRejected); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Receiver accepts syslog messages on any number of sockets and passes each of them on to an
+// ingester. Messages are not authenticated, so the sockets should only be reachable by trusted
+// senders.
+type Receiver struct {
+	logger   *logrus.Logger
+	ingester pb_almanac.IngesterServer
+	tenant   string
+	metrics  *receiverMetrics
+
+	mutex   sync.Mutex
+	closed  bool
+	sockets map[io.Closer]bool
+	wg      sync.WaitGroup
+}
+
+// New returns a receiver which ingests all messages into the supplied tenant.
+func New(logger *logrus.Logger, ingester pb_almanac.IngesterServer, tenantId string) (*Receiver, error) {
+	metrics, err := sharedMetrics()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create metrics: %v", err)
+	}
+	return &Receiver{
+		logger:   logger,
+		ingester: ingester,
+		tenant:   tenantId,
+		metrics:  metrics,
+		sockets:  map[io.Closer]bool{},
+	}, nil
+}
+
+// ServeUdp reads messages from the supplied connection in the background, one per datagram,
+// until the receiver is closed.
+func (r *Receiver) ServeUdp(conn net.PacketConn) error {
+	if !r.track(conn) {
+		return fmt.Errorf("receiver is closed")
+	}
+	go func() {
+		defer r.untrack(conn)
+		buffer := make([]byte, maxMessageBytes)
+		for {
+			n, _, err := conn.ReadFrom(buffer)
+			if err != nil {
+				if !r.isClosed() {
+					r.logger.WithError(err).Warnf("Unable to read syslog datagram, stopping")
+				}
+				return
+			}
+			r.handle(transportUdp, string(buffer[:n]))
+		}
+	}()
+	return nil
+}
+
+// ServeTcp accepts connections from the supplied listener in the background until the receiver
+// is closed. Listeners returned by tls.NewListener are supported as well. Messages are framed
+// either by a preceding length, as in "12 <14>1 - - - -", or by a trailing newline, see rfc 6587.
+func (r *Receiver) ServeTcp(listener net.Listener) error {
+	if !r.track(listener) {
+		return fmt.Errorf("receiver is closed")
+	}
+	go func() {
+		defer r.untrack(listener)
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !r.isClosed() {
+					r.logger.WithError(err).Warnf("Unable to accept syslog connection, stopping")
+				}
+				return
+			}
+			if !r.track(conn) {
+				conn.Close()
+				return
+			}
+			go func() {
+				defer r.untrack(conn)
+				err := r.serveConn(conn)
+				if err != nil && !r.isClosed() {
+					r.logger.WithError(err).Warnf("Closing syslog connection from %v", conn.RemoteAddr())
+				}
+			}()
+		}
+	}()
+	return nil
+}
+
+// Close stops accepting messages and waits for the ones already received to be ingested.
+func (r *Receiver) Close() error {
+	r.mutex.Lock()
+	r.closed = true
+	for socket := range r.sockets {
+		socket.Close()
+	}
+	r.mutex.Unlock()
+
+	r.wg.Wait()
+	return nil
+}
+
+// serveConn reads messages from the supplied connection until it is closed.
+func (r *Receiver) serveConn(conn net.Conn) error {
+	reader := bufio.NewReaderSize(conn, maxMessageBytes)
+	for {
+		first, err := reader.Peek(1)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var raw string
+		if first[0] >= '0' && first[0] <= '9' {
+			raw, err = readOctetCounted(reader)
+		} else {
+			raw, err = readLine(reader)
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if strings.TrimSpace(raw) != "" {
+			r.handle(transportTcp, raw)
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// handle parses and ingests a single message, logging rather than returning failures since there
+// is no way to tell the sender about them.
+func (r *Receiver) handle(transport string, raw string) {
+	r.metrics.numReceived.WithLabelValues(transport).Inc()
+	err := r.ingest(raw)
+	if err != nil {
+		r.metrics.numRejected.WithLabelValues(transport).Inc()
+		r.logger.WithFields(logrus.Fields{transportLabel: transport}).WithError(err).Warnf("Dropping syslog message")
+	}
+}
+
+func (r *Receiver) ingest(raw string) error {
+	message, err := parseMessage(raw, time.Now())
+	if err != nil {
+		return fmt.Errorf("unable to parse message: %v", err)
+	}
+	entry, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("unable to marshal entry: %v", err)
+	}
+
+	request := &pb_almanac.IngestRequest{
+		EntryJson: string(entry),
+		Format:    pb_almanac.IngestRequest_JSON,
+		Tenant:    r.tenant,
+	}
+	_, err = r.ingester.Ingest(context.Background(), request)
+	if err != nil {
+		return fmt.Errorf("unable to ingest entry: %v", err)
+	}
+	return nil
+}
+
+// track adds the supplied socket to those closed along with the receiver. Returns false if the
+// receiver is already closed.
+func (r *Receiver) track(socket io.Closer) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed {
+		return false
+	}
+	r.sockets[socket] = true
+	r.wg.Add(1)
+	return true
+}
+
+func (r *Receiver) untrack(socket io.Closer) {
+	r.mutex.Lock()
+	delete(r.sockets, socket)
+	r.mutex.Unlock()
+
+	socket.Close()
+	r.wg.Done()
+}
+
+func (r *Receiver) isClosed() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.closed
+}
+
+// readOctetCounted reads a message preceded by its length in bytes and a space.
+func readOctetCounted(reader *bufio.Reader) (string, error) {
+	prefix, err := reader.ReadString(' ')
+	if err != nil {
+		return "", fmt.Errorf("unable to read message length: %v", err)
+	}
+	length, err := strconv.Atoi(strings.TrimSuffix(prefix, " "))
+	if err != nil || length < 0 || length > maxMessageBytes {
+		return "", fmt.Errorf("invalid message length %q", prefix)
+	}
+	buffer := make([]byte, length)
+	_, err = io.ReadFull(reader, buffer)
+	if err != nil {
+		return "", fmt.Errorf("unable to read message: %v", err)
+	}
+	return string(buffer), nil
+}
+
+// readLine reads a message terminated by a newline, or by the end of the connection.
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return "", fmt.Errorf("message exceeds %d bytes", maxMessageBytes)
+	}
+	return string(line), err
+}
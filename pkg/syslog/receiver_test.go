@@ -0,0 +1,143 @@
+package syslog
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dinowernli/almanac/pkg/mtls"
+	pb_almanac "github.com/dinowernli/almanac/proto"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+const (
+	testTenant = "network"
+)
+
+func TestReceiveUdp(t *testing.T) {
+	ingester := newFakeIngester()
+	r, err := New(logrus.New(), ingester, testTenant)
+	assert.NoError(t, err)
+	defer r.Close()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	assert.NoError(t, r.ServeUdp(conn))
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	assert.NoError(t, err)
+	defer client.Close()
+	_, err = client.Write([]byte(`<14>1 - host app - - - hello`))
+	assert.NoError(t, err)
+
+	request := ingester.next(t)
+	assert.Equal(t, testTenant, request.Tenant)
+	assert.Equal(t, pb_almanac.IngestRequest_JSON, request.Format)
+	assert.JSONEq(t, `{"facility": "user", "severity": "info", "hostname": "host", "app_name": "app", "message": "hello"}`, request.EntryJson)
+}
+
+func TestReceiveTcp(t *testing.T) {
+	ingester := newFakeIngester()
+	r, err := New(logrus.New(), ingester, testTenant)
+	assert.NoError(t, err)
+	defer r.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	assert.NoError(t, r.ServeTcp(listener))
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	defer client.Close()
+
+	// Newline framing and octet counting can be mixed, and invalid messages are skipped.
+	octetCounted := "<14>1 - - - - - - two\nlines"
+	fmt.Fprintf(client, "<14>1 - - - - - - one\nnot syslog\n%d %s<14>1 - - - - - - three\n", len(octetCounted), octetCounted)
+
+	assert.Equal(t, `{"facility":"user","severity":"info","message":"one"}`, ingester.next(t).EntryJson)
+	assert.Equal(t, `{"facility":"user","severity":"info","message":"two\nlines"}`, ingester.next(t).EntryJson)
+	assert.Equal(t, `{"facility":"user","severity":"info","message":"three"}`, ingester.next(t).EntryJson)
+}
+
+func TestReceiveTls(t *testing.T) {
+	dir, err := ioutil.TempDir("", "almanac-syslog-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	authority, err := mtls.NewTestAuthority()
+	assert.NoError(t, err)
+	files, err := authority.WriteFiles(dir, "syslog")
+	assert.NoError(t, err)
+	certificate, err := tls.LoadX509KeyPair(files.CertFile, files.KeyFile)
+	assert.NoError(t, err)
+
+	ingester := newFakeIngester()
+	r, err := New(logrus.New(), ingester, testTenant)
+	assert.NoError(t, err)
+	defer r.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	assert.NoError(t, r.ServeTcp(tls.NewListener(listener, &tls.Config{Certificates: []tls.Certificate{certificate}})))
+
+	client, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	assert.NoError(t, err)
+	defer client.Close()
+	_, err = client.Write([]byte("<14>1 - - - - - - secret\n"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, `{"facility":"user","severity":"info","message":"secret"}`, ingester.next(t).EntryJson)
+}
+
+func TestCloseStopsReceiving(t *testing.T) {
+	r, err := New(logrus.New(), newFakeIngester(), testTenant)
+	assert.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	assert.NoError(t, r.ServeTcp(listener))
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	defer client.Close()
+
+	assert.NoError(t, r.Close())
+	_, err = net.Dial("tcp", listener.Addr().String())
+	assert.Error(t, err)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer conn.Close()
+	assert.Error(t, r.ServeUdp(conn))
+}
+
+// fakeIngester records the requests it receives.
+type fakeIngester struct {
+	requests chan *pb_almanac.IngestRequest
+}
+
+func newFakeIngester() *fakeIngester {
+	return &fakeIngester{requests: make(chan *pb_almanac.IngestRequest, 10)}
+}
+
+func (i *fakeIngester) Ingest(ctx context.Context, request *pb_almanac.IngestRequest) (*pb_almanac.IngestResponse, error) {
+	i.requests <- request
+	return &pb_almanac.IngestResponse{}, nil
+}
+
+func (i *fakeIngester) next(t *testing.T) *pb_almanac.IngestRequest {
+	select {
+	case request := <-i.requests:
+		return request
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for ingest request")
+		return nil
+	}
+}
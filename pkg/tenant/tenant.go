@@ -0,0 +1,84 @@
+package tenant
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// Default is the tenant of requests which do not name one. Its data is stored exactly as
+	// before tenants existed, so clusters which do not use tenants are unaffected.
+	Default = ""
+
+	// MetadataKey is the grpc metadata key from which services read the tenant of a request.
+	MetadataKey = "almanac-tenant"
+
+	// HttpHeader is the header from which http handlers read the tenant of a request.
+	HttpHeader = "X-Almanac-Tenant"
+)
+
+var (
+	// Tenant ids show up in storage keys, so they are restricted to characters which are safe
+	// there and which never occur in the rest of a chunk id.
+	validTenant = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+)
+
+// Validate returns an error if the supplied tenant id is not valid.
+func Validate(tenant string) error {
+	if tenant == Default {
+		return nil
+	}
+	if !validTenant.MatchString(tenant) {
+		return fmt.Errorf("invalid tenant [%s], must match %s", tenant, validTenant)
+	}
+	return nil
+}
+
+// Resolve returns the tenant of a request which carries the supplied tenant field. The field
+// takes precedence, otherwise the tenant is taken from the incoming grpc metadata. Returns an
+// error if the two disagree or the tenant is invalid.
+func Resolve(ctx context.Context, requested string) (string, error) {
+	fromMetadata := Default
+	if values := metadata.ValueFromIncomingContext(ctx, MetadataKey); len(values) > 0 {
+		if len(values) > 1 {
+			return "", fmt.Errorf("expected a single tenant in metadata, but got %d", len(values))
+		}
+		fromMetadata = values[0]
+	}
+
+	result := requested
+	if result == Default {
+		result = fromMetadata
+	} else if fromMetadata != Default && fromMetadata != requested {
+		return "", fmt.Errorf("request is for tenant [%s], but metadata names tenant [%s]", requested, fromMetadata)
+	}
+
+	err := Validate(result)
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// FromHttp returns the tenant named in the headers of the supplied http request.
+func FromHttp(request *http.Request) (string, error) {
+	result := request.Header.Get(HttpHeader)
+	err := Validate(result)
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// NewOutgoingContext returns a context which sends the supplied tenant as grpc metadata with
+// all calls made using it.
+func NewOutgoingContext(ctx context.Context, tenant string) context.Context {
+	if tenant == Default {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, MetadataKey, tenant)
+}
@@ -0,0 +1,64 @@
+package tenant
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestValidate(t *testing.T) {
+	for _, valid := range []string{Default, "acme", "team-a", "team_b", "7"} {
+		assert.NoError(t, Validate(valid), valid)
+	}
+	for _, invalid := range []string{"Acme", "-acme", "acme.com", "a/b", "a b"} {
+		assert.Error(t, Validate(invalid), invalid)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	withAcme := metadata.NewIncomingContext(ctx, metadata.Pairs(MetadataKey, "acme"))
+
+	tenant, err := Resolve(ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, Default, tenant)
+
+	tenant, err = Resolve(ctx, "acme")
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", tenant)
+
+	tenant, err = Resolve(withAcme, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", tenant)
+
+	tenant, err = Resolve(withAcme, "acme")
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", tenant)
+
+	_, err = Resolve(withAcme, "other")
+	assert.Error(t, err)
+
+	_, err = Resolve(metadata.NewIncomingContext(ctx, metadata.Pairs(MetadataKey, "Not Valid")), "")
+	assert.Error(t, err)
+}
+
+func TestFromHttp(t *testing.T) {
+	request, err := http.NewRequest("GET", "/mixer", nil)
+	assert.NoError(t, err)
+
+	tenant, err := FromHttp(request)
+	assert.NoError(t, err)
+	assert.Equal(t, Default, tenant)
+
+	request.Header.Set(HttpHeader, "acme")
+	tenant, err = FromHttp(request)
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", tenant)
+
+	request.Header.Set(HttpHeader, "../etc")
+	_, err = FromHttp(request)
+	assert.Error(t, err)
+}
@@ -0,0 +1,22 @@
+package util
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// GracefulStop stops the supplied server from accepting new connections and waits for pending
+// requests to finish. If the supplied context is done first, the server is stopped forcefully.
+func GracefulStop(ctx context.Context, server *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		server.Stop()
+	}
+}
@@ -1,25 +1,6 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // source: proto/service.proto
 
-/*
-Package almanac is a generated protocol buffer package.
-
-It is generated from these files:
-	proto/service.proto
-	proto/storage.proto
-
-It has these top-level messages:
-	AppendRequest
-	AppendResponse
-	IngestRequest
-	IngestResponse
-	SearchRequest
-	SearchResponse
-	LogEntry
-	BleveIndex
-	ChunkId
-	Chunk
-*/
 package almanac
 
 import proto "github.com/golang/protobuf/proto"
@@ -36,21 +17,94 @@ var _ = proto.Marshal
 var _ = fmt.Errorf
 var _ = math.Inf
 
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the proto package it is being compiled against.
-// A compilation error at this line likely means your copy of the
-// proto package needs to be updated.
-const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+type IngestRequest_Format int32
+
+const (
+	// The format is detected from the entry: json objects are parsed as
+	// JSON, lines made up entirely of key=value pairs as LOGFMT, and
+	// everything else as TEXT.
+	IngestRequest_AUTO IngestRequest_Format = 0
+	// A json object.
+	IngestRequest_JSON IngestRequest_Format = 1
+	// A line of space separated key=value pairs, e.g., level=info msg="hi".
+	IngestRequest_LOGFMT IngestRequest_Format = 2
+	// Unstructured text, stored as the "message" field of an entry.
+	IngestRequest_TEXT IngestRequest_Format = 3
+)
+
+var IngestRequest_Format_name = map[int32]string{
+	0: "AUTO",
+	1: "JSON",
+	2: "LOGFMT",
+	3: "TEXT",
+}
+var IngestRequest_Format_value = map[string]int32{
+	"AUTO":   0,
+	"JSON":   1,
+	"LOGFMT": 2,
+	"TEXT":   3,
+}
+
+func (x IngestRequest_Format) String() string {
+	return proto.EnumName(IngestRequest_Format_name, int32(x))
+}
+func (IngestRequest_Format) EnumDescriptor() ([]byte, []int) { return fileDescriptor2, []int{2, 0} }
+
+type LabelMatcher_Type int32
+
+const (
+	// Enum sentinel to make sure that the value is always set explicitly.
+	LabelMatcher_UNKNOWN_MATCH LabelMatcher_Type = 0
+	// The value is equal to "value".
+	LabelMatcher_EQUAL LabelMatcher_Type = 1
+	// The value is not equal to "value".
+	LabelMatcher_NOT_EQUAL LabelMatcher_Type = 2
+	// The value is entirely matched by the regular expression "value".
+	LabelMatcher_REGEX LabelMatcher_Type = 3
+	// The value is not entirely matched by the regular expression "value".
+	LabelMatcher_NOT_REGEX LabelMatcher_Type = 4
+	// The value is one of "values".
+	LabelMatcher_IN LabelMatcher_Type = 5
+	// The value is none of "values".
+	LabelMatcher_NOT_IN LabelMatcher_Type = 6
+)
+
+var LabelMatcher_Type_name = map[int32]string{
+	0: "UNKNOWN_MATCH",
+	1: "EQUAL",
+	2: "NOT_EQUAL",
+	3: "REGEX",
+	4: "NOT_REGEX",
+	5: "IN",
+	6: "NOT_IN",
+}
+var LabelMatcher_Type_value = map[string]int32{
+	"UNKNOWN_MATCH": 0,
+	"EQUAL":         1,
+	"NOT_EQUAL":     2,
+	"REGEX":         3,
+	"NOT_REGEX":     4,
+	"IN":            5,
+	"NOT_IN":        6,
+}
+
+func (x LabelMatcher_Type) String() string {
+	return proto.EnumName(LabelMatcher_Type_name, int32(x))
+}
+func (LabelMatcher_Type) EnumDescriptor() ([]byte, []int) { return fileDescriptor2, []int{6, 0} }
 
 // A request to record append a log entry to an open chunk.
 type AppendRequest struct {
 	Entry *LogEntry `protobuf:"bytes,1,opt,name=entry" json:"entry,omitempty"`
+	// The tenant owning the entry. If empty, the tenant is taken from the
+	// "almanac-tenant" metadata of the call, or else the default tenant.
+	Tenant string `protobuf:"bytes,2,opt,name=tenant" json:"tenant,omitempty"`
 }
 
 func (m *AppendRequest) Reset()                    { *m = AppendRequest{} }
 func (m *AppendRequest) String() string            { return proto.CompactTextString(m) }
 func (*AppendRequest) ProtoMessage()               {}
-func (*AppendRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+func (*AppendRequest) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{0} }
 
 func (m *AppendRequest) GetEntry() *LogEntry {
 	if m != nil {
@@ -59,24 +113,37 @@ func (m *AppendRequest) GetEntry() *LogEntry {
 	return nil
 }
 
+func (m *AppendRequest) GetTenant() string {
+	if m != nil {
+		return m.Tenant
+	}
+	return ""
+}
+
 type AppendResponse struct {
 }
 
 func (m *AppendResponse) Reset()                    { *m = AppendResponse{} }
 func (m *AppendResponse) String() string            { return proto.CompactTextString(m) }
 func (*AppendResponse) ProtoMessage()               {}
-func (*AppendResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+func (*AppendResponse) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{1} }
 
 // A request to ingest a single log entry into the system.
 type IngestRequest struct {
-	// A json object representing the entry to ingest.
+	// The entry to ingest, in the supplied format. Despite its name, this only
+	// holds a json object if the format is JSON.
 	EntryJson string `protobuf:"bytes,1,opt,name=entry_json,json=entryJson" json:"entry_json,omitempty"`
+	// The tenant owning the entry. If empty, the tenant is taken from the
+	// "almanac-tenant" metadata of the call, or else the default tenant.
+	Tenant string `protobuf:"bytes,2,opt,name=tenant" json:"tenant,omitempty"`
+	// The format of entry_json. All formats are normalized into json objects.
+	Format IngestRequest_Format `protobuf:"varint,3,opt,name=format,enum=almanac.IngestRequest_Format" json:"format,omitempty"`
 }
 
 func (m *IngestRequest) Reset()                    { *m = IngestRequest{} }
 func (m *IngestRequest) String() string            { return proto.CompactTextString(m) }
 func (*IngestRequest) ProtoMessage()               {}
-func (*IngestRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+func (*IngestRequest) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{2} }
 
 func (m *IngestRequest) GetEntryJson() string {
 	if m != nil {
@@ -85,13 +152,27 @@ func (m *IngestRequest) GetEntryJson() string {
 	return ""
 }
 
+func (m *IngestRequest) GetTenant() string {
+	if m != nil {
+		return m.Tenant
+	}
+	return ""
+}
+
+func (m *IngestRequest) GetFormat() IngestRequest_Format {
+	if m != nil {
+		return m.Format
+	}
+	return IngestRequest_AUTO
+}
+
 type IngestResponse struct {
 }
 
 func (m *IngestResponse) Reset()                    { *m = IngestResponse{} }
 func (m *IngestResponse) String() string            { return proto.CompactTextString(m) }
 func (*IngestResponse) ProtoMessage()               {}
-func (*IngestResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+func (*IngestResponse) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{3} }
 
 // A request to search for log entries.
 type SearchRequest struct {
@@ -105,12 +186,19 @@ type SearchRequest struct {
 	Query string `protobuf:"bytes,4,opt,name=query" json:"query,omitempty"`
 	// The maximum number of results to return.
 	Num int32 `protobuf:"varint,5,opt,name=num" json:"num,omitempty"`
+	// If set, only entries of the streams matching the selector are returned.
+	// Chunks whose stream labels do not match are skipped entirely.
+	LabelSelector *LabelSelector `protobuf:"bytes,6,opt,name=label_selector,json=labelSelector" json:"label_selector,omitempty"`
+	// The tenant whose entries are searched. If empty, the tenant is taken from
+	// the "almanac-tenant" metadata of the call, or else the default tenant.
+	// Searches never return entries of other tenants.
+	Tenant string `protobuf:"bytes,7,opt,name=tenant" json:"tenant,omitempty"`
 }
 
 func (m *SearchRequest) Reset()                    { *m = SearchRequest{} }
 func (m *SearchRequest) String() string            { return proto.CompactTextString(m) }
 func (*SearchRequest) ProtoMessage()               {}
-func (*SearchRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+func (*SearchRequest) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{4} }
 
 func (m *SearchRequest) GetStartMs() int64 {
 	if m != nil {
@@ -140,16 +228,97 @@ func (m *SearchRequest) GetNum() int32 {
 	return 0
 }
 
+func (m *SearchRequest) GetLabelSelector() *LabelSelector {
+	if m != nil {
+		return m.LabelSelector
+	}
+	return nil
+}
+
+func (m *SearchRequest) GetTenant() string {
+	if m != nil {
+		return m.Tenant
+	}
+	return ""
+}
+
+// Selects log entries based on the values of their labels, i.e., their
+// top-level json fields. An entry matches if it matches all matchers.
+type LabelSelector struct {
+	Matchers []*LabelMatcher `protobuf:"bytes,1,rep,name=matchers" json:"matchers,omitempty"`
+}
+
+func (m *LabelSelector) Reset()                    { *m = LabelSelector{} }
+func (m *LabelSelector) String() string            { return proto.CompactTextString(m) }
+func (*LabelSelector) ProtoMessage()               {}
+func (*LabelSelector) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{5} }
+
+func (m *LabelSelector) GetMatchers() []*LabelMatcher {
+	if m != nil {
+		return m.Matchers
+	}
+	return nil
+}
+
+// A condition on the value of a single label. Entries which do not have the
+// label are treated as if its value was the empty string.
+type LabelMatcher struct {
+	// The name of the label.
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// Must be set to something other than "UNKNOWN_MATCH".
+	Type LabelMatcher_Type `protobuf:"varint,2,opt,name=type,enum=almanac.LabelMatcher_Type" json:"type,omitempty"`
+	// The operand of all types except "IN" and "NOT_IN".
+	Value string `protobuf:"bytes,3,opt,name=value" json:"value,omitempty"`
+	// The operand of "IN" and "NOT_IN".
+	Values []string `protobuf:"bytes,4,rep,name=values" json:"values,omitempty"`
+}
+
+func (m *LabelMatcher) Reset()                    { *m = LabelMatcher{} }
+func (m *LabelMatcher) String() string            { return proto.CompactTextString(m) }
+func (*LabelMatcher) ProtoMessage()               {}
+func (*LabelMatcher) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{6} }
+
+func (m *LabelMatcher) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *LabelMatcher) GetType() LabelMatcher_Type {
+	if m != nil {
+		return m.Type
+	}
+	return LabelMatcher_UNKNOWN_MATCH
+}
+
+func (m *LabelMatcher) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+func (m *LabelMatcher) GetValues() []string {
+	if m != nil {
+		return m.Values
+	}
+	return nil
+}
+
 // The result of searching for log entries.
 type SearchResponse struct {
 	// All the entries which have matched the search.
 	Entries []*LogEntry `protobuf:"bytes,2,rep,name=entries" json:"entries,omitempty"`
+	// The chunks which were skipped because they turned out to be corrupt. The
+	// entries are incomplete if this is non-empty.
+	CorruptChunks []*ChunkId `protobuf:"bytes,3,rep,name=corrupt_chunks,json=corruptChunks" json:"corrupt_chunks,omitempty"`
 }
 
 func (m *SearchResponse) Reset()                    { *m = SearchResponse{} }
 func (m *SearchResponse) String() string            { return proto.CompactTextString(m) }
 func (*SearchResponse) ProtoMessage()               {}
-func (*SearchResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (*SearchResponse) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{7} }
 
 func (m *SearchResponse) GetEntries() []*LogEntry {
 	if m != nil {
@@ -158,13 +327,168 @@ func (m *SearchResponse) GetEntries() []*LogEntry {
 	return nil
 }
 
+func (m *SearchResponse) GetCorruptChunks() []*ChunkId {
+	if m != nil {
+		return m.CorruptChunks
+	}
+	return nil
+}
+
+// A request to purge all log entries matching a query within a time range.
+type PurgeRequest struct {
+	// A text-format query selecting the entries to purge.
+	Query string `protobuf:"bytes,1,opt,name=query" json:"query,omitempty"`
+	// A start time in epoch milliseconds, inclusive.
+	StartMs int64 `protobuf:"varint,2,opt,name=start_ms,json=startMs" json:"start_ms,omitempty"`
+	// An end time in epoch milliseconds, inclusive. If zero, the time range is
+	// unbounded.
+	EndMs int64 `protobuf:"varint,3,opt,name=end_ms,json=endMs" json:"end_ms,omitempty"`
+	// What to do with the matching entries.
+	Action PurgeAction `protobuf:"varint,4,opt,name=action,enum=almanac.PurgeAction" json:"action,omitempty"`
+	// The top-level json fields to redact if the action is "REDACT".
+	RedactFields []string `protobuf:"bytes,5,rep,name=redact_fields,json=redactFields" json:"redact_fields,omitempty"`
+	// A human-readable justification for the purge.
+	Reason string `protobuf:"bytes,6,opt,name=reason" json:"reason,omitempty"`
+}
+
+func (m *PurgeRequest) Reset()                    { *m = PurgeRequest{} }
+func (m *PurgeRequest) String() string            { return proto.CompactTextString(m) }
+func (*PurgeRequest) ProtoMessage()               {}
+func (*PurgeRequest) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{8} }
+
+func (m *PurgeRequest) GetQuery() string {
+	if m != nil {
+		return m.Query
+	}
+	return ""
+}
+
+func (m *PurgeRequest) GetStartMs() int64 {
+	if m != nil {
+		return m.StartMs
+	}
+	return 0
+}
+
+func (m *PurgeRequest) GetEndMs() int64 {
+	if m != nil {
+		return m.EndMs
+	}
+	return 0
+}
+
+func (m *PurgeRequest) GetAction() PurgeAction {
+	if m != nil {
+		return m.Action
+	}
+	return PurgeAction_UNKNOWN_ACTION
+}
+
+func (m *PurgeRequest) GetRedactFields() []string {
+	if m != nil {
+		return m.RedactFields
+	}
+	return nil
+}
+
+func (m *PurgeRequest) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+type PurgeResponse struct {
+	// The tombstone recorded for this purge.
+	Tombstone *Tombstone `protobuf:"bytes,1,opt,name=tombstone" json:"tombstone,omitempty"`
+}
+
+func (m *PurgeResponse) Reset()                    { *m = PurgeResponse{} }
+func (m *PurgeResponse) String() string            { return proto.CompactTextString(m) }
+func (*PurgeResponse) ProtoMessage()               {}
+func (*PurgeResponse) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{9} }
+
+func (m *PurgeResponse) GetTombstone() *Tombstone {
+	if m != nil {
+		return m.Tombstone
+	}
+	return nil
+}
+
+type ListTombstonesRequest struct {
+}
+
+func (m *ListTombstonesRequest) Reset()                    { *m = ListTombstonesRequest{} }
+func (m *ListTombstonesRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListTombstonesRequest) ProtoMessage()               {}
+func (*ListTombstonesRequest) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{10} }
+
+type ListTombstonesResponse struct {
+	Tombstones []*Tombstone `protobuf:"bytes,1,rep,name=tombstones" json:"tombstones,omitempty"`
+}
+
+func (m *ListTombstonesResponse) Reset()                    { *m = ListTombstonesResponse{} }
+func (m *ListTombstonesResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListTombstonesResponse) ProtoMessage()               {}
+func (*ListTombstonesResponse) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{11} }
+
+func (m *ListTombstonesResponse) GetTombstones() []*Tombstone {
+	if m != nil {
+		return m.Tombstones
+	}
+	return nil
+}
+
+type ListAuditRecordsRequest struct {
+	// If set, only records caused by this tombstone are returned.
+	TombstoneId string `protobuf:"bytes,1,opt,name=tombstone_id,json=tombstoneId" json:"tombstone_id,omitempty"`
+}
+
+func (m *ListAuditRecordsRequest) Reset()                    { *m = ListAuditRecordsRequest{} }
+func (m *ListAuditRecordsRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListAuditRecordsRequest) ProtoMessage()               {}
+func (*ListAuditRecordsRequest) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{12} }
+
+func (m *ListAuditRecordsRequest) GetTombstoneId() string {
+	if m != nil {
+		return m.TombstoneId
+	}
+	return ""
+}
+
+type ListAuditRecordsResponse struct {
+	Records []*AuditRecord `protobuf:"bytes,1,rep,name=records" json:"records,omitempty"`
+}
+
+func (m *ListAuditRecordsResponse) Reset()                    { *m = ListAuditRecordsResponse{} }
+func (m *ListAuditRecordsResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListAuditRecordsResponse) ProtoMessage()               {}
+func (*ListAuditRecordsResponse) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{13} }
+
+func (m *ListAuditRecordsResponse) GetRecords() []*AuditRecord {
+	if m != nil {
+		return m.Records
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*AppendRequest)(nil), "almanac.AppendRequest")
 	proto.RegisterType((*AppendResponse)(nil), "almanac.AppendResponse")
 	proto.RegisterType((*IngestRequest)(nil), "almanac.IngestRequest")
 	proto.RegisterType((*IngestResponse)(nil), "almanac.IngestResponse")
 	proto.RegisterType((*SearchRequest)(nil), "almanac.SearchRequest")
+	proto.RegisterType((*LabelSelector)(nil), "almanac.LabelSelector")
+	proto.RegisterType((*LabelMatcher)(nil), "almanac.LabelMatcher")
 	proto.RegisterType((*SearchResponse)(nil), "almanac.SearchResponse")
+	proto.RegisterType((*PurgeRequest)(nil), "almanac.PurgeRequest")
+	proto.RegisterType((*PurgeResponse)(nil), "almanac.PurgeResponse")
+	proto.RegisterType((*ListTombstonesRequest)(nil), "almanac.ListTombstonesRequest")
+	proto.RegisterType((*ListTombstonesResponse)(nil), "almanac.ListTombstonesResponse")
+	proto.RegisterType((*ListAuditRecordsRequest)(nil), "almanac.ListAuditRecordsRequest")
+	proto.RegisterType((*ListAuditRecordsResponse)(nil), "almanac.ListAuditRecordsResponse")
+	proto.RegisterEnum("almanac.IngestRequest_Format", IngestRequest_Format_name, IngestRequest_Format_value)
+	proto.RegisterEnum("almanac.LabelMatcher_Type", LabelMatcher_Type_name, LabelMatcher_Type_value)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -404,29 +728,201 @@ var _Mixer_serviceDesc = grpc.ServiceDesc{
 	Metadata: "proto/service.proto",
 }
 
-func init() { proto.RegisterFile("proto/service.proto", fileDescriptor0) }
-
-var fileDescriptor0 = []byte{
-	// 329 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x52, 0x4d, 0x4b, 0xf3, 0x40,
-	0x10, 0x26, 0x6f, 0xde, 0x4d, 0xdb, 0x91, 0x96, 0xba, 0x7e, 0xc5, 0x82, 0x50, 0x72, 0xb1, 0x20,
-	0x44, 0x88, 0x17, 0x7b, 0xf0, 0xa0, 0xd0, 0x83, 0x62, 0x2f, 0xf1, 0x07, 0x94, 0xb5, 0x1d, 0x6a,
-	0xc4, 0xee, 0xa6, 0xbb, 0x1b, 0xb1, 0x37, 0x7f, 0xba, 0xec, 0x6e, 0x56, 0x12, 0xf4, 0xe4, 0x6d,
-	0x66, 0x9f, 0x79, 0x3e, 0x66, 0x12, 0x38, 0x28, 0xa5, 0xd0, 0xe2, 0x52, 0xa1, 0x7c, 0x2f, 0x96,
-	0x98, 0xda, 0x8e, 0x76, 0xd8, 0xdb, 0x86, 0x71, 0xb6, 0x1c, 0x79, 0x54, 0x0b, 0xc9, 0xd6, 0x35,
-	0x9a, 0x5c, 0x43, 0xff, 0xb6, 0x2c, 0x91, 0xaf, 0x72, 0xdc, 0x56, 0xa8, 0x34, 0x3d, 0x07, 0x82,
-	0x5c, 0xcb, 0x5d, 0x1c, 0x8c, 0x83, 0xc9, 0x5e, 0xb6, 0x9f, 0xd6, 0xf4, 0xf4, 0x51, 0xac, 0x67,
-	0x06, 0xc8, 0x1d, 0x9e, 0x0c, 0x61, 0xe0, 0x99, 0xaa, 0x14, 0x5c, 0x61, 0x92, 0x42, 0xff, 0x9e,
-	0xaf, 0x51, 0x69, 0xaf, 0x75, 0x06, 0x60, 0x67, 0x17, 0xaf, 0x4a, 0x70, 0x2b, 0xd8, 0xcb, 0x7b,
-	0xf6, 0xe5, 0x41, 0x09, 0x6e, 0x14, 0xfc, 0x7c, 0xad, 0x50, 0x40, 0xff, 0x09, 0x99, 0x5c, 0xbe,
-	0x78, 0x85, 0x53, 0xe8, 0x2a, 0xcd, 0xa4, 0x5e, 0x6c, 0x54, 0xfc, 0x6f, 0x1c, 0x4c, 0xc2, 0xbc,
-	0x63, 0xfb, 0xb9, 0xa2, 0x47, 0x10, 0x21, 0x5f, 0x19, 0x20, 0xb4, 0x00, 0x41, 0xbe, 0x9a, 0x2b,
-	0x7a, 0x08, 0x64, 0x5b, 0xa1, 0xdc, 0xc5, 0xff, 0xad, 0x9d, 0x6b, 0xe8, 0x10, 0x42, 0x5e, 0x6d,
-	0x62, 0x32, 0x0e, 0x26, 0x24, 0x37, 0x65, 0x72, 0x03, 0x03, 0x6f, 0xe5, 0xcc, 0xe9, 0x05, 0x74,
-	0x4c, 0xb6, 0x02, 0x8d, 0x55, 0xf8, 0xfb, 0xee, 0x7e, 0x22, 0xfb, 0x0c, 0xa0, 0xeb, 0xd6, 0x47,
-	0x49, 0xa7, 0x10, 0xb9, 0x9a, 0x1e, 0x7f, 0x53, 0x5a, 0x57, 0x1d, 0x9d, 0xfc, 0x78, 0xaf, 0x4d,
-	0xa7, 0x10, 0xb9, 0x18, 0x0d, 0x6a, 0xeb, 0x04, 0x0d, 0x6a, 0x3b, 0x6f, 0x36, 0x83, 0xae, 0x3b,
-	0x9f, 0x4b, 0xe0, 0xea, 0x86, 0x4c, 0xeb, 0x5b, 0x34, 0x64, 0xda, 0x37, 0xcf, 0xee, 0x80, 0xcc,
-	0x8b, 0x0f, 0xa7, 0xf1, 0xc7, 0x28, 0xcf, 0x91, 0xfd, 0x99, 0xae, 0xbe, 0x02, 0x00, 0x00, 0xff,
-	0xff, 0xb9, 0xab, 0xa2, 0x24, 0x81, 0x02, 0x00, 0x00,
+// Client API for Admin service
+
+type AdminClient interface {
+	// Records a tombstone for the matching entries. The entries are hidden from
+	// searches immediately and are eventually removed from storage.
+	Purge(ctx context.Context, in *PurgeRequest, opts ...grpc.CallOption) (*PurgeResponse, error)
+	// Returns all tombstones recorded so far.
+	ListTombstones(ctx context.Context, in *ListTombstonesRequest, opts ...grpc.CallOption) (*ListTombstonesResponse, error)
+	// Returns the audit records of entries purged from storage.
+	ListAuditRecords(ctx context.Context, in *ListAuditRecordsRequest, opts ...grpc.CallOption) (*ListAuditRecordsResponse, error)
+}
+
+type adminClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewAdminClient(cc *grpc.ClientConn) AdminClient {
+	return &adminClient{cc}
+}
+
+func (c *adminClient) Purge(ctx context.Context, in *PurgeRequest, opts ...grpc.CallOption) (*PurgeResponse, error) {
+	out := new(PurgeResponse)
+	err := grpc.Invoke(ctx, "/almanac.Admin/Purge", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListTombstones(ctx context.Context, in *ListTombstonesRequest, opts ...grpc.CallOption) (*ListTombstonesResponse, error) {
+	out := new(ListTombstonesResponse)
+	err := grpc.Invoke(ctx, "/almanac.Admin/ListTombstones", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListAuditRecords(ctx context.Context, in *ListAuditRecordsRequest, opts ...grpc.CallOption) (*ListAuditRecordsResponse, error) {
+	out := new(ListAuditRecordsResponse)
+	err := grpc.Invoke(ctx, "/almanac.Admin/ListAuditRecords", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Admin service
+
+type AdminServer interface {
+	// Records a tombstone for the matching entries. The entries are hidden from
+	// searches immediately and are eventually removed from storage.
+	Purge(context.Context, *PurgeRequest) (*PurgeResponse, error)
+	// Returns all tombstones recorded so far.
+	ListTombstones(context.Context, *ListTombstonesRequest) (*ListTombstonesResponse, error)
+	// Returns the audit records of entries purged from storage.
+	ListAuditRecords(context.Context, *ListAuditRecordsRequest) (*ListAuditRecordsResponse, error)
+}
+
+func RegisterAdminServer(s *grpc.Server, srv AdminServer) {
+	s.RegisterService(&_Admin_serviceDesc, srv)
+}
+
+func _Admin_Purge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PurgeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Purge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/almanac.Admin/Purge",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Purge(ctx, req.(*PurgeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListTombstones_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTombstonesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListTombstones(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/almanac.Admin/ListTombstones",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListTombstones(ctx, req.(*ListTombstonesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListAuditRecords_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAuditRecordsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListAuditRecords(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/almanac.Admin/ListAuditRecords",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListAuditRecords(ctx, req.(*ListAuditRecordsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Admin_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "almanac.Admin",
+	HandlerType: (*AdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Purge",
+			Handler:    _Admin_Purge_Handler,
+		},
+		{
+			MethodName: "ListTombstones",
+			Handler:    _Admin_ListTombstones_Handler,
+		},
+		{
+			MethodName: "ListAuditRecords",
+			Handler:    _Admin_ListAuditRecords_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/service.proto",
+}
+
+func init() { proto.RegisterFile("proto/service.proto", fileDescriptor2) }
+
+var fileDescriptor2 = []byte{
+	// 876 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x55, 0x5f, 0x8f, 0xdb, 0x44,
+	0x10, 0xaf, 0xcf, 0xb1, 0x93, 0xcc, 0x9d, 0x23, 0x77, 0xe9, 0x5d, 0x4c, 0xa4, 0x42, 0x6a, 0x1e,
+	0x88, 0x04, 0x0a, 0x60, 0x04, 0xa8, 0x12, 0x3c, 0xa4, 0x55, 0xae, 0xe4, 0xc8, 0x9f, 0x76, 0x2f,
+	0xa7, 0xf6, 0x2d, 0xf2, 0xd9, 0xdb, 0x3b, 0x43, 0xb2, 0x4e, 0x77, 0x37, 0x27, 0xee, 0x8d, 0xaf,
+	0xc4, 0x37, 0x80, 0xcf, 0xc2, 0x23, 0x5f, 0x02, 0xed, 0x1f, 0x3b, 0xf1, 0x5d, 0x8a, 0x04, 0x6f,
+	0x3b, 0xf3, 0x9b, 0xf9, 0xcd, 0xce, 0xcc, 0xcf, 0x6b, 0xf8, 0x60, 0xcd, 0x72, 0x91, 0x7f, 0xc1,
+	0x09, 0xbb, 0xc9, 0x12, 0xd2, 0x57, 0x16, 0xaa, 0xc7, 0xcb, 0x55, 0x4c, 0xe3, 0xa4, 0x53, 0xa0,
+	0x22, 0x67, 0xf1, 0x95, 0x41, 0xc3, 0x97, 0xe0, 0x0d, 0xd6, 0x6b, 0x42, 0x53, 0x4c, 0xde, 0x6d,
+	0x08, 0x17, 0xe8, 0x53, 0x70, 0x08, 0x15, 0xec, 0x36, 0xb0, 0xba, 0x56, 0xef, 0x30, 0x7a, 0xd8,
+	0x37, 0xe9, 0xfd, 0x71, 0x7e, 0x35, 0x94, 0x00, 0xd6, 0x38, 0x3a, 0x01, 0x57, 0x10, 0x1a, 0x53,
+	0x11, 0x1c, 0x74, 0xad, 0x5e, 0x13, 0x1b, 0x2b, 0xf4, 0xa1, 0x55, 0x30, 0xf2, 0x75, 0x4e, 0x39,
+	0x09, 0x7f, 0xb7, 0xc0, 0x1b, 0xd1, 0x2b, 0xc2, 0x45, 0x51, 0xe4, 0x31, 0x80, 0x22, 0x59, 0xfc,
+	0xcc, 0x73, 0xaa, 0x2a, 0x35, 0x71, 0x53, 0x79, 0xce, 0x78, 0x4e, 0xdf, 0x47, 0x8d, 0xbe, 0x01,
+	0xf7, 0x6d, 0xce, 0x56, 0xb1, 0x08, 0xec, 0xae, 0xd5, 0x6b, 0x45, 0x8f, 0xcb, 0xcb, 0x55, 0xe8,
+	0xfb, 0xa7, 0x2a, 0x08, 0x9b, 0xe0, 0x30, 0x02, 0x57, 0x7b, 0x50, 0x03, 0x6a, 0x83, 0x8b, 0xf9,
+	0xcc, 0x7f, 0x20, 0x4f, 0x67, 0xe7, 0xb3, 0xa9, 0x6f, 0x21, 0x00, 0x77, 0x3c, 0x7b, 0x71, 0x3a,
+	0x99, 0xfb, 0x07, 0xd2, 0x3b, 0x1f, 0xbe, 0x99, 0xfb, 0xb6, 0xec, 0xa2, 0xe0, 0x34, 0x5d, 0xfc,
+	0x61, 0x81, 0x77, 0x4e, 0x62, 0x96, 0x5c, 0x17, 0x5d, 0x7c, 0x08, 0x0d, 0x2e, 0x62, 0x26, 0x16,
+	0x2b, 0xae, 0x2e, 0x6a, 0xe3, 0xba, 0xb2, 0x27, 0x1c, 0x1d, 0x83, 0x4b, 0x68, 0x2a, 0x01, 0x5b,
+	0x01, 0x0e, 0xa1, 0xe9, 0x84, 0xa3, 0x47, 0xe0, 0xbc, 0xdb, 0x10, 0x76, 0x1b, 0xd4, 0x54, 0x5f,
+	0xda, 0x40, 0x3e, 0xd8, 0x74, 0xb3, 0x0a, 0x9c, 0xae, 0xd5, 0x73, 0xb0, 0x3c, 0xa2, 0x1f, 0xa0,
+	0xb5, 0x8c, 0x2f, 0xc9, 0x72, 0xc1, 0xc9, 0x92, 0x24, 0x22, 0x67, 0x81, 0xab, 0xb6, 0x71, 0xb2,
+	0xdd, 0x86, 0x84, 0xcf, 0x0d, 0x8a, 0xbd, 0xe5, 0xae, 0xb9, 0x33, 0xbf, 0x7a, 0x65, 0x35, 0xcf,
+	0xc0, 0xab, 0xe4, 0xa1, 0xaf, 0xa0, 0xb1, 0x8a, 0x45, 0x72, 0x4d, 0x18, 0x0f, 0xac, 0xae, 0xdd,
+	0x3b, 0x8c, 0x8e, 0xab, 0x15, 0x26, 0x1a, 0xc5, 0x65, 0x58, 0xf8, 0x97, 0x05, 0x47, 0xbb, 0x10,
+	0x42, 0x50, 0xa3, 0xf1, 0x8a, 0x98, 0x2d, 0xaa, 0x33, 0xea, 0x43, 0x4d, 0xdc, 0xae, 0x89, 0x9a,
+	0x4a, 0x2b, 0xea, 0xec, 0xe5, 0xec, 0xcf, 0x6f, 0xd7, 0x04, 0xab, 0x38, 0x39, 0x97, 0x9b, 0x78,
+	0xb9, 0x21, 0x6a, 0x5a, 0x4d, 0xac, 0x0d, 0xd9, 0x86, 0x3a, 0xf0, 0xa0, 0xd6, 0xb5, 0x65, 0x1b,
+	0xda, 0x0a, 0x63, 0xa8, 0xc9, 0x5c, 0xf4, 0x10, 0xbc, 0x8b, 0xe9, 0x4f, 0xd3, 0xd9, 0xeb, 0xe9,
+	0x62, 0x32, 0x98, 0x3f, 0xff, 0xd1, 0x7f, 0x80, 0x9a, 0xe0, 0x0c, 0x5f, 0x5d, 0x0c, 0xc6, 0xbe,
+	0x85, 0x3c, 0x68, 0x4e, 0x67, 0xf3, 0x85, 0x36, 0x0f, 0x24, 0x82, 0x87, 0x2f, 0x86, 0x6f, 0x7c,
+	0xbb, 0x40, 0xb4, 0x59, 0x43, 0x2e, 0x1c, 0x8c, 0xa6, 0xbe, 0x23, 0x85, 0x20, 0xdd, 0xa3, 0xa9,
+	0xef, 0x86, 0x37, 0xd0, 0x2a, 0x76, 0xad, 0xd7, 0x8f, 0x3e, 0x83, 0xba, 0x14, 0x68, 0x46, 0xe4,
+	0xae, 0xed, 0xfd, 0x5f, 0x46, 0x11, 0x81, 0xbe, 0x83, 0x56, 0x92, 0x33, 0xb6, 0x59, 0x8b, 0x45,
+	0x72, 0xbd, 0xa1, 0xbf, 0x48, 0x19, 0xc8, 0x1c, 0xbf, 0xcc, 0x79, 0x2e, 0xdd, 0xa3, 0x14, 0x7b,
+	0x26, 0x4e, 0xd9, 0x3c, 0xfc, 0xd3, 0x82, 0xa3, 0x97, 0x1b, 0x76, 0x45, 0x0a, 0x8d, 0x95, 0x8a,
+	0xb1, 0x76, 0x15, 0xf3, 0xdf, 0x95, 0xf7, 0x39, 0xb8, 0x71, 0x22, 0xb2, 0x9c, 0x2a, 0xe9, 0xb5,
+	0xa2, 0x47, 0xe5, 0x4d, 0x54, 0xb9, 0x81, 0xc2, 0xb0, 0x89, 0x41, 0x9f, 0x80, 0xc7, 0x48, 0x1a,
+	0x27, 0x62, 0xf1, 0x36, 0x23, 0xcb, 0x94, 0x07, 0x8e, 0x5a, 0xc0, 0x91, 0x76, 0x9e, 0x2a, 0x9f,
+	0x5c, 0x0f, 0x23, 0xb1, 0xfc, 0x80, 0x5d, 0xad, 0x32, 0x6d, 0x85, 0x03, 0xf0, 0x4c, 0x0b, 0x66,
+	0x74, 0x5f, 0x42, 0x53, 0xe4, 0xab, 0x4b, 0x2e, 0x72, 0x4a, 0xcc, 0xb3, 0x82, 0xca, 0xf2, 0xf3,
+	0x02, 0xc1, 0xdb, 0xa0, 0xb0, 0x0d, 0xc7, 0xe3, 0x8c, 0x8b, 0x12, 0xe3, 0x66, 0x1c, 0xe1, 0x18,
+	0x4e, 0xee, 0x02, 0xa6, 0x48, 0x04, 0x50, 0xe6, 0x17, 0x62, 0xde, 0x57, 0x65, 0x27, 0x2a, 0xfc,
+	0x1e, 0xda, 0x92, 0x6d, 0xb0, 0x49, 0x33, 0x81, 0x49, 0x92, 0xb3, 0xb4, 0x28, 0x84, 0x9e, 0xc0,
+	0x51, 0x19, 0xb8, 0xc8, 0x52, 0x33, 0xfe, 0xc3, 0xd2, 0x37, 0x4a, 0xc3, 0x33, 0x08, 0xee, 0x67,
+	0x9b, 0xdb, 0xf4, 0xa1, 0xce, 0xb4, 0xcb, 0x5c, 0x65, 0x3b, 0xef, 0x9d, 0x78, 0x5c, 0x04, 0x45,
+	0xbf, 0x59, 0xd0, 0xd0, 0xaf, 0x26, 0x61, 0xe8, 0x29, 0xb8, 0xfa, 0x8c, 0xb6, 0xdf, 0x7b, 0xe5,
+	0x91, 0xee, 0xb4, 0xef, 0xf9, 0x4d, 0xdd, 0xa7, 0xe0, 0x6a, 0xdd, 0xee, 0xa4, 0x56, 0x1e, 0xad,
+	0x4e, 0xfb, 0x9e, 0x5f, 0xa7, 0x46, 0x43, 0x68, 0xe8, 0x17, 0x4f, 0xdf, 0x40, 0x9f, 0x77, 0x68,
+	0x2a, 0x4f, 0x6c, 0xa7, 0x7d, 0xcf, 0x6f, 0x68, 0x9e, 0x81, 0x33, 0xc9, 0x7e, 0xd5, 0x1c, 0xff,
+	0xf7, 0x2a, 0x7f, 0x5b, 0xe0, 0x0c, 0xd2, 0x55, 0x46, 0xd1, 0xb7, 0xe0, 0x28, 0x2d, 0xa1, 0xe3,
+	0xaa, 0x5e, 0x0b, 0x8a, 0x93, 0xbb, 0x6e, 0x33, 0x87, 0x57, 0xd0, 0xaa, 0xea, 0x04, 0x7d, 0xb4,
+	0xfd, 0x5c, 0xf7, 0x29, 0xab, 0xf3, 0xf1, 0x7b, 0x71, 0x43, 0xf9, 0x1a, 0xfc, 0xbb, 0xeb, 0x46,
+	0xdd, 0x4a, 0xd2, 0x1e, 0x1d, 0x75, 0x9e, 0xfc, 0x4b, 0x84, 0x26, 0xbe, 0x74, 0xd5, 0x9f, 0xf8,
+	0xeb, 0x7f, 0x06, 0x00, 0xa5, 0xb1, 0x8d, 0xfb, 0xbe, 0x07, 0x00, 0x00,
 }
@@ -12,6 +12,34 @@ var _ = proto.Marshal
 var _ = fmt.Errorf
 var _ = math.Inf
 
+// Describes what happens to log entries which match a tombstone.
+type PurgeAction int32
+
+const (
+	// Enum sentinel to make sure that the value is always set explicitly.
+	PurgeAction_UNKNOWN_ACTION PurgeAction = 0
+	// Matching entries are removed entirely.
+	PurgeAction_DROP PurgeAction = 1
+	// The values of some fields of matching entries are replaced.
+	PurgeAction_REDACT PurgeAction = 2
+)
+
+var PurgeAction_name = map[int32]string{
+	0: "UNKNOWN_ACTION",
+	1: "DROP",
+	2: "REDACT",
+}
+var PurgeAction_value = map[string]int32{
+	"UNKNOWN_ACTION": 0,
+	"DROP":           1,
+	"REDACT":         2,
+}
+
+func (x PurgeAction) String() string {
+	return proto.EnumName(PurgeAction_name, int32(x))
+}
+func (PurgeAction) EnumDescriptor() ([]byte, []int) { return fileDescriptor3, []int{0} }
+
 type ChunkId_Type int32
 
 const (
@@ -37,7 +65,7 @@ var ChunkId_Type_value = map[string]int32{
 func (x ChunkId_Type) String() string {
 	return proto.EnumName(ChunkId_Type_name, int32(x))
 }
-func (ChunkId_Type) EnumDescriptor() ([]byte, []int) { return fileDescriptor1, []int{2, 0} }
+func (ChunkId_Type) EnumDescriptor() ([]byte, []int) { return fileDescriptor3, []int{2, 0} }
 
 // A log entry.
 type LogEntry struct {
@@ -52,7 +80,7 @@ type LogEntry struct {
 func (m *LogEntry) Reset()                    { *m = LogEntry{} }
 func (m *LogEntry) String() string            { return proto.CompactTextString(m) }
 func (*LogEntry) ProtoMessage()               {}
-func (*LogEntry) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{0} }
+func (*LogEntry) Descriptor() ([]byte, []int) { return fileDescriptor3, []int{0} }
 
 func (m *LogEntry) GetEntryJson() string {
 	if m != nil {
@@ -84,7 +112,7 @@ type BleveIndex struct {
 func (m *BleveIndex) Reset()                    { *m = BleveIndex{} }
 func (m *BleveIndex) String() string            { return proto.CompactTextString(m) }
 func (*BleveIndex) ProtoMessage()               {}
-func (*BleveIndex) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{1} }
+func (*BleveIndex) Descriptor() ([]byte, []int) { return fileDescriptor3, []int{1} }
 
 func (m *BleveIndex) GetDirectoryZip() []byte {
 	if m != nil {
@@ -104,12 +132,18 @@ type ChunkId struct {
 	Uid string `protobuf:"bytes,3,opt,name=uid" json:"uid,omitempty"`
 	// Must be set to something other than "UNKNOWN_TYPE".
 	Type ChunkId_Type `protobuf:"varint,4,opt,name=type,enum=almanac.ChunkId_Type" json:"type,omitempty"`
+	// The stream labels shared by all entries in the chunk, e.g., the values of
+	// their "service" and "env" fields. Empty for chunks which are not keyed by
+	// stream.
+	Labels map[string]string `protobuf:"bytes,5,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// The tenant owning the entries in the chunk. Empty for the default tenant.
+	Tenant string `protobuf:"bytes,6,opt,name=tenant" json:"tenant,omitempty"`
 }
 
 func (m *ChunkId) Reset()                    { *m = ChunkId{} }
 func (m *ChunkId) String() string            { return proto.CompactTextString(m) }
 func (*ChunkId) ProtoMessage()               {}
-func (*ChunkId) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{2} }
+func (*ChunkId) Descriptor() ([]byte, []int) { return fileDescriptor3, []int{2} }
 
 func (m *ChunkId) GetStartMs() int64 {
 	if m != nil {
@@ -139,6 +173,20 @@ func (m *ChunkId) GetType() ChunkId_Type {
 	return ChunkId_UNKNOWN_TYPE
 }
 
+func (m *ChunkId) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *ChunkId) GetTenant() string {
+	if m != nil {
+		return m.Tenant
+	}
+	return ""
+}
+
 // Represents a chunk of log entries and some additional information about the
 // entries.
 type Chunk struct {
@@ -154,7 +202,7 @@ type Chunk struct {
 func (m *Chunk) Reset()                    { *m = Chunk{} }
 func (m *Chunk) String() string            { return proto.CompactTextString(m) }
 func (*Chunk) ProtoMessage()               {}
-func (*Chunk) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{3} }
+func (*Chunk) Descriptor() ([]byte, []int) { return fileDescriptor3, []int{3} }
 
 func (m *Chunk) GetId() *ChunkId {
 	if m != nil {
@@ -177,38 +225,218 @@ func (m *Chunk) GetIndex() *BleveIndex {
 	return nil
 }
 
+// Records that all log entries matching a query within a time range must be
+// purged from the system.
+type Tombstone struct {
+	// A unique identifier for this tombstone.
+	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	// A text-format query selecting the entries to purge.
+	Query string `protobuf:"bytes,2,opt,name=query" json:"query,omitempty"`
+	// Only entries whose timestamp is at least this value are purged.
+	StartMs int64 `protobuf:"varint,3,opt,name=start_ms,json=startMs" json:"start_ms,omitempty"`
+	// If non-zero, only entries whose timestamp is at most this value are
+	// purged.
+	EndMs int64 `protobuf:"varint,4,opt,name=end_ms,json=endMs" json:"end_ms,omitempty"`
+	// Must be set to something other than "UNKNOWN_ACTION".
+	Action PurgeAction `protobuf:"varint,5,opt,name=action,enum=almanac.PurgeAction" json:"action,omitempty"`
+	// The top-level json fields whose values get replaced if the action is
+	// "REDACT".
+	RedactFields []string `protobuf:"bytes,6,rep,name=redact_fields,json=redactFields" json:"redact_fields,omitempty"`
+	// A human-readable justification for the purge, e.g., a ticket number.
+	Reason string `protobuf:"bytes,7,opt,name=reason" json:"reason,omitempty"`
+	// The epoch time in milliseconds at which the tombstone was created.
+	CreatedMs int64 `protobuf:"varint,8,opt,name=created_ms,json=createdMs" json:"created_ms,omitempty"`
+	// The tenant whose entries are purged. Empty for the default tenant.
+	Tenant string `protobuf:"bytes,9,opt,name=tenant" json:"tenant,omitempty"`
+}
+
+func (m *Tombstone) Reset()                    { *m = Tombstone{} }
+func (m *Tombstone) String() string            { return proto.CompactTextString(m) }
+func (*Tombstone) ProtoMessage()               {}
+func (*Tombstone) Descriptor() ([]byte, []int) { return fileDescriptor3, []int{4} }
+
+func (m *Tombstone) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Tombstone) GetQuery() string {
+	if m != nil {
+		return m.Query
+	}
+	return ""
+}
+
+func (m *Tombstone) GetStartMs() int64 {
+	if m != nil {
+		return m.StartMs
+	}
+	return 0
+}
+
+func (m *Tombstone) GetEndMs() int64 {
+	if m != nil {
+		return m.EndMs
+	}
+	return 0
+}
+
+func (m *Tombstone) GetAction() PurgeAction {
+	if m != nil {
+		return m.Action
+	}
+	return PurgeAction_UNKNOWN_ACTION
+}
+
+func (m *Tombstone) GetRedactFields() []string {
+	if m != nil {
+		return m.RedactFields
+	}
+	return nil
+}
+
+func (m *Tombstone) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *Tombstone) GetCreatedMs() int64 {
+	if m != nil {
+		return m.CreatedMs
+	}
+	return 0
+}
+
+func (m *Tombstone) GetTenant() string {
+	if m != nil {
+		return m.Tenant
+	}
+	return ""
+}
+
+// Records that entries were purged from a stored chunk on behalf of a
+// tombstone.
+type AuditRecord struct {
+	// The id of the tombstone which caused the purge.
+	TombstoneId string `protobuf:"bytes,1,opt,name=tombstone_id,json=tombstoneId" json:"tombstone_id,omitempty"`
+	// The id of the chunk which contained the purged entries.
+	OldChunkId *ChunkId `protobuf:"bytes,2,opt,name=old_chunk_id,json=oldChunkId" json:"old_chunk_id,omitempty"`
+	// The id of the chunk which replaced the old chunk. Unset if no entries
+	// remained after the purge.
+	NewChunkId *ChunkId `protobuf:"bytes,3,opt,name=new_chunk_id,json=newChunkId" json:"new_chunk_id,omitempty"`
+	// The ids of all entries which were purged.
+	EntryIds []string `protobuf:"bytes,4,rep,name=entry_ids,json=entryIds" json:"entry_ids,omitempty"`
+	// The action applied to the purged entries.
+	Action PurgeAction `protobuf:"varint,5,opt,name=action,enum=almanac.PurgeAction" json:"action,omitempty"`
+	// The epoch time in milliseconds at which the purge took place.
+	TimestampMs int64 `protobuf:"varint,6,opt,name=timestamp_ms,json=timestampMs" json:"timestamp_ms,omitempty"`
+}
+
+func (m *AuditRecord) Reset()                    { *m = AuditRecord{} }
+func (m *AuditRecord) String() string            { return proto.CompactTextString(m) }
+func (*AuditRecord) ProtoMessage()               {}
+func (*AuditRecord) Descriptor() ([]byte, []int) { return fileDescriptor3, []int{5} }
+
+func (m *AuditRecord) GetTombstoneId() string {
+	if m != nil {
+		return m.TombstoneId
+	}
+	return ""
+}
+
+func (m *AuditRecord) GetOldChunkId() *ChunkId {
+	if m != nil {
+		return m.OldChunkId
+	}
+	return nil
+}
+
+func (m *AuditRecord) GetNewChunkId() *ChunkId {
+	if m != nil {
+		return m.NewChunkId
+	}
+	return nil
+}
+
+func (m *AuditRecord) GetEntryIds() []string {
+	if m != nil {
+		return m.EntryIds
+	}
+	return nil
+}
+
+func (m *AuditRecord) GetAction() PurgeAction {
+	if m != nil {
+		return m.Action
+	}
+	return PurgeAction_UNKNOWN_ACTION
+}
+
+func (m *AuditRecord) GetTimestampMs() int64 {
+	if m != nil {
+		return m.TimestampMs
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*LogEntry)(nil), "almanac.LogEntry")
 	proto.RegisterType((*BleveIndex)(nil), "almanac.BleveIndex")
 	proto.RegisterType((*ChunkId)(nil), "almanac.ChunkId")
 	proto.RegisterType((*Chunk)(nil), "almanac.Chunk")
+	proto.RegisterType((*Tombstone)(nil), "almanac.Tombstone")
+	proto.RegisterType((*AuditRecord)(nil), "almanac.AuditRecord")
+	proto.RegisterEnum("almanac.PurgeAction", PurgeAction_name, PurgeAction_value)
 	proto.RegisterEnum("almanac.ChunkId_Type", ChunkId_Type_name, ChunkId_Type_value)
 }
 
-func init() { proto.RegisterFile("proto/storage.proto", fileDescriptor1) }
-
-var fileDescriptor1 = []byte{
-	// 352 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x5c, 0x51, 0x6f, 0x6b, 0x9b, 0x40,
-	0x1c, 0xde, 0x69, 0x8c, 0xc9, 0x4f, 0x17, 0xdc, 0x85, 0x80, 0x7b, 0x31, 0x70, 0xee, 0x8d, 0x61,
-	0xc3, 0x31, 0xf7, 0x09, 0x96, 0x11, 0x4a, 0xda, 0x98, 0x16, 0x9b, 0x52, 0x5a, 0x0a, 0x62, 0xe3,
-	0x91, 0x5e, 0x1b, 0x4f, 0xf1, 0x2e, 0xa5, 0xf6, 0x5d, 0xbf, 0x4c, 0x3f, 0x67, 0xb9, 0x6b, 0x62,
-	0xa0, 0xef, 0x7e, 0xcf, 0xc3, 0xa3, 0xcf, 0x9f, 0x83, 0x61, 0x55, 0x97, 0xa2, 0xfc, 0xcd, 0x45,
-	0x59, 0x67, 0x6b, 0x12, 0x2a, 0x84, 0xcd, 0x6c, 0x53, 0x64, 0x2c, 0x5b, 0xf9, 0x37, 0xd0, 0x9b,
-	0x97, 0xeb, 0x29, 0x13, 0x75, 0x83, 0xbf, 0x01, 0x10, 0x79, 0xa4, 0xf7, 0xbc, 0x64, 0x2e, 0xf2,
-	0x50, 0xd0, 0x4f, 0xfa, 0x8a, 0x39, 0xe6, 0x25, 0xc3, 0xdf, 0xc1, 0x16, 0xb4, 0x20, 0x5c, 0x64,
-	0x45, 0x95, 0x16, 0xdc, 0xd5, 0x3c, 0x14, 0xe8, 0x89, 0xd5, 0x72, 0x31, 0xc7, 0x03, 0xd0, 0x68,
-	0xee, 0xea, 0xea, 0x4b, 0x8d, 0xe6, 0xfe, 0x1f, 0x80, 0xc9, 0x86, 0x3c, 0x92, 0x19, 0xcb, 0xc9,
-	0x13, 0xfe, 0x01, 0x9f, 0x73, 0x5a, 0x93, 0x95, 0x28, 0xeb, 0x26, 0x7d, 0xa6, 0x95, 0xb2, 0xb0,
-	0x13, 0xbb, 0x25, 0xaf, 0x69, 0xe5, 0xbf, 0x22, 0x30, 0xff, 0xdf, 0x6d, 0xd9, 0xc3, 0x2c, 0xc7,
-	0x5f, 0xa1, 0xc7, 0x45, 0x56, 0x0b, 0xe9, 0x86, 0x94, 0x9b, 0xa9, 0x70, 0xcc, 0xf1, 0x08, 0xba,
-	0x84, 0xe5, 0x87, 0x18, 0x06, 0x61, 0x79, 0xcc, 0xb1, 0x03, 0xfa, 0xb6, 0x4d, 0x20, 0x4f, 0x3c,
-	0x86, 0x8e, 0x68, 0x2a, 0xe2, 0x76, 0x3c, 0x14, 0x0c, 0xa2, 0x51, 0xb8, 0x2b, 0x1e, 0xee, 0x3c,
-	0xc2, 0x65, 0x53, 0x91, 0x44, 0x49, 0xfc, 0x5f, 0xd0, 0x91, 0x08, 0x3b, 0x60, 0x5f, 0x2c, 0x4e,
-	0x16, 0xa7, 0x97, 0x8b, 0x74, 0x79, 0x75, 0x36, 0x75, 0x3e, 0xe1, 0x3e, 0x18, 0xe7, 0xf1, 0xbf,
-	0xf9, 0xdc, 0x41, 0xd8, 0x04, 0x7d, 0x32, 0x3b, 0x72, 0x34, 0xff, 0x05, 0x81, 0xa1, 0x7e, 0x82,
-	0x3d, 0xd5, 0x5a, 0x06, 0xb4, 0x22, 0xe7, 0xa3, 0x81, 0xdc, 0x01, 0xff, 0x04, 0x53, 0xee, 0x48,
-	0x89, 0x8c, 0xab, 0x07, 0x56, 0xf4, 0xa5, 0x95, 0xed, 0xd7, 0x4f, 0xf6, 0x0a, 0x3c, 0x06, 0x83,
-	0xca, 0xbd, 0x54, 0x0b, 0x2b, 0x1a, 0xb6, 0xd2, 0xc3, 0x94, 0xc9, 0xbb, 0xe2, 0xb6, 0xab, 0x5e,
-	0xf3, 0xef, 0x5b, 0x00, 0x00, 0x00, 0xff, 0xff, 0xc6, 0x88, 0x6f, 0x91, 0xe4, 0x01, 0x00, 0x00,
+func init() { proto.RegisterFile("proto/storage.proto", fileDescriptor3) }
+
+var fileDescriptor3 = []byte{
+	// 646 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x54, 0x5b, 0x6f, 0xd3, 0x4c,
+	0x10, 0xad, 0xed, 0xc4, 0x89, 0xc7, 0xf9, 0x22, 0x7f, 0xdb, 0x16, 0x99, 0x9b, 0x14, 0xcc, 0x4b,
+	0x0a, 0x55, 0x10, 0x01, 0x89, 0xcb, 0x5b, 0x7a, 0x01, 0x05, 0x92, 0xb4, 0x32, 0x41, 0x08, 0x84,
+	0x64, 0x6d, 0xbd, 0x43, 0x31, 0x75, 0x76, 0x83, 0xbd, 0x69, 0x09, 0x6f, 0x3c, 0xc0, 0xdf, 0xe1,
+	0x2f, 0xa2, 0xdd, 0x38, 0x6e, 0x68, 0xd5, 0x07, 0xde, 0x76, 0x66, 0xcf, 0x68, 0xce, 0x9c, 0x33,
+	0xbb, 0xb0, 0x3e, 0xcd, 0x84, 0x14, 0x0f, 0x72, 0x29, 0x32, 0x7a, 0x8c, 0x1d, 0x1d, 0x91, 0x1a,
+	0x4d, 0x27, 0x94, 0xd3, 0x38, 0xf8, 0x08, 0xf5, 0x81, 0x38, 0xde, 0xe7, 0x32, 0x9b, 0x93, 0xdb,
+	0x00, 0xa8, 0x0e, 0xd1, 0x97, 0x5c, 0x70, 0xdf, 0x68, 0x19, 0x6d, 0x27, 0x74, 0x74, 0xe6, 0x55,
+	0x2e, 0x38, 0xb9, 0x03, 0x0d, 0x99, 0x4c, 0x30, 0x97, 0x74, 0x32, 0x8d, 0x26, 0xb9, 0x6f, 0xb6,
+	0x8c, 0xb6, 0x15, 0xba, 0x65, 0x6e, 0x98, 0x93, 0x26, 0x98, 0x09, 0xf3, 0x2d, 0x5d, 0x69, 0x26,
+	0x2c, 0x78, 0x08, 0xb0, 0x93, 0xe2, 0x29, 0xf6, 0x39, 0xc3, 0x6f, 0xe4, 0x2e, 0xfc, 0xc7, 0x92,
+	0x0c, 0x63, 0x29, 0xb2, 0x79, 0xf4, 0x3d, 0x99, 0xea, 0x16, 0x8d, 0xb0, 0x51, 0x26, 0x3f, 0x24,
+	0xd3, 0xe0, 0xb7, 0x09, 0xb5, 0xdd, 0xcf, 0x33, 0x7e, 0xd2, 0x67, 0xe4, 0x3a, 0xd4, 0x73, 0x49,
+	0x33, 0xa9, 0xba, 0x19, 0xba, 0x5b, 0x4d, 0xc7, 0xc3, 0x9c, 0x6c, 0x82, 0x8d, 0x9c, 0x9d, 0xd3,
+	0xa8, 0x22, 0x67, 0xc3, 0x9c, 0x78, 0x60, 0xcd, 0x4a, 0x06, 0xea, 0x48, 0xb6, 0xa0, 0x22, 0xe7,
+	0x53, 0xf4, 0x2b, 0x2d, 0xa3, 0xdd, 0xec, 0x6e, 0x76, 0x8a, 0xc1, 0x3b, 0x45, 0x8f, 0xce, 0x78,
+	0x3e, 0xc5, 0x50, 0x43, 0xc8, 0x63, 0xb0, 0x53, 0x7a, 0x84, 0x69, 0xee, 0x57, 0x5b, 0x56, 0xdb,
+	0xed, 0xde, 0xba, 0x04, 0x1e, 0xe8, 0x6b, 0xad, 0x56, 0x58, 0x60, 0xc9, 0x35, 0xb0, 0x25, 0x72,
+	0xca, 0xa5, 0x6f, 0xeb, 0xae, 0x45, 0x74, 0xe3, 0x19, 0xb8, 0x2b, 0x70, 0xc5, 0xec, 0x04, 0xe7,
+	0x85, 0xaa, 0xea, 0x48, 0x36, 0xa0, 0x7a, 0x4a, 0xd3, 0x19, 0xea, 0x09, 0x9c, 0x70, 0x11, 0x3c,
+	0x37, 0x9f, 0x1a, 0xc1, 0x36, 0x54, 0x14, 0x2d, 0xe2, 0x41, 0xe3, 0xed, 0xe8, 0xf5, 0xe8, 0xe0,
+	0xdd, 0x28, 0x1a, 0xbf, 0x3f, 0xdc, 0xf7, 0xd6, 0x88, 0x03, 0xd5, 0x37, 0xc3, 0xde, 0x60, 0xe0,
+	0x19, 0xa4, 0x06, 0xd6, 0x4e, 0xff, 0xa5, 0x67, 0x06, 0x3f, 0x0c, 0xa8, 0x6a, 0x82, 0xa4, 0xa5,
+	0xe5, 0x57, 0x2d, 0xdc, 0xae, 0x77, 0x91, 0xbc, 0x32, 0x84, 0xdc, 0x87, 0x9a, 0x32, 0x34, 0x41,
+	0xa5, 0x9b, 0x9a, 0xf1, 0xff, 0x12, 0xb6, 0x5c, 0x83, 0x70, 0x89, 0x20, 0x5b, 0x50, 0x4d, 0x94,
+	0x71, 0x5a, 0x4e, 0xb7, 0xbb, 0x5e, 0x42, 0xcf, 0x3d, 0x0d, 0x17, 0x88, 0xe0, 0xa7, 0x09, 0xce,
+	0x58, 0x4c, 0x8e, 0x72, 0x29, 0x38, 0x92, 0x66, 0xc9, 0x43, 0xaf, 0x81, 0x9a, 0xf4, 0xeb, 0x0c,
+	0xb3, 0xf9, 0x72, 0x52, 0x1d, 0xfc, 0xe5, 0xae, 0x75, 0x95, 0xbb, 0x95, 0x55, 0x77, 0xb7, 0xc1,
+	0xa6, 0xb1, 0x4c, 0x04, 0xf7, 0xab, 0xda, 0xcd, 0x8d, 0x92, 0xd1, 0xe1, 0x2c, 0x3b, 0xc6, 0x9e,
+	0xbe, 0x0b, 0x0b, 0x8c, 0x5a, 0xb7, 0x0c, 0x19, 0x8d, 0x65, 0xf4, 0x29, 0xc1, 0x94, 0xe5, 0xbe,
+	0xdd, 0xb2, 0xda, 0x4e, 0xd8, 0x58, 0x24, 0x5f, 0xe8, 0x9c, 0x72, 0x2f, 0x43, 0xaa, 0xf6, 0xbd,
+	0xb6, 0x70, 0x6f, 0x11, 0xa9, 0xb7, 0x10, 0x67, 0x48, 0x25, 0x6a, 0x16, 0x75, 0xcd, 0xc2, 0x29,
+	0x32, 0xc3, 0x55, 0xd3, 0x9d, 0x55, 0xd3, 0x83, 0x5f, 0x26, 0xb8, 0xbd, 0x19, 0x4b, 0x64, 0x88,
+	0xb1, 0xc8, 0x98, 0x7e, 0x33, 0x4b, 0x59, 0xa2, 0x52, 0x13, 0xb7, 0xcc, 0xf5, 0x19, 0xe9, 0x42,
+	0x43, 0xa4, 0x2c, 0x8a, 0x95, 0x4b, 0x0a, 0x62, 0x5e, 0x61, 0x1f, 0x88, 0x94, 0x15, 0x67, 0x55,
+	0xc3, 0xf1, 0xec, 0xbc, 0xc6, 0xba, 0xaa, 0x86, 0xe3, 0xd9, 0xb2, 0xe6, 0x26, 0x2c, 0xde, 0x72,
+	0x94, 0x30, 0x25, 0xab, 0x92, 0xa2, 0xae, 0x13, 0x7d, 0xf6, 0xaf, 0xca, 0x5e, 0xfc, 0x09, 0xec,
+	0x4b, 0x3f, 0xc1, 0xbd, 0x27, 0xe0, 0xae, 0x54, 0x12, 0x02, 0xcd, 0xe5, 0x26, 0xf7, 0x76, 0xc7,
+	0xfd, 0x83, 0x91, 0xb7, 0x46, 0xea, 0x50, 0xd9, 0x0b, 0x0f, 0x0e, 0x3d, 0x83, 0x00, 0xd8, 0xe1,
+	0xfe, 0x5e, 0x6f, 0x77, 0xec, 0x99, 0x47, 0xb6, 0xfe, 0xa0, 0x1e, 0xfd, 0x19, 0x00, 0x44, 0x34,
+	0x4e, 0x86, 0xb7, 0x04, 0x00, 0x00,
 }
@@ -3,15 +3,23 @@ package integration
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"testing"
 	"time"
 
+	"github.com/dinowernli/almanac/pkg/auth"
 	"github.com/dinowernli/almanac/pkg/cluster"
+	"github.com/dinowernli/almanac/pkg/mtls"
+	"github.com/dinowernli/almanac/pkg/service/janitor"
+	"github.com/dinowernli/almanac/pkg/storage"
+	"github.com/dinowernli/almanac/pkg/tenant"
 	pb_almanac "github.com/dinowernli/almanac/proto"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
 )
 
 const (
@@ -24,9 +32,14 @@ var (
 		SmallChunkMaxEntries: 10,
 		SmallChunkSpread:     5 * time.Second,
 		SmallChunkMaxAge:     3 * time.Second,
-		BigChunkMaxSpread:    4 * time.Hour,
+		AppenderMaxEntries:   10000,
+		AppenderMaxBytes:     1024 * 1024,
+		BigChunkLevels:       []janitor.Level{{Spread: 4 * time.Hour}},
+		BigChunkSettleDelay:  1 * time.Minute,
 
 		JanitorCompactionInterval: 10 * time.Second,
+		JanitorNumWorkers:         4,
+		JanitorMaxCompactionBytes: 64 * 1024 * 1024,
 
 		StorageType: "memory",
 		GcsBucket:   "",
@@ -93,7 +106,7 @@ func TestRoundTripThroughStorage(t *testing.T) {
 	// Depending on how the ingesters select appenders, the exact number of stored chunks
 	// could vary. But we definitely should have at least one. If this fails, writing chunks
 	// to storage is probably broken.
-	chunks, err := c.Storage.ListChunks(context.Background(), 0, 0, pb_almanac.ChunkId_SMALL)
+	chunks, err := c.Storage.ListChunks(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_SMALL)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, chunks)
 
@@ -173,6 +186,139 @@ func TestQueryRange(t *testing.T) {
 	assert.Equal(t, 0, len(response.Entries))
 }
 
+func TestShutdownFlushesOpenChunks(t *testing.T) {
+	c := createTestCluster(t)
+
+	ingestRequest, err := newIngestRequest(&entry{Message: "foo", TimestampMs: 5000})
+	assert.NoError(t, err)
+	_, err = c.Ingester.Ingest(context.Background(), ingestRequest)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, c.Shutdown(ctx))
+
+	// The entry was still held in open chunks, which must have made it to storage.
+	chunks, err := c.Storage.ListChunks(context.Background(), tenant.Default, 0, 0, pb_almanac.ChunkId_SMALL)
+	assert.NoError(t, err)
+	assert.Equal(t, appenderFanout, len(chunks))
+}
+
+func TestIsolatesTenants(t *testing.T) {
+	c := createTestCluster(t)
+
+	// Tenant "a" is named in the metadata, tenant "b" in the request itself.
+	tenantA := metadata.NewIncomingContext(context.Background(), metadata.Pairs(tenant.MetadataKey, "a"))
+	for _, message := range []string{"foo", "bar"} {
+		ingestRequest, err := newIngestRequest(&entry{Message: message, TimestampMs: 5000})
+		assert.NoError(t, err)
+		_, err = c.Ingester.Ingest(tenantA, ingestRequest)
+		assert.NoError(t, err)
+	}
+	ingestRequest, err := newIngestRequest(&entry{Message: "foo", TimestampMs: 6000})
+	assert.NoError(t, err)
+	ingestRequest.Tenant = "b"
+	_, err = c.Ingester.Ingest(context.Background(), ingestRequest)
+	assert.NoError(t, err)
+
+	for tenantId, hits := range map[string]int{"a": 1, "b": 1, tenant.Default: 0} {
+		response, err := c.Mixer.Search(context.Background(), &pb_almanac.SearchRequest{Num: 200, Query: "foo", Tenant: tenantId})
+		assert.NoError(t, err)
+		assert.Equal(t, hits, len(response.Entries), tenantId)
+	}
+
+	// A search may not claim a tenant other than the one in its metadata.
+	_, err = c.Mixer.Search(tenantA, &pb_almanac.SearchRequest{Num: 200, Query: "foo", Tenant: "b"})
+	assert.Error(t, err)
+
+	// Flushing the open chunks must keep the entries of each tenant in chunks of their own.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, c.Shutdown(ctx))
+
+	tenants, err := c.Storage.ListTenants(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, tenants)
+
+	for tenantId, numEntries := range map[string]int{"a": 2, "b": 1} {
+		chunks, err := c.Storage.ListChunks(context.Background(), tenantId, 0, 0, pb_almanac.ChunkId_SMALL)
+		assert.NoError(t, err)
+		assert.Equal(t, appenderFanout, len(chunks), tenantId)
+
+		for _, id := range chunks {
+			idProto, err := storage.ChunkIdProto(id)
+			assert.NoError(t, err)
+			chunk, err := c.Storage.LoadChunk(context.Background(), idProto)
+			assert.NoError(t, err)
+			assert.Equal(t, numEntries, len(chunk.Entries()), id)
+			assert.NoError(t, chunk.Close())
+		}
+	}
+}
+
+func TestAuthenticatesAppenderCalls(t *testing.T) {
+	conf := *testConf
+	conf.Credentials = []auth.Credential{
+		{Name: "internal", Token: "internal-token", Role: auth.RoleAdmin},
+		{Name: "reader", Token: "reader-token", Role: auth.RoleRead},
+	}
+
+	// The ingester presents the client token to the appenders, which accept it.
+	conf.ClientToken = "internal-token"
+	c, err := cluster.CreateCluster(context.Background(), logrus.New(), &conf, getAppenderPorts(), appenderFanout)
+	assert.NoError(t, err)
+	defer c.Stop()
+
+	request, err := newIngestRequest(&entry{Message: "foo", TimestampMs: 5000})
+	assert.NoError(t, err)
+	_, err = c.Ingester.Ingest(context.Background(), request)
+	assert.NoError(t, err)
+
+	response, err := c.Mixer.Search(context.Background(), &pb_almanac.SearchRequest{Num: 10, Query: "foo"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(response.Entries))
+
+	// A token which may only read cannot append.
+	conf.ClientToken = "reader-token"
+	c2, err := cluster.CreateCluster(context.Background(), logrus.New(), &conf, getAppenderPorts(), appenderFanout)
+	assert.NoError(t, err)
+	defer c2.Stop()
+
+	_, err = c2.Ingester.Ingest(context.Background(), request)
+	assert.Error(t, err)
+}
+
+func TestMutualTlsBetweenComponents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "almanac-integration-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	authority, err := mtls.NewTestAuthority()
+	assert.NoError(t, err)
+
+	conf := *testConf
+	conf.InternalTlsReloadInterval = time.Minute
+	conf.InternalTls, err = authority.WriteFiles(dir, "all", mtls.IdentityAppender)
+	assert.NoError(t, err)
+	c, err := cluster.CreateCluster(context.Background(), logrus.New(), &conf, getAppenderPorts(), appenderFanout)
+	assert.NoError(t, err)
+	defer c.Stop()
+
+	request, err := newIngestRequest(&entry{Message: "foo", TimestampMs: 5000})
+	assert.NoError(t, err)
+	_, err = c.Ingester.Ingest(context.Background(), request)
+	assert.NoError(t, err)
+
+	// Appenders whose certificate lacks the appender identity are not talked to.
+	conf.InternalTls, err = authority.WriteFiles(dir, "impostor", "mixer")
+	assert.NoError(t, err)
+	c2, err := cluster.CreateCluster(context.Background(), logrus.New(), &conf, getAppenderPorts(), appenderFanout)
+	assert.NoError(t, err)
+	defer c2.Stop()
+
+	_, err = c2.Ingester.Ingest(context.Background(), request)
+	assert.Error(t, err)
+}
+
 func createTestCluster(t *testing.T) *cluster.LocalCluster {
 	c, err := cluster.CreateCluster(context.Background(), logrus.New(), testConf, getAppenderPorts(), appenderFanout)
 	assert.NoError(t, err)
@@ -7,10 +7,10 @@ set -x
 minikube start --vm-driver=xhyve
 eval $(minikube docker-env)
 
-# Build the almanac container and start a deployment.
+# Build the almanac container and start a deployment for each role.
 docker build -t almanac:v1 .
-kubectl create -f kube/deployment.yaml
+kubectl create -f kube/
 
-# Create a service which exposes the binary.
-kubectl expose deployment almanac --type=NodePort
-minikube service almanac --url
\ No newline at end of file
+# Print the urls of the services exposing the mixer and ingester pages.
+minikube service almanac-mixer --url
+minikube service almanac-ingester --url
\ No newline at end of file